@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// corpusSeed makes the generated corpora the same on every run, so results
+// recorded at different times are comparable.
+const corpusSeed = 1
+
+// corpusSlack is the most a generator may write past the size asked for.
+const corpusSlack = 64 * 1024
+
+// corpus is a generated input shared by the benchmarks of every tool.
+type corpus struct {
+	name     string
+	generate func(out *bufio.Writer, size int64, random *rand.Rand)
+}
+
+var corpora = []corpus{
+	{"prose.txt", generateProse},
+	{"unicode.txt", generateUnicode},
+	{"long-line.txt", generateLongLine},
+	{"binary.bin", generateBinary},
+	{"records.json", generateRecords},
+}
+
+var vocabulary = strings.Fields(`the of and to in is was that for it with as his on be at by
+this had not are but from or have an they which one you were her all she there would their we
+him been has when who will more no if out so said what up its about into than them can only
+other new some could time these two may then do first any my now such like our over man me even
+most made after also did many before must through back years where much your way well down
+should because each just those people how too little state good very make world still own see
+men work long get here between both life being under never day same another know while last`)
+
+var scripts = []string{
+	"naïve café", "Grüße aus Köln", "日本語のテキスト", "Ελληνικά γράμματα",
+	"русский текст", "עברית", "emoji 🎉🚀", "é combining", "한국어 문장",
+}
+
+// generateProse writes lines of English words about 60 to 100 bytes long.
+func generateProse(out *bufio.Writer, size int64, random *rand.Rand) {
+
+	var written int64
+	for written < size {
+		length := 60 + random.Intn(40)
+		var line strings.Builder
+		for line.Len() < length {
+			if line.Len() > 0 {
+				line.WriteByte(' ')
+			}
+			line.WriteString(vocabulary[random.Intn(len(vocabulary))])
+		}
+		line.WriteByte('\n')
+
+		n, _ := out.WriteString(line.String())
+		written += int64(n)
+	}
+}
+
+// generateUnicode writes lines mixing scripts, combining marks and emoji.
+func generateUnicode(out *bufio.Writer, size int64, random *rand.Rand) {
+
+	var written int64
+	for written < size {
+		n, _ := fmt.Fprintf(out, "%s %s\n", scripts[random.Intn(len(scripts))], scripts[random.Intn(len(scripts))])
+		written += int64(n)
+	}
+}
+
+// generateLongLine writes words with no newline at all.
+func generateLongLine(out *bufio.Writer, size int64, random *rand.Rand) {
+
+	var written int64
+	for written < size {
+		n, _ := out.WriteString(vocabulary[random.Intn(len(vocabulary))] + " ")
+		written += int64(n)
+	}
+}
+
+// generateBinary writes random bytes.
+func generateBinary(out *bufio.Writer, size int64, random *rand.Rand) {
+
+	buffer := make([]byte, 64*1024)
+	for written := int64(0); written < size; written += int64(len(buffer)) {
+		random.Read(buffer)
+		out.Write(buffer)
+	}
+}
+
+// generateRecords writes a JSON array of small objects.
+func generateRecords(out *bufio.Writer, size int64, random *rand.Rand) {
+
+	written, _ := out.WriteString("[\n")
+	for i := 0; int64(written) < size; i++ {
+		n, _ := fmt.Fprintf(out, "  {\"id\": %d, \"name\": %q, \"score\": %.3f, \"tags\": [%q, %q], \"active\": %t},\n",
+			i, vocabulary[random.Intn(len(vocabulary))], random.Float64()*100,
+			vocabulary[random.Intn(len(vocabulary))], vocabulary[random.Intn(len(vocabulary))], random.Intn(2) == 0)
+		written += n
+	}
+	fmt.Fprintf(out, "  {\"id\": -1}\n]\n")
+}
+
+// generateCorpora writes each corpus of about size bytes to dir, unless an
+// earlier run already left one of that size there. Generators stop at the
+// first whole line or block past size, so a corpus is never more than a
+// block over it.
+func generateCorpora(dir string, size int64) error {
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, corpus := range corpora {
+		path := filepath.Join(dir, corpus.name)
+		if info, err := os.Stat(path); err == nil && info.Size() >= size && info.Size() <= size+corpusSlack {
+			continue
+		}
+
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		out := bufio.NewWriter(file)
+		corpus.generate(out, size, rand.New(rand.NewSource(corpusSeed)))
+
+		if err := out.Flush(); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
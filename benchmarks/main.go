@@ -0,0 +1,176 @@
+// Command benchmarks runs the benchmarks of every tool in this repository
+// against shared generated corpora, records the results in a history file
+// and fails when a benchmark has got slower, or allocates more, than at the
+// last recorded run by more than a threshold.
+//
+// Run it from this directory:
+//
+//	go run . [-count 5] [-size 16M] [-threshold 10] [-bench regexp] [tool ...]
+//
+// Each tool is a directory of the repository with a go.mod, all of them by
+// default. Its benchmarks find the corpora in the directory named by the
+// CODECHALLENGE_CORPUS environment variable and should skip themselves when
+// it is not set.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+// corpusEnv names the directory of the generated corpora for benchmarks.
+const corpusEnv = "CODECHALLENGE_CORPUS"
+
+func main() {
+
+	cli.Name = "benchmarks"
+
+	count := flag.Int("count", 5, "run each benchmark this many times and compare the medians")
+	size := flag.String("size", "16M", "size of each generated corpus, with an optional K, M or G suffix")
+	corpusDir := flag.String("corpus", "corpus", "directory for the generated corpora, reused between runs")
+	historyPath := flag.String("history", "history.jsonl", "file of recorded results, one JSON object per benchmark and run")
+	threshold := flag.Float64("threshold", 10, "fail when a benchmark's time or allocations grow by more than this percentage")
+	bench := flag.String("bench", ".", "run only the benchmarks matching this regular expression")
+	dryRun := flag.Bool("dry-run", false, "compare against the history without recording this run")
+	flag.Parse()
+
+	corpusSize, err := parseSize(*size)
+	if err != nil {
+		cli.Exit(cli.Usagef("invalid corpus size %q: %v", *size, err))
+	}
+	if *count < 1 {
+		cli.Exit(cli.Usagef("-count must be at least 1"))
+	}
+
+	tools := flag.Args()
+	if len(tools) == 0 {
+		if tools, err = findTools(".."); err != nil {
+			cli.Exit(err)
+		}
+	}
+
+	if err := generateCorpora(*corpusDir, corpusSize); err != nil {
+		cli.Exit(err)
+	}
+	corpus, err := filepath.Abs(*corpusDir)
+	if err != nil {
+		cli.Exit(err)
+	}
+
+	history, err := readHistory(*historyPath)
+	if err != nil {
+		cli.Exit(err)
+	}
+
+	run := runInfo{Time: time.Now().UTC(), Commit: gitCommit(), CorpusSize: corpusSize}
+
+	var results []record
+	for _, tool := range tools {
+		fmt.Fprintf(os.Stderr, "running %s benchmarks\n", tool)
+
+		output, err := runBenchmarks(filepath.Join("..", tool), *bench, *count, corpus)
+		if err != nil {
+			cli.Exit(fmt.Errorf("%s: %v", tool, err))
+		}
+		results = append(results, summarize(run, parseBenchmarks(output))...)
+	}
+
+	regressions := compare(os.Stdout, results, history, *threshold/100)
+
+	if regressions > 0 {
+		cli.Exit(fmt.Errorf("%d benchmarks regressed by more than %g%%; not recorded", regressions, *threshold))
+	}
+
+	if !*dryRun {
+		if err := appendHistory(*historyPath, results); err != nil {
+			cli.Exit(err)
+		}
+	}
+}
+
+// findTools returns the directories under root with a go.mod, other than
+// this one and the shared internal module.
+func findTools(root string) ([]string, error) {
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var tools []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == "benchmarks" || name == "internal" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, name, "go.mod")); err == nil {
+			tools = append(tools, name)
+		}
+	}
+
+	return tools, nil
+}
+
+// runBenchmarks runs the benchmarks of the module in dir and returns the
+// output of go test.
+func runBenchmarks(dir string, bench string, count int, corpus string) ([]byte, error) {
+
+	cmd := exec.Command("go", "test", "-run", "^$", "-bench", bench, "-benchmem",
+		"-count", strconv.Itoa(count), "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), corpusEnv+"="+corpus)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go test: %v\n%s%s", err, output, stderr.Bytes())
+	}
+	return output, nil
+}
+
+// gitCommit returns the abbreviated hash of the checked out commit, or ""
+// outside a git repository.
+func gitCommit() string {
+	output, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// parseSize parses a number of bytes with an optional K, M or G suffix.
+func parseSize(value string) (int64, error) {
+
+	multiplier := int64(1)
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'k', 'K':
+			multiplier, value = 1<<10, value[:n-1]
+		case 'm', 'M':
+			multiplier, value = 1<<20, value[:n-1]
+		case 'g', 'G':
+			multiplier, value = 1<<30, value[:n-1]
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if size < 1 {
+		return 0, errors.New("must be positive")
+	}
+	return size * multiplier, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runInfo identifies a run of the benchmarks.
+type runInfo struct {
+	Time       time.Time `json:"time"`
+	Commit     string    `json:"commit,omitempty"`
+	CorpusSize int64     `json:"corpus_size"`
+}
+
+// record is the median result of one benchmark in one run, as kept in the
+// history file.
+type record struct {
+	runInfo
+
+	Benchmark   string  `json:"benchmark"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	MBPerSec    float64 `json:"mb_per_s,omitempty"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+}
+
+// measurement is one line of benchmark output.
+type measurement struct {
+	benchmark string
+	values    map[string]float64
+}
+
+// parseBenchmarks reads the result lines of go test -bench output, naming
+// each benchmark after its package, without the codechallenge/ prefix all
+// the modules share, and without the GOMAXPROCS suffix.
+func parseBenchmarks(output []byte) []measurement {
+
+	var measurements []measurement
+	var pkg string
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if name, found := strings.CutPrefix(line, "pkg: "); found {
+			pkg = strings.TrimPrefix(name, "codechallenge/")
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+
+		name := fields[0]
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+
+		values := make(map[string]float64)
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err == nil {
+				values[fields[i+1]] = value
+			}
+		}
+
+		measurements = append(measurements, measurement{benchmark: pkg + "." + name, values: values})
+	}
+
+	return measurements
+}
+
+// summarize turns the measurements of a tool into a record per benchmark
+// holding the median of each value, which is steadier than the mean when a
+// run is disturbed.
+func summarize(run runInfo, measurements []measurement) []record {
+
+	var names []string
+	byName := make(map[string][]measurement)
+	for _, m := range measurements {
+		if _, seen := byName[m.benchmark]; !seen {
+			names = append(names, m.benchmark)
+		}
+		byName[m.benchmark] = append(byName[m.benchmark], m)
+	}
+
+	median := func(ms []measurement, unit string) float64 {
+		var values []float64
+		for _, m := range ms {
+			if value, ok := m.values[unit]; ok {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return 0
+		}
+		slices.Sort(values)
+		return values[len(values)/2]
+	}
+
+	var records []record
+	for _, name := range names {
+		ms := byName[name]
+		records = append(records, record{
+			runInfo:     run,
+			Benchmark:   name,
+			NsPerOp:     median(ms, "ns/op"),
+			MBPerSec:    median(ms, "MB/s"),
+			BytesPerOp:  median(ms, "B/op"),
+			AllocsPerOp: median(ms, "allocs/op"),
+		})
+	}
+
+	return records
+}
+
+// readHistory reads the recorded results, of which there are none before
+// the first run.
+func readHistory(path string) ([]record, error) {
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []record
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var r record
+		err := decoder.Decode(&r)
+		if err == io.EOF {
+			return history, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		history = append(history, r)
+	}
+}
+
+func appendHistory(path string, results []record) error {
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, r := range results {
+		if err := encoder.Encode(r); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// compare prints each result beside the last recorded result of the same
+// benchmark over a corpus of the same size, and returns how many have
+// regressed by more than threshold, a fraction, in time or allocations.
+func compare(out io.Writer, results []record, history []record, threshold float64) int {
+
+	regressions := 0
+
+	fmt.Fprintf(out, "%-48s %14s %10s %10s %10s %10s\n", "benchmark", "ns/op", "delta", "MB/s", "allocs/op", "delta")
+
+	for _, r := range results {
+
+		var previous *record
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Benchmark == r.Benchmark && history[i].CorpusSize == r.CorpusSize {
+				previous = &history[i]
+				break
+			}
+		}
+
+		timeDelta, allocDelta := "new", "new"
+		verdict := ""
+		if previous != nil {
+			timeChange := change(previous.NsPerOp, r.NsPerOp)
+			allocChange := change(previous.AllocsPerOp, r.AllocsPerOp)
+			timeDelta = fmt.Sprintf("%+.1f%%", timeChange*100)
+			allocDelta = fmt.Sprintf("%+.1f%%", allocChange*100)
+
+			// Allocations are whole numbers, so one more is not a
+			// regression of a benchmark that barely allocates
+			if timeChange > threshold || (allocChange > threshold && r.AllocsPerOp-previous.AllocsPerOp >= 1) {
+				verdict = "  REGRESSED"
+				regressions++
+			}
+		}
+
+		fmt.Fprintf(out, "%-48s %14.0f %10s %10.2f %10.0f %10s%s\n", r.Benchmark, r.NsPerOp, timeDelta, r.MBPerSec, r.AllocsPerOp, allocDelta, verdict)
+	}
+
+	return regressions
+}
+
+// change returns the relative change from before to after.
+func change(before float64, after float64) float64 {
+	if before == 0 {
+		if after == 0 {
+			return 0
+		}
+		return 1
+	}
+	return (after - before) / before
+}
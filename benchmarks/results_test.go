@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestParseAndCompare(t *testing.T) {
+
+	output := []byte(`goos: linux
+pkg: codechallenge/wc
+BenchmarkCorpus/prose.txt/default-8   	      20	  50000000 ns/op	  83.89 MB/s	 1641313 B/op	      40 allocs/op
+BenchmarkCorpus/prose.txt/default-8   	      20	  40000000 ns/op	 104.86 MB/s	 1641313 B/op	      40 allocs/op
+BenchmarkCorpus/prose.txt/default-8   	      20	  45000000 ns/op	  93.21 MB/s	 1641313 B/op	      40 allocs/op
+PASS
+`)
+
+	results := summarize(runInfo{CorpusSize: 1}, parseBenchmarks(output))
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	r := results[0]
+	if r.Benchmark != "wc.BenchmarkCorpus/prose.txt/default" || r.NsPerOp != 45000000 || r.AllocsPerOp != 40 {
+		t.Errorf("got %+v", r)
+	}
+
+	faster, slower := r, r
+	faster.NsPerOp = 30000000
+	slower.NsPerOp = 60000000
+
+	if n := compare(io.Discard, results, []record{faster}, 0.1); n != 1 {
+		t.Errorf("compare against a faster run found %d regressions, want 1", n)
+	}
+	if n := compare(io.Discard, results, []record{slower}, 0.1); n != 0 {
+		t.Errorf("compare against a slower run found %d regressions, want 0", n)
+	}
+
+	// Results over a corpus of another size are not comparable
+	faster.CorpusSize = 2
+	if n := compare(io.Discard, results, []record{faster}, 0.1); n != 0 {
+		t.Errorf("compare across corpus sizes found %d regressions, want 0", n)
+	}
+}
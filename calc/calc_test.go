@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"42", 42},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"2 * 3 / 4", 1.5},
+		{"7 % 4", 3},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"--3", 3},
+		{"-+-3", 3},
+		{"2 * -3", -6},
+		{"2^-1", 0.5},
+		{".5 + 1.25e1", 13},
+		{"6.02E+23 / 1e23", 6.02},
+		{"pi", math.Pi},
+		{"2 * pi", 2 * math.Pi},
+		{"sqrt(16) + abs(-2)", 6},
+		{"max(1, 5, 3) - min(4, 2)", 3},
+		{"pow(2, 10)", 1024},
+		{"sin(pi / 2)", 1},
+		{"log(1000) + ln(e)", 4},
+		{"floor(-2.5) + ceil(2.1) + round(2.5)", 3},
+		{"sqrt(sqrt(16) * 4)", 4},
+	}
+
+	for _, tt := range tests {
+		got, err := evaluate(tt.input)
+		if err != nil {
+			t.Errorf("evaluate(%q): %v", tt.input, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("evaluate(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{"", 0, "unexpected end of input"},
+		{"1 +", 3, "unexpected end of input"},
+		{"1 + * 2", 4, "unexpected '*'"},
+		{"(1 + 2", 6, "expected ')', found end of input"},
+		{"1 2", 2, "unexpected number 2"},
+		{"1 $ 2", 2, "unexpected character '$'"},
+		{"1e", 2, "expected digits in exponent"},
+		{".", 0, "expected digits"},
+		{"x + 1", 0, "unknown name x"},
+		{"foo(1)", 0, "unknown function foo"},
+		{"sqrt(1, 2)", 0, "sqrt takes 1 arguments, not 2"},
+		{"max()", 0, "max takes at least 1 arguments, not 0"},
+		{"sqrt(-1)", 0, "sqrt: -1 is outside the domain"},
+		{"1 / (2 - 2)", 2, "division by zero"},
+		{"5 % 0", 2, "division by zero"},
+		{"max(1,)", 6, "unexpected ')'"},
+	}
+
+	for _, tt := range tests {
+		_, err := evaluate(tt.input)
+		exprErr, ok := err.(*exprError)
+		if !ok {
+			t.Errorf("evaluate(%q) error = %v, want an exprError", tt.input, err)
+			continue
+		}
+		if exprErr.pos != tt.pos || exprErr.msg != tt.msg {
+			t.Errorf("evaluate(%q) error at %d %q, want at %d %q", tt.input, exprErr.pos, exprErr.msg, tt.pos, tt.msg)
+		}
+	}
+}
+
+func TestREPL(t *testing.T) {
+
+	var out, errOut bytes.Buffer
+	input := "0.1 + 0.2\n\n1 +\n2 ^ 10\n"
+	err := repl(strings.NewReader(input), &out, &errOut, 15, false)
+
+	if err != errFailed {
+		t.Errorf("repl = %v, want errFailed", err)
+	}
+	if got := out.String(); got != "0.3\n1024\n" {
+		t.Errorf("output = %q", got)
+	}
+	if got, want := errOut.String(), ": column 4: unexpected end of input\n  1 +\n     ^\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("errors = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// constants are the names an expression can use for numbers.
+var constants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+	"phi": math.Phi,
+}
+
+// function is a function an expression can call. arity is the number of
+// arguments it takes, or -1 for one or more.
+type function struct {
+	arity int
+	fn    func(args []float64) (float64, error)
+}
+
+func unaryFunction(fn func(float64) float64) function {
+	return function{arity: 1, fn: func(args []float64) (float64, error) { return fn(args[0]), nil }}
+}
+
+// functions are the functions an expression can call.
+var functions = map[string]function{
+	"abs":   unaryFunction(math.Abs),
+	"ceil":  unaryFunction(math.Ceil),
+	"floor": unaryFunction(math.Floor),
+	"round": unaryFunction(math.Round),
+	"exp":   unaryFunction(math.Exp),
+	"sin":   unaryFunction(math.Sin),
+	"cos":   unaryFunction(math.Cos),
+	"tan":   unaryFunction(math.Tan),
+	"asin":  unaryFunction(math.Asin),
+	"acos":  unaryFunction(math.Acos),
+	"atan":  unaryFunction(math.Atan),
+	"sqrt":  domainFunction(math.Sqrt, func(x float64) bool { return x >= 0 }),
+	"ln":    domainFunction(math.Log, func(x float64) bool { return x > 0 }),
+	"log":   domainFunction(math.Log10, func(x float64) bool { return x > 0 }),
+	"log2":  domainFunction(math.Log2, func(x float64) bool { return x > 0 }),
+	"pow":   {arity: 2, fn: func(args []float64) (float64, error) { return math.Pow(args[0], args[1]), nil }},
+	"min":   {arity: -1, fn: fold(math.Min)},
+	"max":   {arity: -1, fn: fold(math.Max)},
+}
+
+// domainFunction is fn, restricted to arguments for which valid returns
+// true.
+func domainFunction(fn func(float64) float64, valid func(float64) bool) function {
+	return function{arity: 1, fn: func(args []float64) (float64, error) {
+		if !valid(args[0]) {
+			return 0, fmt.Errorf("%v is outside the domain", args[0])
+		}
+		return fn(args[0]), nil
+	}}
+}
+
+func fold(fn func(a, b float64) float64) func([]float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = fn(result, arg)
+		}
+		return result, nil
+	}
+}
+
+func (n number) eval() (float64, error) {
+	return float64(n), nil
+}
+
+func (v *variable) eval() (float64, error) {
+	value, ok := constants[v.name]
+	if !ok {
+		return 0, &exprError{pos: v.pos, msg: fmt.Sprintf("unknown name %s", v.name)}
+	}
+	return value, nil
+}
+
+func (u *unary) eval() (float64, error) {
+	x, err := u.operand.eval()
+	if err != nil {
+		return 0, err
+	}
+	if u.op == tokenMinus {
+		return -x, nil
+	}
+	return x, nil
+}
+
+func (b *binary) eval() (float64, error) {
+
+	x, err := b.left.eval()
+	if err != nil {
+		return 0, err
+	}
+	y, err := b.right.eval()
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case tokenPlus:
+		return x + y, nil
+	case tokenMinus:
+		return x - y, nil
+	case tokenStar:
+		return x * y, nil
+	case tokenSlash:
+		if y == 0 {
+			return 0, &exprError{pos: b.pos, msg: "division by zero"}
+		}
+		return x / y, nil
+	case tokenPercent:
+		if y == 0 {
+			return 0, &exprError{pos: b.pos, msg: "division by zero"}
+		}
+		return math.Mod(x, y), nil
+	case tokenCaret:
+		return math.Pow(x, y), nil
+	}
+	panic(fmt.Sprintf("unknown operator %v", b.op))
+}
+
+func (c *call) eval() (float64, error) {
+
+	f, ok := functions[c.name]
+	if !ok {
+		return 0, &exprError{pos: c.pos, msg: fmt.Sprintf("unknown function %s", c.name)}
+	}
+	if f.arity >= 0 && len(c.args) != f.arity || f.arity < 0 && len(c.args) == 0 {
+		want := fmt.Sprintf("%d", f.arity)
+		if f.arity < 0 {
+			want = "at least 1"
+		}
+		return 0, &exprError{pos: c.pos, msg: fmt.Sprintf("%s takes %s arguments, not %d", c.name, want, len(c.args))}
+	}
+
+	args := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		value, err := arg.eval()
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+
+	result, err := f.fn(args)
+	if err != nil {
+		return 0, &exprError{pos: c.pos, msg: fmt.Sprintf("%s: %v", c.name, err)}
+	}
+	return result, nil
+}
+
+// evaluate parses and evaluates input.
+func evaluate(input string) (float64, error) {
+	expr, err := parse(input)
+	if err != nil {
+		return 0, err
+	}
+	return expr.eval()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenPlus
+	tokenMinus
+	tokenStar
+	tokenSlash
+	tokenPercent
+	tokenCaret
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of input"
+	case tokenNumber:
+		return "number"
+	case tokenIdent:
+		return "name"
+	}
+	return fmt.Sprintf("%q", punctuation[k])
+}
+
+// punctuation spells the single character tokens.
+var punctuation = map[tokenKind]byte{
+	tokenPlus:    '+',
+	tokenMinus:   '-',
+	tokenStar:    '*',
+	tokenSlash:   '/',
+	tokenPercent: '%',
+	tokenCaret:   '^',
+	tokenLParen:  '(',
+	tokenRParen:  ')',
+	tokenComma:   ',',
+}
+
+var punctuationKinds = func() map[byte]tokenKind {
+	kinds := make(map[byte]tokenKind, len(punctuation))
+	for kind, c := range punctuation {
+		kinds[c] = kind
+	}
+	return kinds
+}()
+
+type token struct {
+	kind tokenKind
+	text string
+
+	// pos is the byte offset of the token in the input
+	pos int
+
+	// value is the value of a number
+	value float64
+}
+
+// exprError is an error in an expression, at a position in the input.
+type exprError struct {
+	pos int
+	msg string
+}
+
+func (e *exprError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.pos+1, e.msg)
+}
+
+// lexer splits an expression into tokens, one at a time.
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) next() (token, error) {
+
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+
+	start := l.pos
+	if start == len(l.input) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.input[start]
+	switch {
+	case isDigit(c) || c == '.':
+		return l.number()
+
+	case isIdentStart(c):
+		for l.pos < len(l.input) && (isIdentStart(l.input[l.pos]) || isDigit(l.input[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenIdent, text: l.input[start:l.pos], pos: start}, nil
+	}
+
+	if kind, ok := punctuationKinds[c]; ok {
+		l.pos++
+		return token{kind: kind, text: l.input[start:l.pos], pos: start}, nil
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[start:])
+	return token{}, &exprError{pos: start, msg: fmt.Sprintf("unexpected character %q", r)}
+}
+
+// number scans a decimal number with an optional fraction and exponent,
+// such as 42, .5, 3.14 or 6.02e23.
+func (l *lexer) number() (token, error) {
+
+	start := l.pos
+	digits := l.digits()
+	if l.pos < len(l.input) && l.input[l.pos] == '.' {
+		l.pos++
+		digits += l.digits()
+	}
+	if digits == 0 {
+		return token{}, &exprError{pos: start, msg: "expected digits"}
+	}
+
+	if l.pos < len(l.input) && (l.input[l.pos] == 'e' || l.input[l.pos] == 'E') {
+		l.pos++
+		if l.pos < len(l.input) && (l.input[l.pos] == '+' || l.input[l.pos] == '-') {
+			l.pos++
+		}
+		if l.digits() == 0 {
+			return token{}, &exprError{pos: l.pos, msg: "expected digits in exponent"}
+		}
+	}
+
+	text := l.input[start:l.pos]
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return token{}, &exprError{pos: start, msg: fmt.Sprintf("invalid number %s", text)}
+	}
+	return token{kind: tokenNumber, text: text, pos: start, value: value}, nil
+}
+
+func (l *lexer) digits() int {
+	start := l.pos
+	for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+		l.pos++
+	}
+	return l.pos - start
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,122 @@
+// Command cccalc is a calculator. It evaluates arithmetic expressions with
+// the usual precedence, parentheses, unary minus, ^ for powers, the
+// constants pi, e, tau and phi, and functions such as sqrt, sin and max,
+// either given as arguments or one per line of standard input.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cccalc [flags] [expression]")
+	fmt.Fprintln(out, "Evaluate expression, or each line of standard input, such as '2 * (3 + 4)'.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cccalc"
+
+	precision := flag.Int("precision", 15, "print results with at most this many significant `digits`")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *precision < 1 {
+		cli.Exit(cli.Usagef("-precision must be positive"))
+	}
+
+	if flag.NArg() > 0 {
+		input := strings.Join(flag.Args(), " ")
+		value, err := evaluate(input)
+		if err != nil {
+			reportError(os.Stderr, input, err)
+			os.Exit(cli.ExitFailure)
+		}
+		fmt.Println(format(value, *precision))
+		return
+	}
+
+	interactive := cli.IsTerminal(os.Stdin)
+	if err := repl(os.Stdin, os.Stdout, os.Stderr, *precision, interactive); err != nil {
+		if !errors.Is(err, errFailed) {
+			cli.Report(err)
+		}
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+// errFailed is returned by repl when an expression failed, after the
+// error has been reported.
+var errFailed = errors.New("an expression failed")
+
+// repl evaluates each line of input, skipping blank ones, printing a
+// prompt before each when interactive. An error in one line is reported
+// and the rest are still evaluated.
+func repl(input io.Reader, out, errOut io.Writer, precision int, interactive bool) error {
+
+	failed := false
+	scanner := bufio.NewScanner(input)
+	for {
+		if interactive {
+			fmt.Fprint(out, "> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		value, err := evaluate(line)
+		if err != nil {
+			reportError(errOut, line, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintln(out, format(value, precision))
+	}
+	if interactive {
+		fmt.Fprintln(out)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed && !interactive {
+		return errFailed
+	}
+	return nil
+}
+
+// reportError prints err, pointing at where in input it happened.
+func reportError(out io.Writer, input string, err error) {
+
+	fmt.Fprintf(out, "%s: %v\n", cli.Name, err)
+
+	var exprErr *exprError
+	if errors.As(err, &exprErr) {
+		fmt.Fprintf(out, "  %s\n  %s^\n", input, strings.Repeat(" ", exprErr.pos))
+	}
+}
+
+// format prints value with at most precision significant digits, which
+// hides the error of binary floating point in results such as 0.1+0.2.
+func format(value float64, precision int) string {
+	return strconv.FormatFloat(value, 'g', precision, 64)
+}
@@ -0,0 +1,206 @@
+package main
+
+import "fmt"
+
+// node is a node of a parsed expression.
+type node interface {
+	eval() (float64, error)
+}
+
+type number float64
+
+type variable struct {
+	name string
+	pos  int
+}
+
+type unary struct {
+	op      tokenKind
+	operand node
+}
+
+type binary struct {
+	op          tokenKind
+	left, right node
+	pos         int
+}
+
+type call struct {
+	name string
+	args []node
+	pos  int
+}
+
+// Binding powers of the operators, from loosest to tightest. Unary minus
+// binds looser than ^, so -2^2 is -4, as in mathematics, and ^ is right
+// associative, so 2^3^2 is 2^9.
+const (
+	powerSum     = 10
+	powerProduct = 20
+	powerPrefix  = 30
+	powerPower   = 40
+)
+
+var infixPowers = map[tokenKind]int{
+	tokenPlus:    powerSum,
+	tokenMinus:   powerSum,
+	tokenStar:    powerProduct,
+	tokenSlash:   powerProduct,
+	tokenPercent: powerProduct,
+	tokenCaret:   powerPower,
+}
+
+// parser is a Pratt parser: each token knows how to start an expression,
+// as a prefix, or continue one, as an infix operator with a binding power,
+// and parseExpression keeps extending the expression while the next
+// operator binds tighter than the one it was called for.
+type parser struct {
+	lexer   *lexer
+	current token
+}
+
+// parse parses input as a single expression.
+func parse(input string) (node, error) {
+
+	p := &parser{lexer: &lexer{input: input}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.current.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return expr, nil
+}
+
+func (p *parser) advance() error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.current = t
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if p.current.kind != kind {
+		return &exprError{pos: p.current.pos, msg: fmt.Sprintf("expected %v, found %v", kind, p.describe())}
+	}
+	return p.advance()
+}
+
+func (p *parser) unexpected() error {
+	return &exprError{pos: p.current.pos, msg: fmt.Sprintf("unexpected %v", p.describe())}
+}
+
+func (p *parser) describe() string {
+	if p.current.text != "" && p.current.kind <= tokenIdent {
+		return fmt.Sprintf("%v %s", p.current.kind, p.current.text)
+	}
+	return p.current.kind.String()
+}
+
+func (p *parser) parseExpression(minPower int) (node, error) {
+
+	left, err := p.parsePrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.current
+		power, ok := infixPowers[op.kind]
+		if !ok || power <= minPower {
+			return left, nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+
+		// A right associative operator lets an operator of the same
+		// power take its right operand
+		rightPower := power
+		if op.kind == tokenCaret {
+			rightPower--
+		}
+
+		right, err := p.parseExpression(rightPower)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op.kind, left: left, right: right, pos: op.pos}
+	}
+}
+
+func (p *parser) parsePrefix() (node, error) {
+
+	t := p.current
+	switch t.kind {
+	case tokenNumber:
+		return number(t.value), p.advance()
+
+	case tokenMinus, tokenPlus:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseExpression(powerPrefix)
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: t.kind, operand: operand}, nil
+
+	case tokenLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(tokenRParen)
+
+	case tokenIdent:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.current.kind != tokenLParen {
+			return &variable{name: t.text, pos: t.pos}, nil
+		}
+		return p.parseCall(t)
+	}
+
+	return nil, p.unexpected()
+}
+
+// parseCall parses the arguments of a call to the function named by name,
+// whose opening parenthesis is the current token.
+func (p *parser) parseCall(name token) (node, error) {
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	c := &call{name: name.text, pos: name.pos}
+	if p.current.kind == tokenRParen {
+		return c, p.advance()
+	}
+
+	for {
+		arg, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+
+		if p.current.kind != tokenComma {
+			return c, p.expect(tokenRParen)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+}
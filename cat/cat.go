@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// options are the ways cat can change what it copies.
+type options struct {
+	numberAll      bool
+	numberNonBlank bool
+	squeezeBlank   bool
+	showEnds       bool
+	showTabs       bool
+	showNonPrint   bool
+}
+
+// plain reports whether opts leave input unchanged, so it can be copied as
+// is.
+func (opts options) plain() bool {
+	return opts == options{}
+}
+
+// printer copies input to out a chunk at a time, changing it as opts ask.
+// Lines may span chunks and files, and numbering carries on from one file
+// to the next, so the state of the current line is kept between calls.
+type printer struct {
+	out  *bufio.Writer
+	opts options
+
+	// line is the number of the last numbered line
+	line int
+
+	// midLine is set when the last chunk ended part way through a line
+	midLine bool
+
+	// blanks is how many blank lines in a row have just been seen
+	blanks int
+}
+
+func newPrinter(out io.Writer, opts options) *printer {
+	return &printer{out: bufio.NewWriter(out), opts: opts}
+}
+
+// write processes one chunk of input, which may start or end part way
+// through a line.
+func (p *printer) write(chunk []byte) error {
+
+	for len(chunk) > 0 {
+		line, rest, newline := bytes.Cut(chunk, []byte{'\n'})
+		chunk = rest
+
+		if !p.midLine {
+			// A line is known to be blank from its first byte, even when
+			// the rest of it is in the next chunk
+			if len(line) == 0 {
+				p.blanks++
+				if p.opts.squeezeBlank && p.blanks > 1 {
+					continue
+				}
+			} else {
+				p.blanks = 0
+			}
+
+			if p.opts.numberAll && !p.opts.numberNonBlank || p.opts.numberNonBlank && len(line) > 0 {
+				p.line++
+				fmt.Fprintf(p.out, "%6d\t", p.line)
+			}
+		}
+
+		p.writeLine(line)
+
+		p.midLine = !newline
+		if newline {
+			if p.opts.showEnds {
+				p.out.WriteByte('$')
+			}
+			p.out.WriteByte('\n')
+		}
+	}
+
+	return p.out.Flush()
+}
+
+// writeLine writes the contents of a line, without its newline, showing
+// tabs and non-printing bytes if asked to.
+func (p *printer) writeLine(line []byte) {
+
+	if !p.opts.showTabs && !p.opts.showNonPrint {
+		p.out.Write(line)
+		return
+	}
+
+	for _, c := range line {
+		switch {
+		case c == '\t':
+			if p.opts.showTabs {
+				p.out.WriteString("^I")
+			} else {
+				p.out.WriteByte(c)
+			}
+		case !p.opts.showNonPrint:
+			p.out.WriteByte(c)
+		default:
+			writeVisible(p.out, c)
+		}
+	}
+}
+
+// writeVisible writes c in the notation of cat -v: bytes with the high bit
+// set as M- followed by the rest, control characters as ^ followed by the
+// letter that is 64 on, and DEL as ^?.
+func writeVisible(out *bufio.Writer, c byte) {
+
+	if c >= 128 {
+		out.WriteString("M-")
+		c -= 128
+	}
+
+	switch {
+	case c < 32:
+		out.WriteByte('^')
+		out.WriteByte(c + 64)
+	case c == 127:
+		out.WriteString("^?")
+	default:
+		out.WriteByte(c)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+
+	input := "one\n\n\n\ttwo\x01\xe9\x7f\nthree"
+
+	tests := []struct {
+		opts options
+		want string
+	}{
+		{options{}, input},
+		{options{numberAll: true}, "     1\tone\n     2\t\n     3\t\n     4\t\ttwo\x01\xe9\x7f\n     5\tthree"},
+		{options{numberNonBlank: true}, "     1\tone\n\n\n     2\t\ttwo\x01\xe9\x7f\n     3\tthree"},
+		{options{numberAll: true, numberNonBlank: true}, "     1\tone\n\n\n     2\t\ttwo\x01\xe9\x7f\n     3\tthree"},
+		{options{squeezeBlank: true}, "one\n\n\ttwo\x01\xe9\x7f\nthree"},
+		{options{squeezeBlank: true, numberAll: true}, "     1\tone\n     2\t\n     3\t\ttwo\x01\xe9\x7f\n     4\tthree"},
+		{options{showEnds: true}, "one$\n$\n$\n\ttwo\x01\xe9\x7f$\nthree"},
+		{options{showTabs: true}, "one\n\n\n^Itwo\x01\xe9\x7f\nthree"},
+		{options{showNonPrint: true}, "one\n\n\n\ttwo^AM-i^?\nthree"},
+		{options{showNonPrint: true, showEnds: true, showTabs: true}, "one$\n$\n$\n^Itwo^AM-i^?$\nthree"},
+	}
+
+	for _, test := range tests {
+		// Feeding the input a byte at a time splits every line across
+		// chunks
+		for _, size := range []int{len(input), 1} {
+			var out bytes.Buffer
+			p := newPrinter(&out, test.opts)
+
+			for chunk := []byte(input); len(chunk) > 0; chunk = chunk[min(size, len(chunk)):] {
+				if err := p.write(chunk[:min(size, len(chunk))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if out.String() != test.want {
+				t.Errorf("%+v in chunks of %d: got %q, want %q", test.opts, size, out.String(), test.want)
+			}
+		}
+	}
+}
+
+// TestPrinterAcrossFiles checks that numbering and squeezing carry on from
+// one file to the next, as when a file without a final newline is followed
+// by another.
+func TestPrinterAcrossFiles(t *testing.T) {
+
+	var out bytes.Buffer
+	p := newPrinter(&out, options{numberAll: true, squeezeBlank: true})
+
+	for _, file := range []string{"a\n\n", "\nb", "c\n"} {
+		if err := p.write([]byte(file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "     1\ta\n     2\t\n     3\tbc\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
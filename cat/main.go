@@ -0,0 +1,108 @@
+// Command cccat concatenates files, or standard input, to standard output,
+// like cat, optionally numbering lines, squeezing blank lines and showing
+// non-printing characters.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cccat [flags] [file ...]")
+	fmt.Fprintln(out, "Concatenate files to standard output, reading standard input when there are none")
+	fmt.Fprintln(out, "or the file is -.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cccat"
+
+	var opts options
+	flag.BoolVar(&opts.numberAll, "n", false, "number all output lines")
+	flag.BoolVar(&opts.numberNonBlank, "b", false, "number non-blank output lines, overriding -n")
+	flag.BoolVar(&opts.squeezeBlank, "s", false, "squeeze repeated blank lines into one")
+	flag.BoolVar(&opts.showEnds, "E", false, "show $ at the end of each line")
+	flag.BoolVar(&opts.showTabs, "T", false, "show tabs as ^I")
+	flag.BoolVar(&opts.showNonPrint, "v", false, "show non-printing characters with ^ and M- notation, except tabs and newlines")
+	showAll := flag.Bool("A", false, "show all: the same as -vET")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *showAll {
+		opts.showNonPrint, opts.showEnds, opts.showTabs = true, true, true
+	}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{cli.Stdin}
+	}
+
+	p := newPrinter(os.Stdout, opts)
+	ok := true
+
+	for _, path := range paths {
+		if err := cat(p, path); err != nil {
+			cli.Report(err)
+			ok = false
+		}
+	}
+
+	if !ok {
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+func cat(p *printer, path string) error {
+
+	file, closeFile, err := cli.Open(path)
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	defer closeFile()
+
+	write := p.write
+	if p.opts.plain() {
+		write = func(chunk []byte) error {
+			_, err := os.Stdout.Write(chunk)
+			return err
+		}
+	}
+
+	err = streamio.ForEachChunk(file, streamio.BufferSizeFor(file), func(chunk []byte) error {
+		if err := write(chunk); err != nil {
+			return &outputError{err}
+		}
+		return nil
+	})
+
+	// Nothing more can be written once standard output fails
+	var output *outputError
+	if errors.As(err, &output) {
+		cli.Exit(output.err)
+	}
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	return nil
+}
+
+// outputError is a failure to write standard output, as opposed to reading
+// an input.
+type outputError struct {
+	err error
+}
+
+func (e *outputError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// agent is the product token the crawler names itself by, in its
+// User-Agent header and when reading robots.txt.
+const agent = "cccrawl"
+
+const userAgent = agent + "/1.0"
+
+// maxPageSize is how much of a page is read for links.
+const maxPageSize = 10 * 1024 * 1024
+
+// errDisallowed records a page robots.txt kept the crawler from.
+var errDisallowed = errors.New("disallowed by robots.txt")
+
+// page is what the crawler learned about a URL.
+type page struct {
+	url   string
+	depth int
+
+	// status is the response's status code, or 0 if there was none
+	status      int
+	contentType string
+
+	// redirect is where a redirect pointed
+	redirect string
+
+	// links are the URLs in scope the page links to, each once
+	links []string
+
+	err error
+}
+
+// crawler visits the pages of a site, starting from its seeds and
+// following links breadth first. It is polite: it obeys robots.txt, waits
+// between requests to the same host, and stays on the hosts of its seeds.
+type crawler struct {
+	client *http.Client
+
+	// maxDepth is how many links away from a seed to go, and maxPages
+	// how many URLs to visit in all
+	maxDepth int
+	maxPages int
+
+	// delay is the least time between requests to a host, which a longer
+	// Crawl-delay in robots.txt overrides
+	delay time.Duration
+
+	workers int
+
+	// log, if set, receives a line for each page visited
+	log io.Writer
+
+	// scope holds the hosts to crawl, as scheme://host
+	scope map[string]bool
+
+	mu    sync.Mutex
+	hosts map[string]*host
+}
+
+// host is the crawler's state for one host.
+type host struct {
+	mu sync.Mutex
+
+	// next is when the next request to the host may be sent
+	next time.Time
+
+	// robots is set once robotsReady is closed
+	robots        *robots
+	robotsReady   chan struct{}
+	robotsStarted bool
+}
+
+// task is a URL waiting to be visited.
+type task struct {
+	url   string
+	depth int
+}
+
+// crawl visits the pages reachable from seeds and returns them sorted by
+// URL. If ctx is canceled it stops, returning the pages visited so far
+// with the context's error.
+func (c *crawler) crawl(ctx context.Context, seeds []string) ([]*page, error) {
+
+	c.hosts = make(map[string]*host)
+	c.scope = make(map[string]bool)
+	seen := make(map[string]bool)
+	var queue []task
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return nil, err
+		}
+		normalized, ok := normalize(u)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an http or https URL", seed)
+		}
+		c.scope[origin(u)] = true
+		if !seen[normalized] {
+			seen[normalized] = true
+			queue = append(queue, task{url: normalized})
+		}
+	}
+
+	tasks := make(chan task)
+	results := make(chan *page)
+	var wg sync.WaitGroup
+	for range c.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				results <- c.visit(ctx, t)
+			}
+		}()
+	}
+
+	var pages []*page
+	inFlight := 0
+	stopped := false
+	for len(queue) > 0 || inFlight > 0 {
+		// Send the next task only while there is one
+		var send chan task
+		var next task
+		if len(queue) > 0 {
+			send, next = tasks, queue[0]
+		}
+
+		select {
+		case send <- next:
+			queue = queue[1:]
+			inFlight++
+
+		case p := <-results:
+			inFlight--
+			pages = append(pages, p)
+			if p.depth >= c.maxDepth {
+				continue
+			}
+			for _, link := range p.links {
+				if !stopped && !seen[link] && len(seen) < c.maxPages {
+					seen[link] = true
+					queue = append(queue, task{url: link, depth: p.depth + 1})
+				}
+			}
+
+		case <-ctx.Done():
+			// Stop handing out work and wait for what is in flight
+			queue, stopped = nil, true
+		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	slices.SortFunc(pages, func(a, b *page) int { return strings.Compare(a.url, b.url) })
+	return pages, ctx.Err()
+}
+
+// visit fetches one page, within the rules of its host, and finds the
+// links on it.
+func (c *crawler) visit(ctx context.Context, t task) *page {
+
+	p := &page{url: t.url, depth: t.depth}
+	u, err := url.Parse(t.url)
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	h := c.host(u)
+	rules, err := c.robotsFor(ctx, h, u)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	if !rules.allowed(u.RequestURI()) {
+		p.err = errDisallowed
+		c.logf("robots %s", p.url)
+		return p
+	}
+
+	resp, err := c.get(ctx, h, rules, u)
+	if err != nil {
+		p.err = err
+		c.logf("error  %s: %v", p.url, err)
+		return p
+	}
+	defer resp.Body.Close()
+
+	p.status = resp.StatusCode
+	p.contentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	c.logf("%d    %s", p.status, p.url)
+
+	switch {
+	case p.status >= 300 && p.status < 400:
+		if location, err := resp.Location(); err == nil {
+			p.redirect = location.String()
+			p.links = c.inScope(u, []string{location.String()})
+		}
+
+	case p.status >= 200 && p.status < 300 && p.contentType == "text/html":
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+		if err != nil {
+			p.err = err
+			return p
+		}
+		doc := parseDocument(body)
+		if doc.nofollow {
+			return p
+		}
+		base := u
+		if doc.base != "" {
+			if b, err := u.Parse(doc.base); err == nil {
+				base = b
+			}
+		}
+		p.links = c.inScope(base, doc.links)
+	}
+	return p
+}
+
+// get sends a request for u once the host's delay allows.
+func (c *crawler) get(ctx context.Context, h *host, rules *robots, u *url.URL) (*http.Response, error) {
+
+	if err := h.wait(ctx, max(c.delay, rules.crawlDelay)); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+	return c.client.Do(req)
+}
+
+// inScope resolves links against base and returns those on the crawled
+// hosts, normalized and each once, in the order found.
+func (c *crawler) inScope(base *url.URL, links []string) []string {
+
+	var result []string
+	found := make(map[string]bool)
+	for _, link := range links {
+		u, err := base.Parse(strings.TrimSpace(link))
+		if err != nil {
+			continue
+		}
+		normalized, ok := normalize(u)
+		if !ok || !c.scope[origin(u)] || found[normalized] {
+			continue
+		}
+		found[normalized] = true
+		result = append(result, normalized)
+	}
+	return result
+}
+
+// host returns the state of the host of u, creating it on first use.
+func (c *crawler) host(u *url.URL) *host {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := origin(u)
+	h := c.hosts[key]
+	if h == nil {
+		h = &host{robotsReady: make(chan struct{})}
+		c.hosts[key] = h
+	}
+	return h
+}
+
+// robotsFor returns the robots.txt rules of the host of u. The first
+// visit to a host fetches them, and any others at the same time wait.
+func (c *crawler) robotsFor(ctx context.Context, h *host, u *url.URL) (*robots, error) {
+
+	h.mu.Lock()
+	load := !h.robotsStarted
+	h.robotsStarted = true
+	h.mu.Unlock()
+
+	if load {
+		h.robots = c.fetchRobots(ctx, h, u)
+		close(h.robotsReady)
+	}
+
+	select {
+	case <-h.robotsReady:
+		return h.robots, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchRobots fetches and parses /robots.txt from the host of u. A file
+// that does not exist allows everything; one that cannot be fetched
+// disallows everything.
+func (c *crawler) fetchRobots(ctx context.Context, h *host, u *url.URL) *robots {
+
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	resp, err := c.get(ctx, h, allowAll, robotsURL)
+	if err != nil {
+		c.logf("error  %s: %v", robotsURL, err)
+		return disallowAll
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		rules, err := parseRobots(resp.Body, agent)
+		if err != nil {
+			return disallowAll
+		}
+		return rules
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return allowAll
+	}
+	return disallowAll
+}
+
+// wait blocks until a request to the host may be sent, and books the next
+// slot delay after it.
+func (h *host) wait(ctx context.Context, delay time.Duration) error {
+
+	h.mu.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(delay)
+	h.mu.Unlock()
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *crawler) logf(format string, args ...any) {
+	if c.log != nil {
+		fmt.Fprintf(c.log, format+"\n", args...)
+	}
+}
+
+// normalize returns u in the form the crawler compares URLs in: without a
+// fragment, with a lowercase scheme and host, without a default port and
+// with / for an empty path. Only http and https URLs are crawled.
+func normalize(u *url.URL) (string, bool) {
+
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	if n.Scheme != "http" && n.Scheme != "https" || n.Host == "" {
+		return "", false
+	}
+	n.Host = canonicalHost(n.Scheme, n.Host)
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	n.Fragment, n.RawFragment = "", ""
+	return n.String(), true
+}
+
+// origin returns the scheme and host of u, normalized as by normalize,
+// which together identify a site.
+func origin(u *url.URL) string {
+
+	scheme := strings.ToLower(u.Scheme)
+	return scheme + "://" + canonicalHost(scheme, u.Host)
+}
+
+// canonicalHost lowercases host and drops the default port of scheme.
+func canonicalHost(scheme, host string) string {
+
+	host = strings.ToLower(host)
+	switch scheme {
+	case "http":
+		host = strings.TrimSuffix(host, ":80")
+	case "https":
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"codechallenge/internal/httpclient"
+)
+
+func TestRobots(t *testing.T) {
+
+	const file = `# comment
+User-agent: other
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Disallow: /*.pdf$
+Disallow: /search?
+Crawl-delay: 2
+
+User-agent: CCcrawl
+User-agent: someone
+Disallow: /mine   # just for us
+Allow: /mine/yes
+Crawl-delay: 0.5
+`
+
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"cccrawl", "/", true},
+		{"cccrawl", "/mine", false},
+		{"cccrawl", "/mine/page", false},
+		{"cccrawl", "/mine/yes", true},
+		{"cccrawl", "/private", true},
+		{"cccrawl", "/robots.txt", true},
+		{"bot", "/", true},
+		{"bot", "/private", false},
+		{"bot", "/private/x", false},
+		{"bot", "/privateer", false},
+		{"bot", "/private/open", true},
+		{"bot", "/docs/a.pdf", false},
+		{"bot", "/docs/a.pdf?x", true},
+		{"bot", "/search?q=1", false},
+		{"bot", "/search", true},
+		{"other", "/anything", false},
+		{"other", "/robots.txt", true},
+	}
+
+	for _, test := range tests {
+		r, err := parseRobots(strings.NewReader(file), test.agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.allowed(test.path); got != test.want {
+			t.Errorf("%s: allowed(%q) = %v, want %v", test.agent, test.path, got, test.want)
+		}
+	}
+
+	r, _ := parseRobots(strings.NewReader(file), "cccrawl")
+	if r.crawlDelay != 500*time.Millisecond {
+		t.Errorf("crawl delay = %v, want 500ms", r.crawlDelay)
+	}
+	r, _ = parseRobots(strings.NewReader(file), "bot")
+	if r.crawlDelay != 2*time.Second {
+		t.Errorf("crawl delay = %v, want 2s", r.crawlDelay)
+	}
+
+	// A group naming the crawler with no rules overrides the * group
+	r, _ = parseRobots(strings.NewReader("User-agent: *\nDisallow: /\n\nUser-agent: cccrawl\nDisallow:\n"), "cccrawl")
+	if !r.allowed("/page") {
+		t.Error("an empty group for the crawler did not allow everything")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/a", "/b", false},
+		{"/a*c", "/abc", true},
+		{"/a*c", "/ab", false},
+		{"/a*c$", "/abcd", false},
+		{"/a*c$", "/abcc", true},
+		{"/a$", "/a", true},
+		{"/a$", "/ab", false},
+		{"*.php", "/x/index.php?q", true},
+		{"/*/*/z", "/x/y/z", true},
+		{"/*/*/z", "/x/z", false},
+	}
+
+	for _, test := range tests {
+		if got := matchPattern(test.pattern, test.path); got != test.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", test.pattern, test.path, got, test.want)
+		}
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+
+	const page = `<!DOCTYPE html>
+<html><head>
+<title>a <a href="/in-title"> b</title>
+<BASE HREF="/base/">
+<link rel=stylesheet href=style.css>
+<script>if (a < b) document.write('<a href="/in-script">')</script>
+</head><body>
+<!-- <a href="/in-comment"> -->
+<a href="one.html">One</a>
+<A class=x HREF = 'two.html?a=1&amp;b=2'>Two</A>
+<a href="/ad" rel="sponsored nofollow">Ad</a>
+<a name="anchor">no href</a>
+<area href=map.html>
+<a href="one.html" href="ignored">Again</a>
+<p>1 < 2</p>
+</body></html>`
+
+	doc := parseDocument([]byte(page))
+	want := []string{"style.css", "one.html", "two.html?a=1&b=2", "map.html", "one.html"}
+	if !slices.Equal(doc.links, want) {
+		t.Errorf("links = %q, want %q", doc.links, want)
+	}
+	if doc.base != "/base/" {
+		t.Errorf("base = %q, want /base/", doc.base)
+	}
+	if doc.nofollow {
+		t.Error("nofollow set without a robots meta tag")
+	}
+
+	doc = parseDocument([]byte(`<meta name="ROBOTS" content="noindex, nofollow"><a href="/x">`))
+	if !doc.nofollow {
+		t.Error("nofollow not set by the robots meta tag")
+	}
+
+	// Unterminated markup ends the document without a fuss
+	for _, page := range []string{"<a href='x", "<!-- open", "<script>x", "<", "<a"} {
+		parseDocument([]byte(page))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"HTTP://Example.COM", "http://example.com/"},
+		{"http://example.com:80/a#frag", "http://example.com/a"},
+		{"https://example.com:443/a?q=1", "https://example.com/a?q=1"},
+		{"http://example.com:8080/", "http://example.com:8080/"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"mailto:someone@example.com", ""},
+		{"ftp://example.com/", ""},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := normalize(u)
+		if got != test.want || ok != (test.want != "") {
+			t.Errorf("normalize(%q) = %q, %v, want %q", test.url, got, ok, test.want)
+		}
+	}
+}
+
+// site serves a small web site for crawls, recording when each path was
+// requested.
+type site struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+	agents   []string
+}
+
+func newSite(t *testing.T, pages map[string]string) (*site, *httptest.Server) {
+	t.Helper()
+
+	s := &site{requests: make(map[string][]time.Time)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.RequestURI()] = append(s.requests[r.URL.RequestURI()], time.Now())
+		s.agents = append(s.agents, r.UserAgent())
+		s.mu.Unlock()
+
+		body, ok := pages[r.URL.RequestURI()]
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case strings.HasPrefix(body, "redirect:"):
+			http.Redirect(w, r, strings.TrimPrefix(body, "redirect:"), http.StatusMovedPermanently)
+		case r.URL.Path == "/robots.txt" || strings.HasSuffix(r.URL.Path, ".txt"):
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, body)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, body)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func (s *site) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests[path])
+}
+
+// summary renders pages as a line each of status, path and depth.
+func summary(pages []*page, base string) string {
+
+	var b strings.Builder
+	for _, p := range pages {
+		fmt.Fprintf(&b, "%d %s %d\n", p.status, strings.TrimPrefix(p.url, base), p.depth)
+	}
+	return b.String()
+}
+
+func TestCrawl(t *testing.T) {
+
+	s, server := newSite(t, map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /secret\n",
+		"/":           `<a href="/a">A</a> <a href="b">B</a> <a href="/secret">S</a> <a href="http://elsewhere.example/">X</a> <a href="mailto:x@example.com">M</a>`,
+		"/a":          `<a href="/">home</a> <a href="/a#top">self</a> <a href="/deep">deep</a> <a href="/notes.txt">notes</a>`,
+		"/b":          `<a href="/old">old</a> <a href="/missing">missing</a>`,
+		"/deep":       `<a href="/deeper">deeper</a>`,
+		"/deeper":     `never reached`,
+		"/old":        "redirect:/a",
+		"/notes.txt":  `<a href="/not-a-link">`,
+		"/secret":     `hidden`,
+	})
+
+	c := &crawler{client: httpclient.New(httpclient.Options{}), maxDepth: 2, maxPages: 100, workers: 3}
+	pages, err := c.crawl(context.Background(), []string{server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `200 / 0
+200 /a 1
+200 /b 1
+200 /deep 2
+404 /missing 2
+200 /notes.txt 2
+301 /old 2
+0 /secret 1
+`
+	if got := summary(pages, server.URL); got != want {
+		t.Errorf("pages:\n%s\nwant:\n%s", got, want)
+	}
+
+	if s.count("/secret") != 0 || s.count("/deeper") != 0 || s.count("/not-a-link") != 0 {
+		t.Error("fetched a page it should not have")
+	}
+	if n := s.count("/robots.txt"); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", n)
+	}
+	for _, agent := range s.agents {
+		if agent != userAgent {
+			t.Errorf("User-Agent = %q, want %q", agent, userAgent)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := writeText(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"301 " + server.URL + "/old -> " + server.URL + "/a\n",
+		"-   " + server.URL + "/secret (disallowed by robots.txt)\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("text site map is missing %q:\n%s", line, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := writeXML(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(buf.String(), "<loc>"), 5; got != want {
+		t.Errorf("XML site map has %d URLs, want %d:\n%s", got, want, buf.String())
+	}
+}
+
+func TestCrawlLimits(t *testing.T) {
+
+	// A chain of pages each linking to the next
+	pages := map[string]string{}
+	for i := range 20 {
+		pages[fmt.Sprintf("/%d", i)] = fmt.Sprintf(`<a href="/%d">next</a>`, i+1)
+	}
+	pages["/"] = `<a href="/0">start</a>`
+	_, server := newSite(t, pages)
+
+	c := &crawler{client: httpclient.New(httpclient.Options{}), maxDepth: 100, maxPages: 5, workers: 2}
+	found, err := c.crawl(context.Background(), []string{server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 5 {
+		t.Errorf("visited %d pages, want 5", len(found))
+	}
+
+	c = &crawler{client: httpclient.New(httpclient.Options{}), maxDepth: 3, maxPages: 100, workers: 2}
+	found, _ = c.crawl(context.Background(), []string{server.URL})
+	if len(found) != 4 {
+		t.Errorf("visited %d pages at depth 3, want 4", len(found))
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+
+	const delay = 50 * time.Millisecond
+	s, server := newSite(t, map[string]string{
+		"/robots.txt": "User-agent: *\nCrawl-delay: 0.05\n",
+		"/":           `<a href="/1">1</a> <a href="/2">2</a> <a href="/3">3</a>`,
+		"/1":          "one",
+		"/2":          "two",
+		"/3":          "three",
+	})
+
+	c := &crawler{client: httpclient.New(httpclient.Options{}), maxDepth: 1, maxPages: 100, workers: 4}
+	if _, err := c.crawl(context.Background(), []string{server.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The delay is only known once robots.txt has been read
+	var times []time.Time
+	for path, requests := range s.requests {
+		if path != "/robots.txt" {
+			times = append(times, requests...)
+		}
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	if len(times) != 4 {
+		t.Fatalf("%d requests, want 4", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		// Allow a little for the server receiving requests out of order
+		if gap := times[i].Sub(times[i-1]); gap < delay-10*time.Millisecond {
+			t.Errorf("requests %d and %d were %v apart, want at least %v", i-1, i, gap, delay)
+		}
+	}
+}
+
+func TestCrawlCanceled(t *testing.T) {
+
+	_, server := newSite(t, map[string]string{
+		"/":  `<a href="/1">1</a> <a href="/2">2</a>`,
+		"/1": "one",
+		"/2": "two",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &crawler{client: httpclient.New(httpclient.Options{}), maxDepth: 1, maxPages: 100, delay: time.Hour, workers: 2}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	pages, err := c.crawl(ctx, []string{server.URL})
+	if err != context.Canceled {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("the crawl did not stop promptly")
+	}
+
+	// The start page was waiting out the delay after robots.txt
+	if len(pages) != 1 || pages[0].url != server.URL+"/" || pages[0].err != context.Canceled {
+		t.Errorf("pages = %v, want the start page, canceled", pages)
+	}
+}
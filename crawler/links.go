@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// document is what the crawler takes from an HTML page.
+type document struct {
+	// links are the href attributes of the page's links, unresolved
+	links []string
+
+	// base is the href of the page's <base> element, which links are
+	// resolved against instead of the page's URL
+	base string
+
+	// nofollow is set by <meta name="robots" content="nofollow">, which
+	// asks crawlers not to follow any of the page's links
+	nofollow bool
+}
+
+// rawTextElements hold text that may contain < without starting a tag, so
+// they are skipped whole.
+var rawTextElements = []string{"script", "style", "textarea", "title"}
+
+// parseDocument finds the links of an HTML page: the hrefs of its <a>,
+// <area> and <link> elements, except those marked rel="nofollow". It
+// reads tags with a small tokenizer rather than building the page's tree,
+// which is all a crawler needs and copes with markup of any quality.
+func parseDocument(page []byte) document {
+
+	var doc document
+	for {
+		i := bytes.IndexByte(page, '<')
+		if i < 0 {
+			return doc
+		}
+		page = page[i+1:]
+
+		if bytes.HasPrefix(page, []byte("!--")) {
+			end := bytes.Index(page[3:], []byte("-->"))
+			if end < 0 {
+				return doc
+			}
+			page = page[3+end+3:]
+			continue
+		}
+
+		name, attrs, rest := parseTag(page)
+		page = rest
+
+		switch name {
+		case "a", "area", "link":
+			href, ok := attrs["href"]
+			if ok && !hasToken(attrs["rel"], "nofollow") {
+				doc.links = append(doc.links, href)
+			}
+		case "base":
+			if href, ok := attrs["href"]; ok && doc.base == "" {
+				doc.base = href
+			}
+		case "meta":
+			if strings.EqualFold(attrs["name"], "robots") && hasToken(strings.ReplaceAll(attrs["content"], ",", " "), "nofollow") {
+				doc.nofollow = true
+			}
+		}
+
+		for _, element := range rawTextElements {
+			if name == element {
+				end := indexEndTag(page, element)
+				if end < 0 {
+					return doc
+				}
+				page = page[end:]
+			}
+		}
+	}
+}
+
+// parseTag reads the start tag after a <, returning its lowercased name
+// and attributes with their values unescaped, and the input after it. The
+// name is empty for anything else, such as an end tag or a stray <.
+func parseTag(input []byte) (name string, attrs map[string]string, rest []byte) {
+
+	n := 0
+	for n < len(input) && isAlnum(input[n]) {
+		n++
+	}
+	if n == 0 {
+		return "", nil, input
+	}
+	name = strings.ToLower(string(input[:n]))
+	input = input[n:]
+
+	attrs = make(map[string]string)
+	for {
+		input = bytes.TrimLeft(input, " \t\r\n\f/")
+		if len(input) == 0 {
+			return name, attrs, input
+		}
+		if input[0] == '>' {
+			return name, attrs, input[1:]
+		}
+
+		n := bytes.IndexAny(input, " \t\r\n\f/>=")
+		if n < 0 {
+			n = len(input)
+		}
+		if n == 0 {
+			// A stray =
+			n = 1
+		}
+		attr := strings.ToLower(string(input[:n]))
+		input = bytes.TrimLeft(input[n:], " \t\r\n\f")
+
+		var value string
+		if len(input) > 0 && input[0] == '=' {
+			value, input = parseValue(bytes.TrimLeft(input[1:], " \t\r\n\f"))
+		}
+
+		// The first of repeated attributes counts
+		if _, ok := attrs[attr]; !ok {
+			attrs[attr] = html.UnescapeString(value)
+		}
+	}
+}
+
+// parseValue reads an attribute value, quoted or not.
+func parseValue(input []byte) (string, []byte) {
+
+	if len(input) > 0 && (input[0] == '"' || input[0] == '\'') {
+		end := bytes.IndexByte(input[1:], input[0])
+		if end < 0 {
+			return string(input[1:]), nil
+		}
+		return string(input[1 : 1+end]), input[1+end+1:]
+	}
+
+	end := bytes.IndexAny(input, " \t\r\n\f>")
+	if end < 0 {
+		end = len(input)
+	}
+	return string(input[:end]), input[end:]
+}
+
+// hasToken reports whether the space-separated list holds token, ignoring
+// case.
+func hasToken(list, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexEndTag returns the index of the end tag </name in s, ignoring
+// case, or -1.
+func indexEndTag(s []byte, name string) int {
+
+	tag := []byte("</" + name)
+	for i := 0; ; {
+		j := bytes.IndexByte(s[i:], '<')
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if i+len(tag) <= len(s) && bytes.EqualFold(s[i:i+len(tag)], tag) {
+			return i
+		}
+		i++
+	}
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
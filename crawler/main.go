@@ -0,0 +1,104 @@
+// Command cccrawl crawls a web site and prints a map of it. It starts
+// from the URLs given and follows links on their hosts breadth first, a
+// few pages at a time, while obeying robots.txt and waiting between
+// requests to each host.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/httpclient"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cccrawl [flags] url...")
+	fmt.Fprintln(out, "Crawl the sites of the urls and print the pages found on them.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cccrawl"
+
+	depth := flag.Int("depth", 3, "follow links at most `N` away from the urls given")
+	maxPages := flag.Int("max-pages", 100, "visit at most `N` pages")
+	delay := flag.Duration("delay", time.Second, "wait at least this long between requests to a host")
+	workers := flag.Int("workers", 4, "fetch up to `N` pages at once")
+	timeout := flag.Duration("timeout", 10*time.Second, "give up on a page after this `duration`")
+	format := flag.String("format", "text", "print the site map as `text` or as sitemaps.org xml")
+	insecure := flag.Bool("k", false, "do not verify servers' TLS certificates")
+	verbose := flag.Bool("v", false, "log each page to standard error as it is fetched")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		cli.Exit(cli.Usagef("expected at least one URL"))
+	}
+	if *depth < 0 || *delay < 0 {
+		cli.Exit(cli.Usagef("-depth and -delay must not be negative"))
+	}
+	if *maxPages < 1 || *workers < 1 {
+		cli.Exit(cli.Usagef("-max-pages and -workers must be positive"))
+	}
+	if err := cli.Choice(*format, "text", "xml"); err != nil {
+		cli.Exit(cli.Usagef("invalid -format %q: %v", *format, err))
+	}
+
+	client := httpclient.New(httpclient.Options{Insecure: *insecure})
+	client.Timeout = *timeout
+
+	c := &crawler{
+		client:   client,
+		maxDepth: *depth,
+		maxPages: *maxPages,
+		delay:    *delay,
+		workers:  *workers,
+	}
+	if *verbose {
+		c.log = os.Stderr
+	}
+
+	var seeds []string
+	for _, arg := range flag.Args() {
+		if !strings.Contains(arg, "://") {
+			arg = "http://" + arg
+		}
+		seeds = append(seeds, arg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// An interrupted crawl still prints what it found
+	pages, err := c.crawl(ctx, seeds)
+	interrupted := errors.Is(err, context.Canceled)
+	if err != nil && !interrupted {
+		cli.Exit(err)
+	}
+
+	write := writeText
+	if *format == "xml" {
+		write = writeXML
+	}
+	if err := write(os.Stdout, pages); err != nil {
+		cli.Exit(err)
+	}
+
+	if interrupted {
+		cli.Warn("interrupted after %d pages", len(pages))
+		os.Exit(cli.ExitFailure)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRobotsSize is how much of a robots.txt file is read, the least RFC
+// 9309 asks crawlers to handle.
+const maxRobotsSize = 500 * 1024
+
+// rule allows or disallows the paths matching a pattern, in which *
+// matches any characters and a final $ anchors the end.
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// robots are the rules of a robots.txt file that apply to this crawler.
+type robots struct {
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// allowAll and disallowAll stand in for a robots.txt that could not be
+// used: a missing file allows everything, and a server error disallows
+// everything until it can be read, as RFC 9309 says.
+var (
+	allowAll    = &robots{}
+	disallowAll = &robots{rules: []rule{{allow: false, pattern: "/"}}}
+)
+
+// group is the rules for a set of user agents.
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// parseRobots reads a robots.txt file and keeps the rules of the groups
+// naming agent, the product token of this crawler, or of the groups for
+// * if none do. Lines it does not understand are ignored.
+func parseRobots(input io.Reader, agent string) (*robots, error) {
+
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(input, maxRobotsSize))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share a group
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			continue
+
+		case "allow", "disallow":
+			// An empty disallow allows everything, like having no rule
+			if current != nil && value != "" {
+				current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+			}
+
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && current != nil && seconds >= 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		inAgents = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Every group naming the agent applies, even one with no rules
+	r := &robots{}
+	for _, name := range []string{strings.ToLower(agent), "*"} {
+		matched := false
+		for _, g := range groups {
+			if slices.Contains(g.agents, name) {
+				r.rules = append(r.rules, g.rules...)
+				r.crawlDelay = max(r.crawlDelay, g.crawlDelay)
+				matched = true
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return r, nil
+}
+
+// allowed reports whether the crawler may fetch path, which includes any
+// query. The rule with the longest matching pattern decides, and an allow
+// wins a tie.
+func (r *robots) allowed(path string) bool {
+
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !matchPattern(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > length || n == length && rule.allow {
+			allow, length = rule.allow, n
+		}
+	}
+	return allow
+}
+
+// matchPattern reports whether path starts with a match for pattern.
+func matchPattern(pattern, path string) bool {
+
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	pieces := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, pieces[0]) {
+		return false
+	}
+	path = path[len(pieces[0]):]
+	pieces = pieces[1:]
+
+	// Each piece after a * matches at its first position, which leaves
+	// the most room for the pieces after it, except that the last piece
+	// of an anchored pattern must match at the end
+	for i, piece := range pieces {
+		if anchored && i == len(pieces)-1 {
+			return strings.HasSuffix(path, piece)
+		}
+		j := strings.Index(path, piece)
+		if j < 0 {
+			return false
+		}
+		path = path[j+len(piece):]
+	}
+	return !anchored || path == ""
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// writeText writes the site map as a line per page: its status, URL, and
+// where it redirects or why it could not be fetched.
+func writeText(out io.Writer, pages []*page) error {
+
+	for _, p := range pages {
+		status := "-"
+		if p.status != 0 {
+			status = strconv.Itoa(p.status)
+		}
+
+		var err error
+		switch {
+		case p.err != nil:
+			_, err = fmt.Fprintf(out, "%-3s %s (%v)\n", status, p.url, p.err)
+		case p.redirect != "":
+			_, err = fmt.Fprintf(out, "%-3s %s -> %s\n", status, p.url, p.redirect)
+		default:
+			_, err = fmt.Fprintf(out, "%-3s %s\n", status, p.url)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// urlset is a site map in the sitemaps.org XML format.
+type urlset struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// writeXML writes the pages that were fetched successfully as a
+// sitemaps.org site map, which search engines read.
+func writeXML(out io.Writer, pages []*page) error {
+
+	var set urlset
+	for _, p := range pages {
+		if p.err == nil && p.status >= 200 && p.status < 300 {
+			set.URLs = append(set.URLs, sitemapURL{Loc: p.url})
+		}
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/httpclient"
+)
+
+// options are what to request and how.
+type options struct {
+	url          string
+	method       string
+	headers      []string
+	data         string
+	follow       bool
+	maxRedirects int
+	timeout      time.Duration
+	include      bool
+	verbose      bool
+	fail         bool
+	insecure     bool
+}
+
+// errHTTP is a response with an error status, which is a failure with -f.
+type errHTTP struct {
+	status string
+}
+
+func (e *errHTTP) Error() string {
+	return "the requested URL returned error: " + e.status
+}
+
+// fetch makes the request opts describe and writes the response body to
+// out, preceded by its status line and header with include. The verbose
+// trace of each request and response, including those of redirects, goes
+// to trace.
+func fetch(ctx context.Context, opts options, stdin io.Reader, out, trace io.Writer) error {
+
+	req, err := newRequest(ctx, opts, stdin)
+	if err != nil {
+		return err
+	}
+
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	clientOpts := httpclient.Options{
+		Follow:       opts.follow,
+		MaxRedirects: opts.maxRedirects,
+		Insecure:     opts.insecure,
+	}
+	if opts.verbose {
+		clientOpts.Trace = trace
+	}
+	client := httpclient.New(clientOpts)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if opts.fail && resp.StatusCode >= 400 {
+		return &errHTTP{status: resp.Status}
+	}
+
+	if opts.include {
+		writeResponseHeader(out, resp)
+	}
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// newRequest builds the request, with the body from -d: the text given,
+// or the contents of a file named after an @, where @- is standard input.
+func newRequest(ctx context.Context, opts options, stdin io.Reader) (*http.Request, error) {
+
+	target := opts.url
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	method := opts.method
+	if method == "" {
+		method = http.MethodGet
+		if opts.data != "" {
+			method = http.MethodPost
+		}
+	}
+
+	var body io.Reader
+	if opts.data != "" {
+		data := []byte(opts.data)
+		if name, ok := strings.CutPrefix(opts.data, "@"); ok {
+			var err error
+			if name == cli.Stdin {
+				data, err = io.ReadAll(stdin)
+			} else {
+				data, err = os.ReadFile(name)
+			}
+			if err != nil {
+				return nil, &cli.FileError{File: name, Err: err}
+			}
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "ccurl/1.0")
+	req.Header.Set("Accept", "*/*")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	for _, header := range opts.headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, cli.Usagef("invalid header %q: must be name: value", header)
+		}
+		value = strings.TrimSpace(value)
+
+		// An empty value removes a header that would otherwise be sent
+		if value == "" {
+			req.Header.Del(name)
+		} else {
+			req.Header.Set(name, value)
+		}
+		if strings.EqualFold(name, "Host") {
+			req.Host = value
+		}
+	}
+
+	return req, nil
+}
+
+// writeResponseHeader writes the status line and header of resp as they
+// were received, for -i.
+func writeResponseHeader(out io.Writer, resp *http.Response) {
+	fmt.Fprintf(out, "%s %s\r\n", resp.Proto, resp.Status)
+	httpclient.WriteHeader(out, resp.Header, "", "\r\n")
+	fmt.Fprint(out, "\r\n")
+}
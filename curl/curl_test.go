@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Custom", r.Header.Get("X-Custom"))
+		w.Write(body)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetch(t *testing.T) {
+
+	server := newTestServer(t)
+
+	dataFile := filepath.Join(t.TempDir(), "body.json")
+	os.WriteFile(dataFile, []byte(`{"from":"file"}`), 0o644)
+
+	tests := []struct {
+		name    string
+		opts    options
+		stdin   string
+		want    string
+		wantErr bool
+	}{
+		{name: "get", opts: options{url: server.URL + "/echo"}, want: ""},
+		{name: "data", opts: options{url: server.URL + "/echo", data: "a=1"}, want: "a=1"},
+		{name: "data file", opts: options{url: server.URL + "/echo", data: "@" + dataFile}, want: `{"from":"file"}`},
+		{name: "data stdin", opts: options{url: server.URL + "/echo", data: "@-"}, stdin: "piped", want: "piped"},
+		{name: "not followed", opts: options{url: server.URL + "/redirect"}, want: "<a href=\"/echo\">Found</a>.\n\n"},
+		{name: "followed", opts: options{url: server.URL + "/redirect", follow: true, maxRedirects: 5, data: "x"}, want: ""},
+		{name: "too many", opts: options{url: server.URL + "/loop", follow: true, maxRedirects: 3}, wantErr: true},
+		{name: "error status", opts: options{url: server.URL + "/missing"}, want: "gone\n"},
+		{name: "fail", opts: options{url: server.URL + "/missing", fail: true}, wantErr: true},
+		{name: "bad header", opts: options{url: server.URL + "/echo", headers: []string{"nocolon"}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		var out, trace bytes.Buffer
+		err := fetch(context.Background(), test.opts, strings.NewReader(test.stdin), &out, &trace)
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: succeeded", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, out.String(), test.want)
+		}
+	}
+}
+
+func TestFetchHeaders(t *testing.T) {
+
+	server := newTestServer(t)
+
+	opts := options{
+		url:     server.URL + "/echo",
+		method:  "PUT",
+		headers: []string{"X-Custom: yes", "Content-Type: application/json"},
+		data:    "{}",
+		include: true,
+		verbose: true,
+	}
+
+	var out, trace bytes.Buffer
+	if err := fetch(context.Background(), opts, nil, &out, &trace); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"HTTP/1.1 200 OK\r\n", "X-Method: PUT\r\n", "X-Custom: yes\r\n", "X-Content-Type: application/json\r\n", "\r\n\r\n{}"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q does not contain %q", out.String(), want)
+		}
+	}
+	for _, want := range []string{"* Connected to", "> PUT /echo HTTP/1.1\n", "> X-Custom: yes\n", "< HTTP/1.1 200 OK\n", "< X-Method: PUT\n"} {
+		if !strings.Contains(trace.String(), want) {
+			t.Errorf("trace %q does not contain %q", trace.String(), want)
+		}
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+
+	server := newTestServer(t)
+
+	start := time.Now()
+	err := fetch(context.Background(), options{url: server.URL + "/slow", timeout: 50 * time.Millisecond}, nil, io.Discard, io.Discard)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timing out took %v", elapsed)
+	}
+}
@@ -0,0 +1,84 @@
+// Command ccurl transfers data from or to a URL over HTTP, like curl: it
+// sends requests with any method, headers and body, follows redirects,
+// and can trace the exchange.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"codechallenge/internal/cli"
+)
+
+// headerList collects the -H flags in the order given.
+type headerList []string
+
+func (h *headerList) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerList) Set(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccurl [flags] url")
+	fmt.Fprintln(out, "Request url and write the response body to standard output.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccurl"
+
+	var opts options
+	var headers headerList
+
+	flag.StringVar(&opts.method, "X", "", "use this request `method`, GET by default or POST with -d")
+	flag.Var(&headers, "H", "send this `header`, as name: value, or with no value to leave one out; may be repeated")
+	flag.StringVar(&opts.data, "d", "", "send this `data` as the request body, or the contents of a file as @file, or standard input as @-")
+	flag.BoolVar(&opts.follow, "L", false, "follow redirects")
+	flag.IntVar(&opts.maxRedirects, "max-redirs", 50, "follow at most `N` redirects with -L")
+	flag.DurationVar(&opts.timeout, "m", 0, "give up on the whole transfer after this `duration`")
+	output := flag.String("o", "", "write the body to `file` instead of standard output")
+	flag.BoolVar(&opts.include, "i", false, "write the response status line and header before the body")
+	flag.BoolVar(&opts.verbose, "v", false, "trace each request and response header to standard error")
+	flag.BoolVar(&opts.fail, "f", false, "fail, writing nothing, on an HTTP error status")
+	flag.BoolVar(&opts.insecure, "k", false, "do not verify the server's TLS certificate")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		cli.Exit(cli.Usagef("expected one URL"))
+	}
+	opts.url = flag.Arg(0)
+	opts.headers = headers
+
+	var out io.Writer = os.Stdout
+	if *output != "" && *output != cli.Stdin {
+		file, err := os.Create(*output)
+		if err != nil {
+			cli.Exit(&cli.FileError{File: *output, Err: err})
+		}
+		defer file.Close()
+		out = file
+	}
+
+	err := fetch(context.Background(), opts, os.Stdin, out, os.Stderr)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("operation timed out after %v", opts.timeout)
+	}
+	if err != nil {
+		cli.Exit(err)
+	}
+}
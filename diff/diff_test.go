@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// lcsLength is the length of the longest common subsequence of a and b,
+// by dynamic programming, which a shortest edit script must agree with.
+func lcsLength(a, b []int) int {
+
+	row := make([]int, len(b)+1)
+	for i := range a {
+		prev := 0
+		for j := range b {
+			saved := row[j+1]
+			if a[i] == b[j] {
+				row[j+1] = prev + 1
+			} else {
+				row[j+1] = max(row[j+1], row[j])
+			}
+			prev = saved
+		}
+	}
+	return row[len(b)]
+}
+
+// checkScript checks that ops turns a into b with as few edits as there
+// can be.
+func checkScript(t *testing.T, a, b []int, ops []op) {
+	t.Helper()
+
+	var got []int
+	edits := 0
+	x, y := 0, 0
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			if o.x != x || o.y != y || a[o.x] != b[o.y] {
+				t.Fatalf("%v to %v: bad equal op %+v at %d,%d", a, b, o, x, y)
+			}
+			got = append(got, a[x])
+			x++
+			y++
+		case opDelete:
+			if o.x != x {
+				t.Fatalf("%v to %v: bad delete op %+v at %d,%d", a, b, o, x, y)
+			}
+			x++
+			edits++
+		case opInsert:
+			if o.y != y {
+				t.Fatalf("%v to %v: bad insert op %+v at %d,%d", a, b, o, x, y)
+			}
+			got = append(got, b[y])
+			y++
+			edits++
+		}
+	}
+
+	if x != len(a) || y != len(b) {
+		t.Fatalf("%v to %v: script ends at %d,%d", a, b, x, y)
+	}
+	if !equalInts(got, b) {
+		t.Fatalf("%v to %v: script makes %v", a, b, got)
+	}
+	if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+		t.Errorf("%v to %v: %d edits, want %d", a, b, edits, want)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDiff(t *testing.T) {
+
+	tests := []struct {
+		a, b []int
+	}{
+		{nil, nil},
+		{nil, []int{1, 2}},
+		{[]int{1, 2}, nil},
+		{[]int{1, 2, 3}, []int{1, 2, 3}},
+
+		// ABCABBA to CBABAC, the example from Myers' paper
+		{[]int{1, 2, 3, 1, 2, 2, 1}, []int{3, 2, 1, 2, 1, 3}},
+		{[]int{1, 2, 3}, []int{4, 5, 6}},
+		{[]int{1, 2, 3, 4}, []int{1, 9, 3, 4}},
+	}
+	for _, tt := range tests {
+		checkScript(t, tt.a, tt.b, diff(tt.a, tt.b))
+	}
+
+	random := rand.New(rand.NewPCG(1, 2))
+	randomLines := func() []int {
+		lines := make([]int, random.IntN(30))
+		for i := range lines {
+			lines[i] = random.IntN(5)
+		}
+		return lines
+	}
+	for range 500 {
+		a, b := randomLines(), randomLines()
+		checkScript(t, a, b, diff(a, b))
+	}
+}
+
+func makeInput(text string) *input {
+	in := &input{file: file{newlineAtEOF: true}}
+	if text == "" {
+		return in
+	}
+	in.newlineAtEOF = strings.HasSuffix(text, "\n")
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		in.lines = append(in.lines, []byte(line))
+	}
+	return in
+}
+
+func TestUnified(t *testing.T) {
+
+	const alphabet = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		opts    options
+		want    string
+	}{
+		{
+			name: "change in the middle", a: alphabet, b: strings.Replace(alphabet, "e\n", "E\n", 1), context: 3,
+			want: "@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n",
+		},
+		{
+			name: "nearby changes share a hunk", a: alphabet, b: strings.NewReplacer("b\n", "B\n", "h\n", "H\n").Replace(alphabet), context: 3,
+			want: "@@ -1,10 +1,10 @@\n a\n-b\n+B\n c\n d\n e\n f\n g\n-h\n+H\n i\n j\n",
+		},
+		{
+			name: "distant changes do not", a: alphabet, b: strings.NewReplacer("a\n", "A\n", "j\n", "J\n").Replace(alphabet), context: 2,
+			want: "@@ -1,3 +1,3 @@\n-a\n+A\n b\n c\n@@ -8,3 +8,3 @@\n h\n i\n-j\n+J\n",
+		},
+		{
+			name: "no context", a: "a\nb\nc\n", b: "a\nc\nd\n", context: 0,
+			want: "@@ -2 +1,0 @@\n-b\n@@ -3,0 +3 @@\n+d\n",
+		},
+		{
+			name: "insert at the start", a: "b\n", b: "a\nb\n", context: 0,
+			want: "@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "from empty", a: "", b: "a\nb\n", context: 3,
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "newline added at the end", a: "a\nb", b: "a\nb\n", context: 3,
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "ignoring all whitespace", a: "a b\nc\n", b: "ab\nd\n", context: 3, opts: options{ignoreAllSpace: true},
+			want: "@@ -1,2 +1,2 @@\n a b\n-c\n+d\n",
+		},
+		{
+			name: "ignoring space changes", a: "a  b \n c\nd\n", b: "a\tb\nc\nd\n", context: 0, opts: options{ignoreSpaceChange: true},
+			want: "@@ -2 +2 @@\n- c\n+c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		a, b := makeInput(tt.a), makeInput(tt.b)
+		a.label, b.label = "a", "b"
+
+		var out bytes.Buffer
+		if err := writeUnified(&out, &a.file, &b.file, compare(a, b, tt.opts), tt.context); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "--- a\n+++ b\n" + tt.want
+		if out.String() != want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.name, out.String(), want)
+		}
+	}
+}
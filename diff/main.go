@@ -0,0 +1,235 @@
+// Command ccdiff compares two files line by line and prints the
+// differences in unified format, like diff -u. It finds a shortest set of
+// changes with Myers' algorithm, and can ignore changes in whitespace.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+	"codechallenge/internal/textutil"
+)
+
+// Exit statuses, as diff has them: 1 is not a failure but the answer that
+// the files differ, so trouble is 2.
+const (
+	exitSame    = 0
+	exitDiffer  = 1
+	exitTrouble = 2
+)
+
+// timeFormat is how the headers give modification times.
+const timeFormat = "2006-01-02 15:04:05.000000000 -0700"
+
+// options are how lines are compared.
+type options struct {
+	ignoreAllSpace    bool
+	ignoreSpaceChange bool
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccdiff [flags] file1 file2")
+	fmt.Fprintln(out, "Print the changes that turn file1 into file2, in unified format. Either file may")
+	fmt.Fprintln(out, "be - for standard input. Exit with 0 if the files are the same, 1 if they differ")
+	fmt.Fprintln(out, "and 2 on trouble.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccdiff"
+
+	flag.Bool("u", false, "print differences in unified format, which they always are")
+	context := flag.Int("U", 3, "print `N` lines of context around each change")
+	ignoreAllSpace := flag.Bool("w", false, "ignore all whitespace when comparing lines")
+	ignoreSpaceChange := flag.Bool("b", false, "ignore changes in the amount of whitespace, and whitespace at the end of lines")
+	brief := flag.Bool("q", false, "only say whether the files differ")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		usageExit(cli.Usagef("need two files to compare"))
+	}
+	if *context < 0 {
+		usageExit(cli.Usagef("-U must not be negative"))
+	}
+	if flag.Arg(0) == cli.Stdin && flag.Arg(1) == cli.Stdin {
+		usageExit(cli.Usagef("cannot compare standard input with itself"))
+	}
+
+	a, err := readFile(flag.Arg(0))
+	if err != nil {
+		trouble(err)
+	}
+	b, err := readFile(flag.Arg(1))
+	if err != nil {
+		trouble(err)
+	}
+
+	opts := options{ignoreAllSpace: *ignoreAllSpace, ignoreSpaceChange: *ignoreSpaceChange}
+	ops := compare(a, b, opts)
+
+	changed := false
+	for _, o := range ops {
+		if o.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		os.Exit(exitSame)
+	}
+
+	if *brief || a.binary || b.binary {
+		kind := "Files"
+		if a.binary || b.binary {
+			kind = "Binary files"
+		}
+		fmt.Printf("%s %s and %s differ\n", kind, a.name, b.name)
+		os.Exit(exitDiffer)
+	}
+
+	if err := writeUnified(os.Stdout, &a.file, &b.file, ops, *context); err != nil {
+		trouble(err)
+	}
+	os.Exit(exitDiffer)
+}
+
+func usageExit(err error) {
+	cli.Report(err)
+	fmt.Fprintf(os.Stderr, "Try '%s -help' for more information.\n", cli.Name)
+	os.Exit(exitTrouble)
+}
+
+func trouble(err error) {
+	cli.Report(err)
+	os.Exit(exitTrouble)
+}
+
+// input is a file read for comparison.
+type input struct {
+	file
+
+	// binary is set when the file holds a NUL byte, and so is not text
+	binary bool
+}
+
+// readFile reads the lines of the file at path, and labels it with its
+// name and modification time for the header.
+func readFile(path string) (*input, error) {
+
+	f, closeFile, err := cli.Open(path)
+	if err != nil {
+		return nil, &cli.FileError{File: path, Err: err}
+	}
+	defer closeFile()
+
+	modTime := time.Now()
+	if info, err := f.Stat(); err == nil {
+		if info.IsDir() {
+			return nil, &cli.FileError{File: path, Err: errors.New("is a directory")}
+		}
+		if info.Mode().IsRegular() {
+			modTime = info.ModTime()
+		}
+	}
+
+	in := &input{file: file{name: path, label: path + "\t" + modTime.Format(timeFormat), newlineAtEOF: true}}
+
+	reader := textutil.NewLineReader(f, streamio.BufferSizeFor(f))
+	for {
+		line, newline, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &cli.FileError{File: path, Err: err}
+		}
+
+		in.lines = append(in.lines, line)
+		in.newlineAtEOF = newline
+		if bytes.IndexByte(line, 0) >= 0 {
+			in.binary = true
+		}
+	}
+
+	return in, nil
+}
+
+// compare works out the changes from a to b, comparing lines as opts
+// says.
+func compare(a, b *input, opts options) []op {
+
+	// Number the distinct lines, so the algorithm compares integers
+	keys := make(map[string]int)
+	keyLines := func(f *input) []int {
+		result := make([]int, len(f.lines))
+		for i, line := range f.lines {
+			key := string(opts.normalize(line))
+
+			// A last line without a newline differs from the same line
+			// with one
+			if i == len(f.lines)-1 && !f.newlineAtEOF {
+				key += "\x00"
+			}
+
+			id, ok := keys[key]
+			if !ok {
+				id = len(keys)
+				keys[key] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+
+	return diff(keyLines(a), keyLines(b))
+}
+
+// normalize returns the form of line that is compared.
+func (opts options) normalize(line []byte) []byte {
+
+	switch {
+	case opts.ignoreAllSpace:
+		return bytes.Join(bytes.Fields(line), nil)
+	case opts.ignoreSpaceChange:
+		return collapseSpace(line)
+	}
+	return line
+}
+
+// collapseSpace turns each run of whitespace in line into a single space,
+// and drops whitespace at the end. Whitespace at the start is kept, as a
+// space, since having some or none is more than a change in amount.
+func collapseSpace(line []byte) []byte {
+
+	result := make([]byte, 0, len(line))
+	inSpace := false
+	for _, c := range line {
+		if isSpace(c) {
+			inSpace = true
+			continue
+		}
+		if inSpace {
+			result = append(result, ' ')
+			inSpace = false
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
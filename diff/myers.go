@@ -0,0 +1,134 @@
+package main
+
+// opKind is what an edit does to a line.
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is one step of an edit script turning a into b: keeping line a[x] as
+// b[y], deleting a[x], or inserting b[y].
+type op struct {
+	kind opKind
+	x, y int
+}
+
+// diff returns a shortest edit script turning a into b, whose elements
+// are compared as they are, so callers map lines to keys first to compare
+// them loosely or quickly.
+//
+// It is Myers' O(ND) algorithm: for each number of edits d in turn, it
+// follows every path of d edits as far as matching lines take it, keeping
+// for each diagonal k = x - y only the furthest reaching one, until a path
+// reaches the end of both. The furthest points of each round are kept to
+// trace the path back. Lines the inputs start and end with in common are
+// set aside first, which keeps the common case of a few changes in a large
+// file cheap.
+func diff(a, b []int) []op {
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	for i := range prefix {
+		ops = append(ops, op{kind: opEqual, x: i, y: i})
+	}
+	for _, o := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		ops = append(ops, op{kind: o.kind, x: o.x + prefix, y: o.y + prefix})
+	}
+	for i := range suffix {
+		ops = append(ops, op{kind: opEqual, x: len(a) - suffix + i, y: len(b) - suffix + i})
+	}
+	return ops
+}
+
+func myers(a, b []int) []op {
+
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit == 0 {
+		return nil
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// trace[d] holds v for diagonals -d-1 to d+1 as round d found it
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+	panic("myers: no path found")
+}
+
+// backtrack follows the path that reached (n, m) back to the start, using
+// the furthest points of each round, and returns it in order.
+func backtrack(trace [][]int, n, m int) []op {
+
+	var ops []op
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: opEqual, x: x, y: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{kind: opInsert, x: x, y: prevY})
+			} else {
+				ops = append(ops, op{kind: opDelete, x: prevX, y: y})
+			}
+			x, y = prevX, prevY
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
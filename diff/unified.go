@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// hunk is a run of changes with the unchanged lines around them, as ops
+// from first up to but not including end.
+type hunk struct {
+	first, end int
+}
+
+// hunks groups the changes in ops into hunks with up to context unchanged
+// lines before and after each. Changes closer together than twice the
+// context share a hunk, so no line is printed twice.
+func hunks(ops []op, context int) []hunk {
+
+	var result []hunk
+	i := 0
+	for {
+		for i < len(ops) && ops[i].kind == opEqual {
+			i++
+		}
+		if i == len(ops) {
+			return result
+		}
+
+		// Take in the changes that follow, until a stretch of unchanged
+		// lines too long to be shared context or the end
+		end := i
+		for {
+			for end < len(ops) && ops[end].kind != opEqual {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == opEqual {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+
+		result = append(result, hunk{first: max(0, i-context), end: min(len(ops), end+context)})
+		i = end
+	}
+}
+
+// file is one side of a comparison.
+type file struct {
+	name  string
+	label string
+	lines [][]byte
+
+	// newlineAtEOF is false when the last line has no newline
+	newlineAtEOF bool
+}
+
+// writeUnified writes the differences ops describes between a and b in
+// unified format, with context unchanged lines around each change.
+func writeUnified(out io.Writer, a, b *file, ops []op, context int) error {
+
+	w := bufio.NewWriter(out)
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", a.label, b.label)
+
+	for _, h := range hunks(ops, context) {
+		span := ops[h.first:h.end]
+
+		// Count the lines of each side in the hunk, and find where each
+		// starts, which for a side with no lines is the line before
+		var aCount, bCount int
+		for _, o := range span {
+			if o.kind != opInsert {
+				aCount++
+			}
+			if o.kind != opDelete {
+				bCount++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(span[0].x, aCount), hunkRange(span[0].y, bCount))
+
+		for _, o := range span {
+			switch o.kind {
+			case opEqual:
+				writeLine(w, ' ', a, o.x)
+			case opDelete:
+				writeLine(w, '-', a, o.x)
+			case opInsert:
+				writeLine(w, '+', b, o.y)
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+// hunkRange formats the start and length of one side of a hunk, from the
+// 0-based index of its first line. The length is left out when it is 1,
+// and an empty side is placed after the line before it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func writeLine(w *bufio.Writer, prefix byte, f *file, i int) {
+	w.WriteByte(prefix)
+	w.Write(f.lines[i])
+	w.WriteByte('\n')
+	if i == len(f.lines)-1 && !f.newlineAtEOF {
+		w.WriteString("\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,99 @@
+// Command ccdns looks up DNS records. By default it resolves names itself,
+// starting from the root servers and following referrals, and with -server
+// it asks a recursive server instead, as a stub resolver does.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"codechallenge/dns/resolver"
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccdns [flags] name")
+	fmt.Fprintln(out, "Print the DNS records of name.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccdns"
+
+	typeName := flag.String("type", "A", "look up records of this `type`: A, AAAA, CNAME or NS")
+	server := flag.String("server", "", "ask the recursive server at `address`, such as 8.8.8.8 or [::1]:53, instead of resolving from the root")
+	trace := flag.Bool("trace", false, "print each server asked and its reply")
+	timeout := flag.Duration("timeout", 10*time.Second, "give up on the whole lookup after this long")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		cli.Exit(cli.Usagef("expected one name to look up"))
+	}
+	name := flag.Arg(0)
+
+	qtype, err := resolver.ParseType(*typeName)
+	if err != nil {
+		cli.Exit(cli.Usagef("%v", err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var records []resolver.Record
+	if *server != "" {
+		addrPort, parseErr := parseServer(*server)
+		if parseErr != nil {
+			cli.Exit(cli.Usagef("invalid -server %q: %v", *server, parseErr))
+		}
+		records, err = resolver.Lookup(ctx, addrPort, name, qtype)
+	} else {
+		r := &resolver.Resolver{}
+		if *trace {
+			r.Trace = printTrace
+		}
+		records, err = r.Resolve(ctx, name, qtype)
+	}
+
+	if err != nil {
+		cli.Report(err)
+		os.Exit(cli.ExitFailure)
+	}
+
+	for _, record := range records {
+		fmt.Println(record)
+	}
+}
+
+// parseServer parses an address with an optional port, 53 if not given.
+func parseServer(server string) (netip.AddrPort, error) {
+
+	if addr, err := netip.ParseAddr(strings.Trim(server, "[]")); err == nil {
+		return netip.AddrPortFrom(addr, 53), nil
+	}
+	return netip.ParseAddrPort(server)
+}
+
+// printTrace prints a reply received while resolving to standard error,
+// keeping standard output for the records found.
+func printTrace(server netip.Addr, question resolver.Question, reply *resolver.Message) {
+
+	fmt.Fprintf(os.Stderr, ";; %s %s from %s: %s, %d answers, %d authorities, %d additional\n",
+		question.Name, question.Type, server, reply.RCode, len(reply.Answers), len(reply.Authorities), len(reply.Additionals))
+
+	for _, section := range [][]resolver.Record{reply.Answers, reply.Authorities} {
+		for _, record := range section {
+			fmt.Fprintf(os.Stderr, ";;   %s\n", record)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Limits on the work one lookup can cause, so that misconfigured or
+// hostile zones cannot keep the resolver busy.
+const (
+	// maxReferrals is the most servers followed down for one name
+	maxReferrals = 16
+
+	// maxDepth is the most lookups nested inside one another, for CNAME
+	// targets and for the addresses of name servers without glue
+	maxDepth = 8
+)
+
+// Resolver resolves names itself, starting at the root servers and
+// following referrals down to a server that has the answer. The zero value
+// is ready to use.
+type Resolver struct {
+	// Roots are the servers to start from, RootServers if empty
+	Roots []netip.Addr
+
+	// Port is the port servers are asked on, 53 if zero
+	Port uint16
+
+	// Timeout limits each query, 3 seconds if zero
+	Timeout time.Duration
+
+	// Exchange sends a query and returns the reply, the package's
+	// Exchange if nil
+	Exchange func(ctx context.Context, addrPort netip.AddrPort, query *Message) (*Message, error)
+
+	// Trace, if set, is called with every reply received
+	Trace func(server netip.Addr, question Question, reply *Message)
+}
+
+// Resolve returns the records of name and type, preceded by any CNAME
+// records leading to them.
+func (r *Resolver) Resolve(ctx context.Context, name string, qtype Type) ([]Record, error) {
+	return r.resolve(ctx, Fqdn(name), qtype, 0)
+}
+
+func (r *Resolver) resolve(ctx context.Context, name string, qtype Type, depth int) ([]Record, error) {
+
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%s: lookups nested too deeply", name)
+	}
+
+	servers := r.Roots
+	if len(servers) == 0 {
+		servers = RootServers
+	}
+	zone := "."
+
+	for range maxReferrals {
+		reply, err := r.ask(ctx, servers, name, qtype)
+		if err != nil {
+			return nil, err
+		}
+		if err := replyError(reply, name); err != nil {
+			return nil, err
+		}
+
+		var records []Record
+		var cname *Record
+		for i, answer := range reply.Answers {
+			if !strings.EqualFold(answer.Name, name) {
+				continue
+			}
+			if answer.Type == qtype {
+				records = append(records, answer)
+			} else if answer.Type == TypeCNAME {
+				cname = &reply.Answers[i]
+			}
+		}
+
+		if len(records) > 0 {
+			return records, nil
+		}
+		if cname != nil {
+			// The target may be in another zone entirely, so it is looked
+			// up from the root rather than trusted from this server
+			rest, err := r.resolve(ctx, cname.Target, qtype, depth+1)
+			return append([]Record{*cname}, rest...), err
+		}
+
+		if reply.Authoritative {
+			return nil, fmt.Errorf("%s %s: %w", name, qtype, ErrNotFound)
+		}
+
+		// Otherwise the reply is a referral to the servers of a zone
+		// closer to name, or says there are no records
+		next, err := r.referral(ctx, reply, name, zone, depth)
+		if err != nil {
+			return nil, err
+		}
+		if next.zone == "" {
+			return nil, fmt.Errorf("%s %s: %w", name, qtype, ErrNotFound)
+		}
+		servers, zone = next.servers, next.zone
+	}
+
+	return nil, fmt.Errorf("%s: too many referrals", name)
+}
+
+// delegation is where a referral leads: the zone and its servers.
+type delegation struct {
+	zone    string
+	servers []netip.Addr
+}
+
+// referral returns the delegation in reply, or an empty one if reply has
+// none. A delegation must be to a zone below the current one that name is
+// in, so following referrals always makes progress.
+func (r *Resolver) referral(ctx context.Context, reply *Message, name, zone string, depth int) (delegation, error) {
+
+	var next delegation
+	var nameServers []string
+
+	for _, authority := range reply.Authorities {
+		if authority.Type != TypeNS {
+			continue
+		}
+		if next.zone == "" {
+			next.zone = authority.Name
+		}
+		if strings.EqualFold(authority.Name, next.zone) {
+			nameServers = append(nameServers, authority.Target)
+		}
+	}
+
+	if next.zone == "" {
+		return delegation{}, nil
+	}
+	if !inZone(name, next.zone) || !inZone(next.zone, zone) || strings.EqualFold(next.zone, zone) {
+		return delegation{}, fmt.Errorf("%s: bad referral from %s to %s", name, zone, next.zone)
+	}
+
+	// Glue records give the addresses of name servers inside the zone
+	for _, additional := range reply.Additionals {
+		if additional.Type != TypeA {
+			continue
+		}
+		for _, ns := range nameServers {
+			if strings.EqualFold(additional.Name, ns) {
+				next.servers = append(next.servers, additional.Addr)
+			}
+		}
+	}
+
+	// Without glue, the name servers' own addresses have to be looked up,
+	// and the first that can be is enough
+	var lookupErr error
+	for _, ns := range nameServers {
+		if len(next.servers) > 0 {
+			break
+		}
+
+		records, err := r.resolve(ctx, ns, TypeA, depth+1)
+		if err != nil {
+			lookupErr = err
+			continue
+		}
+		for _, record := range records {
+			if record.Type == TypeA {
+				next.servers = append(next.servers, record.Addr)
+			}
+		}
+	}
+
+	if len(next.servers) == 0 {
+		err := fmt.Errorf("%s: no address for any server of %s", name, next.zone)
+		if lookupErr != nil {
+			err = fmt.Errorf("%w: %w", err, lookupErr)
+		}
+		return delegation{}, err
+	}
+	return next, nil
+}
+
+// ask sends the query to each of servers in turn until one replies.
+func (r *Resolver) ask(ctx context.Context, servers []netip.Addr, name string, qtype Type) (*Message, error) {
+
+	exchange := r.Exchange
+	if exchange == nil {
+		exchange = Exchange
+	}
+	port := r.Port
+	if port == 0 {
+		port = 53
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 3 * time.Second
+	}
+
+	var errs []error
+	for _, server := range servers {
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		reply, err := exchange(queryCtx, netip.AddrPortFrom(server, port), NewQuery(name, qtype, false))
+		cancel()
+
+		if err != nil {
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if r.Trace != nil {
+			r.Trace(server, Question{Name: name, Type: qtype}, reply)
+		}
+		return reply, nil
+	}
+
+	return nil, fmt.Errorf("%s: no server answered: %w", name, errors.Join(errs...))
+}
+
+// inZone reports whether name is zone or below it.
+func inZone(name, zone string) bool {
+	name, zone = strings.ToLower(name), strings.ToLower(zone)
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
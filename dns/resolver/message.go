@@ -0,0 +1,376 @@
+package resolver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Type is a record type.
+type Type uint16
+
+// The record types the resolver understands. Records of other types are
+// parsed with their data left raw.
+const (
+	TypeA     Type = 1
+	TypeNS    Type = 2
+	TypeCNAME Type = 5
+	TypeAAAA  Type = 28
+)
+
+var typeNames = map[Type]string{TypeA: "A", TypeNS: "NS", TypeCNAME: "CNAME", TypeAAAA: "AAAA"}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", uint16(t))
+}
+
+// ParseType returns the type named name, such as "AAAA".
+func ParseType(name string) (Type, error) {
+	for t, typeName := range typeNames {
+		if strings.EqualFold(name, typeName) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown record type %q", name)
+}
+
+// ClassINET is the Internet class, the only one in use.
+const ClassINET uint16 = 1
+
+// RCode is the response code of a reply.
+type RCode uint8
+
+// Response codes
+const (
+	RCodeSuccess        RCode = 0
+	RCodeFormatError    RCode = 1
+	RCodeServerFailure  RCode = 2
+	RCodeNameError      RCode = 3
+	RCodeNotImplemented RCode = 4
+	RCodeRefused        RCode = 5
+)
+
+var rcodeNames = []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED"}
+
+func (r RCode) String() string {
+	if int(r) < len(rcodeNames) {
+		return rcodeNames[r]
+	}
+	return fmt.Sprintf("RCODE%d", uint8(r))
+}
+
+// Header is the fixed part at the start of a message.
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	RCode              RCode
+}
+
+// Question is what a query asks for.
+type Question struct {
+	Name string
+	Type Type
+}
+
+// Record is a resource record. Addr holds the address of an A or AAAA
+// record and Target the name in an NS or CNAME record; the data of other
+// types is left in Data.
+type Record struct {
+	Name   string
+	Type   Type
+	TTL    uint32
+	Addr   netip.Addr
+	Target string
+	Data   []byte
+}
+
+func (r Record) String() string {
+
+	var value string
+	switch r.Type {
+	case TypeA, TypeAAAA:
+		value = r.Addr.String()
+	case TypeNS, TypeCNAME:
+		value = r.Target
+	default:
+		value = fmt.Sprintf("\\# %d %x", len(r.Data), r.Data)
+	}
+	return fmt.Sprintf("%s\t%d\tIN\t%s\t%s", r.Name, r.TTL, r.Type, value)
+}
+
+// Message is a DNS query or reply.
+type Message struct {
+	Header
+	Questions   []Question
+	Answers     []Record
+	Authorities []Record
+	Additionals []Record
+}
+
+// Pack encodes m in the wire format. Names are written in full, without
+// compression.
+func (m *Message) Pack() ([]byte, error) {
+
+	var flags uint16
+	for _, flag := range []struct {
+		set bool
+		bit uint16
+	}{
+		{m.Response, 1 << 15},
+		{m.Authoritative, 1 << 10},
+		{m.Truncated, 1 << 9},
+		{m.RecursionDesired, 1 << 8},
+		{m.RecursionAvailable, 1 << 7},
+	} {
+		if flag.set {
+			flags |= flag.bit
+		}
+	}
+	flags |= uint16(m.RCode & 0xf)
+
+	b := make([]byte, 0, 512)
+	b = binary.BigEndian.AppendUint16(b, m.ID)
+	b = binary.BigEndian.AppendUint16(b, flags)
+	for _, count := range []int{len(m.Questions), len(m.Answers), len(m.Authorities), len(m.Additionals)} {
+		b = binary.BigEndian.AppendUint16(b, uint16(count))
+	}
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Type))
+		b = binary.BigEndian.AppendUint16(b, ClassINET)
+	}
+
+	for _, section := range [][]Record{m.Answers, m.Authorities, m.Additionals} {
+		for _, r := range section {
+			if b, err = appendRecord(b, r); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return b, nil
+}
+
+func appendRecord(b []byte, r Record) ([]byte, error) {
+
+	b, err := appendName(b, r.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(r.Type))
+	b = binary.BigEndian.AppendUint16(b, ClassINET)
+	b = binary.BigEndian.AppendUint32(b, r.TTL)
+
+	var data []byte
+	switch r.Type {
+	case TypeA:
+		address := r.Addr.As4()
+		data = address[:]
+	case TypeAAAA:
+		address := r.Addr.As16()
+		data = address[:]
+	case TypeNS, TypeCNAME:
+		if data, err = appendName(nil, r.Target); err != nil {
+			return nil, err
+		}
+	default:
+		data = r.Data
+	}
+
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...), nil
+}
+
+// appendName appends name as a sequence of length-prefixed labels ending
+// with the empty root label.
+func appendName(b []byte, name string) ([]byte, error) {
+
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > 253 {
+		return nil, fmt.Errorf("name %q is too long", name)
+	}
+
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("name %q has an empty or too long label", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+var errShort = errors.New("message is truncated")
+
+// Parse decodes a message in the wire format.
+func Parse(b []byte) (*Message, error) {
+
+	if len(b) < 12 {
+		return nil, errShort
+	}
+
+	flags := binary.BigEndian.Uint16(b[2:])
+	m := &Message{Header: Header{
+		ID:                 binary.BigEndian.Uint16(b),
+		Response:           flags&(1<<15) != 0,
+		Authoritative:      flags&(1<<10) != 0,
+		Truncated:          flags&(1<<9) != 0,
+		RecursionDesired:   flags&(1<<8) != 0,
+		RecursionAvailable: flags&(1<<7) != 0,
+		RCode:              RCode(flags & 0xf),
+	}}
+
+	counts := [4]int{}
+	for i := range counts {
+		counts[i] = int(binary.BigEndian.Uint16(b[4+2*i:]))
+	}
+
+	p := parser{msg: b, offset: 12}
+
+	for range counts[0] {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		fixed, err := p.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+		m.Questions = append(m.Questions, Question{Name: name, Type: Type(binary.BigEndian.Uint16(fixed))})
+	}
+
+	for i, section := range []*[]Record{&m.Answers, &m.Authorities, &m.Additionals} {
+		for range counts[i+1] {
+			r, err := p.record()
+			if err != nil {
+				return nil, err
+			}
+			*section = append(*section, r)
+		}
+	}
+
+	return m, nil
+}
+
+// parser reads the parts of a message in order.
+type parser struct {
+	msg    []byte
+	offset int
+}
+
+func (p *parser) bytes(n int) ([]byte, error) {
+	if p.offset+n > len(p.msg) {
+		return nil, errShort
+	}
+	b := p.msg[p.offset : p.offset+n]
+	p.offset += n
+	return b, nil
+}
+
+func (p *parser) record() (Record, error) {
+
+	name, err := p.name()
+	if err != nil {
+		return Record{}, err
+	}
+	fixed, err := p.bytes(10)
+	if err != nil {
+		return Record{}, err
+	}
+
+	r := Record{
+		Name: name,
+		Type: Type(binary.BigEndian.Uint16(fixed)),
+		TTL:  binary.BigEndian.Uint32(fixed[4:]),
+	}
+
+	length := int(binary.BigEndian.Uint16(fixed[8:]))
+	start := p.offset
+	data, err := p.bytes(length)
+	if err != nil {
+		return Record{}, err
+	}
+
+	switch r.Type {
+	case TypeA, TypeAAAA:
+		addr, ok := netip.AddrFromSlice(data)
+		if !ok || r.Type == TypeA && length != 4 || r.Type == TypeAAAA && length != 16 {
+			return Record{}, fmt.Errorf("%s record for %s has %d bytes of address", r.Type, name, length)
+		}
+		r.Addr = addr
+	case TypeNS, TypeCNAME:
+		// The name may point back into the rest of the message
+		target := parser{msg: p.msg, offset: start}
+		if r.Target, err = target.name(); err != nil {
+			return Record{}, err
+		}
+	default:
+		r.Data = data
+	}
+
+	return r, nil
+}
+
+// name reads a name, following compression pointers to names earlier in
+// the message. Names are returned fully qualified, ending with a dot.
+func (p *parser) name() (string, error) {
+
+	var labels []string
+	offset := p.offset
+
+	// Pointers must go backwards, which rules out loops, but a limit on
+	// them is simpler to trust
+	jumped := false
+	for jumps := 0; ; {
+		if offset >= len(p.msg) {
+			return "", errShort
+		}
+		length := int(p.msg[offset])
+
+		switch {
+		case length == 0:
+			if !jumped {
+				p.offset = offset + 1
+			}
+			return strings.Join(labels, ".") + ".", nil
+
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(p.msg) {
+				return "", errShort
+			}
+			if jumps++; jumps > 64 {
+				return "", errors.New("too many compression pointers")
+			}
+			if !jumped {
+				p.offset = offset + 2
+				jumped = true
+			}
+			offset = int(binary.BigEndian.Uint16(p.msg[offset:]) & 0x3fff)
+
+		case length > 63:
+			return "", fmt.Errorf("invalid label length %d", length)
+
+		default:
+			if offset+1+length > len(p.msg) {
+				return "", errShort
+			}
+			labels = append(labels, string(p.msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
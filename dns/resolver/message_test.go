@@ -0,0 +1,80 @@
+package resolver
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestPackParse(t *testing.T) {
+
+	m := &Message{
+		Header:    Header{ID: 0xbeef, Response: true, Authoritative: true, RecursionDesired: true, RCode: RCodeSuccess},
+		Questions: []Question{{Name: "www.example.com.", Type: TypeAAAA}},
+		Answers: []Record{
+			{Name: "www.example.com.", Type: TypeCNAME, TTL: 60, Target: "example.com."},
+			{Name: "example.com.", Type: TypeAAAA, TTL: 300, Addr: netip.MustParseAddr("2001:db8::1")},
+		},
+		Authorities: []Record{{Name: "example.com.", Type: TypeNS, TTL: 3600, Target: "ns.example.com."}},
+		Additionals: []Record{
+			{Name: "ns.example.com.", Type: TypeA, TTL: 3600, Addr: netip.MustParseAddr("192.0.2.1")},
+			{Name: "example.com.", Type: 16, TTL: 1, Data: []byte("\x05hello")},
+		},
+	}
+
+	packed, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, m) {
+		t.Errorf("round trip changed the message:\ngot  %+v\nwant %+v", parsed, m)
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+
+	// A reply with one question and one CNAME answer whose owner and
+	// target both point back into the question's name
+	msg := []byte{
+		0, 1, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0,
+		// 12: www.example.com. A IN
+		3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1,
+		// www.example.com. CNAME, TTL 5, pointing at example.com. at 16
+		0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 5, 0, 2, 0xc0, 16,
+	}
+
+	m, err := Parse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Record{Name: "www.example.com.", Type: TypeCNAME, TTL: 5, Target: "example.com."}
+	if len(m.Answers) != 1 || !reflect.DeepEqual(m.Answers[0], want) {
+		t.Errorf("got answers %+v, want %+v", m.Answers, want)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+
+	header := []byte{0, 1, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}
+
+	tests := map[string][]byte{
+		"short header":  header[:5],
+		"short name":    append(header, 3, 'w'),
+		"pointer loop":  append(header, 0xc0, 12, 0, 1, 0, 1),
+		"bad label":     append(header, 0x40, 0, 0, 1, 0, 1),
+		"short qtype":   append(header, 0, 0),
+		"missing count": append(append([]byte{}, header[:7]...), 2, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1),
+	}
+
+	for name, msg := range tests {
+		if _, err := Parse(msg); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package resolver builds and parses DNS messages in the wire format and
+// resolves names, either by asking a recursive server, as a stub resolver
+// does, or by following referrals down from the root servers itself.
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// RootServers are the addresses of the root name servers a through m, as
+// given in the root hints file.
+var RootServers = []netip.Addr{
+	netip.MustParseAddr("198.41.0.4"),
+	netip.MustParseAddr("170.247.170.2"),
+	netip.MustParseAddr("192.33.4.12"),
+	netip.MustParseAddr("199.7.91.13"),
+	netip.MustParseAddr("192.203.230.10"),
+	netip.MustParseAddr("192.5.5.241"),
+	netip.MustParseAddr("192.112.36.4"),
+	netip.MustParseAddr("198.97.190.53"),
+	netip.MustParseAddr("192.36.148.17"),
+	netip.MustParseAddr("192.58.128.30"),
+	netip.MustParseAddr("193.0.14.129"),
+	netip.MustParseAddr("199.7.83.42"),
+	netip.MustParseAddr("202.12.27.33"),
+}
+
+// ErrNotFound is returned for a name that does not exist, or that has no
+// records of the type asked for.
+var ErrNotFound = errors.New("no such name or record")
+
+// NewQuery returns a query for name and type, with a random ID.
+func NewQuery(name string, qtype Type, recursionDesired bool) *Message {
+	return &Message{
+		Header:    Header{ID: uint16(rand.Uint32()), RecursionDesired: recursionDesired},
+		Questions: []Question{{Name: Fqdn(name), Type: qtype}},
+	}
+}
+
+// Fqdn returns name fully qualified, ending with a dot.
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// Exchange sends query to the server at addrPort over UDP and returns its
+// reply, asking again over TCP if the reply was too large for UDP.
+func Exchange(ctx context.Context, addrPort netip.AddrPort, query *Message) (*Message, error) {
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := exchange(ctx, "udp", addrPort, packed, query.ID)
+	if err == nil && reply.Truncated {
+		reply, err = exchange(ctx, "tcp", addrPort, packed, query.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("asking %s: %w", addrPort, err)
+	}
+	return reply, nil
+}
+
+func exchange(ctx context.Context, network string, addrPort netip.AddrPort, packed []byte, id uint16) (*Message, error) {
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addrPort.String())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	// Over TCP, each message is preceded by its length
+	stream := network == "tcp"
+	if stream {
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		packed = append(framed, packed...)
+	}
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+
+	for {
+		var buffer []byte
+		if stream {
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return nil, err
+			}
+			buffer = make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, buffer); err != nil {
+				return nil, err
+			}
+		} else {
+			buffer = make([]byte, 65535)
+			n, err := conn.Read(buffer)
+			if err != nil {
+				return nil, err
+			}
+			buffer = buffer[:n]
+		}
+
+		reply, err := Parse(buffer)
+		if err != nil {
+			return nil, err
+		}
+
+		// A stray reply to some other query is skipped
+		if reply.ID == id && reply.Response {
+			return reply, nil
+		}
+		if stream {
+			return nil, errors.New("reply does not match the query")
+		}
+	}
+}
+
+// Lookup asks the recursive server at addrPort for the records of name and
+// type, as a stub resolver does, and returns the answers, which start with
+// any CNAME records leading from name to the records asked for.
+func Lookup(ctx context.Context, addrPort netip.AddrPort, name string, qtype Type) ([]Record, error) {
+
+	reply, err := Exchange(ctx, addrPort, NewQuery(name, qtype, true))
+	if err != nil {
+		return nil, err
+	}
+	if err := replyError(reply, name); err != nil {
+		return nil, err
+	}
+	if len(reply.Answers) == 0 {
+		return nil, fmt.Errorf("%s: %w", Fqdn(name), ErrNotFound)
+	}
+	return reply.Answers, nil
+}
+
+// replyError returns the error a reply's response code stands for, if any.
+func replyError(reply *Message, name string) error {
+	switch reply.RCode {
+	case RCodeSuccess:
+		return nil
+	case RCodeNameError:
+		return fmt.Errorf("%s: %w", Fqdn(name), ErrNotFound)
+	default:
+		return fmt.Errorf("%s: server replied %s", Fqdn(name), reply.RCode)
+	}
+}
@@ -0,0 +1,182 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// zone is a fake name server, answering from its records: exact matches
+// as answers, and otherwise the NS records of the closest zone it
+// delegates as a referral, with glue.
+type zone struct {
+	records []Record
+}
+
+func (z zone) answer(q Question) *Message {
+
+	reply := &Message{Header: Header{Response: true}, Questions: []Question{q}}
+
+	for _, r := range z.records {
+		if r.Name == q.Name && (r.Type == q.Type || r.Type == TypeCNAME) {
+			reply.Answers = append(reply.Answers, r)
+		}
+	}
+	if len(reply.Answers) > 0 {
+		reply.Authoritative = true
+		return reply
+	}
+
+	for _, r := range z.records {
+		if r.Type == TypeNS && inZone(q.Name, r.Name) {
+			reply.Authorities = append(reply.Authorities, r)
+			for _, glue := range z.records {
+				if glue.Name == r.Target && glue.Type == TypeA {
+					reply.Additionals = append(reply.Additionals, glue)
+				}
+			}
+		}
+	}
+	if len(reply.Authorities) == 0 {
+		reply.Authoritative = true
+		reply.RCode = RCodeNameError
+	}
+	return reply
+}
+
+func a(name, addr string) Record {
+	return Record{Name: name, Type: TypeA, TTL: 60, Addr: netip.MustParseAddr(addr)}
+}
+
+func ns(name, target string) Record {
+	return Record{Name: name, Type: TypeNS, TTL: 60, Target: target}
+}
+
+func TestResolve(t *testing.T) {
+
+	servers := map[netip.Addr]zone{
+		// The root delegates com. with glue and net. with glue
+		netip.MustParseAddr("10.0.0.1"): {[]Record{
+			ns("com.", "a.gtld."), a("a.gtld.", "10.0.0.2"),
+			ns("net.", "b.gtld."), a("b.gtld.", "10.0.0.3"),
+		}},
+		// com. delegates example.com. to a server in net., without glue
+		netip.MustParseAddr("10.0.0.2"): {[]Record{ns("example.com.", "ns.hosting.net.")}},
+		netip.MustParseAddr("10.0.0.3"): {[]Record{a("ns.hosting.net.", "10.0.0.4")}},
+		netip.MustParseAddr("10.0.0.4"): {[]Record{
+			a("example.com.", "192.0.2.10"),
+			{Name: "www.example.com.", Type: TypeCNAME, TTL: 60, Target: "example.com."},
+		}},
+	}
+
+	var asked []string
+	r := &Resolver{
+		Roots: []netip.Addr{netip.MustParseAddr("10.0.0.1")},
+		Exchange: func(ctx context.Context, addrPort netip.AddrPort, query *Message) (*Message, error) {
+			server, ok := servers[addrPort.Addr()]
+			if !ok {
+				return nil, errors.New("unreachable")
+			}
+			asked = append(asked, addrPort.Addr().String())
+			return server.answer(query.Questions[0]), nil
+		},
+	}
+
+	records, err := r.Resolve(context.Background(), "www.example.com", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, record := range records {
+		got = append(got, record.String())
+	}
+	want := []string{
+		"www.example.com.\t60\tIN\tCNAME\texample.com.",
+		"example.com.\t60\tIN\tA\t192.0.2.10",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got records\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(asked) == 0 || asked[0] != "10.0.0.1" {
+		t.Errorf("servers asked %v do not start at the root", asked)
+	}
+
+	if _, err := r.Resolve(context.Background(), "missing.example.com", TypeA); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing name gave %v, want ErrNotFound", err)
+	}
+
+	// A server that refers to its own zone again is not followed round
+	servers[netip.MustParseAddr("10.0.0.2")] = zone{[]Record{ns("com.", "a.gtld."), a("a.gtld.", "10.0.0.2")}}
+	if _, err := r.Resolve(context.Background(), "example.com", TypeA); err == nil || !strings.Contains(err.Error(), "bad referral") {
+		t.Errorf("referral loop gave %v", err)
+	}
+}
+
+// TestLookupTruncated checks that a stub lookup whose UDP reply is
+// truncated asks again over TCP.
+func TestLookupTruncated(t *testing.T) {
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	port := udp.LocalAddr().(*net.UDPAddr).Port
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Skipf("TCP port %d is taken: %v", port, err)
+	}
+	defer tcp.Close()
+
+	full := func(query *Message, truncated bool) []byte {
+		reply := &Message{Header: Header{ID: query.ID, Response: true, Truncated: truncated}, Questions: query.Questions}
+		if !truncated {
+			reply.Answers = []Record{a(query.Questions[0].Name, "192.0.2.20")}
+		}
+		packed, _ := reply.Pack()
+		return packed
+	}
+
+	go func() {
+		buffer := make([]byte, 512)
+		n, from, err := udp.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		query, _ := Parse(buffer[:n])
+		udp.WriteTo(full(query, true), from)
+	}()
+
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var length [2]byte
+		io.ReadFull(conn, length[:])
+		buffer := make([]byte, binary.BigEndian.Uint16(length[:]))
+		io.ReadFull(conn, buffer)
+
+		query, _ := Parse(buffer)
+		reply := full(query, false)
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...))
+	}()
+
+	addrPort := netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(port))
+	records, err := Lookup(context.Background(), addrPort, "example.com", TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Addr.String() != "192.0.2.20" {
+		t.Errorf("got %v", records)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeObject prints an object for reading: a blob, commit or tag as it
+// is, and a tree as a listing of its entries like git ls-tree.
+func writeObject(out io.Writer, obj object) error {
+
+	if obj.kind != typeTree {
+		_, err := out.Write(obj.data)
+		return err
+	}
+
+	entries, err := parseTree(obj.data)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%06o %s %s\t%s\n", e.mode, e.kind(), e.id, e.name)
+	}
+	_, err = io.WriteString(out, b.String())
+	return err
+}
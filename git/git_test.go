@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRepo builds a repository in a temporary directory by writing loose
+// objects and references directly.
+type testRepo struct {
+	t    *testing.T
+	root string
+	repo *repository
+
+	// clock is the commit time of the next commit
+	clock int64
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	for _, dir := range []string{"objects", "refs/heads", "refs/tags"} {
+		if err := os.MkdirAll(filepath.Join(gitDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr := &testRepo{t: t, root: root, repo: openRepository(gitDir), clock: 1700000000}
+	tr.write("HEAD", "ref: refs/heads/main\n")
+	return tr
+}
+
+// write writes a file under the git directory.
+func (tr *testRepo) write(name, content string) {
+	tr.t.Helper()
+	if err := os.WriteFile(filepath.Join(tr.repo.dir, name), []byte(content), 0o644); err != nil {
+		tr.t.Fatal(err)
+	}
+}
+
+// store writes a loose object with the header given, which tests may get
+// wrong on purpose, and returns the name it is stored under.
+func (tr *testRepo) store(header string, data []byte, name id) id {
+
+	tr.t.Helper()
+	var buf bytes.Buffer
+	z := zlib.NewWriter(&buf)
+	z.Write([]byte(header))
+	z.Write(data)
+	z.Close()
+
+	path := loosePath(tr.repo.objects, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tr.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o444); err != nil {
+		tr.t.Fatal(err)
+	}
+	return name
+}
+
+func (tr *testRepo) object(kind string, data []byte) id {
+	return tr.store(fmt.Sprintf("%s %d\x00", kind, len(data)), data, hashObject(kind, data))
+}
+
+func (tr *testRepo) tree(entries ...treeEntry) id {
+
+	var data []byte
+	for _, e := range entries {
+		data = fmt.Appendf(data, "%o %s\x00", e.mode, e.name)
+		data = append(data, e.id[:]...)
+	}
+	return tr.object(typeTree, data)
+}
+
+func (tr *testRepo) commit(message string, parents ...id) id {
+
+	tr.clock += 60
+	blob := tr.object(typeBlob, []byte(message+"\n"))
+	tree := tr.tree(treeEntry{mode: 0o100644, name: "file", id: blob})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "tree %s\n", tree)
+	for _, p := range parents {
+		fmt.Fprintf(&b, "parent %s\n", p)
+	}
+	fmt.Fprintf(&b, "author A U Thor <author@example.com> %d +0100\n", tr.clock)
+	fmt.Fprintf(&b, "committer C O Mitter <committer@example.com> %d +0100\n", tr.clock)
+	fmt.Fprintf(&b, "\n%s\n", message)
+	return tr.object(typeCommit, []byte(b.String()))
+}
+
+func TestParseTree(t *testing.T) {
+
+	a, b := hashObject(typeBlob, []byte("a")), hashObject(typeTree, nil)
+	data := append([]byte("100644 a.txt\x00"), a[:]...)
+	data = append(data, "40000 dir\x00"...)
+	data = append(data, b[:]...)
+
+	entries, err := parseTree(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []treeEntry{{0o100644, "a.txt", a}, {modeTree, "dir", b}}
+	if len(entries) != 2 || entries[0] != want[0] || entries[1] != want[1] {
+		t.Errorf("parseTree = %v, want %v", entries, want)
+	}
+	if entries[1].kind() != typeTree || entries[0].kind() != typeBlob {
+		t.Errorf("kinds = %s, %s", entries[0].kind(), entries[1].kind())
+	}
+
+	for _, bad := range []string{"100644", "100644 \x00", "10x644 a\x00" + string(a[:]), "100644 a\x00short"} {
+		if _, err := parseTree([]byte(bad)); err == nil {
+			t.Errorf("parseTree(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestParseCommit(t *testing.T) {
+
+	data := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"parent 0000000000000000000000000000000000000001\n" +
+		"parent 0000000000000000000000000000000000000002\n" +
+		"author A U Thor <author@example.com> 1700000000 -0500\n" +
+		"committer C O Mitter <committer@example.com> 1700000060 +0530\n" +
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+		" \n" +
+		" iQEz\n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"\n" +
+		"Subject line\n\nBody.\n"
+
+	c, err := parseCommit([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.tree.String() != "4b825dc642cb6eb9a060e54bf8d69288fbee4904" || len(c.parents) != 2 || c.parents[1][19] != 2 {
+		t.Errorf("tree %s, parents %v", c.tree, c.parents)
+	}
+	if c.author.name != "A U Thor" || c.author.email != "author@example.com" {
+		t.Errorf("author = %+v", c.author)
+	}
+	if got := c.committer.when.Format(time.RFC3339); got != "2023-11-15T03:44:20+05:30" {
+		t.Errorf("commit time = %s", got)
+	}
+	if c.subject() != "Subject line" {
+		t.Errorf("subject = %q", c.subject())
+	}
+
+	for _, bad := range []string{
+		"author A <a> 1 +0000\ncommitter A <a> 1 +0000\n\nno tree\n",
+		"tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\nauthor A <a> 1\ncommitter A <a> 1 +0000\n\nno zone\n",
+		"tree 4b825dc6\nauthor A <a> 1 +0000\ncommitter A <a> 1 +0000\n\nshort tree\n",
+	} {
+		if _, err := parseCommit([]byte(bad)); err == nil {
+			t.Errorf("parseCommit(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestReadLoose(t *testing.T) {
+
+	tr := newTestRepo(t)
+	good := tr.object(typeBlob, []byte("hello\n"))
+
+	// The name git gives the blob "hello\n"
+	if got := good.String(); got != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Fatalf("hashObject = %s", got)
+	}
+	obj, err := tr.repo.read(good)
+	if err != nil || obj.kind != typeBlob || string(obj.data) != "hello\n" {
+		t.Fatalf("read = %+v, %v", obj, err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		data   string
+		want   string
+	}{
+		{"short", "blob 10\x00", "hello\n", "header says 10"},
+		{"long", "blob 2\x00", "hello\n", "header says 2"},
+		{"unknown type", "thing 6\x00", "hello\n", "unknown object type"},
+		{"bad size", "blob -6\x00", "hello\n", "invalid object header"},
+		{"unterminated", "blob 6", "", "not terminated"},
+		{"wrong name", "blob 6\x00", "howdy\n", "does not match"},
+	}
+	for _, test := range tests {
+		// Store each under a name of its own, which matches none of them
+		name := hashObject(typeBlob, []byte(test.name))
+		tr.store(test.header, []byte(test.data), name)
+		if _, err := tr.repo.read(name); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	missing := hashObject(typeBlob, []byte("missing"))
+	if _, err := tr.repo.read(missing); !errors.Is(err, errNotFound) {
+		t.Errorf("missing object: err = %v", err)
+	}
+	os.MkdirAll(filepath.Join(tr.repo.objects, "pack"), 0o755)
+	tr.write("objects/pack/pack-1.pack", "")
+	if _, err := tr.repo.read(missing); !errors.Is(err, errPacked) {
+		t.Errorf("missing object with packs: err = %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	tr := newTestRepo(t)
+	root := tr.commit("root")
+	side := tr.commit("side", root)
+	main := tr.commit("main", root)
+	merge := tr.commit("merge", main, side)
+	tag := tr.object(typeTag, []byte(fmt.Sprintf("object %s\ntype commit\ntag v1\ntagger T <t> 1700000000 +0000\n\nrelease\n", merge)))
+
+	tr.write("refs/heads/main", merge.String()+"\n")
+	tr.write("refs/tags/v1", tag.String()+"\n")
+	tr.write("packed-refs", "# pack-refs with: peeled fully-peeled sorted\n"+
+		side.String()+" refs/heads/side\n"+
+		root.String()+" refs/heads/main\n")
+	tr.write("refs/heads/alias", "ref: refs/heads/side\n")
+	rootCommit, _ := tr.repo.commit(root)
+	tests := []struct {
+		rev  string
+		want id
+	}{
+		{"HEAD", merge},
+		{"", merge},
+		{"main", merge},
+		{"refs/heads/main", merge},
+		{"heads/main", merge},
+		{"side", side},
+		{"alias", side},
+		{"v1", tag},
+		{"v1^{}", merge},
+		{"v1^{commit}", merge},
+		{"v1^", main},
+		{"HEAD^2", side},
+		{"HEAD^0", merge},
+		{"HEAD~2", root},
+		{"HEAD^^", root},
+		{"HEAD^2~1", root},
+		{"HEAD~2^{tree}", rootCommit.tree},
+		{merge.String(), merge},
+		{merge.String()[:8], merge},
+	}
+	for _, test := range tests {
+		got, err := tr.repo.resolve(test.rev)
+		if err != nil || got != test.want {
+			t.Errorf("resolve(%q) = %s, %v; want %s", test.rev, got, err, test.want)
+		}
+	}
+
+	for _, bad := range []string{"nope", "HEAD~3", "HEAD^3", "HEAD^x", "HEAD^{blob}", "HEAD^{tree", "abc"} {
+		if i, err := tr.repo.resolve(bad); err == nil {
+			t.Errorf("resolve(%q) = %s, want an error", bad, i)
+		}
+	}
+}
+
+func TestFindRepository(t *testing.T) {
+
+	tr := newTestRepo(t)
+	sub := filepath.Join(tr.root, "a", "b")
+	os.MkdirAll(sub, 0o755)
+
+	r, err := findRepository(sub)
+	if err != nil || r.dir != tr.repo.dir {
+		t.Errorf("from a subdirectory: %v, %v", r, err)
+	}
+	r, err = findRepository(tr.repo.dir)
+	if err != nil || r.dir != tr.repo.dir {
+		t.Errorf("from the git directory: %v, %v", r, err)
+	}
+
+	worktree := t.TempDir()
+	os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+tr.repo.dir+"\n"), 0o644)
+	r, err = findRepository(worktree)
+	if err != nil || r.dir != tr.repo.dir {
+		t.Errorf("through a gitdir file: %v, %v", r, err)
+	}
+}
+
+func TestLog(t *testing.T) {
+
+	tr := newTestRepo(t)
+	root := tr.commit("root")
+	left := tr.commit("left", root)
+	right := tr.commit("right", root)
+	merge := tr.commit("merge", left, right)
+	top := tr.commit("top", merge)
+	fix := tr.commit("fix", root)
+	tr.write("refs/heads/main", top.String()+"\n")
+	tr.write("refs/heads/fix", fix.String()+"\n")
+	tr.write("refs/tags/v1", merge.String()+"\n")
+
+	var out strings.Builder
+	if err := writeLog(&out, tr.repo, []id{top, fix}, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`* %s (fix) fix
+| * %s (HEAD -> main) top
+| * %s (tag: v1) merge
+| |\
+| | * %s right
+| * | %s left
+|/ /
+* %s root
+`, fix.short(), top.short(), merge.short(), right.short(), left.short(), root.short())
+	if out.String() != want {
+		t.Errorf("log:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := writeLog(&out, tr.repo, []id{top}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), "*"); got != 2 {
+		t.Errorf("log -n 2 showed %d commits:\n%s", got, out.String())
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	tr := newTestRepo(t)
+	first := tr.commit("first")
+	tr.commit("second", first)
+
+	var reported []error
+	warn := func(err error) { reported = append(reported, err) }
+	n, err := verify(new(strings.Builder), tr.repo, nil, false, warn)
+	if err != nil || n != 6 || len(reported) != 0 {
+		t.Fatalf("clean repository: %d objects, err %v, reported %v", n, err, reported)
+	}
+
+	// A commit whose content is swapped for a blob's under its name
+	tr.store("blob 3\x00", []byte("abc"), hashObject(typeBlob, []byte("other")))
+	broken := hashObject(typeCommit, []byte("tree x\n"))
+	tr.store("commit 7\x00", []byte("tree x\n"), broken)
+
+	var out strings.Builder
+	n, err = verify(&out, tr.repo, nil, true, warn)
+	if err != nil || n != 8 {
+		t.Fatalf("%d objects, err %v", n, err)
+	}
+	if len(reported) != 2 {
+		t.Fatalf("reported %v, want 2 problems", reported)
+	}
+	if !strings.Contains(reported[0].Error()+reported[1].Error(), "does not match") ||
+		!strings.Contains(reported[0].Error()+reported[1].Error(), "commit tree") {
+		t.Errorf("reported %v", reported)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 6 {
+		t.Errorf("verbose listing has %d lines, want 6:\n%s", lines, out.String())
+	}
+}
+
+func TestWriteObject(t *testing.T) {
+
+	tr := newTestRepo(t)
+	blob := tr.object(typeBlob, []byte("x"))
+	tree := tr.tree(treeEntry{0o100755, "run", blob}, treeEntry{modeTree, "sub", tr.tree()})
+
+	obj, err := tr.repo.read(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := writeObject(&out, obj); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("100755 blob %s\trun\n040000 tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\tsub\n", blob)
+	if out.String() != want {
+		t.Errorf("tree listing:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
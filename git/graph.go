@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// history returns the commits reachable from starts, newest first, with
+// every commit before its parents: in topological order, taking the most
+// recently committed of the commits that are ready at each step. If limit
+// is positive, only that many are returned.
+func (r *repository) history(starts []id, limit int) ([]id, error) {
+
+	// Find every reachable commit and count its children
+	children := make(map[id]int)
+	seen := make(map[id]bool)
+	stack := slices.Clone(starts)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		c, err := r.commit(i)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range c.parents {
+			children[parent]++
+			stack = append(stack, parent)
+		}
+	}
+
+	ready := &commitHeap{repo: r}
+	for i := range seen {
+		if children[i] == 0 {
+			ready.ids = append(ready.ids, i)
+		}
+	}
+	heap.Init(ready)
+
+	var order []id
+	for ready.Len() > 0 && (limit <= 0 || len(order) < limit) {
+		i := heap.Pop(ready).(id)
+		order = append(order, i)
+		for _, parent := range r.commits[i].parents {
+			children[parent]--
+			if children[parent] == 0 {
+				heap.Push(ready, parent)
+			}
+		}
+	}
+	return order, nil
+}
+
+// commitHeap orders commits by commit time, newest first, and then by
+// name, so that the order does not depend on map iteration.
+type commitHeap struct {
+	repo *repository
+	ids  []id
+}
+
+func (h *commitHeap) Len() int      { return len(h.ids) }
+func (h *commitHeap) Swap(i, j int) { h.ids[i], h.ids[j] = h.ids[j], h.ids[i] }
+func (h *commitHeap) Push(x any)    { h.ids = append(h.ids, x.(id)) }
+
+func (h *commitHeap) Less(i, j int) bool {
+	a, b := h.repo.commits[h.ids[i]], h.repo.commits[h.ids[j]]
+	if !a.committer.when.Equal(b.committer.when) {
+		return a.committer.when.After(b.committer.when)
+	}
+	return bytes.Compare(h.ids[i][:], h.ids[j][:]) < 0
+}
+
+func (h *commitHeap) Pop() any {
+	last := h.ids[len(h.ids)-1]
+	h.ids = h.ids[:len(h.ids)-1]
+	return last
+}
+
+// graph draws the lines of history beside a list of commits, as
+// git log --graph does. Each lane is a column holding the commit expected
+// further down it.
+type graph struct {
+	out   io.Writer
+	lanes []id
+}
+
+// edge joins a lane on one row to a lane on the next.
+type edge struct {
+	from, to int
+}
+
+// row prints the line for commit i with parents, and the connecting lines
+// that lead to the next.
+func (g *graph) row(i id, parents []id, text string) error {
+
+	// Lanes that were all waiting for i merge into the first of them
+	var at []int
+	for lane, expected := range g.lanes {
+		if expected == i {
+			at = append(at, lane)
+		}
+	}
+	if len(at) == 0 {
+		g.lanes = append(g.lanes, i)
+		at = []int{len(g.lanes) - 1}
+	}
+	col := at[0]
+	if len(at) > 1 {
+		var edges []edge
+		var lanes []id
+		for lane, expected := range g.lanes {
+			if lane != col && expected == i {
+				edges = append(edges, edge{lane, col})
+				continue
+			}
+			edges = append(edges, edge{lane, len(lanes)})
+			lanes = append(lanes, expected)
+		}
+		if err := g.connect(edges); err != nil {
+			return err
+		}
+		g.lanes = lanes
+	}
+
+	var line strings.Builder
+	for lane := range g.lanes {
+		if lane == col {
+			line.WriteString("* ")
+		} else {
+			line.WriteString("| ")
+		}
+	}
+	line.WriteString(text)
+	if _, err := fmt.Fprintln(g.out, line.String()); err != nil {
+		return err
+	}
+
+	// The first parent takes over the commit's lane, and the others open
+	// new lanes beside it unless a lane already waits for them
+	var edges []edge
+	var lanes []id
+	for lane, expected := range g.lanes {
+		if lane != col {
+			edges = append(edges, edge{lane, len(lanes)})
+			lanes = append(lanes, expected)
+			continue
+		}
+		for n, parent := range parents {
+			if n > 0 && slices.Contains(g.lanes, parent) {
+				continue
+			}
+			edges = append(edges, edge{lane, len(lanes)})
+			lanes = append(lanes, parent)
+		}
+	}
+	for _, parent := range parents[min(1, len(parents)):] {
+		if slices.Contains(g.lanes, parent) {
+			edges = append(edges, edge{col, slices.Index(lanes, parent)})
+		}
+	}
+	g.lanes = lanes
+	return g.connect(edges)
+}
+
+// connect draws a row of lines between lanes, unless every line is
+// straight down.
+func (g *graph) connect(edges []edge) error {
+
+	width := 0
+	straight := true
+	for _, e := range edges {
+		width = max(width, e.from+1, e.to+1)
+		straight = straight && e.from == e.to
+	}
+	if straight {
+		return nil
+	}
+
+	line := bytes.Repeat([]byte(" "), 2*width)
+	for _, e := range edges {
+		switch {
+		case e.to == e.from:
+			line[2*e.from] = '|'
+		case e.to > e.from:
+			line[2*e.from+1] = '\\'
+		default:
+			line[2*e.from-1] = '/'
+		}
+	}
+	_, err := fmt.Fprintf(g.out, "%s\n", bytes.TrimRight(line, " "))
+	return err
+}
+
+// decorations returns, for each commit some reference points to, labels
+// naming the references, as git log --decorate shows them.
+func (r *repository) decorations() (map[id][]string, error) {
+
+	refs, err := r.refs()
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[id][]string)
+
+	branch := r.head()
+	if branch == "" {
+		if i, err := r.resolveRef("HEAD"); err == nil {
+			labels[i] = append(labels[i], "HEAD")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(refs)) {
+		i, err := r.peel(refs[name], typeCommit)
+		if err != nil {
+			continue
+		}
+
+		var label string
+		switch {
+		case strings.HasPrefix(name, "refs/heads/"):
+			label = strings.TrimPrefix(name, "refs/heads/")
+			if name == branch {
+				label = "HEAD -> " + label
+				labels[i] = slices.Insert(labels[i], 0, label)
+				continue
+			}
+		case strings.HasPrefix(name, "refs/tags/"):
+			label = "tag: " + strings.TrimPrefix(name, "refs/tags/")
+		case strings.HasPrefix(name, "refs/remotes/"):
+			label = strings.TrimPrefix(name, "refs/remotes/")
+		default:
+			continue
+		}
+		labels[i] = append(labels[i], label)
+	}
+	return labels, nil
+}
+
+// writeLog prints the history from starts as a graph, a line per commit
+// with its short name, the references pointing to it and its subject.
+func writeLog(out io.Writer, r *repository, starts []id, limit int) error {
+
+	order, err := r.history(starts, limit)
+	if err != nil {
+		return err
+	}
+	labels, err := r.decorations()
+	if err != nil {
+		return err
+	}
+
+	g := &graph{out: out}
+	for _, i := range order {
+		c := r.commits[i]
+		text := i.short()
+		if l := labels[i]; len(l) > 0 {
+			text += " (" + strings.Join(l, ", ") + ")"
+		}
+		text += " " + c.subject()
+		if err := g.row(i, c.parents, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
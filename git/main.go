@@ -0,0 +1,143 @@
+// Command ccgit inspects a git repository without git. It reads the
+// repository's loose objects, inflating and parsing blobs, trees, commits
+// and tags, prints them, draws the commit graph, and verifies that each
+// object's content matches its name. Objects in pack files are not read.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccgit -cat [flags] object ...")
+	fmt.Fprintln(out, "       ccgit -type [flags] object ...")
+	fmt.Fprintln(out, "       ccgit -log [flags] [revision ...]")
+	fmt.Fprintln(out, "       ccgit -verify [flags] [object ...]")
+	fmt.Fprintln(out, "Print objects or their types and sizes, draw the history from the revisions")
+	fmt.Fprintln(out, "given or HEAD, or check the objects named or every loose object.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccgit"
+
+	cat := flag.Bool("cat", false, "print objects: blobs, commits and tags as they are, trees as a listing")
+	showType := flag.Bool("type", false, "print the type and size of objects")
+	log := flag.Bool("log", false, "draw the commit graph")
+	check := flag.Bool("verify", false, "check that objects inflate, parse and match their names")
+	dir := flag.String("C", ".", "use the repository `dir` is in")
+	limit := flag.Int("n", 0, "with -log, show at most `N` commits")
+	verbose := flag.Bool("v", false, "with -verify, list each sound object")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	modes := 0
+	for _, set := range []bool{*cat, *showType, *log, *check} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		cli.Exit(cli.Usagef("need exactly one of -cat, -type, -log and -verify"))
+	}
+	if (*cat || *showType) && flag.NArg() == 0 {
+		cli.Exit(cli.Usagef("need objects to print"))
+	}
+	if *limit < 0 {
+		cli.Exit(cli.Usagef("-n must not be negative"))
+	}
+
+	repo, err := findRepository(*dir)
+	if err != nil {
+		cli.Exit(err)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	failed := false
+	warn := func(err error) {
+		out.Flush()
+		cli.Report(err)
+		failed = true
+	}
+
+	switch {
+	case *cat || *showType:
+		for _, rev := range flag.Args() {
+			i, err := repo.resolve(rev)
+			if err == nil {
+				err = printObject(out, repo, i, *showType)
+			}
+			if err != nil {
+				warn(err)
+			}
+		}
+
+	case *log:
+		revs := flag.Args()
+		if len(revs) == 0 {
+			revs = []string{"HEAD"}
+		}
+		var starts []id
+		for _, rev := range revs {
+			i, err := repo.resolve(rev)
+			if err == nil {
+				i, err = repo.peel(i, typeCommit)
+			}
+			if err != nil {
+				cli.Exit(err)
+			}
+			starts = append(starts, i)
+		}
+		err = writeLog(out, repo, starts, *limit)
+
+	case *check:
+		var ids []id
+		for _, rev := range flag.Args() {
+			i, err := repo.resolve(rev)
+			if err != nil {
+				cli.Exit(err)
+			}
+			ids = append(ids, i)
+		}
+		var checked int
+		checked, err = verify(out, repo, ids, *verbose, warn)
+		if err == nil && *verbose {
+			fmt.Fprintf(out, "%d objects checked\n", checked)
+		}
+	}
+
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		out.Flush()
+		cli.Exit(err)
+	}
+	if failed {
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+// printObject prints the object i, or only its type and size.
+func printObject(out *bufio.Writer, repo *repository, i id, typeOnly bool) error {
+
+	obj, err := repo.read(i)
+	if err != nil {
+		return err
+	}
+	if typeOnly {
+		_, err := fmt.Fprintf(out, "%s %d\n", obj.kind, len(obj.data))
+		return err
+	}
+	return writeObject(out, obj)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// id is the SHA-1 name of an object.
+type id [sha1.Size]byte
+
+func (i id) String() string {
+	return hex.EncodeToString(i[:])
+}
+
+// short returns the abbreviated form of i used in one-line listings.
+func (i id) short() string {
+	return i.String()[:7]
+}
+
+// parseID parses a full hexadecimal object name.
+func parseID(s string) (id, error) {
+
+	var i id
+	if len(s) != 2*len(i) {
+		return i, fmt.Errorf("invalid object name %q", s)
+	}
+	if _, err := hex.Decode(i[:], []byte(s)); err != nil {
+		return i, fmt.Errorf("invalid object name %q", s)
+	}
+	return i, nil
+}
+
+// Object types, as named in object headers.
+const (
+	typeBlob   = "blob"
+	typeTree   = "tree"
+	typeCommit = "commit"
+	typeTag    = "tag"
+)
+
+// object is an object's type and content, without its header.
+type object struct {
+	kind string
+	data []byte
+}
+
+// maxObjectSize bounds the size an object header may claim, so that a
+// corrupt one cannot make the reader allocate without limit.
+const maxObjectSize = 1 << 30
+
+var (
+	// errPacked is returned for an object that is not stored loose; only
+	// loose objects can be read
+	errPacked = errors.New("object is in a pack file, and only loose objects can be read")
+
+	errNotFound = errors.New("object not found")
+)
+
+// hashObject returns the name of an object with the given type and
+// content, which is the hash of its header and content.
+func hashObject(kind string, data []byte) id {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", kind, len(data))
+	h.Write(data)
+	var i id
+	h.Sum(i[:0])
+	return i
+}
+
+// loosePath returns where the loose object i is stored under objects.
+func loosePath(objects string, i id) string {
+	name := i.String()
+	return filepath.Join(objects, name[:2], name[2:])
+}
+
+// readLoose inflates the loose object stored in file and checks its header
+// against its content. It does not check the object's name.
+func readLoose(file string) (object, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return object{}, err
+	}
+	defer f.Close()
+
+	z, err := zlib.NewReader(f)
+	if err != nil {
+		return object{}, fmt.Errorf("inflating object: %w", err)
+	}
+	defer z.Close()
+
+	// The header is "type size\x00"; types are short and sizes at most
+	// ten digits
+	data, err := io.ReadAll(io.LimitReader(z, 32))
+	if err != nil {
+		return object{}, fmt.Errorf("inflating object: %w", err)
+	}
+	nul := bytes.IndexByte(data, 0)
+	if nul < 0 {
+		return object{}, errors.New("object header is not terminated")
+	}
+	kind, sizeText, ok := strings.Cut(string(data[:nul]), " ")
+	size, err := strconv.ParseInt(sizeText, 10, 64)
+	if !ok || err != nil || size < 0 || strings.HasPrefix(sizeText, "+") {
+		return object{}, fmt.Errorf("invalid object header %q", data[:nul])
+	}
+	switch kind {
+	case typeBlob, typeTree, typeCommit, typeTag:
+	default:
+		return object{}, fmt.Errorf("unknown object type %q", kind)
+	}
+	if size > maxObjectSize {
+		return object{}, fmt.Errorf("object size %d is too large", size)
+	}
+
+	// Read one byte past the size to catch content longer than the header
+	// says
+	content := make([]byte, 0, size)
+	content = append(content, data[nul+1:]...)
+	rest, err := io.ReadAll(io.LimitReader(z, size+1-int64(len(content))))
+	if err != nil {
+		return object{}, fmt.Errorf("inflating object: %w", err)
+	}
+	content = append(content, rest...)
+	if int64(len(content)) != size {
+		return object{}, fmt.Errorf("object is %d bytes, but its header says %d", len(content), size)
+	}
+	return object{kind: kind, data: content}, nil
+}
+
+// treeEntry is an entry of a tree: a file, a directory or a submodule.
+type treeEntry struct {
+	mode uint32
+	name string
+	id   id
+}
+
+// Modes of tree entries that are not plain files.
+const (
+	modeTree      = 0o40000
+	modeSymlink   = 0o120000
+	modeSubmodule = 0o160000
+)
+
+// kind returns the type of the object the entry names.
+func (e treeEntry) kind() string {
+	switch e.mode {
+	case modeTree:
+		return typeTree
+	case modeSubmodule:
+		return typeCommit
+	}
+	return typeBlob
+}
+
+// parseTree parses the content of a tree, a list of entries each made of
+// an octal mode, a space, a name, a NUL and a binary object name.
+func parseTree(data []byte) ([]treeEntry, error) {
+
+	var entries []treeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		if space < 0 {
+			return nil, errors.New("tree entry has no mode")
+		}
+		mode, err := strconv.ParseUint(string(data[:space]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("tree entry has invalid mode %q", data[:space])
+		}
+		data = data[space+1:]
+
+		nul := bytes.IndexByte(data, 0)
+		if nul <= 0 {
+			return nil, errors.New("tree entry has no name")
+		}
+		e := treeEntry{mode: uint32(mode), name: string(data[:nul])}
+		data = data[nul+1:]
+		if len(data) < len(e.id) {
+			return nil, fmt.Errorf("tree entry %q is truncated", e.name)
+		}
+		copy(e.id[:], data)
+		data = data[len(e.id):]
+
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// signature is who made a commit or tag, and when.
+type signature struct {
+	name, email string
+	when        time.Time
+}
+
+// parseSignature parses "Name <email> seconds +zone".
+func parseSignature(s string) (signature, error) {
+
+	open := strings.LastIndexByte(s, '<')
+	end := strings.LastIndexByte(s, '>')
+	if open < 0 || end < open {
+		return signature{}, fmt.Errorf("invalid signature %q", s)
+	}
+	sig := signature{name: strings.TrimSpace(s[:open]), email: s[open+1 : end]}
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) != 2 || len(fields[1]) != 5 {
+		return signature{}, fmt.Errorf("invalid signature %q", s)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return signature{}, fmt.Errorf("invalid signature %q", s)
+	}
+	zone, err := time.Parse("-0700", fields[1])
+	if err != nil {
+		return signature{}, fmt.Errorf("invalid signature %q", s)
+	}
+	sig.when = time.Unix(seconds, 0).In(zone.Location())
+	return sig, nil
+}
+
+// commit is a parsed commit object.
+type commit struct {
+	tree      id
+	parents   []id
+	author    signature
+	committer signature
+	message   string
+}
+
+// subject returns the first line of the commit message.
+func (c *commit) subject() string {
+	line, _, _ := strings.Cut(strings.TrimLeft(c.message, "\n"), "\n")
+	return line
+}
+
+// parseCommit parses the content of a commit: header lines, then a blank
+// line and the message. Headers other than tree, parent, author and
+// committer, such as signatures, are skipped with their continuation
+// lines.
+func parseCommit(data []byte) (*commit, error) {
+
+	header, message, _ := strings.Cut(string(data), "\n\n")
+	c := &commit{message: message}
+
+	var hasTree, hasAuthor, hasCommitter bool
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+
+		var err error
+		switch key {
+		case "tree":
+			if hasTree {
+				return nil, errors.New("commit has more than one tree")
+			}
+			c.tree, err = parseID(value)
+			hasTree = true
+		case "parent":
+			var parent id
+			parent, err = parseID(value)
+			c.parents = append(c.parents, parent)
+		case "author":
+			c.author, err = parseSignature(value)
+			hasAuthor = true
+		case "committer":
+			c.committer, err = parseSignature(value)
+			hasCommitter = true
+		}
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", key, err)
+		}
+	}
+
+	if !hasTree || !hasAuthor || !hasCommitter {
+		return nil, errors.New("commit is missing its tree, author or committer")
+	}
+	return c, nil
+}
+
+// parseTagTarget returns the object an annotated tag points at.
+func parseTagTarget(data []byte) (id, string, error) {
+
+	header, _, _ := strings.Cut(string(data), "\n\n")
+	var target id
+	var kind string
+	var err error
+	hasObject := false
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			target, err = parseID(value)
+			if err != nil {
+				return id{}, "", fmt.Errorf("tag object: %w", err)
+			}
+			hasObject = true
+		case "type":
+			kind = value
+		}
+	}
+	if !hasObject || kind == "" {
+		return id{}, "", errors.New("tag is missing its object or type")
+	}
+	return target, kind, nil
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// repository is a git directory: the .git of a work tree, or a bare
+// repository.
+type repository struct {
+	dir     string
+	objects string
+
+	// commits caches the commits read so far
+	commits map[id]*commit
+}
+
+// findRepository finds the git directory of the repository dir is in,
+// looking in dir and then in each directory above it.
+func findRepository(dir string) (*repository, error) {
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	start := dir
+	for {
+		gitDir, err := gitDirIn(dir)
+		if err != nil {
+			return nil, err
+		}
+		if gitDir != "" {
+			return openRepository(gitDir), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("%s: not in a git repository", start)
+		}
+		dir = parent
+	}
+}
+
+// gitDirIn returns the git directory of dir: its .git directory, the
+// directory a .git file points to, or dir itself if it is bare. It returns
+// "" if dir has none.
+func gitDirIn(dir string) (string, error) {
+
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	switch {
+	case err == nil && info.IsDir():
+		return dotGit, nil
+	case err == nil:
+		// A work tree added with git worktree, or a submodule, has a file
+		// naming its git directory
+		content, err := os.ReadFile(dotGit)
+		if err != nil {
+			return "", err
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+		if !ok {
+			return "", fmt.Errorf("%s: not a gitdir file", dotGit)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		return target, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+
+	if isGitDir(dir) {
+		return dir, nil
+	}
+	return "", nil
+}
+
+// isGitDir reports whether dir looks like a git directory.
+func isGitDir(dir string) bool {
+
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func openRepository(gitDir string) *repository {
+	return &repository{
+		dir:     gitDir,
+		objects: filepath.Join(gitDir, "objects"),
+		commits: make(map[id]*commit),
+	}
+}
+
+// read returns the object named i, checking that its content matches its
+// name.
+func (r *repository) read(i id) (object, error) {
+
+	obj, err := readLoose(loosePath(r.objects, i))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if r.hasPacks() {
+			return object{}, fmt.Errorf("%s: %w", i, errPacked)
+		}
+		return object{}, fmt.Errorf("%s: %w", i, errNotFound)
+	case err != nil:
+		return object{}, fmt.Errorf("%s: %w", i, err)
+	}
+
+	if hashObject(obj.kind, obj.data) != i {
+		return object{}, fmt.Errorf("%s: content does not match the object name", i)
+	}
+	return obj, nil
+}
+
+// hasPacks reports whether the repository has pack files, where an object
+// that is not loose may be.
+func (r *repository) hasPacks() bool {
+	packs, _ := filepath.Glob(filepath.Join(r.objects, "pack", "*.pack"))
+	return len(packs) > 0
+}
+
+// commit reads and parses the commit i.
+func (r *repository) commit(i id) (*commit, error) {
+
+	if c := r.commits[i]; c != nil {
+		return c, nil
+	}
+	obj, err := r.read(i)
+	if err != nil {
+		return nil, err
+	}
+	if obj.kind != typeCommit {
+		return nil, fmt.Errorf("%s is a %s, not a commit", i, obj.kind)
+	}
+	c, err := parseCommit(obj.data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i, err)
+	}
+	r.commits[i] = c
+	return c, nil
+}
+
+// looseIDs returns the names of all loose objects, sorted.
+func (r *repository) looseIDs() ([]id, error) {
+
+	dirs, err := os.ReadDir(r.objects)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []id
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(r.objects, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if i, err := parseID(dir.Name() + file.Name()); err == nil {
+				ids = append(ids, i)
+			}
+		}
+	}
+	slices.SortFunc(ids, func(a, b id) int { return strings.Compare(string(a[:]), string(b[:])) })
+	return ids, nil
+}
+
+// refs returns every reference, loose or packed, by full name, with
+// symbolic references other than HEAD left out.
+func (r *repository) refs() (map[string]id, error) {
+
+	refs := make(map[string]id)
+
+	// Loose references override packed ones of the same name
+	if err := r.readPackedRefs(refs); err != nil {
+		return nil, err
+	}
+	err := filepath.WalkDir(filepath.Join(r.dir, "refs"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if i, err := r.resolveRef(name); err == nil {
+			refs[name] = i
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+func (r *repository) readPackedRefs(refs map[string]id) error {
+
+	f, err := os.Open(filepath.Join(r.dir, "packed-refs"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Skip the header, and the peeled values of tags on ^ lines
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		value, name, ok := strings.Cut(line, " ")
+		i, err := parseID(value)
+		if !ok || err != nil {
+			return fmt.Errorf("packed-refs: invalid line %q", line)
+		}
+		refs[name] = i
+	}
+	return scanner.Err()
+}
+
+// maxSymrefDepth bounds chains of symbolic references, to stop at loops.
+const maxSymrefDepth = 5
+
+// resolveRef returns the object the reference name points to, following
+// symbolic references.
+func (r *repository) resolveRef(name string) (id, error) {
+
+	for range maxSymrefDepth {
+		content, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			return r.packedRef(name)
+		}
+		if err != nil {
+			return id{}, err
+		}
+
+		value := strings.TrimSpace(string(content))
+		if target, ok := strings.CutPrefix(value, "ref: "); ok {
+			name = target
+			continue
+		}
+		i, err := parseID(value)
+		if err != nil {
+			return id{}, fmt.Errorf("%s: %w", name, err)
+		}
+		return i, nil
+	}
+	return id{}, fmt.Errorf("%s: too many levels of symbolic references", name)
+}
+
+func (r *repository) packedRef(name string) (id, error) {
+
+	refs := make(map[string]id)
+	if err := r.readPackedRefs(refs); err != nil {
+		return id{}, err
+	}
+	i, ok := refs[name]
+	if !ok {
+		return id{}, fmt.Errorf("%s: no such reference", name)
+	}
+	return i, nil
+}
+
+// head returns the full name of the branch HEAD is on, or "" if HEAD is
+// detached.
+func (r *repository) head() string {
+
+	content, err := os.ReadFile(filepath.Join(r.dir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	target, _ := strings.CutPrefix(strings.TrimSpace(string(content)), "ref: ")
+	if strings.HasPrefix(target, "refs/") {
+		return target
+	}
+	return ""
+}
+
+// resolve finds the object a revision names: a full or abbreviated object
+// name, HEAD, or a branch, tag or other reference, optionally followed by
+// ^, ^N or ~N to step to a parent, or ^{type} to peel it to a tree, commit
+// or, with ^{}, to what a tag points at.
+func (r *repository) resolve(rev string) (id, error) {
+
+	base := rev
+	if i := strings.IndexAny(rev, "^~"); i >= 0 {
+		base = rev[:i]
+	}
+	i, err := r.resolveName(base)
+	if err != nil {
+		return id{}, err
+	}
+
+	for suffix := rev[len(base):]; suffix != ""; {
+		if kind, ok := strings.CutPrefix(suffix, "^{"); ok {
+			end := strings.IndexByte(kind, '}')
+			if end < 0 {
+				return id{}, fmt.Errorf("%s: invalid revision", rev)
+			}
+			suffix = kind[end+1:]
+			if i, err = r.peel(i, kind[:end]); err != nil {
+				return id{}, fmt.Errorf("%s: %w", rev, err)
+			}
+			continue
+		}
+
+		op := suffix[0]
+		if op != '^' && op != '~' {
+			return id{}, fmt.Errorf("%s: invalid revision", rev)
+		}
+		digits := len(suffix[1:]) - len(strings.TrimLeft(suffix[1:], "0123456789"))
+		n := 1
+		if digits > 0 {
+			n, err = strconv.Atoi(suffix[1 : 1+digits])
+			if err != nil {
+				return id{}, fmt.Errorf("%s: invalid revision", rev)
+			}
+		}
+		suffix = suffix[1+digits:]
+
+		if i, err = r.peel(i, typeCommit); err != nil {
+			return id{}, fmt.Errorf("%s: %w", rev, err)
+		}
+		if op == '^' {
+			i, err = r.parent(i, n)
+		} else {
+			for ; n > 0 && err == nil; n-- {
+				i, err = r.parent(i, 1)
+			}
+		}
+		if err != nil {
+			return id{}, fmt.Errorf("%s: %w", rev, err)
+		}
+	}
+	return i, nil
+}
+
+// parent returns the nth parent of commit i, or i itself for n = 0.
+func (r *repository) parent(i id, n int) (id, error) {
+
+	if n == 0 {
+		return i, nil
+	}
+	c, err := r.commit(i)
+	if err != nil {
+		return id{}, err
+	}
+	if n > len(c.parents) {
+		return id{}, fmt.Errorf("commit %s has no parent %d", i.short(), n)
+	}
+	return c.parents[n-1], nil
+}
+
+// resolveName finds the object name names, trying the forms of reference
+// names git does, in git's order.
+func (r *repository) resolveName(name string) (id, error) {
+
+	if name == "" {
+		name = "HEAD"
+	}
+	if i, err := parseID(name); err == nil {
+		return i, nil
+	}
+	for _, candidate := range []string{name, "refs/" + name, "refs/tags/" + name, "refs/heads/" + name, "refs/remotes/" + name} {
+		if candidate == "HEAD" || strings.HasPrefix(candidate, "refs/") {
+			if i, err := r.resolveRef(candidate); err == nil {
+				return i, nil
+			}
+		}
+	}
+	if isHex(name) && len(name) >= 4 {
+		return r.expand(name)
+	}
+	return id{}, fmt.Errorf("%s: unknown revision", name)
+}
+
+// expand finds the loose object whose name starts with prefix.
+func (r *repository) expand(prefix string) (id, error) {
+
+	prefix = strings.ToLower(prefix)
+	files, err := os.ReadDir(filepath.Join(r.objects, prefix[:2]))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return id{}, err
+	}
+
+	var found []id
+	for _, file := range files {
+		name := prefix[:2] + file.Name()
+		if strings.HasPrefix(name, prefix) {
+			if i, err := parseID(name); err == nil {
+				found = append(found, i)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return id{}, fmt.Errorf("%s: unknown revision", prefix)
+	case 1:
+		return found[0], nil
+	}
+	return id{}, fmt.Errorf("%s: ambiguous object name", prefix)
+}
+
+// peel follows annotated tags from i, and a commit to its tree, until it
+// reaches an object of type kind. An empty kind stops at the first object
+// that is not a tag.
+func (r *repository) peel(i id, kind string) (id, error) {
+
+	for range maxSymrefDepth {
+		obj, err := r.read(i)
+		if err != nil {
+			return id{}, err
+		}
+		switch {
+		case obj.kind == kind || kind == "" && obj.kind != typeTag:
+			return i, nil
+		case obj.kind == typeTag:
+			target, _, err := parseTagTarget(obj.data)
+			if err != nil {
+				return id{}, fmt.Errorf("%s: %w", i, err)
+			}
+			i = target
+		case obj.kind == typeCommit && kind == typeTree:
+			c, err := r.commit(i)
+			if err != nil {
+				return id{}, err
+			}
+			return c.tree, nil
+		default:
+			return id{}, fmt.Errorf("%s is a %s, not a %s", i, obj.kind, kind)
+		}
+	}
+	return id{}, fmt.Errorf("%s: too many levels of tags", i)
+}
+
+func isHex(s string) bool {
+	return strings.Trim(strings.ToLower(s), "0123456789abcdef") == ""
+}
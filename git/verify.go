@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// verifyObject checks the loose object i: that it inflates, that its
+// header matches its content, that its content hashes to its name, and
+// that a tree, commit or tag parses.
+func (r *repository) verifyObject(i id) (object, error) {
+
+	obj, err := r.read(i)
+	if err != nil {
+		return obj, err
+	}
+
+	switch obj.kind {
+	case typeTree:
+		_, err = parseTree(obj.data)
+	case typeCommit:
+		_, err = parseCommit(obj.data)
+	case typeTag:
+		_, _, err = parseTagTarget(obj.data)
+	}
+	if err != nil {
+		return obj, fmt.Errorf("%s: %w", i, err)
+	}
+	return obj, nil
+}
+
+// verify checks the objects named, or every loose object if none are,
+// reporting each problem to warn. With verbose set it prints each object
+// that is sound. It returns how many objects it checked.
+func verify(out io.Writer, r *repository, ids []id, verbose bool, warn func(error)) (int, error) {
+
+	if ids == nil {
+		var err error
+		if ids, err = r.looseIDs(); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, i := range ids {
+		obj, err := r.verifyObject(i)
+		if err != nil {
+			warn(err)
+			continue
+		}
+		if verbose {
+			if _, err := fmt.Fprintf(out, "%s %-6s %d\n", i, obj.kind, len(obj.data)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(ids), nil
+}
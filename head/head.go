@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// headLines copies the first n lines of input to out. A negative n copies
+// all but the last -n lines, holding only those back.
+func headLines(out io.Writer, input io.Reader, n int64) error {
+
+	if n < 0 {
+		return allButLastLines(out, input, -n)
+	}
+
+	reader := bufio.NewReader(input)
+
+	for n > 0 {
+		line, err := reader.ReadSlice('\n')
+		if len(line) > 0 {
+			if _, err := out.Write(line); err != nil {
+				return err
+			}
+		}
+
+		switch err {
+		case nil:
+			n--
+		case bufio.ErrBufferFull:
+			// The rest of a long line follows
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// headBytes copies the first n bytes of input to out. A negative n copies
+// all but the last -n bytes.
+func headBytes(out io.Writer, input io.Reader, n int64) error {
+
+	if n < 0 {
+		return allButLastBytes(out, input, -n)
+	}
+
+	_, err := io.CopyN(out, input, n)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// allButLastLines copies input to out, holding back the last n lines.
+func allButLastLines(out io.Writer, input io.Reader, n int64) error {
+
+	reader := bufio.NewReader(input)
+
+	// The most recent lines read, up to n of them
+	var held [][]byte
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			held = append(held, line)
+			if int64(len(held)) > n {
+				if _, err := out.Write(held[0]); err != nil {
+					return err
+				}
+				held = held[1:]
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// allButLastBytes copies input to out, holding back the last n bytes.
+func allButLastBytes(out io.Writer, input io.Reader, n int64) error {
+
+	var held bytes.Buffer
+	chunk := make([]byte, 64*1024)
+
+	for {
+		read, err := input.Read(chunk)
+		held.Write(chunk[:read])
+
+		if excess := int64(held.Len()) - n; excess > 0 {
+			if _, err := out.Write(held.Next(int(excess))); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHead(t *testing.T) {
+
+	input := "one\ntwo\nthree\nfour"
+
+	tests := []struct {
+		copyHead func(out *bytes.Buffer, n int64) error
+		n        int64
+		want     string
+	}{
+		{lines, 2, "one\ntwo\n"},
+		{lines, 10, input},
+		{lines, 0, ""},
+		{lines, -1, "one\ntwo\nthree\n"},
+		{lines, -3, "one\n"},
+		{lines, -9, ""},
+		{byteCount, 5, "one\nt"},
+		{byteCount, -5, "one\ntwo\nthree"},
+		{byteCount, -100, ""},
+	}
+
+	for i, test := range tests {
+		var out bytes.Buffer
+		if err := test.copyHead(&out, test.n); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%d: n=%d got %q, want %q", i, test.n, out.String(), test.want)
+		}
+	}
+}
+
+func lines(out *bytes.Buffer, n int64) error {
+	return headLines(out, strings.NewReader("one\ntwo\nthree\nfour"), n)
+}
+
+func byteCount(out *bytes.Buffer, n int64) error {
+	return headBytes(out, strings.NewReader("one\ntwo\nthree\nfour"), n)
+}
+
+func TestParseCount(t *testing.T) {
+
+	tests := map[string]int64{"10": 10, "-3": -3, "2K": 2048, "1M": 1 << 20, "0": 0}
+	for value, want := range tests {
+		if got, err := parseCount(value); err != nil || got != want {
+			t.Errorf("parseCount(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "x", "--1", "1.5"} {
+		if _, err := parseCount(value); err == nil {
+			t.Errorf("parseCount(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Command cchead prints the first lines or bytes of each file, or of
+// standard input, like head.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cchead [flags] [file ...]")
+	fmt.Fprintln(out, "Print the first 10 lines of each file, or of standard input when there are none")
+	fmt.Fprintln(out, "or the file is -.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cchead"
+
+	lines := flag.String("n", "10", "print the first `N` lines, or all but the last N with -N")
+	bytes := flag.String("c", "", "print the first `N` bytes, or all but the last N with -N")
+	quiet := flag.Bool("q", false, "never print headers giving file names")
+	verbose := flag.Bool("v", false, "always print headers giving file names")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	count := *lines
+	copyHead := headLines
+	if *bytes != "" {
+		count, copyHead = *bytes, headBytes
+	}
+
+	n, err := parseCount(count)
+	if err != nil {
+		cli.Exit(cli.Usagef("invalid count %q: %v", count, err))
+	}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{cli.Stdin}
+	}
+
+	headers := *verbose || (len(paths) > 1 && !*quiet)
+
+	out := bufio.NewWriter(os.Stdout)
+	ok := true
+
+	for i, path := range paths {
+		if headers {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "==> %s <==\n", cli.DisplayName(path))
+		}
+
+		if err := head(out, path, n, copyHead); err != nil {
+			cli.Report(err)
+			ok = false
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		cli.Exit(err)
+	}
+	if !ok {
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+func head(out io.Writer, path string, n int64, copyHead func(io.Writer, io.Reader, int64) error) error {
+
+	file, closeFile, err := cli.Open(path)
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	defer closeFile()
+
+	if err := copyHead(out, file, n); err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	return nil
+}
+
+// parseCount parses the value of -n or -c, where a leading - asks for all
+// but that many from the end.
+func parseCount(value string) (int64, error) {
+
+	rest, allBut := strings.CutPrefix(value, "-")
+
+	count, err := cli.ParseCount(rest)
+	if allBut {
+		count = -count
+	}
+	return count, err
+}
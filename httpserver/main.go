@@ -0,0 +1,68 @@
+// Command cchttpd is an HTTP/1.1 server written directly on TCP
+// connections, without net/http: it parses requests itself, routes them,
+// serves static files, and keeps connections alive between requests.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cchttpd [flags]")
+	fmt.Fprintln(out, "Serve the files under a directory over HTTP.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cchttpd"
+
+	addr := flag.String("addr", ":8080", "listen on this `address`")
+	root := flag.String("root", ".", "serve the files under this `directory`")
+	idle := flag.Duration("idle-timeout", time.Minute, "close connections idle for this long")
+	quiet := flag.Bool("quiet", false, "do not log each request")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		cli.Exit(cli.Usagef("unexpected argument %q", flag.Arg(0)))
+	}
+	if info, err := os.Stat(*root); err != nil || !info.IsDir() {
+		cli.Exit(cli.Usagef("-root %s is not a directory", *root))
+	}
+
+	var router Router
+	router.Handle("GET", "/", fileServer(*root, "/"))
+
+	s := &server{handler: router.Serve, idleTimeout: *idle}
+	if !*quiet {
+		s.accessLog = log.New(os.Stderr, "cchttpd: ", log.LstdFlags)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		cli.Exit(err)
+	}
+	fmt.Fprintf(os.Stderr, "cchttpd: serving %s on %s\n", *root, listener.Addr())
+
+	if err := s.serve(ctx, listener); err != nil {
+		cli.Exit(err)
+	}
+}
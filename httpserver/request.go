@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Limits on the parts of a request, so a client cannot make the server
+// hold unbounded amounts of it.
+const (
+	maxLineSize = 8 * 1024
+	maxHeaders  = 100
+)
+
+// Header holds header fields by their canonical names, such as
+// Content-Type, each with its values in the order received.
+type Header map[string][]string
+
+// Get returns the first value of the field named name, or "".
+func (h Header) Get(name string) string {
+	if values := h[textproto.CanonicalMIMEHeaderKey(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set replaces the values of the field named name with value.
+func (h Header) Set(name, value string) {
+	h[textproto.CanonicalMIMEHeaderKey(name)] = []string{value}
+}
+
+// Request is a parsed HTTP/1.x request.
+type Request struct {
+	Method string
+	Target string
+	Path   string
+	Query  url.Values
+	Proto  string
+	Header Header
+
+	// Body reads the request body, decoded from chunks if it was sent in
+	// them. It is empty if the request has none.
+	Body io.Reader
+}
+
+// keepAlive reports whether the client wants the connection kept open
+// after this request: by default in HTTP/1.1, and only if asked for in
+// HTTP/1.0.
+func (r *Request) keepAlive() bool {
+
+	connection := strings.ToLower(r.Header.Get("Connection"))
+	if r.Proto == "HTTP/1.0" {
+		return connection == "keep-alive"
+	}
+	return connection != "close"
+}
+
+// statusError is a malformed request, answered with its status before the
+// connection is closed.
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...any) error {
+	return &statusError{status: statusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+// readRequest reads a request line and header fields from reader, leaving
+// the body to be read through the request's Body.
+func readRequest(reader *bufio.Reader) (*Request, error) {
+
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clients may send empty lines between requests
+	for line == "" {
+		if line, err = readLine(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	method, rest, ok1 := strings.Cut(line, " ")
+	target, proto, ok2 := strings.Cut(rest, " ")
+	if !ok1 || !ok2 || method == "" || target == "" {
+		return nil, badRequest("malformed request line %q", line)
+	}
+	if proto != "HTTP/1.1" && proto != "HTTP/1.0" {
+		return nil, &statusError{status: statusVersionNotSupported, msg: fmt.Sprintf("unsupported protocol %q", proto)}
+	}
+
+	parsed, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, badRequest("malformed request target %q", target)
+	}
+
+	r := &Request{
+		Method: method,
+		Target: target,
+		Path:   parsed.Path,
+		Query:  parsed.Query(),
+		Proto:  proto,
+		Header: Header{},
+	}
+
+	if err := readHeader(reader, r.Header); err != nil {
+		return nil, err
+	}
+
+	if r.Body, err = bodyReader(reader, r.Header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// readHeader reads header fields up to the empty line that ends them.
+func readHeader(reader *bufio.Reader, header Header) error {
+
+	for n := 0; ; n++ {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+		if n == maxHeaders {
+			return &statusError{status: statusHeaderFieldsTooLarge, msg: "too many header fields"}
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return badRequest("malformed header field %q", line)
+		}
+
+		key := textproto.CanonicalMIMEHeaderKey(name)
+		header[key] = append(header[key], strings.TrimSpace(value))
+	}
+}
+
+// bodyReader returns a reader for the body the header describes: chunked,
+// of a given length, or absent.
+func bodyReader(reader *bufio.Reader, header Header) (io.Reader, error) {
+
+	if encoding := header.Get("Transfer-Encoding"); encoding != "" {
+		if !strings.EqualFold(encoding, "chunked") {
+			return nil, &statusError{status: statusNotImplemented, msg: fmt.Sprintf("unsupported transfer encoding %q", encoding)}
+		}
+		return &chunkedReader{reader: reader}, nil
+	}
+
+	length := header.Get("Content-Length")
+	if length == "" {
+		return eofReader{}, nil
+	}
+
+	n, err := strconv.ParseInt(length, 10, 64)
+	if err != nil || n < 0 {
+		return nil, badRequest("invalid Content-Length %q", length)
+	}
+	return io.LimitReader(reader, n), nil
+}
+
+// readLine reads a line ended by CRLF, or by a bare LF, which clients are
+// allowed some leniency about, and returns it without the ending.
+func readLine(reader *bufio.Reader) (string, error) {
+
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", &statusError{status: statusHeaderFieldsTooLarge, msg: "request line or header field too long"}
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+// chunkedReader decodes a body sent with chunked transfer encoding: chunks
+// each preceded by their size in hex, ended by a chunk of size 0 and any
+// trailer fields, which are read and dropped.
+type chunkedReader struct {
+	reader *bufio.Reader
+
+	// left is how much of the current chunk is still to be read
+	left int64
+	done bool
+}
+
+func (c *chunkedReader) Read(buffer []byte) (int, error) {
+
+	if c.done {
+		return 0, io.EOF
+	}
+
+	if c.left == 0 {
+		line, err := readLine(c.reader)
+		if err != nil {
+			return 0, err
+		}
+
+		// Chunk extensions after a ; are ignored
+		size, _, _ := strings.Cut(line, ";")
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 16, 64)
+		if err != nil || n < 0 {
+			return 0, badRequest("malformed chunk size %q", line)
+		}
+
+		if n == 0 {
+			c.done = true
+			if err := readHeader(c.reader, Header{}); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		c.left = n
+	}
+
+	n, err := c.reader.Read(buffer[:min(int64(len(buffer)), c.left)])
+	c.left -= int64(n)
+
+	if c.left == 0 && err == nil {
+		// Each chunk's data is followed by CRLF
+		if line, lineErr := readLine(c.reader); lineErr != nil || line != "" {
+			return n, badRequest("chunk not followed by CRLF")
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
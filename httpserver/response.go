@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is what a handler answers a request with.
+type Response struct {
+	Status int
+	Header Header
+
+	// Body is the content, sent with ContentLength as its length, or in
+	// chunks if ContentLength is negative
+	Body          io.Reader
+	ContentLength int64
+}
+
+// newResponse returns a response with a body of text.
+func newResponse(status int, contentType, text string) *Response {
+	r := &Response{
+		Status:        status,
+		Header:        Header{},
+		Body:          strings.NewReader(text),
+		ContentLength: int64(len(text)),
+	}
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
+
+// errorResponse returns a plain text response giving status and its
+// reason.
+func errorResponse(status int) *Response {
+	return newResponse(status, "text/plain; charset=utf-8", fmt.Sprintf("%d %s\n", status, statusText[status]))
+}
+
+// write writes the response to w, without its body for a HEAD request.
+// Whether the connection is to be closed afterwards goes in its
+// Connection field.
+func (r *Response) write(w *bufio.Writer, method string, keepAlive bool) error {
+
+	if r.Header == nil {
+		r.Header = Header{}
+	}
+
+	chunked := r.ContentLength < 0
+	if chunked {
+		r.Header.Set("Transfer-Encoding", "chunked")
+	} else {
+		r.Header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	r.Header.Set("Date", time.Now().UTC().Format(timeFormat))
+	if !keepAlive {
+		r.Header.Set("Connection", "close")
+	}
+
+	fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", r.Status, statusText[r.Status])
+
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			fmt.Fprintf(w, "%s: %s\r\n", name, value)
+		}
+	}
+	w.WriteString("\r\n")
+
+	if method == "HEAD" || r.Body == nil {
+		if chunked && method != "HEAD" {
+			w.WriteString("0\r\n\r\n")
+		}
+		return w.Flush()
+	}
+
+	if chunked {
+		if _, err := io.Copy(&chunkedWriter{w}, r.Body); err != nil {
+			return err
+		}
+		w.WriteString("0\r\n\r\n")
+	} else if _, err := io.CopyN(w, r.Body, r.ContentLength); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// chunkedWriter writes each write as one chunk.
+type chunkedWriter struct {
+	w *bufio.Writer
+}
+
+func (c *chunkedWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	fmt.Fprintf(c.w, "%x\r\n", len(p))
+	c.w.Write(p)
+	_, err := c.w.WriteString("\r\n")
+	return len(p), err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// Handler answers a request.
+type Handler func(*Request) *Response
+
+// route is a pattern and the handlers for it by method.
+type route struct {
+	pattern  string
+	handlers map[string]Handler
+}
+
+// Router sends each request to the handler registered for its method and
+// path. A pattern ending in / matches every path below it, and the longest
+// matching pattern wins; any other pattern matches only that path.
+type Router struct {
+	routes []*route
+}
+
+// Handle registers handler for requests with method to paths matching
+// pattern. A GET handler also answers HEAD requests.
+func (rt *Router) Handle(method, pattern string, handler Handler) {
+
+	for _, r := range rt.routes {
+		if r.pattern == pattern {
+			r.handlers[method] = handler
+			return
+		}
+	}
+	rt.routes = append(rt.routes, &route{pattern: pattern, handlers: map[string]Handler{method: handler}})
+}
+
+// Serve answers r, with 404 Not Found when no pattern matches its path and
+// 405 Method Not Allowed, listing the methods that are, when one does but
+// not for its method.
+func (rt *Router) Serve(r *Request) *Response {
+
+	var best *route
+	for _, candidate := range rt.routes {
+		if matches(candidate.pattern, r.Path) && (best == nil || len(candidate.pattern) > len(best.pattern)) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return errorResponse(statusNotFound)
+	}
+
+	handler, ok := best.handlers[r.Method]
+	if !ok && r.Method == "HEAD" {
+		handler, ok = best.handlers["GET"]
+	}
+	if !ok {
+		allowed := make([]string, 0, len(best.handlers))
+		for method := range best.handlers {
+			allowed = append(allowed, method)
+		}
+		slices.Sort(allowed)
+
+		response := errorResponse(statusMethodNotAllowed)
+		response.Header.Set("Allow", strings.Join(allowed, ", "))
+		return response
+	}
+
+	return handler(r)
+}
+
+func matches(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return path == pattern
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// server serves HTTP/1.1 on connections it accepts itself, each in its own
+// goroutine, keeping them open for further requests unless the client or
+// an error says otherwise.
+type server struct {
+	handler Handler
+
+	// idleTimeout is how long a connection may wait for its next request
+	idleTimeout time.Duration
+
+	// accessLog, if set, logs every request answered
+	accessLog *log.Logger
+}
+
+// serve accepts connections on listener until ctx is done, then waits for
+// the requests in progress to be answered and closes idle connections.
+func (s *server) serve(ctx context.Context, listener net.Listener) error {
+
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle answers the requests on conn one after another.
+func (s *server) handle(ctx context.Context, conn net.Conn) {
+
+	defer conn.Close()
+
+	// Shutting down stops the wait for another request straight away
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	reader := bufio.NewReaderSize(conn, maxLineSize)
+	w := bufio.NewWriter(conn)
+
+	for ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+
+		r, err := readRequest(reader)
+
+		var status *statusError
+		if errors.As(err, &status) {
+			errorResponse(status.status).write(w, "", false)
+			lingeringClose(conn, reader)
+			return
+		}
+		if err != nil {
+			// The client closed the connection or went quiet
+			return
+		}
+
+		// A request in progress is not cut short by the idle timeout
+		conn.SetReadDeadline(time.Time{})
+
+		response := s.handler(r)
+
+		// Only wait for another request if this one will have been read
+		// in full, and the server is not shutting down
+		keepAlive := r.keepAlive() && ctx.Err() == nil
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			keepAlive = false
+		}
+
+		err = response.write(w, r.Method, keepAlive)
+		if closer, ok := response.Body.(io.Closer); ok {
+			closer.Close()
+		}
+
+		if s.accessLog != nil {
+			s.accessLog.Printf("%s %s %s %d", conn.RemoteAddr(), r.Method, r.Target, response.Status)
+		}
+
+		if err != nil || !keepAlive {
+			return
+		}
+	}
+}
+
+// lingeringClose shuts the sending side of conn and reads for a moment
+// before it is closed. Closing a connection with input still unread makes
+// TCP reset it, which can discard a reply the client has not read yet.
+func lingeringClose(conn net.Conn, reader io.Reader) {
+
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	io.Copy(io.Discard, reader)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves a directory with index.html and style.css under
+// /files/, and echoes request bodies posted to /echo, returning the
+// server's address.
+func startServer(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, contents := range map[string]string{
+		"index.html":     "<h1>home</h1>",
+		"style.css":      "body {}",
+		"sub/index.html": "<h1>sub</h1>",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A file beside the root that must not be reachable
+	os.WriteFile(filepath.Join(filepath.Dir(root), "secret"), []byte("secret"), 0o644)
+
+	var router Router
+	router.Handle("GET", "/files/", fileServer(root, "/files"))
+	router.Handle("POST", "/echo", func(r *Request) *Response {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return errorResponse(statusBadRequest)
+		}
+		return newResponse(statusOK, "text/plain", string(body))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &server{handler: router.Serve, idleTimeout: 5 * time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.serve(ctx, listener) }()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	return listener.Addr().String()
+}
+
+func TestServer(t *testing.T) {
+
+	addr := startServer(t)
+
+	tests := []struct {
+		method, path string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"GET", "/files/style.css", 200, "text/css; charset=utf-8", "body {}"},
+		{"GET", "/files/", 200, "text/html; charset=utf-8", "<h1>home</h1>"},
+		{"GET", "/files/sub", 200, "text/html; charset=utf-8", "<h1>sub</h1>"},
+		{"HEAD", "/files/style.css", 200, "text/css; charset=utf-8", ""},
+		{"GET", "/files/missing", 404, "text/plain; charset=utf-8", "404 Not Found\n"},
+		{"GET", "/elsewhere", 404, "text/plain; charset=utf-8", "404 Not Found\n"},
+		{"DELETE", "/files/style.css", 405, "text/plain; charset=utf-8", "405 Method Not Allowed\n"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, "http://"+addr+test.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status || resp.Header.Get("Content-Type") != test.contentType || string(body) != test.body {
+			t.Errorf("%s %s: got %d %q %q, want %d %q %q", test.method, test.path,
+				resp.StatusCode, resp.Header.Get("Content-Type"), body, test.status, test.contentType, test.body)
+		}
+	}
+}
+
+// exchange sends raw requests on one connection and returns everything the
+// server sends back until it closes the connection.
+func exchange(t *testing.T, addr, requests string) string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, requests); err != nil {
+		t.Fatal(err)
+	}
+
+	replies, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(replies)
+}
+
+func TestKeepAlive(t *testing.T) {
+
+	addr := startServer(t)
+
+	// Two requests on one connection, the second asking to close it
+	replies := exchange(t, addr,
+		"GET /files/style.css HTTP/1.1\r\nHost: x\r\n\r\n"+
+			"POST /echo HTTP/1.1\r\nHost: x\r\nConnection: close\r\nTransfer-Encoding: chunked\r\n\r\n"+
+			"5\r\nhello\r\n6;ext=1\r\n world\r\n0\r\n\r\n")
+
+	reader := bufio.NewReader(strings.NewReader(replies))
+	for _, want := range []string{"body {}", "hello world"} {
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatalf("reading replies %q: %v", replies, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != want {
+			t.Errorf("got body %q, want %q", body, want)
+		}
+	}
+
+	// HTTP/1.0 closes after one request unless asked otherwise
+	replies = exchange(t, addr, "GET /files/style.css HTTP/1.0\r\n\r\n")
+	if !strings.HasPrefix(replies, "HTTP/1.1 200 OK\r\n") || !strings.Contains(replies, "Connection: close\r\n") {
+		t.Errorf("HTTP/1.0 request got %q", replies)
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+
+	addr := startServer(t)
+
+	tests := map[string]string{
+		"GET /files/../../secret HTTP/1.1\r\nConnection: close\r\n\r\n":  "HTTP/1.1 404 ",
+		"GET /files/%2e%2e/secret HTTP/1.1\r\nConnection: close\r\n\r\n": "HTTP/1.1 404 ",
+		"nonsense\r\n\r\n":                                             "HTTP/1.1 400 ",
+		"GET / HTTP/2.0\r\n\r\n":                                       "HTTP/1.1 505 ",
+		"GET / HTTP/1.1\r\nno colon\r\n\r\n":                           "HTTP/1.1 400 ",
+		"GET / HTTP/1.1\r\n" + strings.Repeat("x", 10000) + "\r\n\r\n": "HTTP/1.1 431 ",
+	}
+
+	for request, want := range tests {
+		if replies := exchange(t, addr, request); !strings.HasPrefix(replies, want) {
+			t.Errorf("%.40q got %.60q, want %q", request, replies, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileServer returns a handler serving the files under root, with the
+// path of each request, less prefix, taken as a path below root. A
+// directory is served as its index.html. The handler never serves anything
+// outside root, whatever the path.
+func fileServer(root, prefix string) Handler {
+	return func(r *Request) *Response {
+
+		// Cleaning a rooted path removes every .. that would climb out
+		name := path.Clean("/" + strings.TrimPrefix(r.Path, prefix))
+		full := filepath.Join(root, filepath.FromSlash(name))
+
+		info, err := os.Stat(full)
+		if err == nil && info.IsDir() {
+			full = filepath.Join(full, "index.html")
+			info, err = os.Stat(full)
+		}
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return errorResponse(statusNotFound)
+		case errors.Is(err, fs.ErrPermission):
+			return errorResponse(statusForbidden)
+		case err != nil:
+			return errorResponse(statusInternalServerError)
+		case !info.Mode().IsRegular():
+			return errorResponse(statusForbidden)
+		}
+
+		modified := info.ModTime().UTC().Truncate(time.Second)
+		if since, err := time.Parse(timeFormat, r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+			return &Response{Status: statusNotModified, Header: Header{}}
+		}
+
+		file, err := os.Open(full)
+		if err != nil {
+			return errorResponse(statusForbidden)
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(full))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		response := &Response{
+			Status:        statusOK,
+			Header:        Header{},
+			Body:          file,
+			ContentLength: info.Size(),
+		}
+		response.Header.Set("Content-Type", contentType)
+		response.Header.Set("Last-Modified", modified.Format(timeFormat))
+		return response
+	}
+}
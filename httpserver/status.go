@@ -0,0 +1,31 @@
+package main
+
+// Status codes the server sends.
+const (
+	statusOK                   = 200
+	statusNotModified          = 304
+	statusBadRequest           = 400
+	statusForbidden            = 403
+	statusNotFound             = 404
+	statusMethodNotAllowed     = 405
+	statusHeaderFieldsTooLarge = 431
+	statusInternalServerError  = 500
+	statusNotImplemented       = 501
+	statusVersionNotSupported  = 505
+)
+
+var statusText = map[int]string{
+	statusOK:                   "OK",
+	statusNotModified:          "Not Modified",
+	statusBadRequest:           "Bad Request",
+	statusForbidden:            "Forbidden",
+	statusNotFound:             "Not Found",
+	statusMethodNotAllowed:     "Method Not Allowed",
+	statusHeaderFieldsTooLarge: "Request Header Fields Too Large",
+	statusInternalServerError:  "Internal Server Error",
+	statusNotImplemented:       "Not Implemented",
+	statusVersionNotSupported:  "HTTP Version Not Supported",
+}
+
+// timeFormat is the format of dates in header fields.
+const timeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
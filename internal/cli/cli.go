@@ -0,0 +1,95 @@
+// Package cli holds the conventions shared by the command-line tools in this
+// repository, so that every challenge behaves the same way: exit statuses,
+// error reporting, standard input given as "-", terminal and color
+// detection, and flag helpers.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Exit statuses
+const (
+	// ExitOK means the command did everything it was asked to
+	ExitOK = 0
+
+	// ExitFailure means at least one input could not be processed, or a
+	// check the command makes did not pass
+	ExitFailure = 1
+
+	// ExitUsage means the command line was invalid and nothing was done
+	ExitUsage = 2
+)
+
+// Name is the program name that prefixes diagnostics. Commands set it to
+// their documented name before reporting anything.
+var Name = filepath.Base(os.Args[0])
+
+// FileError is a failure to open, read or walk a single input.
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+
+	// Drop the "open <path>" prefix that would repeat the file name
+	var pathErr *fs.PathError
+	if errors.As(e.Err, &pathErr) {
+		return DisplayName(e.File) + ": " + pathErr.Err.Error()
+	}
+
+	return DisplayName(e.File) + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// UsageError is an invalid flag or argument.
+type UsageError struct {
+	msg string
+}
+
+func (e *UsageError) Error() string {
+	return e.msg
+}
+
+// Usagef returns a *UsageError with a formatted message.
+func Usagef(format string, args ...any) error {
+	return &UsageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Report prints err to stderr prefixed with the program name.
+func Report(err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", Name, err)
+}
+
+// Warn prints a diagnostic that does not affect the exit status.
+func Warn(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, Name+": "+format+"\n", args...)
+}
+
+// Exit reports err, if any, and exits with the status documented for it:
+// ExitUsage for a *UsageError, with a pointer to -help, and ExitFailure for
+// anything else.
+func Exit(err error) {
+
+	if err == nil {
+		os.Exit(ExitOK)
+	}
+
+	Report(err)
+
+	var usage *UsageError
+	if errors.As(err, &usage) {
+		fmt.Fprintf(os.Stderr, "Try '%s -help' for more information.\n", Name)
+		os.Exit(ExitUsage)
+	}
+
+	os.Exit(ExitFailure)
+}
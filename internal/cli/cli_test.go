@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStderr returns what run writes to os.Stderr.
+func captureStderr(t *testing.T, run func()) string {
+
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := os.Stderr
+	os.Stderr = writer
+	defer func() { os.Stderr = stderr }()
+
+	run()
+	writer.Close()
+
+	out, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestReportAndWarn(t *testing.T) {
+
+	name := Name
+	Name = "tool"
+	defer func() { Name = name }()
+
+	tests := []struct {
+		name string
+		run  func()
+		want string
+	}{
+		{"report", func() { Report(errors.New("broken")) }, "tool: broken\n"},
+		{
+			"file error",
+			func() {
+				Report(&FileError{File: "a.txt", Err: &fs.PathError{Op: "open", Path: "a.txt", Err: fs.ErrNotExist}})
+			},
+			"tool: a.txt: file does not exist\n",
+		},
+		{"standard input", func() { Report(&FileError{File: Stdin, Err: errors.New("read failed")}) }, "tool: standard input: read failed\n"},
+		{"warn", func() { Warn("skipped %d of %s", 2, "b") }, "tool: skipped 2 of b\n"},
+		{"warn percent", func() { Warn("100%% done") }, "tool: 100% done\n"},
+	}
+
+	for _, test := range tests {
+		if got := captureStderr(t, test.run); got != test.want {
+			t.Errorf("%s: wrote %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+
+	// The test binary runs itself to exit with the error named here
+	if kind := os.Getenv("CLI_TEST_EXIT"); kind != "" {
+		Name = "tool"
+		switch kind {
+		case "ok":
+			Exit(nil)
+		case "usage":
+			Exit(fmt.Errorf("parsing: %w", Usagef("bad flag -%s", "x")))
+		case "failure":
+			Exit(&FileError{File: "a.txt", Err: errors.New("unreadable")})
+		}
+		return
+	}
+
+	tests := []struct {
+		kind   string
+		status int
+		stderr string
+	}{
+		{"ok", ExitOK, ""},
+		{"usage", ExitUsage, "tool: parsing: bad flag -x\nTry 'tool -help' for more information.\n"},
+		{"failure", ExitFailure, "tool: a.txt: unreadable\n"},
+	}
+
+	for _, test := range tests {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestExit$")
+		cmd.Env = append(os.Environ(), "CLI_TEST_EXIT="+test.kind)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		cmd.Run()
+
+		if status := cmd.ProcessState.ExitCode(); status != test.status {
+			t.Errorf("%s: exit status %d, want %d", test.kind, status, test.status)
+		}
+		if stderr.String() != test.stderr {
+			t.Errorf("%s: stderr %q, want %q", test.kind, stderr.String(), test.stderr)
+		}
+	}
+}
+
+func TestOptionalFlag(t *testing.T) {
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "", false},
+		{[]string{"-color"}, "auto", false},
+		{[]string{"-color=always"}, "always", false},
+		{[]string{"-color=false"}, "", false},
+		{[]string{"-color=true"}, "auto", false},
+		{[]string{"-color=sometimes"}, "", true},
+	}
+
+	for _, test := range tests {
+		color := &OptionalFlag{Implied: "auto", Allowed: []string{"", "auto", "always", "never"}}
+
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		flags.Var(color, "color", "")
+
+		err := flags.Parse(test.args)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error %v, want error %v", test.args, err, test.wantErr)
+			continue
+		}
+		if color.Value != test.want {
+			t.Errorf("%q: value %q, want %q", test.args, color.Value, test.want)
+		}
+	}
+}
+
+func TestChoice(t *testing.T) {
+
+	if err := Choice("b", "a", "b"); err != nil {
+		t.Errorf("Choice(b) = %v, want nil", err)
+	}
+
+	err := Choice("c", "", "a", "b")
+	if want := "must be one of a, b"; err == nil || err.Error() != want {
+		t.Errorf("Choice(c) = %v, want %q", err, want)
+	}
+}
+
+func TestParseCount(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"42", 42, false},
+		{"2K", 2048, false},
+		{"3M", 3 << 20, false},
+		{"1G", 1 << 30, false},
+		{"", 0, true},
+		{"K", 0, true},
+		{"-1", 0, true},
+		{"1.5K", 0, true},
+		{"1T", 0, true},
+		{"9223372036854775807G", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseCount(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseCount(%q) = %d, %v, want %d, error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+
+	file, closeFile, err := Open(Stdin)
+	if err != nil || file != os.Stdin {
+		t.Fatalf("Open(%q) = %v, %v, want standard input", Stdin, file, err)
+	}
+	closeFile()
+
+	// Closing standard input is left to the caller
+	if _, err := os.Stdin.Stat(); err != nil {
+		t.Errorf("standard input was closed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, closeFile, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeFile()
+	if _, err := file.Stat(); err == nil {
+		t.Errorf("Open(%q) left the file open", path)
+	}
+
+	if _, _, err := Open(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(missing) = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+
+	tests := map[string]string{
+		Stdin:   "standard input",
+		"":      "standard input",
+		"a.txt": "a.txt",
+		"--":    "--",
+	}
+
+	for name, want := range tests {
+		if got := DisplayName(name); got != want {
+			t.Errorf("DisplayName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+
+	// A regular file is never a terminal
+	file, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if IsTerminal(file) {
+		t.Error("IsTerminal reported a regular file as a terminal")
+	}
+
+	tests := map[string]bool{
+		"always": true,
+		"never":  false,
+		"auto":   false,
+		"":       false,
+	}
+
+	for mode, want := range tests {
+		if got := UseColor(mode, file); got != want {
+			t.Errorf("UseColor(%q) = %v, want %v", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OptionalFlag is a string flag that may also be given without a value, as
+// -name, in which case it takes its Implied value. Only the Allowed values
+// are accepted; include "" to allow the flag to be turned off with
+// -name=false.
+type OptionalFlag struct {
+	Value   string
+	Implied string
+	Allowed []string
+}
+
+func (f *OptionalFlag) String() string {
+	return f.Value
+}
+
+func (f *OptionalFlag) Set(value string) error {
+
+	if value == "true" {
+		value = f.Implied
+	}
+	if value == "false" {
+		value = ""
+	}
+
+	if err := Choice(value, f.Allowed...); err != nil {
+		return err
+	}
+	f.Value = value
+	return nil
+}
+
+// IsBoolFlag lets the flag package accept the flag without a value.
+func (f *OptionalFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Choice checks that value is one of allowed, returning an error listing
+// the non-empty choices if not.
+func Choice(value string, allowed ...string) error {
+
+	for _, choice := range allowed {
+		if value == choice {
+			return nil
+		}
+	}
+
+	var choices []string
+	for _, choice := range allowed {
+		if choice != "" {
+			choices = append(choices, choice)
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(choices, ", "))
+}
+
+// ParseCount parses a count of lines or bytes given on the command line: a
+// non-negative number with an optional K, M or G suffix for powers of 1024.
+func ParseCount(value string) (int64, error) {
+
+	multiplier := int64(1)
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			multiplier, value = 1<<10, value[:n-1]
+		case 'M':
+			multiplier, value = 1<<20, value[:n-1]
+		case 'G':
+			multiplier, value = 1<<30, value[:n-1]
+		}
+	}
+
+	count, err := strconv.ParseUint(value, 10, 63)
+	if err != nil || int64(count) > math.MaxInt64/multiplier {
+		return 0, errors.New("must be a number of lines or bytes")
+	}
+
+	return int64(count) * multiplier, nil
+}
@@ -0,0 +1,36 @@
+package cli
+
+import "os"
+
+// Stdin is the file argument that stands for standard input.
+const Stdin = "-"
+
+// IsStdin reports whether name stands for standard input, either as "-" or
+// as the empty name of an input read when no files are given.
+func IsStdin(name string) bool {
+	return name == Stdin || name == ""
+}
+
+// DisplayName returns how name is referred to in diagnostics.
+func DisplayName(name string) string {
+	if IsStdin(name) {
+		return "standard input"
+	}
+	return name
+}
+
+// Open opens the named file for reading, or returns standard input for
+// "-". Closing standard input is left to the caller's discretion, so the
+// returned close function only closes files Open opened.
+func Open(name string) (file *os.File, close func() error, err error) {
+
+	if name == Stdin {
+		return os.Stdin, func() error { return nil }, nil
+	}
+
+	file, err = os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import "os"
+
+// IsTerminal reports whether file is a terminal.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// UseColor decides whether to color output written to out for a -color
+// mode of "always", "never" or "auto", which colors a terminal unless the
+// NO_COLOR environment variable is set.
+func UseColor(mode string, out *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "auto":
+		return os.Getenv("NO_COLOR") == "" && IsTerminal(out)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,8 @@
+module codechallenge/internal
+
+go 1.23.2
+
+require (
+	github.com/klauspost/compress v1.17.11
+	github.com/rivo/uniseg v0.4.7
+)
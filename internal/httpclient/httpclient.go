@@ -0,0 +1,122 @@
+// Package httpclient holds the HTTP client plumbing shared by the tools in
+// this repository that make requests: a client configured for redirects
+// and TLS verification, and tracing of each request and response header
+// in the style of curl -v.
+package httpclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+)
+
+// Options configure a client made by New.
+type Options struct {
+	// Follow makes the client follow redirects, up to MaxRedirects of
+	// them; otherwise a redirect is returned as the response
+	Follow       bool
+	MaxRedirects int
+
+	// Insecure skips verifying servers' TLS certificates
+	Insecure bool
+
+	// Trace, if set, receives each request sent and each response header
+	// received, including those of redirects
+	Trace io.Writer
+}
+
+// New returns a client with its own transport, configured by opts.
+func New(opts Options) *http.Client {
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !opts.Follow {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("maximum (%d) redirects followed", opts.MaxRedirects)
+			}
+			return nil
+		},
+	}
+	if opts.Trace != nil {
+		client.Transport = &tracingTransport{base: transport, out: opts.Trace}
+	}
+	return client
+}
+
+// tracingTransport writes each request it sends and each response header
+// it receives, as curl -v does.
+type tracingTransport struct {
+	base http.RoundTripper
+	out  io.Writer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			verb := "Connected to"
+			if info.Reused {
+				verb = "Reusing connection to"
+			}
+			fmt.Fprintf(t.out, "* %s %s (%s)\n", verb, req.URL.Host, info.Conn.RemoteAddr())
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				fmt.Fprintf(t.out, "* TLS connection using %s / %s\n",
+					tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the headers the request carries are shown; those the transport
+	// adds as it writes the request, such as a default User-Agent or
+	// Accept-Encoding, never reach req.Header
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(t.out, "> %s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(t.out, "> Host: %s\n", host)
+	WriteHeader(t.out, req.Header, "> ", "\n")
+	fmt.Fprintln(t.out, ">")
+
+	fmt.Fprintf(t.out, "< %s %s\n", resp.Proto, resp.Status)
+	WriteHeader(t.out, resp.Header, "< ", "\n")
+	fmt.Fprintln(t.out, "<")
+
+	return resp, nil
+}
+
+// WriteHeader writes the fields of header sorted by name, each line
+// starting with prefix and ending with eol.
+func WriteHeader(out io.Writer, header http.Header, prefix, eol string) {
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(out, "%s%s: %s%s", prefix, name, value, eol)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserAgent(t *testing.T) {
+
+	agents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.UserAgent()
+	}))
+	defer server.Close()
+
+	var trace strings.Builder
+	client := New(Options{Trace: &trace})
+
+	req, err := http.NewRequest("GET", server.URL+"/page", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", "tool/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := <-agents; got != "tool/1.0" {
+		t.Errorf("server saw User-Agent %q, want %q", got, "tool/1.0")
+	}
+
+	for _, want := range []string{"> GET /page HTTP/1.1\n", "> User-Agent: tool/1.0\n", "< HTTP/1.1 200 OK\n"} {
+		if !strings.Contains(trace.String(), want) {
+			t.Errorf("trace is missing %q:\n%s", want, trace.String())
+		}
+	}
+
+	// The default agent is added by the transport as it writes the request,
+	// so the trace cannot show it
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := <-agents; !strings.HasPrefix(got, "Go-http-client/") {
+		t.Errorf("server saw User-Agent %q, want the default", got)
+	}
+	if strings.Count(trace.String(), "User-Agent") != 1 {
+		t.Errorf("trace shows a User-Agent the request did not carry:\n%s", trace.String())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := New(Options{})
+	client.Timeout = 50 * time.Millisecond
+
+	_, err := client.Get(server.URL)
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+		t.Errorf("Get = %v, want a timeout", err)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+
+	// /n redirects to /n-1, and /0 answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		opts    Options
+		path    string
+		status  int
+		wantErr string
+	}{
+		{"not followed", Options{}, "/2", http.StatusFound, ""},
+		{"followed", Options{Follow: true, MaxRedirects: 5}, "/3", http.StatusOK, ""},
+		{"at the limit", Options{Follow: true, MaxRedirects: 3}, "/3", http.StatusOK, ""},
+		{"past the limit", Options{Follow: true, MaxRedirects: 2}, "/3", 0, "maximum (2) redirects followed"},
+	}
+
+	for _, test := range tests {
+		resp, err := New(test.opts).Get(server.URL + test.path)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: error %v, want %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+	}
+}
+
+func TestTraceRedirects(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		}
+	}))
+	defer server.Close()
+
+	var trace strings.Builder
+	resp, err := New(Options{Follow: true, MaxRedirects: 1, Trace: &trace}).Get(server.URL + "/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := []string{"> GET /old ", "< HTTP/1.1 301 Moved Permanently\n", "> GET /new ", "< HTTP/1.1 200 OK\n"}
+	rest := trace.String()
+	for _, line := range want {
+		i := strings.Index(rest, line)
+		if i < 0 {
+			t.Fatalf("trace is missing %q in order:\n%s", line, trace.String())
+		}
+		rest = rest[i+len(line):]
+	}
+}
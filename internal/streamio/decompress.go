@@ -0,0 +1,71 @@
+package streamio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic  = []byte{0x1F, 0x8B}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+	// After its header and block size digit, a bzip2 stream starts with a
+	// block, or with the end of the stream when it is empty
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// sniffSize is how many bytes Decompress looks at, enough for the longest
+// magic, bzip2's.
+const sniffSize = 10
+
+// Decompress sniffs the magic bytes at the start of input and returns a
+// reader over its decompressed content. Input in any other format is
+// returned as is. Gzip, bzip2 and zstd are recognized.
+func Decompress(input io.Reader) (io.ReadCloser, error) {
+
+	reader := bufio.NewReader(input)
+
+	// Peek returns fewer bytes for short inputs, which match nothing
+	magic, _ := reader.Peek(sniffSize)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(reader)
+
+	case isBzip2(magic):
+		return io.NopCloser(bzip2.NewReader(reader)), nil
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(reader), nil
+}
+
+// isBzip2 reports whether magic starts a bzip2 stream. "BZh" alone is too
+// common at the start of text to go by, so the block size digit and the
+// magic of the first block must follow it.
+func isBzip2(magic []byte) bool {
+
+	if len(magic) < sniffSize || !bytes.HasPrefix(magic, bzip2Magic) {
+		return false
+	}
+
+	if level := magic[3]; level < '1' || level > '9' {
+		return false
+	}
+
+	block := magic[4:sniffSize]
+	return bytes.Equal(block, bzip2BlockMagic) || bytes.Equal(block, bzip2EndMagic)
+}
@@ -0,0 +1,94 @@
+package streamio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval is how often progress is redrawn.
+const progressInterval = 250 * time.Millisecond
+
+// ProgressReader reports the bytes read through it, and the throughput, on
+// a single status line, which Finish clears.
+type ProgressReader struct {
+	input io.Reader
+	out   io.Writer
+	name  string
+
+	// size of the input when known, for showing a percentage
+	size int64
+
+	read    int64
+	start   time.Time
+	updated time.Time
+	drawn   bool
+}
+
+// NewProgressReader returns a ProgressReader over file that draws its
+// status line, labelled name, on out. The size of a regular file is shown
+// along with a percentage.
+func NewProgressReader(file *os.File, name string, out io.Writer) *ProgressReader {
+
+	progress := &ProgressReader{input: file, out: out, name: name}
+
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+		progress.size = info.Size()
+	}
+
+	progress.start = time.Now()
+	progress.updated = progress.start
+
+	return progress
+}
+
+func (progress *ProgressReader) Read(buffer []byte) (int, error) {
+
+	n, err := progress.input.Read(buffer)
+	progress.read += int64(n)
+
+	if now := time.Now(); now.Sub(progress.updated) >= progressInterval {
+		progress.updated = now
+		progress.draw(now)
+	}
+
+	return n, err
+}
+
+func (progress *ProgressReader) draw(now time.Time) {
+
+	rate := float64(progress.read) / now.Sub(progress.start).Seconds()
+
+	fmt.Fprintf(progress.out, "\r\033[K%s: %s", progress.name, FormatBytes(float64(progress.read)))
+	if progress.size > 0 {
+		fmt.Fprintf(progress.out, " of %s (%d%%)", FormatBytes(float64(progress.size)), progress.read*100/progress.size)
+	}
+	fmt.Fprintf(progress.out, ", %s/s", FormatBytes(rate))
+
+	progress.drawn = true
+}
+
+// Finish clears the status line so it does not mix with other output.
+func (progress *ProgressReader) Finish() {
+	if progress.drawn {
+		fmt.Fprint(progress.out, "\r\033[K")
+	}
+}
+
+// FormatBytes renders a byte count with a binary unit suffix.
+func FormatBytes(count float64) string {
+
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	unit := 0
+	for count >= 1024 && unit < len(units)-1 {
+		count /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", count, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", count, units[unit])
+}
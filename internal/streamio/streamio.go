@@ -0,0 +1,136 @@
+// Package streamio holds the reader plumbing shared by the tools in this
+// repository for working through large and streaming inputs: reading in
+// chunks sized to the input, counting and size-limited readers, readers
+// that stop with a context, progress reporting and transparent
+// decompression.
+package streamio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// Read sizes for BufferSizeFor. Regular files are read in large chunks to
+// keep system calls rare; pipes and terminals deliver at most a pipe's
+// worth at a time, so a large buffer would sit mostly empty.
+const (
+	RegularFileBufferSize = 256 * 1024
+	StreamBufferSize      = 64 * 1024
+)
+
+// BufferSizeFor picks the read size for file.
+func BufferSizeFor(file *os.File) int {
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+		return RegularFileBufferSize
+	}
+	return StreamBufferSize
+}
+
+// ForEachChunk reads input to the end, bufferSize bytes at a time, calling
+// chunk with each piece read. The slice is reused by the next read. It
+// returns the first error from reading or from chunk.
+func ForEachChunk(input io.Reader, bufferSize int, chunk func([]byte) error) error {
+
+	buffer := make([]byte, bufferSize)
+
+	for {
+		n, err := input.Read(buffer)
+		if n > 0 {
+			if err := chunk(buffer[:n]); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// CountingReader counts the bytes read through it.
+type CountingReader struct {
+	Reader io.Reader
+	N      int64
+}
+
+func (reader *CountingReader) Read(buffer []byte) (int, error) {
+	n, err := reader.Reader.Read(buffer)
+	reader.N += int64(n)
+	return n, err
+}
+
+// ErrTooLarge is returned by a reader from LimitReader once its input goes
+// past the limit.
+var ErrTooLarge = errors.New("input is too large")
+
+// LimitReader returns a reader over input that fails with ErrTooLarge if
+// input holds more than limit bytes. Unlike io.LimitReader, which ends
+// quietly at the limit, it never lets a truncated input pass for a whole
+// one.
+func LimitReader(input io.Reader, limit int64) io.Reader {
+	return &limitedReader{input: input, remaining: limit}
+}
+
+type limitedReader struct {
+	input     io.Reader
+	remaining int64
+}
+
+func (reader *limitedReader) Read(buffer []byte) (int, error) {
+
+	// Read one byte past the limit to tell a full input from a larger one
+	if int64(len(buffer)) > reader.remaining+1 {
+		buffer = buffer[:reader.remaining+1]
+	}
+
+	n, err := reader.input.Read(buffer)
+	if int64(n) > reader.remaining {
+		n = int(reader.remaining)
+		reader.remaining = 0
+		return n, ErrTooLarge
+	}
+
+	reader.remaining -= int64(n)
+	return n, err
+}
+
+// ContextReader returns a reader over input that fails once ctx is done,
+// with the cause of ctx. A read that has already blocked is not
+// interrupted, so for pipes and sockets pair it with a read deadline, as
+// SetReadDeadline does.
+func ContextReader(ctx context.Context, input io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, input: input}
+}
+
+type contextReader struct {
+	ctx   context.Context
+	input io.Reader
+}
+
+func (reader *contextReader) Read(buffer []byte) (int, error) {
+
+	if reader.ctx.Err() != nil {
+		return 0, context.Cause(reader.ctx)
+	}
+
+	n, err := reader.input.Read(buffer)
+
+	// A read deadline set from the context has passed
+	if errors.Is(err, os.ErrDeadlineExceeded) && reader.ctx.Err() != nil {
+		err = context.Cause(reader.ctx)
+	}
+	return n, err
+}
+
+// SetReadDeadline makes a read of file that blocks past the deadline of ctx
+// fail, where the file supports it, as pipes and sockets usually do.
+func SetReadDeadline(ctx context.Context, file *os.File) {
+	if deadline, ok := ctx.Deadline(); ok {
+		file.SetReadDeadline(deadline)
+	}
+}
@@ -0,0 +1,212 @@
+package streamio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// bzip2 streams made with bzip2 -9, as the standard library has no encoder
+var (
+	bzip2Hello = []byte{
+		0x42, 0x5A, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x54, 0xA4, 0x97, 0x84, 0x00, 0x00,
+		0x02, 0xD1, 0x80, 0x00, 0x10, 0x40, 0x04, 0x06, 0x44, 0x90, 0x80, 0x20, 0x00, 0x31, 0x00, 0x30,
+		0x20, 0x68, 0x62, 0x00, 0x49, 0xD4, 0xB2, 0x1F, 0x3F, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x54,
+		0xA4, 0x97, 0x84,
+	}
+	bzip2Empty = []byte{0x42, 0x5A, 0x68, 0x39, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x00, 0x00, 0x00, 0x00}
+)
+
+func TestDecompress(t *testing.T) {
+
+	const hello = "hello, world\n"
+
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(hello))
+	writer.Close()
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zstded := encoder.EncodeAll([]byte(hello), nil)
+	encoder.Close()
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"gzip", gzipped.Bytes(), hello},
+		{"bzip2", bzip2Hello, hello},
+		{"empty bzip2", bzip2Empty, ""},
+		{"zstd", zstded, hello},
+		{"plain", []byte(hello), hello},
+		{"empty", nil, ""},
+
+		// Text that starts like a compressed stream passes through as is
+		{"bzip2 lookalike", []byte("BZhello world\n"), "BZhello world\n"},
+		{"bzip2 header only", []byte("BZh9"), "BZh9"},
+		{"gzip lookalike", []byte{0x1F}, "\x1F"},
+	}
+
+	for _, test := range tests {
+		reader, err := Decompress(bytes.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: Decompress: %v", test.name, err)
+			continue
+		}
+
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Errorf("%s: read: %v", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 2048)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var out bytes.Buffer
+	progress := NewProgressReader(file, "input.txt", &out)
+
+	// Nothing is drawn before the first interval is up
+	buffer := make([]byte, 1024)
+	if _, err := progress.Read(buffer); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("drew %q before the interval was up", out.String())
+	}
+
+	progress.updated = progress.updated.Add(-progressInterval)
+	if _, err := progress.Read(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	line := out.String()
+	if want := "\r\033[Kinput.txt: 2.0 KiB of 2.0 KiB (100%), "; !strings.HasPrefix(line, want) {
+		t.Errorf("status line = %q, want prefix %q", line, want)
+	}
+
+	progress.Finish()
+	if got := strings.TrimPrefix(out.String(), line); got != "\r\033[K" {
+		t.Errorf("Finish wrote %q, want the line cleared", got)
+	}
+}
+
+func TestProgressReaderFinishUndrawn(t *testing.T) {
+
+	file, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var out bytes.Buffer
+	progress := NewProgressReader(file, "null", &out)
+	io.Copy(io.Discard, progress)
+	progress.Finish()
+
+	if out.Len() != 0 {
+		t.Errorf("wrote %q for a read that finished within the interval", out.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+
+	tests := []struct {
+		count float64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{5 * 1024 * 1024 * 1024, "5.0 GiB"},
+		{2048 * 1024 * 1024 * 1024 * 1024, "2048.0 TiB"},
+	}
+
+	for _, test := range tests {
+		if got := FormatBytes(test.count); got != test.want {
+			t.Errorf("FormatBytes(%v) = %q, want %q", test.count, got, test.want)
+		}
+	}
+}
+
+func TestContextReader(t *testing.T) {
+
+	cause := errors.New("timed out")
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	counter := &CountingReader{Reader: strings.NewReader("abcdef")}
+	reader := ContextReader(ctx, counter)
+
+	buffer := make([]byte, 4)
+	if n, err := reader.Read(buffer); n != 4 || err != nil {
+		t.Fatalf("Read = %d, %v before cancelling, want 4, nil", n, err)
+	}
+
+	cancel(cause)
+	if n, err := reader.Read(buffer); n != 0 || !errors.Is(err, cause) {
+		t.Errorf("Read = %d, %v after cancelling, want 0, %v", n, err, cause)
+	}
+	if counter.N != 4 {
+		t.Errorf("counted %d bytes, want 4", counter.N)
+	}
+}
+
+func TestLimitReader(t *testing.T) {
+
+	tests := []struct {
+		input   string
+		limit   int64
+		want    string
+		wantErr error
+	}{
+		{"", 0, "", nil},
+		{"abc", 3, "abc", nil},
+		{"abc", 10, "abc", nil},
+		{"abcd", 3, "abc", ErrTooLarge},
+		{"a", 0, "", ErrTooLarge},
+	}
+
+	for _, test := range tests {
+		got, err := io.ReadAll(LimitReader(strings.NewReader(test.input), test.limit))
+		if string(got) != test.want || !errors.Is(err, test.wantErr) {
+			t.Errorf("LimitReader(%q, %d) read %q, %v, want %q, %v", test.input, test.limit, got, err, test.want, test.wantErr)
+		}
+	}
+
+	// One byte at a time, the byte past the limit is still caught
+	reader := LimitReader(iotest.OneByteReader(strings.NewReader("abcd")), 3)
+	if got, err := io.ReadAll(reader); string(got) != "abc" || err != ErrTooLarge {
+		t.Errorf("one byte at a time: read %q, %v, want %q, %v", got, err, "abc", ErrTooLarge)
+	}
+}
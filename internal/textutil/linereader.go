@@ -0,0 +1,58 @@
+package textutil
+
+import (
+	"bufio"
+	"io"
+)
+
+// LineReader reads a text a line at a time, for tools that work line by
+// line over input of any size. Unlike bufio.Scanner it has no limit on line
+// length, keeps track of whether the last line ended with a newline, and
+// can tell when the line just read is the last.
+type LineReader struct {
+	reader *bufio.Reader
+	number int
+}
+
+// NewLineReader returns a LineReader over input, reading bufferSize bytes
+// at a time.
+func NewLineReader(input io.Reader, bufferSize int) *LineReader {
+	return &LineReader{reader: bufio.NewReaderSize(input, bufferSize)}
+}
+
+// Next returns the next line without its newline, and whether it had one,
+// which only the last line of a text may lack. A carriage return before
+// the newline is left in the line. The line is a new slice the caller may
+// keep. At the end of the input Next returns io.EOF.
+func (r *LineReader) Next() (line []byte, newline bool, err error) {
+
+	line, err = r.reader.ReadBytes('\n')
+	if len(line) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, false, err
+	}
+
+	r.number++
+	if line[len(line)-1] == '\n' {
+		return line[:len(line)-1], true, nil
+	}
+
+	// A final line without a newline; any error is reported by the next
+	// call
+	return line, false, nil
+}
+
+// Number returns the 1-based number of the line last returned by Next.
+func (r *LineReader) Number() int {
+	return r.number
+}
+
+// AtEOF reports whether the input has no more lines after the one last
+// returned by Next. It may block until more input arrives or the input
+// ends. A read error counts as the end, and is then returned by Next.
+func (r *LineReader) AtEOF() bool {
+	_, err := r.reader.Peek(1)
+	return err != nil
+}
@@ -0,0 +1,61 @@
+package textutil
+
+import "sort"
+
+// LineIndex maps byte offsets in a text to line and column numbers, for
+// pointing at a position in error messages.
+type LineIndex struct {
+	text []byte
+
+	// starts holds the offset at which each line begins
+	starts []int
+}
+
+// NewLineIndex indexes the lines of text, which must not change while the
+// index is in use.
+func NewLineIndex(text []byte) *LineIndex {
+
+	index := &LineIndex{text: text, starts: []int{0}}
+
+	for i, b := range text {
+		if b == '\n' {
+			index.starts = append(index.starts, i+1)
+		}
+	}
+
+	return index
+}
+
+// Position returns the 1-based line and column of offset. Columns count
+// grapheme clusters, so a multi-byte character, or a letter with a
+// combining accent, moves the column by one. Offsets past the end of the
+// text are placed at its end.
+func (index *LineIndex) Position(offset int) (line int, column int) {
+
+	offset = max(0, min(offset, len(index.text)))
+
+	// The last line starting at or before offset
+	line = sort.SearchInts(index.starts, offset+1)
+	start := index.starts[line-1]
+
+	return line, Graphemes(index.text[start:offset]) + 1
+}
+
+// Line returns the text of the 1-based line n without its line terminator.
+func (index *LineIndex) Line(n int) []byte {
+
+	if n < 1 || n > len(index.starts) {
+		return nil
+	}
+
+	start := index.starts[n-1]
+	end := len(index.text)
+	if n < len(index.starts) {
+		end = index.starts[n] - 1
+	}
+	if end > start && index.text[end-1] == '\r' {
+		end--
+	}
+
+	return index.text[start:end]
+}
@@ -0,0 +1,88 @@
+package textutil
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLineIndexPosition(t *testing.T) {
+
+	index := NewLineIndex([]byte("ab\r\ncafé x\n\nz\ne\u0301 y"))
+
+	tests := []struct {
+		offset       int
+		line, column int
+	}{
+		{0, 1, 1},
+		{2, 1, 3},
+		{4, 2, 1},
+		{10, 2, 6},
+		{12, 3, 1},
+		{13, 4, 1},
+		{15, 5, 1},
+
+		// The combining accent belongs to the e before it
+		{19, 5, 3},
+		{99, 5, 4},
+	}
+
+	for _, test := range tests {
+		line, column := index.Position(test.offset)
+		if line != test.line || column != test.column {
+			t.Errorf("Position(%d) = %d:%d, want %d:%d", test.offset, line, column, test.line, test.column)
+		}
+	}
+
+	if got := string(index.Line(1)); got != "ab" {
+		t.Errorf("Line(1) = %q, want %q", got, "ab")
+	}
+	if got := string(index.Line(4)); got != "z" {
+		t.Errorf("Line(4) = %q, want %q", got, "z")
+	}
+}
+
+func TestLineReader(t *testing.T) {
+
+	type line struct {
+		text    string
+		newline bool
+		last    bool
+	}
+
+	tests := []struct {
+		input string
+		want  []line
+	}{
+		{"", nil},
+		{"a\n", []line{{"a", true, true}}},
+		{"a\r\n\nb", []line{{"a\r", true, false}, {"", true, false}, {"b", false, true}}},
+		{strings.Repeat("x", 100) + "\ny\n", []line{{strings.Repeat("x", 100), true, false}, {"y", true, true}}},
+	}
+
+	for _, test := range tests {
+
+		// A buffer smaller than a line must not split it
+		reader := NewLineReader(strings.NewReader(test.input), 16)
+
+		var got []line
+		for {
+			text, newline, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reader.Number() != len(got)+1 {
+				t.Errorf("%q: Number() = %d, want %d", test.input, reader.Number(), len(got)+1)
+			}
+			got = append(got, line{string(text), newline, reader.AtEOF()})
+		}
+
+		if !slices.Equal(got, test.want) {
+			t.Errorf("lines of %q = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
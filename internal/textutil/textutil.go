@@ -0,0 +1,82 @@
+// Package textutil holds the text handling shared by the tools in this
+// repository: byte order marks, UTF-8 validation, grapheme clusters,
+// reading line by line and mapping byte offsets to lines and columns.
+package textutil
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// BOM is the UTF-8 encoding of the byte order mark.
+var BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// HasBOM reports whether data starts with a UTF-8 byte order mark.
+func HasBOM(data []byte) bool {
+	return bytes.HasPrefix(data, BOM)
+}
+
+// SkipBOM returns a reader over input that omits a leading UTF-8 byte order
+// mark, if there is one.
+func SkipBOM(input io.Reader) io.Reader {
+
+	reader := bufio.NewReader(input)
+
+	if prefix, _ := reader.Peek(len(BOM)); HasBOM(prefix) {
+		reader.Discard(len(BOM))
+	}
+
+	return reader
+}
+
+// CountRunes counts the runes in input along with the bytes read and the
+// number of invalid UTF-8 sequences found. Each invalid byte counts as a
+// rune, as utf8.DecodeRune reads it.
+func CountRunes(input io.Reader) (runes int, bytes int, invalid int, err error) {
+
+	reader := bufio.NewReader(input)
+
+	for {
+		r, size, err := reader.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				return runes, bytes, invalid, err
+			}
+			break
+		}
+
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+
+		runes++
+		bytes += size
+	}
+
+	return runes, bytes, invalid, nil
+}
+
+// FirstInvalid returns the offset of the first byte of data that is not
+// valid UTF-8, or -1 if it is all valid.
+func FirstInvalid(data []byte) int {
+
+	for offset := 0; offset < len(data); {
+		r, size := utf8.DecodeRune(data[offset:])
+		if r == utf8.RuneError && size == 1 {
+			return offset
+		}
+		offset += size
+	}
+
+	return -1
+}
+
+// Graphemes counts the user-perceived characters of text, the extended
+// grapheme clusters of UAX #29, so that "e" with a combining accent is one.
+func Graphemes(text []byte) int {
+	return uniseg.GraphemeClusterCount(string(text))
+}
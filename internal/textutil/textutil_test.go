@@ -0,0 +1,86 @@
+package textutil
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSkipBOM(t *testing.T) {
+
+	tests := map[string]string{
+		"":                "",
+		"\uFEFFabc":       "abc",
+		"abc\uFEFF":       "abc\uFEFF",
+		"\uFEFF\uFEFFabc": "\uFEFFabc",
+		"\xEF\xBB":        "\xEF\xBB",
+		"\xEF\xBBabc":     "\xEF\xBBabc",
+	}
+
+	for input, want := range tests {
+		got, err := io.ReadAll(SkipBOM(strings.NewReader(input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("SkipBOM(%q) read %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFirstInvalid(t *testing.T) {
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", -1},
+		{"abc", -1},
+		{"café \u2615", -1},
+		{"\xFFabc", 0},
+		{"ab\xFF", 2},
+
+		// A sequence cut short is invalid from its first byte
+		{"é\xE2\x98", 2},
+
+		// Overlong encodings and surrogates are not valid UTF-8
+		{"a\xC0\x80", 1},
+		{"ab\xED\xA0\x80", 2},
+
+		// A lone continuation byte after a valid character
+		{"é\x80", 2},
+	}
+
+	for _, test := range tests {
+		if got := FirstInvalid([]byte(test.text)); got != test.want {
+			t.Errorf("FirstInvalid(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"empty", "", 0},
+		{"ASCII", "abc", 3},
+		{"precomposed", "café", 4},
+		{"combining accent", "cafe\u0301", 4},
+		{"stacked combining marks", "a\u0301\u0323b", 2},
+		{"CRLF", "a\r\nb", 3},
+		{"ZWJ family", "\U0001F468\u200D\U0001F469\u200D\U0001F467", 1},
+		{"ZWJ emoji between letters", "a\U0001F469\u200D\U0001F4BBb", 3},
+		{"skin tone", "\U0001F44D\U0001F3FD", 1},
+		{"flags", "\U0001F1EB\U0001F1F7\U0001F1E9\U0001F1EA", 2},
+		{"invalid bytes", "a\xFF\xFEb", 4},
+	}
+
+	for _, test := range tests {
+		if got := Graphemes([]byte(test.text)); got != test.want {
+			t.Errorf("%s: Graphemes(%q) = %d, want %d", test.name, test.text, got, test.want)
+		}
+	}
+}
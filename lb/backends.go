@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// backend is a server requests are forwarded to.
+type backend struct {
+	url   *url.URL
+	proxy *httputil.ReverseProxy
+
+	// healthy is cleared while health checks of the backend fail
+	healthy atomic.Bool
+
+	// active is how many requests the backend is serving
+	active atomic.Int64
+}
+
+func newBackend(target *url.URL) *backend {
+
+	b := &backend{url: target, proxy: httputil.NewSingleHostReverseProxy(target)}
+	b.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, fmt.Sprintf("backend %s: %v", target, err), http.StatusBadGateway)
+	}
+
+	// Backends are assumed to be up until a health check says otherwise
+	b.healthy.Store(true)
+	return b
+}
+
+// readBackends reads a backends file: one backend URL per line, with blank
+// lines and lines starting with # ignored.
+func readBackends(path string) ([]*url.URL, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []*url.URL
+	scanner := bufio.NewScanner(file)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		target, err := url.Parse(text)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("%s:%d: invalid backend URL %q", path, line, text)
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, scanner.Err()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthChecker checks every backend in a pool at each interval by
+// requesting path from it, taking a backend out of rotation while it does
+// not answer with a success or redirect status, and back in once it does.
+type healthChecker struct {
+	pool     *pool
+	path     string
+	interval time.Duration
+	client   *http.Client
+}
+
+// run checks the backends until ctx is done.
+func (h *healthChecker) run(ctx context.Context) {
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll checks every backend at once and waits for the results.
+func (h *healthChecker) checkAll(ctx context.Context) {
+
+	var wg sync.WaitGroup
+	for _, b := range h.pool.all() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.check(ctx, b)
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *healthChecker) check(ctx context.Context, b *backend) {
+
+	healthy := h.probe(ctx, b)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if was := b.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			log.Printf("backend %s is healthy again", b.url)
+		} else {
+			log.Printf("backend %s failed its health check", b.url)
+		}
+	}
+}
+
+func (h *healthChecker) probe(ctx context.Context, b *backend) bool {
+
+	ctx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url.JoinPath(h.path).String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode < http.StatusBadRequest
+}
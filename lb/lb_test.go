@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBackend is a backend server that answers with its name, and fails
+// its health checks while down is set.
+type testBackend struct {
+	*httptest.Server
+	down atomic.Bool
+}
+
+func newTestBackend(t *testing.T, name string, handler http.HandlerFunc) *testBackend {
+	t.Helper()
+
+	b := &testBackend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			if b.down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		if handler != nil {
+			handler(w, r)
+		}
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func urls(t *testing.T, backends ...*testBackend) []*url.URL {
+	t.Helper()
+
+	var targets []*url.URL
+	for _, b := range backends {
+		target, err := url.Parse(b.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// get requests / from the balancer and returns the body, or the status if
+// it is not OK.
+func get(t *testing.T, balancer *httptest.Server) string {
+	t.Helper()
+
+	resp, err := http.Get(balancer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.Status
+	}
+	return string(body)
+}
+
+func TestRoundRobin(t *testing.T) {
+
+	p := &pool{strategy: roundRobin}
+	p.set(urls(t, newTestBackend(t, "a", nil), newTestBackend(t, "b", nil), newTestBackend(t, "c", nil)))
+
+	balancer := httptest.NewServer(p)
+	defer balancer.Close()
+
+	var got []string
+	for range 6 {
+		got = append(got, get(t, balancer))
+	}
+
+	if strings.Join(got, "") != "abcabc" {
+		t.Errorf("requests went to %v", got)
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+
+	release := make(chan struct{})
+	slow := newTestBackend(t, "slow", func(w http.ResponseWriter, r *http.Request) { <-release })
+	fast := newTestBackend(t, "fast", nil)
+
+	p := &pool{strategy: leastConnections}
+	p.set(urls(t, slow, fast))
+
+	balancer := httptest.NewServer(p)
+	defer balancer.Close()
+
+	// The first request goes to the first backend and stays there
+	done := make(chan string)
+	go func() { done <- get(t, balancer) }()
+
+	for deadline := time.Now().Add(5 * time.Second); p.all()[0].active.Load() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("first request did not reach the slow backend")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for range 3 {
+		if got := get(t, balancer); got != "fast" {
+			t.Errorf("request went to %s while the slow backend was busy", got)
+		}
+	}
+
+	close(release)
+	if got := <-done; got != "slow" {
+		t.Errorf("first request went to %s", got)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+
+	a, b := newTestBackend(t, "a", nil), newTestBackend(t, "b", nil)
+
+	p := &pool{strategy: roundRobin}
+	p.set(urls(t, a, b))
+	checker := &healthChecker{pool: p, path: "/health", interval: time.Second, client: &http.Client{}}
+
+	balancer := httptest.NewServer(p)
+	defer balancer.Close()
+
+	a.down.Store(true)
+	checker.checkAll(context.Background())
+
+	for range 3 {
+		if got := get(t, balancer); got != "b" {
+			t.Errorf("request went to %s, which is down", got)
+		}
+	}
+
+	b.down.Store(true)
+	checker.checkAll(context.Background())
+
+	if got := get(t, balancer); got != "503 Service Unavailable" {
+		t.Errorf("with every backend down, got %s", got)
+	}
+
+	a.down.Store(false)
+	checker.checkAll(context.Background())
+
+	if got := get(t, balancer); got != "a" {
+		t.Errorf("request went to %s, not the recovered backend", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+
+	release := make(chan struct{})
+	a := newTestBackend(t, "a", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("wait") {
+			<-release
+		}
+	})
+	b, c := newTestBackend(t, "b", nil), newTestBackend(t, "c", nil)
+
+	path := filepath.Join(t.TempDir(), "backends")
+	writeBackends := func(backends ...*testBackend) {
+		var lines []string
+		for _, backend := range backends {
+			lines = append(lines, backend.URL)
+		}
+		contents := "# backends\n" + strings.Join(lines, "\n") + "\n"
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &pool{strategy: roundRobin}
+	checker := &healthChecker{pool: p, path: "/health", interval: time.Second, client: &http.Client{}}
+	r := &reloader{path: path, pool: p, checker: checker}
+
+	balancer := httptest.NewServer(p)
+	defer balancer.Close()
+
+	writeBackends(a)
+	r.reload(context.Background())
+
+	// A request in flight on a removed backend is still answered
+	done := make(chan string)
+	go func() {
+		resp, err := http.Get(balancer.URL + "?wait")
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		done <- string(body)
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); p.all()[0].active.Load() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("request did not reach the backend")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	writeBackends(b, c)
+	r.reload(context.Background())
+
+	got := map[string]bool{}
+	for range 4 {
+		got[get(t, balancer)] = true
+	}
+	if len(got) != 2 || !got["b"] || !got["c"] {
+		t.Errorf("after reloading, requests went to %v", got)
+	}
+
+	close(release)
+	if got := <-done; got != "a" {
+		t.Errorf("request in flight on a removed backend got %q", got)
+	}
+
+	// A broken file leaves the backends as they were
+	if err := os.WriteFile(path, []byte("not a url\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r.reload(context.Background())
+	if n := len(p.all()); n != 2 {
+		t.Errorf("after a failed reload there are %d backends, want 2", n)
+	}
+}
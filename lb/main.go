@@ -0,0 +1,106 @@
+// Command cclb is an HTTP load balancer. It forwards requests to the
+// backends listed in a file, choosing among them round-robin or by fewest
+// active requests, checks their health in the background, and picks up
+// changes to the file without dropping requests.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cclb [flags] -backends file")
+	fmt.Fprintln(out, "Balance HTTP requests between the backends listed in file, one URL per line. The")
+	fmt.Fprintln(out, "file is read again on SIGHUP and whenever it changes.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cclb"
+	log.SetPrefix("cclb: ")
+
+	listen := flag.String("listen", ":8080", "accept requests on this `address`")
+	backendsPath := flag.String("backends", "", "read the backend URLs from `file`")
+	strategyName := flag.String("strategy", "round-robin", "how to choose a backend: round-robin or least-connections")
+	healthPath := flag.String("health-path", "/", "request this `path` from each backend to check its health")
+	healthInterval := flag.Duration("health-interval", 10*time.Second, "check backends this often")
+	watchInterval := flag.Duration("watch", 2*time.Second, "check the backends file for changes this often, or never if 0")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *backendsPath == "" {
+		cli.Exit(cli.Usagef("-backends is required"))
+	}
+	if flag.NArg() > 0 {
+		cli.Exit(cli.Usagef("unexpected argument %q", flag.Arg(0)))
+	}
+
+	chosen, ok := strategies[*strategyName]
+	if !ok {
+		names := make([]string, 0, len(strategies))
+		for name := range strategies {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		cli.Exit(cli.Usagef("invalid strategy %q: must be one of %s", *strategyName, strings.Join(names, ", ")))
+	}
+	if *healthInterval <= 0 {
+		cli.Exit(cli.Usagef("-health-interval must be positive"))
+	}
+
+	targets, err := readBackends(*backendsPath)
+	if err != nil {
+		cli.Exit(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	p := &pool{strategy: chosen}
+	p.set(targets)
+
+	checker := &healthChecker{
+		pool:     p,
+		path:     *healthPath,
+		interval: *healthInterval,
+		client:   &http.Client{},
+	}
+	go checker.run(ctx)
+
+	r := &reloader{path: *backendsPath, pool: p, checker: checker}
+	go r.run(ctx, *watchInterval)
+
+	server := &http.Server{Addr: *listen, Handler: p}
+	go func() {
+		<-ctx.Done()
+
+		// Let requests in flight finish
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	log.Printf("balancing %d backends on %s with %s", len(targets), *listen, *strategyName)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		cli.Exit(err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// strategy picks which of the healthy backends serves the next request.
+type strategy func(p *pool, healthy []*backend) *backend
+
+var strategies = map[string]strategy{
+	"round-robin":       roundRobin,
+	"least-connections": leastConnections,
+}
+
+// roundRobin takes each backend in turn.
+func roundRobin(p *pool, healthy []*backend) *backend {
+	n := p.next.Add(1) - 1
+	return healthy[n%uint64(len(healthy))]
+}
+
+// leastConnections takes the backend serving the fewest requests, the
+// first of them on a tie.
+func leastConnections(p *pool, healthy []*backend) *backend {
+
+	best := healthy[0]
+	for _, b := range healthy[1:] {
+		if b.active.Load() < best.active.Load() {
+			best = b
+		}
+	}
+	return best
+}
+
+var errNoBackends = errors.New("no healthy backends")
+
+// pool is the set of backends being balanced between. It serves requests
+// as an http.Handler.
+type pool struct {
+	strategy strategy
+
+	mu       sync.RWMutex
+	backends []*backend
+
+	// next is the round-robin position
+	next atomic.Uint64
+}
+
+// set replaces the backends with targets. Backends that remain keep their
+// health and the requests they are serving; removed backends finish the
+// requests they are serving but are given no more.
+func (p *pool) set(targets []*url.URL) (added, removed []*backend) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := make(map[string]*backend, len(p.backends))
+	for _, b := range p.backends {
+		current[b.url.String()] = b
+	}
+
+	backends := make([]*backend, 0, len(targets))
+	for _, target := range targets {
+		key := target.String()
+		b, ok := current[key]
+		if !ok {
+			b = newBackend(target)
+			added = append(added, b)
+		}
+		delete(current, key)
+		backends = append(backends, b)
+	}
+
+	for _, b := range p.backends {
+		if _, ok := current[b.url.String()]; ok {
+			removed = append(removed, b)
+		}
+	}
+
+	p.backends = backends
+	return added, removed
+}
+
+// all returns the backends, whether healthy or not.
+func (p *pool) all() []*backend {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.backends
+}
+
+// pick chooses the backend for a request.
+func (p *pool) pick() (*backend, error) {
+
+	var healthy []*backend
+	for _, b := range p.all() {
+		if b.healthy.Load() {
+			healthy = append(healthy, b)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil, errNoBackends
+	}
+	return p.strategy(p, healthy), nil
+}
+
+func (p *pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	b, err := p.pick()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	b.active.Add(1)
+	defer b.active.Add(-1)
+
+	b.proxy.ServeHTTP(w, r)
+}
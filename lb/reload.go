@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloader reads the backends file again on SIGHUP, and when it changes,
+// and updates the pool. A file that cannot be read or has errors leaves
+// the backends as they were.
+type reloader struct {
+	path    string
+	pool    *pool
+	checker *healthChecker
+
+	// modTime is when the file was last changed, as of the last reload
+	modTime time.Time
+}
+
+// run reloads until ctx is done, checking the file for changes every
+// interval, or only on SIGHUP if interval is 0.
+func (r *reloader) run(ctx context.Context, interval time.Duration) {
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.reload(ctx)
+		case <-tick:
+			if info, err := os.Stat(r.path); err == nil && !info.ModTime().Equal(r.modTime) {
+				r.reload(ctx)
+			}
+		}
+	}
+}
+
+func (r *reloader) reload(ctx context.Context) {
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	targets, err := readBackends(r.path)
+	if err != nil {
+		log.Printf("keeping the current backends: %v", err)
+		return
+	}
+
+	added, removed := r.pool.set(targets)
+	for _, b := range removed {
+		log.Printf("removed backend %s", b.url)
+	}
+
+	// New backends are checked straight away rather than at the next
+	// round of checks
+	for _, b := range added {
+		log.Printf("added backend %s", b.url)
+		go r.checker.check(ctx, b)
+	}
+}
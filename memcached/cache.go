@@ -0,0 +1,202 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// item is a stored value with its flags, expiry time and CAS unique.
+type item struct {
+	key     string
+	value   []byte
+	flags   uint32
+	expires time.Time
+	cas     uint64
+}
+
+// size is roughly the memory an item takes, counted against the cache's
+// limit.
+func (it *item) size() int64 {
+	const overhead = 64
+	return int64(len(it.key) + len(it.value) + overhead)
+}
+
+// storeResult is the outcome of a storage command, named as the protocol
+// reports it.
+type storeResult string
+
+const (
+	stored    storeResult = "STORED"
+	notStored storeResult = "NOT_STORED"
+	exists    storeResult = "EXISTS"
+	notFound  storeResult = "NOT_FOUND"
+	tooLarge  storeResult = "SERVER_ERROR object too large for cache"
+)
+
+// cache holds items up to a memory limit, evicting the least recently used
+// when a new item would go over it. Expired items are removed when next
+// looked up or when evicted.
+type cache struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List
+	used    int64
+	limit   int64
+	nextCAS uint64
+	now     func() time.Time
+	evicted uint64
+}
+
+func newCache(limit int64) *cache {
+	return &cache{
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+		limit: limit,
+		now:   time.Now,
+	}
+}
+
+// lookup returns the live item for key, removing it if it has expired. The
+// caller holds mu.
+func (c *cache) lookup(key string) *item {
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	it := element.Value.(*item)
+	if !it.expires.IsZero() && !c.now().Before(it.expires) {
+		c.remove(element)
+		return nil
+	}
+	return it
+}
+
+func (c *cache) remove(element *list.Element) {
+	it := c.lru.Remove(element).(*item)
+	delete(c.items, it.key)
+	c.used -= it.size()
+}
+
+// get returns the items for keys that are present, marking each as
+// recently used.
+func (c *cache) get(keys []string) []item {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var found []item
+	for _, key := range keys {
+		if it := c.lookup(key); it != nil {
+			c.lru.MoveToFront(c.items[key])
+			found = append(found, *it)
+		}
+	}
+	return found
+}
+
+// storeMode is which storage command is being carried out.
+type storeMode int
+
+const (
+	modeSet storeMode = iota
+	modeAdd
+	modeReplace
+	modeAppend
+	modePrepend
+	modeCAS
+)
+
+// store carries out a storage command. For modeCAS, cas is the unique the
+// client last saw.
+func (c *cache) store(mode storeMode, it item, cas uint64) storeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existing := c.lookup(it.key)
+
+	switch mode {
+	case modeAdd:
+		if existing != nil {
+			// A failed add still counts as a use of the item
+			c.lru.MoveToFront(c.items[it.key])
+			return notStored
+		}
+	case modeReplace, modeAppend, modePrepend:
+		if existing == nil {
+			return notStored
+		}
+	case modeCAS:
+		if existing == nil {
+			return notFound
+		}
+		if existing.cas != cas {
+			return exists
+		}
+	}
+
+	// Appending and prepending keep the existing flags and expiry
+	switch mode {
+	case modeAppend:
+		it.value = append(append([]byte{}, existing.value...), it.value...)
+		it.flags, it.expires = existing.flags, existing.expires
+	case modePrepend:
+		it.value = append(append([]byte{}, it.value...), existing.value...)
+		it.flags, it.expires = existing.flags, existing.expires
+	}
+
+	if it.size() > c.limit {
+		return tooLarge
+	}
+
+	if existing != nil {
+		c.remove(c.items[it.key])
+	}
+
+	c.nextCAS++
+	it.cas = c.nextCAS
+
+	for c.used+it.size() > c.limit {
+		c.remove(c.lru.Back())
+		c.evicted++
+	}
+
+	c.items[it.key] = c.lru.PushFront(&it)
+	c.used += it.size()
+	return stored
+}
+
+// delete removes key, reporting whether it was present.
+func (c *cache) delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lookup(key) == nil {
+		return false
+	}
+	c.remove(c.items[key])
+	return true
+}
+
+// touch sets a new expiry time for key, reporting whether it was present.
+func (c *cache) touch(key string, expires time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it := c.lookup(key)
+	if it == nil {
+		return false
+	}
+	it.expires = expires
+	c.lru.MoveToFront(c.items[key])
+	return true
+}
+
+// stats returns the number of items, the bytes they use and how many have
+// been evicted.
+func (c *cache) stats() (items int, used int64, evicted uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.used, c.evicted
+}
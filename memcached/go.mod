@@ -0,0 +1,7 @@
+module codechallenge/memcached
+
+go 1.23.2
+
+require codechallenge/internal v0.0.0
+
+replace codechallenge/internal => ../internal
@@ -0,0 +1,101 @@
+// Command ccmemcached is a memcached server speaking the text protocol:
+// set, add, replace, append, prepend and cas to store items, get and gets
+// to fetch them, delete and touch, with items that expire and least
+// recently used items evicted to stay within a memory limit.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccmemcached [flags]")
+	fmt.Fprintln(out, "Serve a memcached-compatible cache over the text protocol.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccmemcached"
+
+	port := flag.Int("p", 11211, "listen on this TCP `port`")
+	memory := flag.String("m", "64M", "use at most this much `memory` for items")
+	maxItem := flag.String("I", "1M", "refuse items larger than this `size`")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	limit, err := cli.ParseCount(*memory)
+	if err != nil || limit == 0 {
+		cli.Exit(cli.Usagef("invalid -m %q", *memory))
+	}
+	itemLimit, err := cli.ParseCount(*maxItem)
+	if err != nil || itemLimit == 0 || itemLimit > limit {
+		cli.Exit(cli.Usagef("invalid -I %q: must be at most -m", *maxItem))
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		cli.Exit(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("ccmemcached: listening on %s with %s of memory", listener.Addr(), *memory)
+	if err := serve(ctx, listener, newCache(limit), int(itemLimit)); err != nil {
+		cli.Exit(err)
+	}
+}
+
+// serve accepts clients on listener until ctx is done, serving each in its
+// own goroutine.
+func serve(ctx context.Context, listener net.Listener, c *cache, maxItem int) error {
+
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+
+			closeOnStop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer closeOnStop()
+
+			s := &session{
+				cache:   c,
+				reader:  bufio.NewReaderSize(conn, maxLineLength),
+				w:       bufio.NewWriter(conn),
+				maxItem: maxItem,
+			}
+			s.serve()
+		}()
+	}
+}
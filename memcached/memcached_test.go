@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startServer serves c and returns a connection to it.
+func startServer(t *testing.T, c *cache) net.Conn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- serve(ctx, listener, c, 1024) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// send writes request and reads back exactly as many bytes as want has.
+func send(t *testing.T, conn net.Conn, request, want string) {
+	t.Helper()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("%q: read %q: %v", request, got, err)
+	}
+	if string(got) != want {
+		t.Errorf("%q: got %q, want %q", request, got, want)
+	}
+}
+
+func TestProtocol(t *testing.T) {
+
+	c := newCache(1 << 20)
+	now := time.Unix(1_700_000_000, 0)
+	var mu sync.Mutex
+	c.now = func() time.Time { mu.Lock(); defer mu.Unlock(); return now }
+
+	conn := startServer(t, c)
+
+	steps := []struct{ request, reply string }{
+		{"get missing\r\n", "END\r\n"},
+		{"set a 5 0 5\r\nhello\r\n", "STORED\r\n"},
+		{"get a\r\n", "VALUE a 5 5\r\nhello\r\nEND\r\n"},
+		{"add a 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"add b 0 0 1\r\nx\r\n", "STORED\r\n"},
+		{"replace missing 0 0 1\r\nx\r\n", "NOT_STORED\r\n"},
+		{"replace b 1 0 1\r\ny\r\n", "STORED\r\n"},
+		{"append a 0 0 1\r\n!\r\n", "STORED\r\n"},
+		{"prepend a 0 0 1\r\n>\r\n", "STORED\r\n"},
+		{"get a b missing\r\n", "VALUE a 5 7\r\n>hello!\r\nVALUE b 1 1\r\ny\r\nEND\r\n"},
+		{"gets b\r\n", "VALUE b 1 1 3\r\ny\r\nEND\r\n"},
+		{"cas b 0 0 1 2\r\nz\r\n", "EXISTS\r\n"},
+		{"cas b 0 0 1 3\r\nz\r\n", "STORED\r\n"},
+		{"cas missing 0 0 1 3\r\nz\r\n", "NOT_FOUND\r\n"},
+		{"delete b\r\n", "DELETED\r\n"},
+		{"delete b\r\n", "NOT_FOUND\r\n"},
+		{"set quiet 0 0 1 noreply\r\nq\r\nget quiet\r\n", "VALUE quiet 0 1\r\nq\r\nEND\r\n"},
+		{"set binary 0 0 4\r\na\r\nb\r\n", "STORED\r\n"},
+		{"get binary\r\n", "VALUE binary 0 4\r\na\r\nb\r\nEND\r\n"},
+		{"set bad 0 0 2\r\nabc\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"set short 0 0\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"set big 0 0 2000\r\n" + strings.Repeat("x", 2000) + "\r\n", "SERVER_ERROR object too large for cache\r\n"},
+		{"get " + strings.Repeat("k", 251) + "\r\n", "CLIENT_ERROR key too long\r\n"},
+		{"bogus\r\n", "ERROR\r\n"},
+		{"set gone 0 -1 1\r\nx\r\nget gone\r\n", "STORED\r\nEND\r\n"},
+		{"set soon 0 10 1\r\nx\r\ntouch soon 100\r\n", "STORED\r\nTOUCHED\r\n"},
+		{"touch missing 10\r\n", "NOT_FOUND\r\n"},
+		{"version\r\n", "VERSION 1.6.0-cc\r\n"},
+	}
+
+	for _, step := range steps {
+		send(t, conn, step.request, step.reply)
+	}
+
+	// Relative expiry times count from now
+	mu.Lock()
+	now = now.Add(50 * time.Second)
+	mu.Unlock()
+	send(t, conn, "get soon\r\n", "VALUE soon 0 1\r\nx\r\nEND\r\n")
+
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	send(t, conn, "get soon\r\n", "END\r\n")
+
+	send(t, conn, "quit\r\n", "")
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("after quit, read gave %v, want EOF", err)
+	}
+}
+
+func TestEviction(t *testing.T) {
+
+	// Room for about three items
+	one := (&item{key: "k0", value: make([]byte, 100)}).size()
+	c := newCache(3*one + one/2)
+
+	for i := range 3 {
+		c.store(modeSet, item{key: fmt.Sprint("k", i), value: make([]byte, 100)}, 0)
+	}
+
+	// Using k0 makes k1 the least recently used
+	c.get([]string{"k0"})
+	c.store(modeSet, item{key: "k3", value: make([]byte, 100)}, 0)
+
+	var present []string
+	for _, it := range c.get([]string{"k0", "k1", "k2", "k3"}) {
+		present = append(present, it.key)
+	}
+	if strings.Join(present, " ") != "k0 k2 k3" {
+		t.Errorf("after eviction %v are present, want k0 k2 k3", present)
+	}
+
+	items, used, evicted := c.stats()
+	if items != 3 || used != 3*one || evicted != 1 {
+		t.Errorf("stats are %d items, %d bytes, %d evicted", items, used, evicted)
+	}
+}
+
+// TestConcurrentClients has many clients increment through cas at once,
+// each retrying on EXISTS, so no increment may be lost.
+func TestConcurrentClients(t *testing.T) {
+
+	c := newCache(1 << 20)
+	first := startServer(t, c)
+	send(t, first, "set n 0 0 1\r\n0\r\n", "STORED\r\n")
+	addr := first.RemoteAddr().String()
+
+	const clients, increments = 8, 25
+	var wg sync.WaitGroup
+
+	for range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+
+			for done := 0; done < increments; {
+				fmt.Fprint(conn, "gets n\r\n")
+				var key string
+				var flags, size int
+				var cas uint64
+				var n int
+				fmt.Fscanf(reader, "VALUE %s %d %d %d\r\n", &key, &flags, &size, &cas)
+				fmt.Fscanf(reader, "%d\r\n", &n)
+				reader.ReadString('\n')
+
+				value := fmt.Sprint(n + 1)
+				fmt.Fprintf(conn, "cas n 0 0 %d %d\r\n%s\r\n", len(value), cas, value)
+				if reply, _ := reader.ReadString('\n'); reply == "STORED\r\n" {
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	send(t, first, "get n\r\n", fmt.Sprintf("VALUE n 0 3\r\n%d\r\nEND\r\n", clients*increments))
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxKeyLength is the longest key the protocol allows
+	maxKeyLength = 250
+
+	// maxRelativeExpiry is the largest expiry time taken as seconds from
+	// now; larger ones are Unix times
+	maxRelativeExpiry = 60 * 60 * 24 * 30
+
+	// maxLineLength bounds a command line
+	maxLineLength = 2048
+)
+
+// clientError is a malformed command, answered with CLIENT_ERROR.
+type clientError string
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+// session is one client's connection, reading commands and writing
+// replies.
+type session struct {
+	cache   *cache
+	reader  *bufio.Reader
+	w       *bufio.Writer
+	maxItem int
+}
+
+var storeModes = map[string]storeMode{
+	"set":     modeSet,
+	"add":     modeAdd,
+	"replace": modeReplace,
+	"append":  modeAppend,
+	"prepend": modePrepend,
+	"cas":     modeCAS,
+}
+
+// errQuit ends a session at the client's request.
+var errQuit = errors.New("quit")
+
+// serve answers commands until the client quits or disconnects.
+func (s *session) serve() error {
+
+	for {
+		line, err := s.reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			fmt.Fprint(s.w, "CLIENT_ERROR line too long\r\n")
+			s.w.Flush()
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.command(strings.Fields(string(line)))
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+
+		var malformed clientError
+		if errors.As(err, &malformed) {
+			fmt.Fprintf(s.w, "CLIENT_ERROR %s\r\n", malformed)
+		} else if err != nil {
+			return err
+		}
+
+		// Replies to pipelined commands go out together
+		if s.reader.Buffered() == 0 {
+			if err := s.w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *session) command(args []string) error {
+
+	if len(args) == 0 {
+		fmt.Fprint(s.w, "ERROR\r\n")
+		return nil
+	}
+
+	name := args[0]
+	if mode, ok := storeModes[name]; ok {
+		return s.store(mode, args[1:])
+	}
+
+	switch name {
+	case "get", "gets":
+		return s.get(args[1:], name == "gets")
+	case "delete":
+		return s.delete(args[1:])
+	case "touch":
+		return s.touch(args[1:])
+	case "stats":
+		return s.stats()
+	case "version":
+		fmt.Fprint(s.w, "VERSION 1.6.0-cc\r\n")
+		return nil
+	case "quit":
+		return errQuit
+	default:
+		fmt.Fprint(s.w, "ERROR\r\n")
+		return nil
+	}
+}
+
+// store handles set, add, replace, append, prepend and cas:
+//
+//	<command> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply]
+//
+// followed by a line of data.
+func (s *session) store(mode storeMode, args []string) error {
+
+	fields := 4
+	if mode == modeCAS {
+		fields = 5
+	}
+	noreply := len(args) == fields+1 && args[fields] == "noreply"
+	if len(args) != fields && !noreply {
+		return clientError("bad command line format")
+	}
+
+	key := args[0]
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+		return clientError("bad command line format")
+	}
+
+	var cas uint64
+	if mode == modeCAS {
+		var err error
+		if cas, err = strconv.ParseUint(args[4], 10, 64); err != nil {
+			return clientError("bad command line format")
+		}
+	}
+
+	// The data is read, or skipped, whatever else is wrong, so the next
+	// command is read from the right place
+	if size > s.maxItem {
+		if _, err := s.reader.Discard(size + 2); err != nil {
+			return err
+		}
+		fmt.Fprint(s.w, "SERVER_ERROR object too large for cache\r\n")
+		return nil
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(s.reader, data); err != nil {
+		return err
+	}
+	if string(data[size:]) != "\r\n" {
+		// Skip the rest of the overlong line, so it is not taken for a
+		// command
+		if data[size+1] != '\n' {
+			s.reader.ReadSlice('\n')
+		}
+		return clientError("bad data chunk")
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	result := s.cache.store(mode, item{
+		key:     key,
+		value:   data[:size],
+		flags:   uint32(flags),
+		expires: s.expiry(exptime),
+	}, cas)
+
+	if !noreply {
+		fmt.Fprintf(s.w, "%s\r\n", result)
+	}
+	return nil
+}
+
+// get handles get and gets, which also gives each item's CAS unique:
+//
+//	get <key>*
+func (s *session) get(keys []string, withCAS bool) error {
+
+	if len(keys) == 0 {
+		return clientError("bad command line format")
+	}
+	for _, key := range keys {
+		if err := checkKey(key); err != nil {
+			return err
+		}
+	}
+
+	for _, it := range s.cache.get(keys) {
+		fmt.Fprintf(s.w, "VALUE %s %d %d", it.key, it.flags, len(it.value))
+		if withCAS {
+			fmt.Fprintf(s.w, " %d", it.cas)
+		}
+		s.w.WriteString("\r\n")
+		s.w.Write(it.value)
+		s.w.WriteString("\r\n")
+	}
+	s.w.WriteString("END\r\n")
+	return nil
+}
+
+// delete handles delete <key> [noreply].
+func (s *session) delete(args []string) error {
+
+	noreply := len(args) == 2 && args[1] == "noreply"
+	if len(args) != 1 && !noreply {
+		return clientError("bad command line format")
+	}
+
+	reply := "NOT_FOUND"
+	if s.cache.delete(args[0]) {
+		reply = "DELETED"
+	}
+	if !noreply {
+		fmt.Fprintf(s.w, "%s\r\n", reply)
+	}
+	return nil
+}
+
+// touch handles touch <key> <exptime> [noreply].
+func (s *session) touch(args []string) error {
+
+	noreply := len(args) == 3 && args[2] == "noreply"
+	if len(args) != 2 && !noreply {
+		return clientError("bad command line format")
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return clientError("bad command line format")
+	}
+
+	reply := "NOT_FOUND"
+	if s.cache.touch(args[0], s.expiry(exptime)) {
+		reply = "TOUCHED"
+	}
+	if !noreply {
+		fmt.Fprintf(s.w, "%s\r\n", reply)
+	}
+	return nil
+}
+
+func (s *session) stats() error {
+
+	items, used, evicted := s.cache.stats()
+	fmt.Fprintf(s.w, "STAT curr_items %d\r\n", items)
+	fmt.Fprintf(s.w, "STAT bytes %d\r\n", used)
+	fmt.Fprintf(s.w, "STAT limit_maxbytes %d\r\n", s.cache.limit)
+	fmt.Fprintf(s.w, "STAT evictions %d\r\n", evicted)
+	s.w.WriteString("END\r\n")
+	return nil
+}
+
+// expiry converts an expiry time from a command: 0 for never, a negative
+// number for already expired, a number of seconds from now up to 30 days,
+// and otherwise a Unix time.
+func (s *session) expiry(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return time.Unix(1, 0)
+	case exptime <= maxRelativeExpiry:
+		return s.cache.now().Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+// checkKey checks that key is short enough and free of control
+// characters.
+func checkKey(key string) error {
+
+	if len(key) > maxKeyLength {
+		return clientError("key too long")
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] == 0x7f {
+			return clientError("invalid key")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// result is what one exchange with a server tells about the local clock.
+type result struct {
+	server string
+
+	// offset is how far the local clock is behind the server's: adding
+	// it to local time gives the server's time
+	offset time.Duration
+
+	// delay is the time the request and reply spent on the network,
+	// leaving out the time the server took to answer
+	delay time.Duration
+
+	stratum   uint8
+	rootDelay time.Duration
+}
+
+// client queries NTP servers.
+type client struct {
+	timeout time.Duration
+
+	// now reads the local clock, time.Now if nil
+	now func() time.Time
+}
+
+func (c *client) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// query sends one client request to server, a host with an optional port,
+// and works out the clock offset and round trip delay from the reply, as
+// described in RFC 5905 section 8:
+//
+//	offset = ((t2 - t1) + (t3 - t4)) / 2
+//	delay  = (t4 - t1) - (t3 - t2)
+//
+// where t1 is when the request was sent, t2 when the server received it, t3
+// when the server sent the reply and t4 when it arrived.
+func (c *client) query(ctx context.Context, server string) (*result, error) {
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The transmit timestamp of a request is only echoed back, so send a
+	// random one rather than revealing the local clock, and check the
+	// echo to tell the reply from a spoofed or stale one
+	var nonce [8]byte
+	rand.Read(nonce[:])
+	request := packet{version: version, mode: modeClient, transmit: timestamp(binary.BigEndian.Uint64(nonce[:]))}
+
+	t1 := c.clock()
+	if _, err := conn.Write(request.marshal()); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buffer)
+		t4 := c.clock()
+		if err != nil {
+			return nil, err
+		}
+
+		reply, err := parsePacket(buffer[:n])
+		if err != nil || reply.origin != request.transmit {
+			continue
+		}
+		if err := checkReply(reply); err != nil {
+			return nil, err
+		}
+
+		t2, t3 := reply.receive.time(), reply.transmit.time()
+		return &result{
+			server:    server,
+			offset:    (t2.Sub(t1) + t3.Sub(t4)) / 2,
+			delay:     t4.Sub(t1) - t3.Sub(t2),
+			stratum:   reply.stratum,
+			rootDelay: reply.rootDelay.duration(),
+		}, nil
+	}
+}
+
+// checkReply rejects replies that carry no usable time.
+func checkReply(reply *packet) error {
+
+	if reply.mode != modeServer {
+		return fmt.Errorf("reply has mode %d, not server", reply.mode)
+	}
+	if reply.stratum == 0 {
+		return fmt.Errorf("server sent kiss code %q", reply.kissCode())
+	}
+	if reply.leap == leapUnknown {
+		return errors.New("server clock is not synchronized")
+	}
+	if reply.transmit == 0 || reply.receive == 0 {
+		return errors.New("reply has no timestamps")
+	}
+	return nil
+}
+
+// best picks the result to trust from several servers: the one with the
+// least delay, since the offset assumes the request and reply took equally
+// long, and the error in that assumption is at most half the delay.
+func best(results []*result) *result {
+
+	var chosen *result
+	for _, r := range results {
+		if chosen == nil || r.delay < chosen.delay {
+			chosen = r
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,103 @@
+// Command ccntp is a simple NTP client. It asks one or more time servers
+// for the time, works out how far the local clock is off from each reply,
+// and prints the time corrected by the most trustworthy one. It only
+// reports the offset; it does not set the clock.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+// defaultServers are queried when no servers are given.
+var defaultServers = []string{
+	"0.pool.ntp.org",
+	"1.pool.ntp.org",
+	"2.pool.ntp.org",
+	"3.pool.ntp.org",
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccntp [flags] [server[:port]...]")
+	fmt.Fprintln(out, "Query NTP servers, by default the pool.ntp.org servers, and print the")
+	fmt.Fprintln(out, "offset of the local clock from each and the corrected time.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccntp"
+
+	timeout := flag.Duration("timeout", 5*time.Second, "wait this long for each server")
+	quiet := flag.Bool("q", false, "only print the corrected time")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	servers := flag.Args()
+	if len(servers) == 0 {
+		servers = defaultServers
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := &client{timeout: *timeout}
+
+	results := make([]*result, len(servers))
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = c.query(ctx, server)
+		}()
+	}
+	wg.Wait()
+
+	var answered []*result
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(out, "SERVER\tSTRATUM\tOFFSET\tDELAY")
+	}
+	for i, server := range servers {
+		if errs[i] != nil {
+			cli.Warn("%s: %v", server, errs[i])
+			continue
+		}
+		answered = append(answered, results[i])
+		if !*quiet {
+			r := results[i]
+			fmt.Fprintf(out, "%s\t%d\t%+.6fs\t%.6fs\n", r.server, r.stratum, r.offset.Seconds(), r.delay.Seconds())
+		}
+	}
+	out.Flush()
+
+	chosen := best(answered)
+	if chosen == nil {
+		cli.Exit(errors.New("no server answered"))
+	}
+
+	corrected := time.Now().Add(chosen.offset)
+	if *quiet {
+		fmt.Println(corrected.Format(time.RFC3339Nano))
+		return
+	}
+	fmt.Printf("\nUsing %s, offset %+.6fs ± %.6fs\n", chosen.server, chosen.offset.Seconds(), (chosen.delay / 2).Seconds())
+	fmt.Println("Local time:    ", time.Now().Format(time.RFC3339Nano))
+	fmt.Println("Corrected time:", corrected.Format(time.RFC3339Nano))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+
+	tests := []struct {
+		time time.Time
+		want timestamp
+	}{
+		{time.Unix(0, 0), ntpEpochOffset << 32},
+		{time.Unix(0, 5e8), ntpEpochOffset<<32 | 1<<31},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 3913056000 << 32},
+	}
+
+	for _, tt := range tests {
+		if got := toTimestamp(tt.time); got != tt.want {
+			t.Errorf("toTimestamp(%v) = %#x, want %#x", tt.time, got, tt.want)
+		}
+		if got := tt.want.time(); !got.Equal(tt.time) {
+			t.Errorf("%#x.time() = %v, want %v", tt.want, got, tt.time)
+		}
+	}
+
+	// Fractions survive the round trip to within a nanosecond
+	now := time.Unix(1700000000, 123456789)
+	if got := toTimestamp(now).time(); got.Sub(now).Abs() > time.Nanosecond {
+		t.Errorf("round trip of %v = %v", now, got)
+	}
+}
+
+func TestPacket(t *testing.T) {
+
+	p := packet{
+		leap:           leapNone,
+		version:        version,
+		mode:           modeServer,
+		stratum:        2,
+		poll:           6,
+		precision:      -20,
+		rootDelay:      0x00018000,
+		rootDispersion: 0x00000100,
+		referenceID:    0xc0a80001,
+		reference:      0x0102030405060708,
+		origin:         0x1112131415161718,
+		receive:        0x2122232425262728,
+		transmit:       0x3132333435363738,
+	}
+
+	b := p.marshal()
+	if len(b) != packetSize {
+		t.Fatalf("marshal made %d bytes, want %d", len(b), packetSize)
+	}
+	if b[0] != 0x24 || b[2] != 6 || b[3] != 0xec {
+		t.Errorf("header bytes = % x, want 24 02 06 ec", b[:4])
+	}
+
+	got, err := parsePacket(append(b, make([]byte, 20)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != p {
+		t.Errorf("parsePacket(marshal()) = %+v, want %+v", *got, p)
+	}
+	if d := got.rootDelay.duration(); d != 1500*time.Millisecond {
+		t.Errorf("root delay = %v, want 1.5s", d)
+	}
+
+	if _, err := parsePacket(b[:47]); err != errShortPacket {
+		t.Errorf("parsePacket of 47 bytes = %v, want errShortPacket", err)
+	}
+
+	kiss := packet{referenceID: 0x52415445}
+	if code := kiss.kissCode(); code != "RATE" {
+		t.Errorf("kissCode = %q, want RATE", code)
+	}
+}
+
+// fakeServer answers NTP requests as a server whose clock is skew ahead of
+// the local one and which takes hold to answer, after a one-way network
+// delay the client's clock sees as latency in each direction.
+func fakeServer(t *testing.T, skew, hold time.Duration, reply func(*packet)) (string, *fakeClock) {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), latency: 20 * time.Millisecond}
+
+	go func() {
+		buffer := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			request, err := parsePacket(buffer[:n])
+			if err != nil {
+				continue
+			}
+
+			// The client read its clock just before sending
+			clock.mu.Lock()
+			received := clock.now.Add(clock.latency + skew)
+			p := &packet{
+				version:  version,
+				mode:     modeServer,
+				stratum:  1,
+				origin:   request.transmit,
+				receive:  toTimestamp(received),
+				transmit: toTimestamp(received.Add(hold)),
+			}
+			if reply != nil {
+				reply(p)
+			}
+			clock.now = clock.now.Add(2*clock.latency + hold)
+			clock.mu.Unlock()
+			conn.WriteTo(p.marshal(), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), clock
+}
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	latency time.Duration
+}
+
+func (c *fakeClock) read() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func TestQuery(t *testing.T) {
+
+	skew := 3 * time.Second
+	hold := 5 * time.Millisecond
+	addr, clock := fakeServer(t, skew, hold, nil)
+
+	c := &client{timeout: time.Second, now: clock.read}
+	r, err := c.query(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.offset != skew {
+		t.Errorf("offset = %v, want %v", r.offset, skew)
+	}
+	if r.delay != 2*clock.latency {
+		t.Errorf("delay = %v, want %v", r.delay, 2*clock.latency)
+	}
+	if r.stratum != 1 {
+		t.Errorf("stratum = %d, want 1", r.stratum)
+	}
+}
+
+func TestQueryRejects(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		reply func(*packet)
+		want  string
+	}{
+		{"kiss of death", func(p *packet) { p.stratum = 0; p.referenceID = 0x52415445 }, `kiss code "RATE"`},
+		{"unsynchronized", func(p *packet) { p.leap = leapUnknown }, "not synchronized"},
+		{"wrong mode", func(p *packet) { p.mode = modeClient }, "mode 3"},
+		{"no timestamps", func(p *packet) { p.transmit = 0 }, "no timestamps"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, _ := fakeServer(t, 0, 0, tt.reply)
+			c := &client{timeout: time.Second}
+			_, err := c.query(context.Background(), addr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("query error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// A reply that does not echo the request is ignored, so the query
+	// times out
+	addr, _ := fakeServer(t, 0, 0, func(p *packet) { p.origin++ })
+	c := &client{timeout: 100 * time.Millisecond}
+	if _, err := c.query(context.Background(), addr); err == nil {
+		t.Error("query accepted a reply to a different request")
+	}
+}
+
+func TestBest(t *testing.T) {
+
+	results := []*result{
+		{server: "a", delay: 30 * time.Millisecond},
+		{server: "b", delay: 10 * time.Millisecond},
+		{server: "c", delay: 20 * time.Millisecond},
+	}
+	if got := best(results); got.server != "b" {
+		t.Errorf("best = %s, want b", got.server)
+	}
+	if best(nil) != nil {
+		t.Error("best(nil) is not nil")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// packetSize is the size of an NTP packet without extensions or a MAC.
+const packetSize = 48
+
+// Modes of an NTP association, from RFC 5905.
+const (
+	modeClient = 3
+	modeServer = 4
+)
+
+// Leap indicators. leapUnknown means the server's clock is not
+// synchronized.
+const (
+	leapNone    = 0
+	leapUnknown = 3
+)
+
+// version is the NTP version the client speaks.
+const version = 4
+
+// timestamp is an NTP timestamp: seconds since 1900 in the high 32 bits and
+// fractions of a second in the low 32.
+type timestamp uint64
+
+// ntpEpochOffset is the number of seconds from 1900, the NTP epoch, to
+// 1970, the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// toTimestamp converts t, which must fall in the NTP era that started in
+// 1900 and ends in 2036.
+func toTimestamp(t time.Time) timestamp {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return timestamp(seconds<<32 | fraction)
+}
+
+func (ts timestamp) time() time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanoseconds := int64((uint64(ts&math.MaxUint32)*1e9 + 1<<31) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
+
+// shortDuration is an NTP short format duration: seconds in the high 16
+// bits and fractions of a second in the low 16, as in root delay and
+// dispersion.
+type shortDuration uint32
+
+func (d shortDuration) duration() time.Duration {
+	return time.Duration(uint64(d) * uint64(time.Second) >> 16)
+}
+
+// packet is an NTP packet, as laid out in RFC 5905 section 7.3.
+type packet struct {
+	leap      uint8
+	version   uint8
+	mode      uint8
+	stratum   uint8
+	poll      int8
+	precision int8
+
+	rootDelay      shortDuration
+	rootDispersion shortDuration
+
+	// referenceID identifies the server's reference clock, or for stratum
+	// 0, holds a kiss code in ASCII
+	referenceID uint32
+
+	reference timestamp
+	origin    timestamp
+	receive   timestamp
+	transmit  timestamp
+}
+
+func (p *packet) marshal() []byte {
+
+	b := make([]byte, packetSize)
+	b[0] = p.leap<<6 | (p.version&7)<<3 | p.mode&7
+	b[1] = p.stratum
+	b[2] = byte(p.poll)
+	b[3] = byte(p.precision)
+	binary.BigEndian.PutUint32(b[4:], uint32(p.rootDelay))
+	binary.BigEndian.PutUint32(b[8:], uint32(p.rootDispersion))
+	binary.BigEndian.PutUint32(b[12:], p.referenceID)
+	binary.BigEndian.PutUint64(b[16:], uint64(p.reference))
+	binary.BigEndian.PutUint64(b[24:], uint64(p.origin))
+	binary.BigEndian.PutUint64(b[32:], uint64(p.receive))
+	binary.BigEndian.PutUint64(b[40:], uint64(p.transmit))
+	return b
+}
+
+var errShortPacket = errors.New("packet is too short")
+
+// parsePacket parses the header of an NTP packet, ignoring any extension
+// fields and MAC that follow.
+func parsePacket(b []byte) (*packet, error) {
+
+	if len(b) < packetSize {
+		return nil, errShortPacket
+	}
+
+	return &packet{
+		leap:           b[0] >> 6,
+		version:        b[0] >> 3 & 7,
+		mode:           b[0] & 7,
+		stratum:        b[1],
+		poll:           int8(b[2]),
+		precision:      int8(b[3]),
+		rootDelay:      shortDuration(binary.BigEndian.Uint32(b[4:])),
+		rootDispersion: shortDuration(binary.BigEndian.Uint32(b[8:])),
+		referenceID:    binary.BigEndian.Uint32(b[12:]),
+		reference:      timestamp(binary.BigEndian.Uint64(b[16:])),
+		origin:         timestamp(binary.BigEndian.Uint64(b[24:])),
+		receive:        timestamp(binary.BigEndian.Uint64(b[32:])),
+		transmit:       timestamp(binary.BigEndian.Uint64(b[40:])),
+	}, nil
+}
+
+// kissCode returns the four ASCII characters of the reference ID, which for
+// a stratum 0 reply say why the server refused to answer, such as "RATE".
+func (p *packet) kissCode() string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], p.referenceID)
+	return string(b[:])
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// level is an error correction level, which trades capacity for the share
+// of a symbol that can be damaged and still read: about 7% for L, 15% for
+// M, 25% for Q and 30% for H.
+type level int
+
+const (
+	levelL level = iota
+	levelM
+	levelQ
+	levelH
+)
+
+var levelNames = [...]string{"L", "M", "Q", "H"}
+
+func (l level) String() string {
+	return levelNames[l]
+}
+
+// parseLevel parses a level name.
+func parseLevel(name string) (level, error) {
+	for l, n := range levelNames {
+		if n == name {
+			return level(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", name)
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// eccPerBlock and eccBlocks give, by level and version, the error
+// correction codewords of each block and the number of blocks, from table
+// 9 of ISO/IEC 18004. Index 0 is unused.
+var eccPerBlock = [4][maxVersion + 1]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var eccBlocks = [4][maxVersion + 1]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// modeByte is the mode indicator of byte mode, which encodes any data as
+// 8 bits a byte.
+const modeByte = 0b0100
+
+var errTooLong = errors.New("data too long for a QR code")
+
+// rawModules returns the number of modules of a symbol of the version that
+// hold data or error correction: the whole symbol less the finder patterns
+// with their separators, the timing patterns, the alignment patterns where
+// they do not overlap those, the format information and dark module, and
+// from version 7 the version information.
+func rawModules(version int) int {
+
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+	}
+	if version >= 7 {
+		n -= 36
+	}
+	return n
+}
+
+// dataCodewords returns how many codewords of a symbol hold data.
+func dataCodewords(version int, l level) int {
+	return rawModules(version)/8 - eccPerBlock[l][version]*eccBlocks[l][version]
+}
+
+// countBits returns the size of the character count of byte mode.
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// byteCapacity returns how many bytes a symbol holds in byte mode.
+func byteCapacity(version int, l level) int {
+	return (8*dataCodewords(version, l) - 4 - countBits(version)) / 8
+}
+
+// chooseVersion returns the smallest version that holds data in byte mode
+// at the level.
+func chooseVersion(data []byte, l level) (int, error) {
+	for v := minVersion; v <= maxVersion; v++ {
+		if len(data) <= byteCapacity(v, l) {
+			return v, nil
+		}
+	}
+	return 0, errTooLong
+}
+
+// bitBuffer collects bits, most significant first.
+type bitBuffer struct {
+	bytes []byte
+	n     int
+}
+
+// append adds the low count bits of value.
+func (b *bitBuffer) append(value uint, count int) {
+	for i := count - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if value>>i&1 != 0 {
+			b.bytes[b.n/8] |= 0x80 >> (b.n % 8)
+		}
+		b.n++
+	}
+}
+
+// encodeData returns the data codewords of a symbol: the mode, count and
+// data, a terminator of up to four zero bits, zeros to the end of the byte
+// and alternating pad codewords to fill the capacity.
+func encodeData(data []byte, version int, l level) []byte {
+
+	capacity := 8 * dataCodewords(version, l)
+	var b bitBuffer
+	b.append(modeByte, 4)
+	b.append(uint(len(data)), countBits(version))
+	for _, c := range data {
+		b.append(uint(c), 8)
+	}
+	b.append(0, min(4, capacity-b.n))
+	b.append(0, (8-b.n%8)%8)
+	for pad := uint(0xEC); b.n < capacity; pad ^= 0xEC ^ 0x11 {
+		b.append(pad, 8)
+	}
+	return b.bytes
+}
+
+// addErrorCorrection splits the data codewords into the version's blocks,
+// computes each block's error correction codewords, and interleaves them:
+// the first data codeword of each block, then the second, and so on, then
+// the error correction codewords likewise. Blocks differ in length by at
+// most one data codeword, the shorter ones first.
+func addErrorCorrection(data []byte, version int, l level) []byte {
+
+	blocks := eccBlocks[l][version]
+	eccLen := eccPerBlock[l][version]
+	raw := rawModules(version) / 8
+	shortBlocks := blocks - raw%blocks
+	shortLen := raw/blocks - eccLen
+
+	generator := rsGenerator(eccLen)
+	dataBlocks := make([][]byte, blocks)
+	eccBlocks := make([][]byte, blocks)
+	for i := range blocks {
+		n := shortLen
+		if i >= shortBlocks {
+			n++
+		}
+		dataBlocks[i], data = data[:n], data[n:]
+		eccBlocks[i] = rsRemainder(dataBlocks[i], generator)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range shortLen + 1 {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := range eccLen {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2⁸) with the QR code polynomial
+// x⁸ + x⁴ + x³ + x² + 1.
+func gfMultiply(x, y byte) byte {
+
+	var z byte
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ byte(int(z>>7)*0x1D)
+		if y>>i&1 != 0 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// rsGenerator returns the Reed-Solomon generator polynomial of the degree,
+// the product of (x - αⁱ) for i below it, as its coefficients from the
+// highest power down, leaving out the leading 1.
+func rsGenerator(degree int) []byte {
+
+	g := make([]byte, degree)
+	g[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		// Multiply by (x - root)
+		for j := range g {
+			g[j] = gfMultiply(g[j], root)
+			if j+1 < len(g) {
+				g[j] ^= g[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+	return g
+}
+
+// rsRemainder returns the error correction codewords of data: the
+// remainder of dividing it, shifted up by the generator's degree, by the
+// generator.
+func rsRemainder(data, generator []byte) []byte {
+
+	r := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i, g := range generator {
+			r[i] ^= gfMultiply(g, factor)
+		}
+	}
+	return r
+}
@@ -0,0 +1,88 @@
+// Command ccqr makes QR codes. It encodes its arguments, or standard input
+// without any, as a QR code in byte mode at the error correction level
+// chosen, and draws it on the terminal with block characters or writes it
+// as a PNG image.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccqr [flags] [text...]")
+	fmt.Fprintln(out, "Encode text, or standard input, as a QR code.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccqr"
+
+	levelName := flag.String("level", "M", "error correction `level`: L, M, Q or H")
+	output := flag.String("o", "", "write a PNG image to `file` instead of drawing on the terminal")
+	scale := flag.Int("scale", 8, "with -o, draw each module as `N` by N pixels")
+	border := flag.Int("border", quietZone, "surround the code with a light border of `N` modules")
+	plain := flag.Bool("plain", false, "draw in the terminal's own colors, without ANSI escapes")
+	verbose := flag.Bool("v", false, "report the version, level and mask chosen to standard error")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	l, err := parseLevel(strings.ToUpper(*levelName))
+	if err != nil {
+		cli.Exit(cli.Usagef("%v", err))
+	}
+	if *scale < 1 || *border < 0 {
+		cli.Exit(cli.Usagef("-scale must be positive and -border must not be negative"))
+	}
+
+	var data []byte
+	if flag.NArg() > 0 {
+		data = []byte(strings.Join(flag.Args(), " "))
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			cli.Exit(&cli.FileError{File: cli.Stdin, Err: err})
+		}
+		// A line typed or echoed in ends with a newline nobody means to
+		// encode
+		data = bytes.TrimSuffix(bytes.TrimSuffix(data, []byte("\n")), []byte("\r"))
+	}
+
+	s, err := encode(data, l)
+	if err != nil {
+		cli.Exit(fmt.Errorf("%w: %d bytes, at most %d at level %v", err, len(data), byteCapacity(maxVersion, l), l))
+	}
+	if *verbose {
+		cli.Warn("version %d (%d×%d), level %v, mask %d", s.version, s.size, s.size, s.level, s.mask)
+	}
+
+	if *output == "" {
+		if err := writeTerminal(os.Stdout, s, *border, !*plain); err != nil {
+			cli.Exit(err)
+		}
+		return
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		cli.Exit(&cli.FileError{File: *output, Err: err})
+	}
+	err = writePNG(file, s, *scale, *border)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cli.Exit(&cli.FileError{File: *output, Err: err})
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+// symbol is a QR code: a square of dark and light modules.
+type symbol struct {
+	size    int
+	version int
+	level   level
+	mask    int
+
+	dark []bool
+
+	// function marks the modules of function patterns and format and
+	// version information, which hold no data and are not masked
+	function []bool
+}
+
+func (s *symbol) at(x, y int) bool {
+	return s.dark[y*s.size+x]
+}
+
+// setFunction sets a module of a function pattern.
+func (s *symbol) setFunction(x, y int, dark bool) {
+	s.dark[y*s.size+x] = dark
+	s.function[y*s.size+x] = true
+}
+
+// encode makes the symbol for data at the level, in the smallest version
+// that holds it, with the mask that scores best.
+func encode(data []byte, l level) (*symbol, error) {
+
+	version, err := chooseVersion(data, l)
+	if err != nil {
+		return nil, err
+	}
+	codewords := addErrorCorrection(encodeData(data, version, l), version, l)
+
+	size := 17 + 4*version
+	s := &symbol{
+		size:     size,
+		version:  version,
+		level:    l,
+		dark:     make([]bool, size*size),
+		function: make([]bool, size*size),
+	}
+	s.drawFunctionPatterns()
+	s.placeCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		s.applyMask(mask)
+		s.drawFormat(mask)
+		if p := s.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		// Masking again undoes it
+		s.applyMask(mask)
+	}
+	s.mask = best
+	s.applyMask(best)
+	s.drawFormat(best)
+	return s, nil
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns
+// and the version information, and reserves the format information,
+// which depends on the mask.
+func (s *symbol) drawFunctionPatterns() {
+
+	for i := range s.size {
+		s.setFunction(6, i, i%2 == 0)
+		s.setFunction(i, 6, i%2 == 0)
+	}
+
+	s.drawFinder(3, 3)
+	s.drawFinder(s.size-4, 3)
+	s.drawFinder(3, s.size-4)
+
+	positions := alignmentPositions(s.version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// The corners with finder patterns have none
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			s.drawAlignment(x, y)
+		}
+	}
+
+	s.drawFormat(0)
+	s.drawVersion()
+}
+
+// drawFinder draws a finder pattern centred on x, y, with the light
+// separator around it where it falls inside the symbol.
+func (s *symbol) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if 0 <= xx && xx < s.size && 0 <= yy && yy < s.size {
+				d := max(abs(dx), abs(dy))
+				s.setFunction(xx, yy, d != 2 && d != 4)
+			}
+		}
+	}
+}
+
+// drawAlignment draws an alignment pattern centred on x, y.
+func (s *symbol) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			s.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPositions returns the coordinates, on both axes, of the centres
+// of the alignment patterns of a version: 6, then evenly spaced to the far
+// edge, with the spacing even and any slack taken up by the first gap.
+func alignmentPositions(version int) []int {
+
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	}
+
+	positions := make([]int, n)
+	positions[0] = 6
+	for i, pos := n-1, 17+4*version-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// formatBits returns the 15 bits of format information for a level and
+// mask: a BCH(15,5) code masked with 101010000010010 so that it is never
+// all zeros.
+func formatBits(l level, mask int) int {
+
+	// The level's indicator is not its order: L is 01, M 00, Q 11, H 10
+	indicator := [...]int{1, 0, 3, 2}[l]
+	data := indicator<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormat draws both copies of the format information, and the dark
+// module beside one of them.
+func (s *symbol) drawFormat(mask int) {
+
+	bits := formatBits(s.level, mask)
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	// Around the top left finder
+	for i := range 6 {
+		s.setFunction(8, i, bit(i))
+	}
+	s.setFunction(8, 7, bit(6))
+	s.setFunction(8, 8, bit(7))
+	s.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		s.setFunction(14-i, 8, bit(i))
+	}
+
+	// Split between the other two finders
+	for i := range 8 {
+		s.setFunction(s.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		s.setFunction(8, s.size-15+i, bit(i))
+	}
+	s.setFunction(8, s.size-8, true)
+}
+
+// versionBits returns the 18 bits of version information, a BCH(18,6)
+// code of the version.
+func versionBits(version int) int {
+	rem := version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawVersion draws both copies of the version information, which
+// versions 7 and up have beside the top right and bottom left finders.
+func (s *symbol) drawVersion() {
+
+	if s.version < 7 {
+		return
+	}
+	bits := versionBits(s.version)
+	for i := range 18 {
+		dark := bits>>i&1 != 0
+		a, b := s.size-11+i%3, i/3
+		s.setFunction(a, b, dark)
+		s.setFunction(b, a, dark)
+	}
+}
+
+// placeCodewords fills the modules that are not function patterns with
+// the bits of codewords, most significant first, in a zigzag of two
+// columns at a time from the bottom right, up then down, skipping the
+// vertical timing pattern. Modules left over stay light.
+func (s *symbol) placeCodewords(codewords []byte) {
+
+	i := 0
+	for right := s.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range s.size {
+			for j := range 2 {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = s.size - 1 - vert
+				}
+				if s.function[y*s.size+x] {
+					continue
+				}
+				if i < len(codewords)*8 {
+					s.dark[y*s.size+x] = codewords[i/8]>>(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// maskFunctions are the eight masks, each reporting whether it flips the
+// module at x, y.
+var maskFunctions = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// applyMask flips the data modules the mask selects.
+func (s *symbol) applyMask(mask int) {
+	for y := range s.size {
+		for x := range s.size {
+			if !s.function[y*s.size+x] && maskFunctions[mask](x, y) {
+				s.dark[y*s.size+x] = !s.dark[y*s.size+x]
+			}
+		}
+	}
+}
+
+// Penalty weights of the mask evaluation of ISO/IEC 18004.
+const (
+	penaltyRun     = 3  // for a run of five modules of one color, plus one for each more
+	penaltyBlock   = 3  // for each 2×2 block of one color
+	penaltyFinder  = 40 // for each pattern that looks like a finder
+	penaltyBalance = 10 // for each 5% the dark share is away from half
+)
+
+// penalty scores the symbol as masked, lower being easier to read:
+// long runs and blocks of one color, look-alikes of finder patterns and
+// an imbalance of dark and light all count against it.
+func (s *symbol) penalty() int {
+
+	p := 0
+	for i := range s.size {
+		p += s.linePenalty(func(j int) bool { return s.at(j, i) })
+		p += s.linePenalty(func(j int) bool { return s.at(i, j) })
+	}
+
+	for y := range s.size - 1 {
+		for x := range s.size - 1 {
+			c := s.at(x, y)
+			if c == s.at(x+1, y) && c == s.at(x, y+1) && c == s.at(x+1, y+1) {
+				p += penaltyBlock
+			}
+		}
+	}
+
+	dark := 0
+	for _, d := range s.dark {
+		if d {
+			dark++
+		}
+	}
+	total := len(s.dark)
+	// The number of whole 5% steps away from half
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	p += max(k, 0) * penaltyBalance
+	return p
+}
+
+// finderLike is dark-light-dark-dark-dark-light-dark with four light
+// modules on one side, the ratio of a finder pattern.
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// linePenalty scores the runs and finder look-alikes of one row or
+// column, whose modules module returns.
+func (s *symbol) linePenalty(module func(int) bool) int {
+
+	p := 0
+	run := 1
+	for j := 1; j <= s.size; j++ {
+		if j < s.size && module(j) == module(j-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += penaltyRun + run - 5
+		}
+		run = 1
+	}
+
+	for j := 0; j+11 <= s.size; j++ {
+		for _, pattern := range finderLike {
+			matched := true
+			for k, dark := range pattern {
+				if module(j+k) != dark {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				p += penaltyFinder
+			}
+		}
+	}
+	return p
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestReedSolomon(t *testing.T) {
+
+	// The worked example of "HELLO WORLD" at 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsGenerator(10)); !bytes.Equal(got, want) {
+		t.Errorf("error correction = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeData(t *testing.T) {
+
+	// Mode 0100, count 00000010, "hi", terminator, then pad codewords
+	got := encodeData([]byte("hi"), 1, levelH)
+	want := []byte{0x40, 0x26, 0x86, 0x90, 0xEC, 0x11, 0xEC, 0x11, 0xEC}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeData = % x, want % x", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+
+	tests := []struct {
+		level level
+		mask  int
+		want  string
+	}{
+		{levelL, 0, "111011111000100"},
+		{levelL, 1, "111001011110011"},
+		{levelL, 2, "111110110101010"},
+		{levelL, 3, "111100010011101"},
+		{levelL, 4, "110011000101111"},
+		{levelL, 5, "110001100011000"},
+		{levelL, 6, "110110001000001"},
+		{levelL, 7, "110100101110110"},
+		{levelM, 0, "101010000010010"},
+		{levelQ, 0, "011010101011111"},
+		{levelH, 0, "001011010001001"},
+	}
+
+	for _, test := range tests {
+		if got := fmt.Sprintf("%015b", formatBits(test.level, test.mask)); got != test.want {
+			t.Errorf("formatBits(%v, %d) = %s, want %s", test.level, test.mask, got, test.want)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+
+	tests := []struct {
+		version int
+		want    string
+	}{
+		{7, "000111110010010100"},
+		{8, "001000010110111100"},
+		{40, "101000110001101001"},
+	}
+
+	for _, test := range tests {
+		if got := fmt.Sprintf("%018b", versionBits(test.version)); got != test.want {
+			t.Errorf("versionBits(%d) = %s, want %s", test.version, got, test.want)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+
+	tests := []struct {
+		version int
+		want    []int
+	}{
+		{1, nil},
+		{2, []int{6, 18}},
+		{7, []int{6, 22, 38}},
+		{15, []int{6, 26, 48, 70}},
+		{32, []int{6, 34, 60, 86, 112, 138}},
+		{36, []int{6, 24, 50, 76, 102, 128, 154}},
+		{40, []int{6, 30, 58, 86, 114, 142, 170}},
+	}
+
+	for _, test := range tests {
+		if got := alignmentPositions(test.version); !slices.Equal(got, test.want) {
+			t.Errorf("alignmentPositions(%d) = %v, want %v", test.version, got, test.want)
+		}
+	}
+}
+
+func TestByteCapacity(t *testing.T) {
+
+	tests := []struct {
+		version int
+		want    [4]int
+	}{
+		{1, [4]int{17, 14, 11, 7}},
+		{2, [4]int{32, 26, 20, 14}},
+		{10, [4]int{271, 213, 151, 119}},
+		{40, [4]int{2953, 2331, 1663, 1273}},
+	}
+
+	for _, test := range tests {
+		for l, want := range test.want {
+			if got := byteCapacity(test.version, level(l)); got != want {
+				t.Errorf("byteCapacity(%d, %v) = %d, want %d", test.version, level(l), got, want)
+			}
+		}
+	}
+
+	if _, err := encode(make([]byte, 1274), levelH); err != errTooLong {
+		t.Errorf("encoding 1274 bytes at H: error = %v, want %v", err, errTooLong)
+	}
+}
+
+// decode reads the data back out of a symbol the way a reader would, from
+// its modules alone apart from the version: it reads the format
+// information, unmasks, collects the codewords in placement order, splits
+// them into blocks and checks each with its error correction.
+func decode(t *testing.T, s *symbol) []byte {
+	t.Helper()
+
+	// The first copy of the format information, bit 14 first
+	var bits int
+	for i := 14; i >= 0; i-- {
+		var x, y int
+		switch {
+		case i < 6:
+			x, y = 8, i
+		case i == 6:
+			x, y = 8, 7
+		case i == 7:
+			x, y = 8, 8
+		case i == 8:
+			x, y = 7, 8
+		default:
+			x, y = 14-i, 8
+		}
+		bits <<= 1
+		if s.at(x, y) {
+			bits |= 1
+		}
+	}
+	var l level
+	mask := -1
+	for candidate := range 4 {
+		for m := range 8 {
+			if formatBits(level(candidate), m) == bits {
+				l, mask = level(candidate), m
+			}
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format information %015b is not valid", bits)
+	}
+
+	// Read the data modules in the placement order, unmasked
+	var codewords []byte
+	var n int
+	for right := s.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range s.size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = s.size - 1 - vert
+				}
+				if s.function[y*s.size+x] {
+					continue
+				}
+				if n%8 == 0 {
+					codewords = append(codewords, 0)
+				}
+				if s.at(x, y) != maskFunctions[mask](x, y) {
+					codewords[n/8] |= 0x80 >> (n % 8)
+				}
+				n++
+			}
+		}
+	}
+	codewords = codewords[:rawModules(s.version)/8]
+
+	// Undo the interleaving
+	blocks := eccBlocks[l][s.version]
+	eccLen := eccPerBlock[l][s.version]
+	shortLen := len(codewords)/blocks - eccLen
+	shortBlocks := blocks - len(codewords)%blocks
+	blockData := make([][]byte, blocks)
+	next := 0
+	for i := range shortLen + 1 {
+		for b := range blocks {
+			if i < shortLen || b >= shortBlocks {
+				blockData[b] = append(blockData[b], codewords[next])
+				next++
+			}
+		}
+	}
+	for range eccLen {
+		for b := range blocks {
+			blockData[b] = append(blockData[b], codewords[next])
+			next++
+		}
+	}
+
+	// A block without errors is a multiple of the generator, so it is zero
+	// at each of the generator's roots
+	var data []byte
+	for b, block := range blockData {
+		root := byte(1)
+		for i := range eccLen {
+			var value byte
+			for _, c := range block {
+				value = gfMultiply(value, root) ^ c
+			}
+			if value != 0 {
+				t.Fatalf("block %d has syndrome %d = %d", b, i, value)
+			}
+			root = gfMultiply(root, 2)
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	// Byte mode: 0100, the count, then the bytes
+	read := func(pos, count int) int {
+		v := 0
+		for i := pos; i < pos+count; i++ {
+			v = v<<1 | int(data[i/8]>>(7-i%8)&1)
+		}
+		return v
+	}
+	if mode := read(0, 4); mode != modeByte {
+		t.Fatalf("mode = %04b, want %04b", mode, modeByte)
+	}
+	count := read(4, countBits(s.version))
+	result := make([]byte, count)
+	for i := range count {
+		result[i] = byte(read(4+countBits(s.version)+8*i, 8))
+	}
+	return result
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	inputs := [][]byte{
+		nil,
+		[]byte("hello world"),
+		[]byte("https://example.com/a/longer/path?with=query&and=more#fragment"),
+		[]byte("héllo wörld ✓"),
+		bytes.Repeat([]byte{0, 255, 17, 236}, 60),
+		bytes.Repeat([]byte("version seven and up carry version information. "), 4),
+		bytes.Repeat([]byte("x"), 1200),
+	}
+
+	for _, input := range inputs {
+		for l := levelL; l <= levelH; l++ {
+			s, err := encode(input, l)
+			if err != nil {
+				t.Fatalf("encode(%d bytes, %v): %v", len(input), l, err)
+			}
+			if got := decode(t, s); !bytes.Equal(got, input) {
+				t.Errorf("decoded %d bytes at %v-%v, want %d bytes", len(got), s.version, l, len(input))
+			}
+		}
+	}
+}
+
+func TestVersionChoice(t *testing.T) {
+
+	tests := []struct {
+		size  int
+		level level
+		want  int
+	}{
+		{0, levelH, 1},
+		{17, levelL, 1},
+		{18, levelL, 2},
+		{7, levelH, 1},
+		{8, levelH, 2},
+		{2953, levelL, 40},
+	}
+
+	for _, test := range tests {
+		s, err := encode(make([]byte, test.size), test.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.version != test.want || s.size != 17+4*test.want {
+			t.Errorf("%d bytes at %v: version %d, size %d, want version %d", test.size, test.level, s.version, s.size, test.want)
+		}
+	}
+}
+
+// TestGolden compares the rendering of symbols with the files in
+// testdata/golden: a drawing with block characters, which shows the
+// modules at a glance in a diff, and a PNG image. Run with -update to
+// rewrite them after a deliberate change.
+func TestGolden(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		text  string
+		level level
+	}{
+		{"hello-m", "hello world", levelM},
+		{"url-h", "https://example.com", levelH},
+		{"version7-l", strings.Repeat("0123456789", 15), levelL},
+	}
+
+	for _, test := range tests {
+		s, err := encode([]byte(test.text), test.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var text, image bytes.Buffer
+		if err := writeTerminal(&text, s, 1, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := writePNG(&image, s, 4, quietZone); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, golden := range []struct {
+			ext  string
+			data []byte
+		}{
+			{".txt", text.Bytes()},
+			{".png", image.Bytes()},
+		} {
+			path := filepath.Join("testdata", "golden", test.name+golden.ext)
+			if *update {
+				if err := os.WriteFile(path, golden.data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(golden.data, want) {
+				t.Errorf("%s differs from %s; run with -update if that is intended", test.name+golden.ext, path)
+			}
+		}
+	}
+}
+
+func TestTerminalColors(t *testing.T) {
+
+	s, err := encode([]byte("x"), levelL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := writeTerminal(&out, s, quietZone, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each line is wrapped in the colors, and an odd number of rows ends
+	// with a half line
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if want := (s.size + 2*quietZone + 1) / 2; len(lines) != want {
+		t.Errorf("%d lines, want %d", len(lines), want)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, ansiColors) || !strings.HasSuffix(line, ansiReset) {
+			t.Errorf("line %d = %s, want it wrapped in colors", i, strconv.Quote(line))
+		}
+	}
+}
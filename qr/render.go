@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// quietZone is the light border a reader needs around a symbol, in
+// modules.
+const quietZone = 4
+
+// module reports whether the module at x, y is dark, treating everything
+// outside the symbol, such as the quiet zone, as light.
+func (s *symbol) module(x, y int) bool {
+	return 0 <= x && x < s.size && 0 <= y && y < s.size && s.at(x, y)
+}
+
+// image returns the symbol as a black and white image, scale pixels to a
+// module, with a quiet zone of border modules.
+func (s *symbol) image(scale, border int) *image.Paletted {
+
+	side := (s.size + 2*border) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := range side {
+		for x := range side {
+			if s.module(x/scale-border, y/scale-border) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+// writePNG writes the symbol as a PNG image.
+func writePNG(out io.Writer, s *symbol, scale, border int) error {
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(out, s.image(scale, border))
+}
+
+// ANSI escapes to draw black on white, whatever the terminal's colors.
+const (
+	ansiColors = "\x1b[30;107m"
+	ansiReset  = "\x1b[0m"
+)
+
+// writeTerminal draws the symbol with Unicode half blocks, two rows of
+// modules to a line of text. With ansi the colors are set explicitly, since
+// on a terminal with light text on a dark background a symbol drawn in the
+// text color comes out inverted, which many readers reject.
+func writeTerminal(out io.Writer, s *symbol, border int, ansi bool) error {
+
+	w := bufio.NewWriter(out)
+	for y := -border; y < s.size+border; y += 2 {
+		if ansi {
+			w.WriteString(ansiColors)
+		}
+		for x := -border; x < s.size+border; x++ {
+			top := s.module(x, y)
+			bottom := y+1 < s.size+border && s.module(x, y+1)
+			switch {
+			case top && bottom:
+				w.WriteString("█")
+			case top:
+				w.WriteString("▀")
+			case bottom:
+				w.WriteString("▄")
+			default:
+				w.WriteByte(' ')
+			}
+		}
+		if ansi {
+			w.WriteString(ansiReset)
+		}
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
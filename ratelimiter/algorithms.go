@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket lets each client make bursts of up to capacity requests,
+// refilling its bucket at rate tokens a second; each request takes a
+// token.
+type tokenBucket struct {
+	capacity int
+	rate     float64
+	clients  keyed[bucket]
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(capacity int, rate float64, now func() time.Time) *tokenBucket {
+	return &tokenBucket{capacity: capacity, rate: rate, clients: newKeyed[bucket](now)}
+}
+
+func (l *tokenBucket) allow(key string) decision {
+	return l.clients.with(key, func(b *bucket, now time.Time) decision {
+
+		// A new client starts with a full bucket
+		if b.last.IsZero() {
+			b.tokens = float64(l.capacity)
+		} else {
+			b.tokens = math.Min(float64(l.capacity), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		}
+		b.last = now
+
+		d := decision{limit: l.capacity}
+		if b.tokens >= 1 {
+			b.tokens--
+			d.allowed = true
+		} else {
+			d.retryAfter = time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		}
+		d.remaining = int(b.tokens)
+		return d
+	})
+}
+
+func (l *tokenBucket) sweep() {
+
+	// A client whose bucket has refilled is the same as a new one
+	full := time.Duration(float64(l.capacity) / l.rate * float64(time.Second))
+	l.clients.sweep(func(b *bucket, now time.Time) bool {
+		return now.Sub(b.last) >= full
+	})
+}
+
+// fixedWindow lets each client make limit requests in each window of
+// time, the windows starting at multiples of window since the epoch. A
+// client can make up to twice the limit around the boundary between two
+// windows.
+type fixedWindow struct {
+	limit   int
+	window  time.Duration
+	clients keyed[counter]
+}
+
+type counter struct {
+	start time.Time
+	count int
+}
+
+func newFixedWindow(limit int, window time.Duration, now func() time.Time) *fixedWindow {
+	return &fixedWindow{limit: limit, window: window, clients: newKeyed[counter](now)}
+}
+
+func (l *fixedWindow) allow(key string) decision {
+	return l.clients.with(key, func(c *counter, now time.Time) decision {
+
+		start := now.Truncate(l.window)
+		if !c.start.Equal(start) {
+			c.start, c.count = start, 0
+		}
+
+		d := decision{limit: l.limit}
+		if c.count < l.limit {
+			c.count++
+			d.allowed = true
+		} else {
+			d.retryAfter = start.Add(l.window).Sub(now)
+		}
+		d.remaining = l.limit - c.count
+		return d
+	})
+}
+
+func (l *fixedWindow) sweep() {
+	l.clients.sweep(func(c *counter, now time.Time) bool {
+		return now.Sub(c.start) >= l.window
+	})
+}
+
+// slidingWindow lets each client make limit requests in any window of
+// time, estimating the requests in the window ending now from the counts
+// in the current fixed window and the one before, weighted by how much of
+// the previous window the sliding one still covers. This smooths out the
+// bursts fixedWindow allows at window boundaries while keeping only two
+// counts per client.
+type slidingWindow struct {
+	limit   int
+	window  time.Duration
+	clients keyed[slidingCounter]
+}
+
+type slidingCounter struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+func newSlidingWindow(limit int, window time.Duration, now func() time.Time) *slidingWindow {
+	return &slidingWindow{limit: limit, window: window, clients: newKeyed[slidingCounter](now)}
+}
+
+func (l *slidingWindow) allow(key string) decision {
+	return l.clients.with(key, func(c *slidingCounter, now time.Time) decision {
+
+		start := now.Truncate(l.window)
+		switch {
+		case c.start.Equal(start):
+		case c.start.Add(l.window).Equal(start):
+			c.start, c.previous, c.current = start, c.current, 0
+		default:
+			c.start, c.previous, c.current = start, 0, 0
+		}
+
+		// The share of the previous window still inside the sliding one
+		overlap := 1 - float64(now.Sub(start))/float64(l.window)
+		estimate := float64(c.previous)*overlap + float64(c.current)
+
+		d := decision{limit: l.limit}
+		if estimate+1 <= float64(l.limit) {
+			c.current++
+			estimate++
+			d.allowed = true
+		} else if c.previous > 0 {
+			// Wait until enough of the previous window has slid out
+			excess := estimate + 1 - float64(l.limit)
+			d.retryAfter = time.Duration(excess / float64(c.previous) * float64(l.window))
+		} else {
+			d.retryAfter = start.Add(l.window).Sub(now)
+		}
+		d.remaining = max(l.limit-int(math.Ceil(estimate)), 0)
+		return d
+	})
+}
+
+func (l *slidingWindow) sweep() {
+	l.clients.sweep(func(c *slidingCounter, now time.Time) bool {
+		return now.Sub(c.start) >= 2*l.window
+	})
+}
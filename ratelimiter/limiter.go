@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// decision is a limiter's answer for one request.
+type decision struct {
+	allowed bool
+
+	// limit is how many requests a client may make at once
+	limit int
+
+	// remaining is how many more requests the client may make now
+	remaining int
+
+	// retryAfter is, for a request that is not allowed, how long until one
+	// would be
+	retryAfter time.Duration
+}
+
+// limiter decides whether each request from a client, identified by key,
+// is allowed. Limiters are safe for concurrent use.
+type limiter interface {
+	allow(key string) decision
+
+	// sweep forgets clients that have been idle long enough that
+	// forgetting them changes nothing
+	sweep()
+}
+
+// keyed holds per-client state for a limiter, created on first use. One
+// lock guards every client's state; the work done under it is a few
+// arithmetic operations, far less than serving the request.
+type keyed[S any] struct {
+	mu      sync.Mutex
+	clients map[string]*S
+	now     func() time.Time
+}
+
+func newKeyed[S any](now func() time.Time) keyed[S] {
+	if now == nil {
+		now = time.Now
+	}
+	return keyed[S]{clients: make(map[string]*S), now: now}
+}
+
+// with calls f with the state for key, and the time, under the lock.
+func (k *keyed[S]) with(key string, f func(state *S, now time.Time) decision) decision {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	state, ok := k.clients[key]
+	if !ok {
+		state = new(S)
+		k.clients[key] = state
+	}
+	return f(state, k.now())
+}
+
+// sweep removes the clients for which idle returns true.
+func (k *keyed[S]) sweep(idle func(state *S, now time.Time) bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.now()
+	for key, state := range k.clients {
+		if idle(state, now) {
+			delete(k.clients, key)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// clock is a fake time that tests move on by hand.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Unix(1_700_000_000, 0)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// allowed counts how many of n requests for key l allows.
+func allowed(l limiter, key string, n int) int {
+	count := 0
+	for range n {
+		if l.allow(key).allowed {
+			count++
+		}
+	}
+	return count
+}
+
+func TestTokenBucket(t *testing.T) {
+
+	c := newClock()
+	l := newTokenBucket(5, 2, c.Now)
+
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("burst allowed %d, want 5", n)
+	}
+
+	d := l.allow("a")
+	if d.allowed || d.retryAfter != 500*time.Millisecond {
+		t.Errorf("empty bucket gave %+v, want a refusal for 500ms", d)
+	}
+
+	// Other clients have buckets of their own
+	if n := allowed(l, "b", 5); n != 5 {
+		t.Errorf("second client allowed %d, want 5", n)
+	}
+
+	c.advance(time.Second)
+	if n := allowed(l, "a", 10); n != 2 {
+		t.Errorf("after a second allowed %d, want 2", n)
+	}
+
+	// Refilling stops at the capacity
+	c.advance(time.Hour)
+	if n := allowed(l, "a", 10); n != 5 {
+		t.Errorf("after an hour allowed %d, want 5", n)
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+
+	c := newClock()
+	l := newFixedWindow(3, time.Minute, c.Now)
+
+	if n := allowed(l, "a", 5); n != 3 {
+		t.Errorf("allowed %d in a window, want 3", n)
+	}
+
+	d := l.allow("a")
+	if d.allowed || d.retryAfter != time.Minute-c.Now().Sub(c.Now().Truncate(time.Minute)) {
+		t.Errorf("full window gave %+v", d)
+	}
+
+	c.advance(time.Minute)
+	if n := allowed(l, "a", 5); n != 3 {
+		t.Errorf("allowed %d in the next window, want 3", n)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+
+	c := newClock()
+	c.now = c.now.Truncate(time.Minute)
+	l := newSlidingWindow(10, time.Minute, c.Now)
+
+	if n := allowed(l, "a", 20); n != 10 {
+		t.Errorf("allowed %d in a window, want 10", n)
+	}
+
+	// A quarter into the next window, three quarters of the previous
+	// window's 10 requests still count
+	c.advance(75 * time.Second)
+	if n := allowed(l, "a", 20); n != 2 {
+		t.Errorf("allowed %d a quarter into the next window, want 2", n)
+	}
+
+	// Two windows on, nothing counts
+	c.advance(2 * time.Minute)
+	if n := allowed(l, "a", 20); n != 10 {
+		t.Errorf("allowed %d after two idle windows, want 10", n)
+	}
+}
+
+func TestSweep(t *testing.T) {
+
+	c := newClock()
+	limiters := map[string]limiter{
+		"token-bucket":   newTokenBucket(5, 1, c.Now),
+		"fixed-window":   newFixedWindow(5, time.Minute, c.Now),
+		"sliding-window": newSlidingWindow(5, time.Minute, c.Now),
+	}
+
+	for _, l := range limiters {
+		for i := range 100 {
+			l.allow(fmt.Sprint(i))
+		}
+	}
+	c.advance(10 * time.Minute)
+
+	for name, l := range limiters {
+		l.sweep()
+
+		var left int
+		switch l := l.(type) {
+		case *tokenBucket:
+			left = len(l.clients.clients)
+		case *fixedWindow:
+			left = len(l.clients.clients)
+		case *slidingWindow:
+			left = len(l.clients.clients)
+		}
+		if left != 0 {
+			t.Errorf("%s: %d idle clients left after sweeping", name, left)
+		}
+	}
+}
+
+// TestContention has many goroutines hammer a few keys at once, and checks
+// that each limiter allows exactly its limit per key, no more and no less.
+func TestContention(t *testing.T) {
+
+	c := newClock()
+	c.now = c.now.Truncate(time.Minute)
+
+	limiters := map[string]limiter{
+		"token-bucket":   newTokenBucket(100, 0.001, c.Now),
+		"fixed-window":   newFixedWindow(100, time.Minute, c.Now),
+		"sliding-window": newSlidingWindow(100, time.Minute, c.Now),
+	}
+
+	for name, l := range limiters {
+		const keys, goroutines, requests = 4, 64, 50
+
+		var counts [keys]atomic.Int64
+		var wg sync.WaitGroup
+
+		for g := range goroutines {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range requests {
+					key := (g + i) % keys
+					if l.allow(fmt.Sprint("client-", key)).allowed {
+						counts[key].Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		for key := range counts {
+			if n := counts[key].Load(); n != 100 {
+				t.Errorf("%s: client %d was allowed %d requests, want 100", name, key, n)
+			}
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+
+	c := newClock()
+	l := newFixedWindow(2, time.Minute, c.Now)
+
+	handler := limit(l, clientKey("X-API-Key"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	request := func(apiKey, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/limited", nil)
+		r.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i, want := range []int{200, 200, 429} {
+		w := request("", "192.0.2.1:1234")
+		if w.Code != want {
+			t.Errorf("request %d got %d, want %d", i+1, w.Code, want)
+		}
+	}
+
+	// The same address from another port is the same client, while an
+	// API key is a client of its own
+	if w := request("", "192.0.2.1:5678"); w.Code != 429 {
+		t.Errorf("same address from another port got %d", w.Code)
+	}
+	if w := request("key-1", "192.0.2.1:1234"); w.Code != 200 || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("API key got %d with %q remaining", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	w := request("", "192.0.2.1:1234")
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("refusal headers %v", w.Header())
+	}
+}
+
+func TestCheckAPI(t *testing.T) {
+
+	l := newFixedWindow(1, time.Minute, newClock().Now)
+	handler := checkHandler(l)
+
+	check := func(method, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/check?key="+key, nil))
+		return w
+	}
+
+	if w := check("POST", "k"); w.Code != 200 || !strings.Contains(w.Body.String(), `"allowed":true`) {
+		t.Errorf("first check got %d %s", w.Code, w.Body)
+	}
+	if w := check("POST", "k"); w.Code != 429 || !strings.Contains(w.Body.String(), `"allowed":false`) {
+		t.Errorf("second check got %d %s", w.Code, w.Body)
+	}
+	if w := check("GET", "k"); w.Code != 405 {
+		t.Errorf("GET got %d", w.Code)
+	}
+	if w := check("POST", ""); w.Code != 400 {
+		t.Errorf("missing key got %d", w.Code)
+	}
+}
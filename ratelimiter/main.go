@@ -0,0 +1,99 @@
+// Command ccratelimit is a rate limiting service. It limits each client,
+// by IP address or by an API key header, with a token bucket, a fixed
+// window or a sliding window, both as middleware in front of a demo
+// endpoint and as an API other services can ask.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccratelimit [flags]")
+	fmt.Fprintln(out, "Serve /limited, which is rate limited per client, /unlimited, which is not, and")
+	fmt.Fprintln(out, "POST /check?key=K, which counts a request for K and says whether it is allowed.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccratelimit"
+
+	addr := flag.String("addr", ":8080", "listen on this `address`")
+	algorithm := flag.String("algorithm", "token-bucket", "limit with token-bucket, fixed-window or sliding-window")
+	limitFlag := flag.Int("limit", 10, "allow this many requests per window, or this burst size for token-bucket")
+	window := flag.Duration("window", time.Minute, "the window length for fixed-window and sliding-window")
+	rate := flag.Float64("rate", 1, "refill this many tokens a second for token-bucket")
+	keyHeader := flag.String("key-header", "", "limit by the value of this request `header`, such as X-API-Key, falling back to the client's IP address")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *limitFlag < 1 || *window <= 0 || *rate <= 0 {
+		cli.Exit(cli.Usagef("-limit, -window and -rate must be positive"))
+	}
+
+	var l limiter
+	switch *algorithm {
+	case "token-bucket":
+		l = newTokenBucket(*limitFlag, *rate, nil)
+	case "fixed-window":
+		l = newFixedWindow(*limitFlag, *window, nil)
+	case "sliding-window":
+		l = newSlidingWindow(*limitFlag, *window, nil)
+	default:
+		cli.Exit(cli.Usagef("invalid -algorithm %q: must be one of token-bucket, fixed-window, sliding-window", *algorithm))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/limited", limit(l, clientKey(*keyHeader), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Limited, don't over use me!")
+	})))
+	mux.HandleFunc("/unlimited", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Unlimited! Let's Go!")
+	})
+	mux.Handle("/check", checkHandler(l))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				l.sweep()
+			}
+		}
+	}()
+
+	server := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	log.Printf("ccratelimit: limiting with %s on %s", *algorithm, *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		cli.Exit(err)
+	}
+}
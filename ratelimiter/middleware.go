@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// clientKey returns the key a request is limited by: the value of header
+// if set and present, and otherwise the client's IP address.
+func clientKey(header string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if header != "" {
+			if key := r.Header.Get(header); key != "" {
+				return key
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// limit wraps next so that requests are only passed on when l allows them,
+// and answered with 429 Too Many Requests otherwise. Every response says
+// how the client stands in X-RateLimit-Limit and X-RateLimit-Remaining, and
+// a refusal says when to try again in Retry-After.
+func limit(l limiter, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		d := l.allow(key(r))
+		setHeaders(w.Header(), d)
+
+		if !d.allowed {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setHeaders(h http.Header, d decision) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(d.limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+	if !d.allowed {
+		h.Set("Retry-After", strconv.Itoa(retrySeconds(d.retryAfter)))
+	}
+}
+
+// retrySeconds rounds up, since Retry-After is in whole seconds and
+// retrying early would be refused.
+func retrySeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// checkResponse is the body of a reply from the check API.
+type checkResponse struct {
+	Allowed           bool    `json:"allowed"`
+	Limit             int     `json:"limit"`
+	Remaining         int     `json:"remaining"`
+	RetryAfterSeconds float64 `json:"retry_after_seconds,omitempty"`
+}
+
+// checkHandler serves the check API, for services that enforce limits
+// themselves: a POST with a key parameter counts a request for that key
+// and answers whether it is allowed, as JSON, with 429 if not.
+func checkHandler(l limiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := r.FormValue("key")
+		if key == "" {
+			http.Error(w, "missing key", http.StatusBadRequest)
+			return
+		}
+
+		d := l.allow(key)
+		setHeaders(w.Header(), d)
+		w.Header().Set("Content-Type", "application/json")
+
+		if !d.allowed {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+		json.NewEncoder(w).Encode(checkResponse{
+			Allowed:           d.allowed,
+			Limit:             d.limit,
+			Remaining:         d.remaining,
+			RetryAfterSeconds: d.retryAfter.Seconds(),
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// aof is the append-only file: a log of every command that changed the
+// keyspace, replayed on startup. Each command is written to the operating
+// system as it is logged; fsync says how often it is then flushed to disk.
+type aof struct {
+	file  *os.File
+	w     *bufio.Writer
+	fsync string
+
+	mu sync.Mutex
+}
+
+// fsyncPolicies are the choices of how often the file is flushed to disk:
+// after every command, once a second, or when the operating system decides.
+var fsyncPolicies = []string{"always", "everysec", "no"}
+
+func openAOF(path string, fsync string) (*aof, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &aof{file: file, w: bufio.NewWriter(file), fsync: fsync}, nil
+}
+
+// append logs a command.
+func (a *aof) append(args [][]byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	encodeCommand(a.w, args...)
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+
+	if a.fsync == "always" {
+		return a.file.Sync()
+	}
+	return nil
+}
+
+// syncEverySecond flushes the file to disk every second until ctx is done,
+// for the everysec policy.
+func (a *aof) syncEverySecond(ctx context.Context) {
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			a.file.Sync()
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *aof) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.file.Sync(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command is a command the server understands. run carries it out and
+// writes the reply, and returns the command to log to the append-only file
+// if it changed anything, rewritten where needed so that replaying it later
+// has the same effect: relative expiry times are logged as absolute ones.
+type command struct {
+	run func(s *server, w writer, args [][]byte) [][]byte
+
+	// arity is the number of arguments, counting the name, or if negative
+	// the least number
+	arity int
+}
+
+var commands = map[string]command{
+	"PING":      {ping, -1},
+	"ECHO":      {echo, 2},
+	"GET":       {get, 2},
+	"SET":       {set, -3},
+	"DEL":       {del, -2},
+	"EXISTS":    {exists, -2},
+	"EXPIRE":    {expire(time.Second), 3},
+	"PEXPIRE":   {expire(time.Millisecond), 3},
+	"PEXPIREAT": {pexpireAt, 3},
+	"TTL":       {ttl(time.Second), 2},
+	"PTTL":      {ttl(time.Millisecond), 2},
+	"INCR":      {incrBy(1), 2},
+	"DECR":      {incrBy(-1), 2},
+	"INCRBY":    {incrBy(0), 3},
+	"DECRBY":    {incrBy(0), 3},
+	"COMMAND":   {commandInfo, -1},
+}
+
+func ping(s *server, w writer, args [][]byte) [][]byte {
+	if len(args) > 1 {
+		w.bulk(args[1])
+	} else {
+		w.simple("PONG")
+	}
+	return nil
+}
+
+func echo(s *server, w writer, args [][]byte) [][]byte {
+	w.bulk(args[1])
+	return nil
+}
+
+// commandInfo answers the COMMAND introspection that redis-cli sends on
+// connecting with an empty list, which it accepts.
+func commandInfo(s *server, w writer, args [][]byte) [][]byte {
+	w.array(0)
+	return nil
+}
+
+func get(s *server, w writer, args [][]byte) [][]byte {
+	if value, ok := s.store.get(string(args[1])); ok {
+		w.bulk(value)
+	} else {
+		w.null()
+	}
+	return nil
+}
+
+// set handles SET key value [EX seconds | PX milliseconds | EXAT unix-time
+// | PXAT unix-time-milliseconds] [NX | XX].
+func set(s *server, w writer, args [][]byte) [][]byte {
+
+	var expires time.Time
+	var onlyIfMissing, onlyIfExists bool
+
+	for i := 3; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+
+		switch option {
+		case "NX":
+			onlyIfMissing = true
+			continue
+		case "XX":
+			onlyIfExists = true
+			continue
+		case "EX", "PX", "EXAT", "PXAT":
+		default:
+			w.error("ERR syntax error")
+			return nil
+		}
+
+		if i+1 == len(args) || !expires.IsZero() {
+			w.error("ERR syntax error")
+			return nil
+		}
+		i++
+
+		n, err := strconv.ParseInt(string(args[i]), 10, 64)
+		if err != nil || n <= 0 {
+			w.error("ERR invalid expire time in 'set' command")
+			return nil
+		}
+
+		switch option {
+		case "EX":
+			expires = s.store.now().Add(time.Duration(n) * time.Second)
+		case "PX":
+			expires = s.store.now().Add(time.Duration(n) * time.Millisecond)
+		case "EXAT":
+			expires = time.Unix(n, 0)
+		case "PXAT":
+			expires = time.UnixMilli(n)
+		}
+	}
+
+	if onlyIfMissing && onlyIfExists {
+		w.error("ERR syntax error")
+		return nil
+	}
+
+	if !s.store.set(string(args[1]), args[2], expires, onlyIfMissing, onlyIfExists) {
+		w.null()
+		return nil
+	}
+	w.simple("OK")
+
+	logged := [][]byte{[]byte("SET"), args[1], args[2]}
+	if !expires.IsZero() {
+		logged = append(logged, []byte("PXAT"), unixMilli(expires))
+	}
+	return logged
+}
+
+func del(s *server, w writer, args [][]byte) [][]byte {
+
+	n := s.store.del(keys(args[1:])...)
+	w.integer(n)
+
+	if n == 0 {
+		return nil
+	}
+	return args
+}
+
+func exists(s *server, w writer, args [][]byte) [][]byte {
+	w.integer(s.store.exists(keys(args[1:])...))
+	return nil
+}
+
+// expire handles EXPIRE and PEXPIRE, whose times are in unit.
+func expire(unit time.Duration) func(s *server, w writer, args [][]byte) [][]byte {
+	return func(s *server, w writer, args [][]byte) [][]byte {
+
+		n, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil {
+			w.error(errNotInteger.Error())
+			return nil
+		}
+
+		expires := s.store.now().Add(time.Duration(n) * unit)
+		return expireAt(s, w, args[1], expires)
+	}
+}
+
+func pexpireAt(s *server, w writer, args [][]byte) [][]byte {
+
+	n, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		w.error(errNotInteger.Error())
+		return nil
+	}
+
+	return expireAt(s, w, args[1], time.UnixMilli(n))
+}
+
+func expireAt(s *server, w writer, key []byte, expires time.Time) [][]byte {
+
+	if !s.store.expireAt(string(key), expires) {
+		w.integer(0)
+		return nil
+	}
+
+	w.integer(1)
+	return [][]byte{[]byte("PEXPIREAT"), key, unixMilli(expires)}
+}
+
+// ttl handles TTL and PTTL, which answer in unit.
+func ttl(unit time.Duration) func(s *server, w writer, args [][]byte) [][]byte {
+	return func(s *server, w writer, args [][]byte) [][]byte {
+		w.integer(s.store.ttl(string(args[1]), unit))
+		return nil
+	}
+}
+
+// incrBy handles INCR and DECR, which add delta, and INCRBY and DECRBY,
+// given a delta of 0, which take it as an argument.
+func incrBy(delta int64) func(s *server, w writer, args [][]byte) [][]byte {
+	return func(s *server, w writer, args [][]byte) [][]byte {
+
+		by := delta
+		if by == 0 {
+			n, err := strconv.ParseInt(string(args[2]), 10, 64)
+			if err != nil {
+				w.error(errNotInteger.Error())
+				return nil
+			}
+			by = n
+			if strings.EqualFold(string(args[0]), "DECRBY") {
+				by = -n
+			}
+		}
+
+		n, err := s.store.incrBy(string(args[1]), by)
+		if err != nil {
+			w.error(err.Error())
+			return nil
+		}
+		w.integer(n)
+
+		return [][]byte{[]byte("INCRBY"), args[1], []byte(strconv.FormatInt(by, 10))}
+	}
+}
+
+func keys(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys
+}
+
+func unixMilli(t time.Time) []byte {
+	return strconv.AppendInt(nil, t.UnixMilli(), 10)
+}
@@ -0,0 +1,116 @@
+// Command ccredis is a Redis-compatible key-value server. It speaks RESP,
+// so redis-cli and Redis client libraries can talk to it, and supports
+// GET, SET, DEL, EXISTS, EXPIRE, TTL and INCR and their relatives, with
+// keys that expire and optional persistence to an append-only file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccredis [flags]")
+	fmt.Fprintln(out, "Serve a Redis-compatible key-value store.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccredis"
+	log.SetPrefix("ccredis: ")
+
+	addr := flag.String("addr", ":6379", "listen on this `address`")
+	aofPath := flag.String("appendonly", "", "log changes to, and restore them on startup from, this `file`")
+	fsync := flag.String("appendfsync", "everysec", "flush the append-only file to disk: always, everysec or no")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if err := cli.Choice(*fsync, fsyncPolicies...); err != nil {
+		cli.Exit(cli.Usagef("invalid -appendfsync %q: %v", *fsync, err))
+	}
+	if flag.NArg() > 0 {
+		cli.Exit(cli.Usagef("unexpected argument %q", flag.Arg(0)))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := newServer()
+
+	if *aofPath != "" {
+		if err := restore(s, *aofPath); err != nil {
+			cli.Exit(&cli.FileError{File: *aofPath, Err: err})
+		}
+
+		a, err := openAOF(*aofPath, *fsync)
+		if err != nil {
+			cli.Exit(&cli.FileError{File: *aofPath, Err: err})
+		}
+		defer a.Close()
+
+		s.aof = a
+		if *fsync == "everysec" {
+			go a.syncEverySecond(ctx)
+		}
+	}
+
+	go s.expireKeys(ctx, 100*time.Millisecond)
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		cli.Exit(err)
+	}
+	log.Printf("ready to accept connections on %s", listener.Addr())
+
+	if err := s.serve(ctx, listener); err != nil {
+		cli.Report(err)
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+// restore replays the append-only file at path, if there is one yet, and
+// cuts off any incomplete command at its end so that new commands are
+// appended after the last whole one.
+func restore(s *server, path string) error {
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n, end, err := s.replay(file)
+	if err != nil {
+		return err
+	}
+	log.Printf("restored %d commands from %s", n, path)
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if end < info.Size() {
+		log.Printf("removing an incomplete command from the end of %s", path)
+		return os.Truncate(path, end)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits on what a client may send, as in Redis, so a bad or hostile
+// client cannot make the server allocate without bound.
+// maxInline is also the size of each connection's read buffer, which any
+// line has to fit in.
+const (
+	maxArgs     = 1024 * 1024
+	maxBulkSize = 512 * 1024 * 1024
+	maxInline   = 64 * 1024
+)
+
+// protocolError is a malformed request. The connection is closed after
+// replying with it, since the rest of the stream cannot be trusted.
+type protocolError struct {
+	msg string
+}
+
+func (e *protocolError) Error() string {
+	return "Protocol error: " + e.msg
+}
+
+// readCommand reads one command: a RESP array of bulk strings, or an
+// inline command of words separated by spaces, as typed into telnet.
+func readCommand(reader *bufio.Reader) ([][]byte, error) {
+
+	prefix, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if prefix[0] != '*' {
+		return readInline(reader)
+	}
+
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, &protocolError{"invalid multibulk length"}
+	}
+
+	args := make([][]byte, 0, max(n, 0))
+	for range n {
+		arg, err := readBulk(reader)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func readBulk(reader *bufio.Reader) ([]byte, error) {
+
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return nil, &protocolError{fmt.Sprintf("expected '$', got '%.1s'", line)}
+	}
+
+	size, err := strconv.Atoi(string(line[1:]))
+	if err != nil || size < 0 || size > maxBulkSize {
+		return nil, &protocolError{"invalid bulk length"}
+	}
+
+	bulk := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, bulk); err != nil {
+		return nil, err
+	}
+	if string(bulk[size:]) != "\r\n" {
+		return nil, &protocolError{"bulk string not followed by CRLF"}
+	}
+	return bulk[:size], nil
+}
+
+func readInline(reader *bufio.Reader) ([][]byte, error) {
+
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var args [][]byte
+	for _, word := range strings.Fields(string(line)) {
+		args = append(args, []byte(word))
+	}
+	return args, nil
+}
+
+// readLine reads a line ended by CRLF, or by LF alone for inline
+// commands, and returns it without the ending.
+func readLine(reader *bufio.Reader) ([]byte, error) {
+
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, &protocolError{"too big inline request"}
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// writer writes RESP replies.
+type writer struct {
+	*bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w writer) error(msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func (w writer) integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w writer) bulk(b []byte) {
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// null writes the null bulk string, the reply for a missing value.
+func (w writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+func (w writer) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// encodeCommand encodes args as a RESP array of bulk strings, as commands
+// are sent and as they are logged to the append-only file.
+func encodeCommand(w *bufio.Writer, args ...[]byte) {
+	out := writer{w}
+	out.array(len(args))
+	for _, arg := range args {
+		out.bulk(arg)
+	}
+}
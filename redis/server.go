@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"codechallenge/internal/streamio"
+)
+
+// server serves the keyspace to clients over RESP.
+type server struct {
+	store *store
+
+	// aof, if set, logs every change
+	aof *aof
+
+	// mu makes commands run one at a time, as in Redis, so that the
+	// append-only file records changes in the order they took effect
+	mu sync.Mutex
+}
+
+func newServer() *server {
+	return &server{store: newStore()}
+}
+
+// serve accepts clients on listener, each served concurrently, until ctx is
+// done.
+func (s *server) serve(ctx context.Context, listener net.Listener) error {
+
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle serves one client until it disconnects or ctx is done. Replies to
+// pipelined commands are written together once every command read so far
+// has been answered.
+func (s *server) handle(ctx context.Context, conn net.Conn) {
+
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reader := bufio.NewReaderSize(conn, maxInline)
+	w := writer{bufio.NewWriter(conn)}
+
+	for {
+		args, err := readCommand(reader)
+
+		var protocol *protocolError
+		if errors.As(err, &protocol) {
+			w.error("ERR " + protocol.Error())
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		if len(args) > 0 {
+			s.execute(w, args)
+		}
+
+		if reader.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// execute runs a command, writing its reply to w, and logs it to the
+// append-only file if it changed anything.
+func (s *server) execute(w writer, args [][]byte) {
+
+	name := strings.ToUpper(string(args[0]))
+
+	cmd, ok := commands[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if cmd.arity > 0 && len(args) != cmd.arity || cmd.arity < 0 && len(args) < -cmd.arity {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logged := cmd.run(s, w, args)
+	if logged != nil && s.aof != nil {
+		if err := s.aof.append(logged); err != nil {
+			// The change has been made, but would be lost on a restart
+			w.error(fmt.Sprintf("ERR writing the append-only file: %v", err))
+		}
+	}
+}
+
+// expireKeys sweeps expired keys out of the store every interval until ctx
+// is done.
+func (s *server) expireKeys(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.store.sweep(20)
+		}
+	}
+}
+
+// replay runs the commands logged in an append-only file, to restore the
+// keyspace as it was, and returns how many there were and where the last
+// of them ends. A command cut short at the end of the file, as when the
+// server stopped part way through writing it, is ignored.
+func (s *server) replay(input io.Reader) (n int, end int64, err error) {
+
+	counter := &streamio.CountingReader{Reader: input}
+	reader := bufio.NewReader(counter)
+	discard := writer{bufio.NewWriter(io.Discard)}
+
+	for {
+		end = counter.N - int64(reader.Buffered())
+
+		args, err := readCommand(reader)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return n, end, nil
+		}
+		if err != nil {
+			return n, end, fmt.Errorf("command %d: %w", n+1, err)
+		}
+
+		if len(args) > 0 {
+			s.execute(discard, args)
+			n++
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client sends commands to a test server and reads the raw replies.
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func startServer(t *testing.T, s *server) *client {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.serve(ctx, listener) }()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &client{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do sends a command, given as words, and returns the reply with each CRLF
+// written as a space, as in "$3 bar".
+func (c *client) do(command string) string {
+	c.t.Helper()
+
+	var args [][]byte
+	for _, word := range strings.Fields(command) {
+		args = append(args, []byte(word))
+	}
+
+	w := bufio.NewWriter(c.conn)
+	encodeCommand(w, args...)
+	if err := w.Flush(); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.reply()
+}
+
+func (c *client) reply() string {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	if line[0] == '$' && line != "$-1" {
+		value, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		return line + " " + strings.TrimSuffix(value, "\r\n")
+	}
+	return line
+}
+
+func TestCommands(t *testing.T) {
+
+	c := startServer(t, newServer())
+
+	steps := []struct{ command, want string }{
+		{"PING", "+PONG"},
+		{"ping hello", "$5 hello"},
+		{"ECHO hi", "$2 hi"},
+		{"GET foo", "$-1"},
+		{"SET foo bar", "+OK"},
+		{"GET foo", "$3 bar"},
+		{"SET foo baz NX", "$-1"},
+		{"SET missing x XX", "$-1"},
+		{"SET foo baz XX", "+OK"},
+		{"GET foo", "$3 baz"},
+		{"EXISTS foo missing foo", ":2"},
+		{"DEL foo missing", ":1"},
+		{"GET foo", "$-1"},
+		{"INCR n", ":1"},
+		{"INCRBY n 10", ":11"},
+		{"DECR n", ":10"},
+		{"DECRBY n 20", ":-10"},
+		{"SET s abc", "+OK"},
+		{"INCR s", "-ERR value is not an integer or out of range"},
+		{"TTL n", ":-1"},
+		{"TTL missing", ":-2"},
+		{"EXPIRE n 100", ":1"},
+		{"TTL n", ":100"},
+		{"EXPIRE missing 100", ":0"},
+		{"SET foo bar EX", "-ERR syntax error"},
+		{"SET foo bar EX 0", "-ERR invalid expire time in 'set' command"},
+		{"GET", "-ERR wrong number of arguments for 'get' command"},
+		{"NOPE x", "-ERR unknown command 'NOPE'"},
+	}
+
+	for _, step := range steps {
+		if got := c.do(step.command); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.command, got, step.want)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+
+	s := newServer()
+	now := time.Unix(1_000_000, 0)
+	s.store.now = func() time.Time { return now }
+
+	c := startServer(t, s)
+
+	c.do("SET a 1 PX 1500")
+	c.do("SET b 2")
+	c.do("PEXPIRE b 500")
+	c.do("SET c 3 EX 10")
+
+	if got := c.do("PTTL a"); got != ":1500" {
+		t.Errorf("PTTL a = %s", got)
+	}
+
+	now = now.Add(time.Second)
+
+	if got := c.do("GET b"); got != "$-1" {
+		t.Errorf("GET b after it expired = %s", got)
+	}
+	if got := c.do("GET a"); got != "$1 1" {
+		t.Errorf("GET a before it expired = %s", got)
+	}
+
+	// Keys nobody asks for are swept up too
+	now = now.Add(time.Hour)
+	s.store.sweep(20)
+	if n := len(s.store.data); n != 0 {
+		t.Errorf("%d keys left after sweeping", n)
+	}
+}
+
+func TestPipelineAndInline(t *testing.T) {
+
+	c := startServer(t, newServer())
+
+	var commands bytes.Buffer
+	w := bufio.NewWriter(&commands)
+	for range 100 {
+		encodeCommand(w, []byte("INCR"), []byte("n"))
+	}
+	w.Flush()
+	commands.WriteString("GET n\r\n")
+
+	if _, err := c.conn.Write(commands.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		c.reply()
+	}
+	if got := c.reply(); got != "$3 100" {
+		t.Errorf("GET n after 100 pipelined INCRs = %s", got)
+	}
+
+	c.conn.Write([]byte("*1\r\n:1\r\n"))
+	if got := c.reply(); got != "-ERR Protocol error: expected '$', got ':'" {
+		t.Errorf("malformed command got %q", got)
+	}
+}
+
+func TestAppendOnlyFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+
+	first := newServer()
+	a, err := openAOF(path, "always")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.aof = a
+
+	c := startServer(t, first)
+	c.do("SET kept value")
+	c.do("SET expiring value EX 1000")
+	c.do("SET gone value")
+	c.do("DEL gone")
+	c.do("INCRBY n 5")
+	c.do("DECR n")
+	c.do("GET kept")
+	a.Close()
+
+	// A command cut short at the end is ignored
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("*3\r\n$3\r\nSET\r\n$4\r\nlost")
+	file.Close()
+
+	second := newServer()
+	file, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	n, end, err := second.replay(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("replayed %d commands, want 6", n)
+	}
+	if info, _ := file.Stat(); info.Size()-end != int64(len("*3\r\n$3\r\nSET\r\n$4\r\nlost")) {
+		t.Errorf("last whole command ends at %d of %d bytes", end, info.Size())
+	}
+
+	c = startServer(t, second)
+	for command, want := range map[string]string{
+		"GET kept":     "$5 value",
+		"GET gone":     "$-1",
+		"GET n":        "$1 4",
+		"TTL expiring": ":1000",
+		"GET lost":     "$-1",
+	} {
+		if got := c.do(command); got != want {
+			t.Errorf("after replaying, %s = %s, want %s", command, got, want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errNotInteger = errors.New("ERR value is not an integer or out of range")
+
+// entry is a stored value and when it expires, if it does.
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// store is the keyspace, safe for concurrent use. Expired keys are removed
+// when they are next looked up, and by sweep for keys nobody looks up.
+type store struct {
+	mu   sync.Mutex
+	data map[string]entry
+
+	// now is the clock, replaceable in tests
+	now func() time.Time
+}
+
+func newStore() *store {
+	return &store{data: make(map[string]entry), now: time.Now}
+}
+
+// lookup returns the live entry for key, removing it if it has expired.
+// The caller holds mu.
+func (s *store) lookup(key string) (entry, bool) {
+
+	e, ok := s.data[key]
+	if ok && e.expired(s.now()) {
+		delete(s.data, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func (s *store) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(key)
+	return e.value, ok
+}
+
+// set stores value under key, expiring at expires unless that is zero. With
+// onlyIfMissing or onlyIfExists it does nothing, and returns false, unless
+// the key is missing or exists.
+func (s *store) set(key string, value []byte, expires time.Time, onlyIfMissing, onlyIfExists bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.lookup(key)
+	if onlyIfMissing && exists || onlyIfExists && !exists {
+		return false
+	}
+
+	s.data[key] = entry{value: value, expires: expires}
+	return true
+}
+
+// del removes keys and returns how many of them existed.
+func (s *store) del(keys ...string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, key := range keys {
+		if _, ok := s.lookup(key); ok {
+			delete(s.data, key)
+			n++
+		}
+	}
+	return n
+}
+
+// exists returns how many of keys exist, counting repeats.
+func (s *store) exists(keys ...string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, key := range keys {
+		if _, ok := s.lookup(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// expireAt sets key to expire at expires, removing it now if that has
+// passed, and reports whether the key exists.
+func (s *store) expireAt(key string, expires time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(key)
+	if !ok {
+		return false
+	}
+
+	e.expires = expires
+	if e.expired(s.now()) {
+		delete(s.data, key)
+	} else {
+		s.data[key] = e
+	}
+	return true
+}
+
+// ttl returns how long key has left to live, or -1 if it does not expire
+// and -2 if it does not exist, in the given unit.
+func (s *store) ttl(key string, unit time.Duration) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(key)
+	switch {
+	case !ok:
+		return -2
+	case e.expires.IsZero():
+		return -1
+	}
+
+	// Round up, so a key with any time left does not report 0
+	left := e.expires.Sub(s.now())
+	return int64((left + unit - 1) / unit)
+}
+
+// incrBy adds delta to the integer stored at key, treating a missing key as
+// 0, and returns the result. The key keeps its expiry.
+func (s *store) incrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(key)
+
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+			return 0, errNotInteger
+		}
+	}
+
+	if delta > 0 && n > math.MaxInt64-delta || delta < 0 && n < math.MinInt64-delta {
+		return 0, errors.New("ERR increment or decrement would overflow")
+	}
+	n += delta
+
+	e.value = strconv.AppendInt(nil, n, 10)
+	s.data[key] = e
+	return n, nil
+}
+
+// sweep removes expired keys, looking at up to sample keys at a time and
+// going on while more than a quarter of those were expired, as Redis does,
+// so the cost stays small when few keys have expired.
+func (s *store) sweep(sample int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		now := s.now()
+		seen, expired := 0, 0
+
+		// Map iteration order is random, which makes this a sample
+		for key, e := range s.data {
+			if seen == sample {
+				break
+			}
+			seen++
+			if e.expired(now) {
+				delete(s.data, key)
+				expired++
+			}
+		}
+
+		if expired*4 <= seen {
+			return
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"codechallenge/internal/textutil"
+)
+
+// editor runs a script over a stream of lines.
+type editor struct {
+	commands []*command
+
+	// quiet stops the pattern space being printed at the end of each
+	// cycle, as -n does, so only p and s///p print
+	quiet bool
+
+	// line is the number of the last line read, which carries on from one
+	// input to the next unless the editor is reset
+	line int
+
+	// quit is set by a q command, with the exit status it asked for
+	quit     bool
+	exitCode int
+}
+
+// reset makes line numbers and ranges start again, for editing files
+// separately, as -i does.
+func (e *editor) reset() {
+	e.line = 0
+	for _, c := range e.commands {
+		c.inRange = false
+	}
+}
+
+// run edits the lines of input, writing the result to out. When last is
+// false, the end of input is not the end of the text, so $ does not match
+// its last line. A last line without a newline is written without one.
+func (e *editor) run(input io.Reader, bufferSize int, out io.Writer, last bool) error {
+
+	reader := textutil.NewLineReader(input, bufferSize)
+	w := bufio.NewWriter(out)
+
+	for !e.quit {
+		line, newline, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		e.line++
+
+		isLast := last && reader.AtEOF()
+		e.cycle(w, line, newline || !isLast, isLast)
+	}
+
+	return w.Flush()
+}
+
+// cycle runs the script over one line, the pattern space, and then prints
+// it unless it was deleted or the editor is quiet.
+func (e *editor) cycle(w *bufio.Writer, space []byte, newline bool, last bool) {
+
+	print := func(text []byte) {
+		w.Write(text)
+		if newline {
+			w.WriteByte('\n')
+		}
+	}
+
+	for _, c := range e.commands {
+		if c.selects(e.line, space, last) == c.negate {
+			continue
+		}
+
+		switch c.name {
+		case 'p':
+			print(space)
+		case 'd':
+			return
+		case '=':
+			w.WriteString(strconv.Itoa(e.line) + "\n")
+		case 'q':
+			e.quit, e.exitCode = true, c.exitCode
+			if !e.quiet {
+				print(space)
+			}
+			return
+		case 's':
+			var replaced bool
+			space, replaced = c.subst.apply(space)
+			if replaced && c.subst.print {
+				print(space)
+			}
+		default:
+			panic(fmt.Sprintf("unknown command %c", c.name))
+		}
+	}
+
+	if !e.quiet {
+		print(space)
+	}
+}
+
+// selects reports whether the addresses of c select a line. A range
+// selects from a line its start matches through the next line its end
+// matches; when the end is a line number no later than the start, just
+// the one line.
+func (c *command) selects(line int, space []byte, last bool) bool {
+
+	if c.start.kind == addressNone {
+		return true
+	}
+	if c.end.kind == addressNone {
+		return c.start.matches(line, space, last)
+	}
+
+	if c.inRange {
+		switch c.end.kind {
+		case addressLine:
+			c.inRange = line < c.end.line
+		default:
+			if c.end.matches(line, space, last) {
+				c.inRange = false
+			}
+		}
+		return true
+	}
+
+	if !c.start.matches(line, space, last) {
+		return false
+	}
+	switch c.end.kind {
+	case addressLine:
+		c.inRange = c.end.line > line
+	case addressLast:
+		c.inRange = !last
+	default:
+		c.inRange = true
+	}
+	return true
+}
+
+func (a address) matches(line int, space []byte, last bool) bool {
+	switch a.kind {
+	case addressLine:
+		return line == a.line
+	case addressLast:
+		return last
+	case addressRegexp:
+		return a.re.Match(space)
+	}
+	return false
+}
+
+// apply makes the substitution in space, reporting whether it replaced
+// anything.
+func (s *substitution) apply(space []byte) ([]byte, bool) {
+
+	matches := s.re.FindAllSubmatchIndex(space, -1)
+	if len(matches) < s.occurrence {
+		return space, false
+	}
+	matches = matches[s.occurrence-1:]
+	if !s.global {
+		matches = matches[:1]
+	}
+
+	var result []byte
+	end := 0
+	for _, match := range matches {
+		result = append(result, space[end:match[0]]...)
+		for _, part := range s.replacement {
+			if part.group < 0 {
+				result = append(result, part.text...)
+				continue
+			}
+			if start := match[2*part.group]; start >= 0 {
+				result = append(result, space[start:match[2*part.group+1]]...)
+			}
+		}
+		end = match[1]
+	}
+	return append(result, space[end:]...), true
+}
@@ -0,0 +1,12 @@
+module codechallenge/sed
+
+go 1.23.2
+
+require codechallenge/internal v0.0.0
+
+require (
+	github.com/klauspost/compress v1.17.11 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+)
+
+replace codechallenge/internal => ../internal
@@ -0,0 +1,205 @@
+// Command ccsed is a stream editor like sed. It supports s/regexp/
+// replacement/ with the g, p, i and N flags, p, d, = and q, addresses by
+// line number, $ and regular expression, ranges of them, ! to negate them,
+// -n, and editing files in place with -i.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+)
+
+// scriptList collects the scripts of repeated -e flags.
+type scriptList []string
+
+func (l *scriptList) String() string {
+	return strings.Join(*l, "\n")
+}
+
+func (l *scriptList) Set(script string) error {
+	*l = append(*l, script)
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccsed [flags] script [file ...]")
+	fmt.Fprintln(out, "       ccsed [flags] -e script [-e script ...] [file ...]")
+	fmt.Fprintln(out, "Run script over each line of the files, or of standard input when there are")
+	fmt.Fprintln(out, "none or the file is -, printing the result, such as 's/cat/dog/g' or '2,/^$/d'.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccsed"
+
+	var scripts scriptList
+	flag.Var(&scripts, "e", "add `script` to the commands to run")
+	scriptFile := flag.String("f", "", "add the script in `file` to the commands to run")
+	quiet := flag.Bool("n", false, "only print lines printed by p and s///p")
+	extended := flag.Bool("E", false, "use extended regular expressions rather than basic ones")
+	inPlace := flag.Bool("i", false, "edit the files in place rather than printing the result")
+	backup := flag.String("backup", "", "with -i, keep each original file with this `suffix` added to its name")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if *scriptFile != "" {
+		data, err := os.ReadFile(*scriptFile)
+		if err != nil {
+			cli.Exit(err)
+		}
+		scripts = append(scripts, strings.TrimSuffix(string(data), "\n"))
+	}
+	if len(scripts) == 0 {
+		if len(args) == 0 {
+			cli.Exit(cli.Usagef("no script given"))
+		}
+		scripts, args = scriptList{args[0]}, args[1:]
+	}
+
+	commands, err := parseScript(scripts.String(), *extended)
+	if err != nil {
+		cli.Exit(cli.Usagef("invalid script: %v", err))
+	}
+	e := &editor{commands: commands, quiet: *quiet}
+
+	if *backup != "" && !*inPlace {
+		cli.Exit(cli.Usagef("-backup needs -i"))
+	}
+	if *inPlace {
+		if len(args) == 0 {
+			cli.Exit(cli.Usagef("-i needs files to edit"))
+		}
+		if err := editInPlace(e, args, *backup); err != nil {
+			cli.Exit(err)
+		}
+		os.Exit(e.exitCode)
+	}
+
+	if len(args) == 0 {
+		args = []string{cli.Stdin}
+	}
+
+	ok := true
+	for i, path := range args {
+		if err := edit(e, path, os.Stdout, i == len(args)-1); err != nil {
+			var fileErr *cli.FileError
+			if !errors.As(err, &fileErr) {
+				cli.Exit(err)
+			}
+			cli.Report(err)
+			ok = false
+		}
+		if e.quit {
+			break
+		}
+	}
+
+	if !ok {
+		os.Exit(cli.ExitFailure)
+	}
+	os.Exit(e.exitCode)
+}
+
+// edit runs e over the file at path, writing the result to out. A failure
+// to read the file is a *cli.FileError; any other error is from writing.
+func edit(e *editor, path string, out io.Writer, last bool) error {
+
+	file, closeFile, err := cli.Open(path)
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	defer closeFile()
+
+	return e.run(&fileReader{file: file, path: path}, streamio.BufferSizeFor(file), out, last)
+}
+
+// fileReader tags read errors with the file they came from, to tell them
+// from errors writing the output.
+type fileReader struct {
+	file *os.File
+	path string
+}
+
+func (r *fileReader) Read(buffer []byte) (int, error) {
+	n, err := r.file.Read(buffer)
+	if err != nil && err != io.EOF {
+		err = &cli.FileError{File: r.path, Err: err}
+	}
+	return n, err
+}
+
+// editInPlace runs e over each file separately, replacing its contents
+// with the result. The result is written to a temporary file beside the
+// original and renamed over it, so a failure part way leaves the original
+// as it was. As in sed, a q command stops editing, and the rest of the
+// file it was in is dropped.
+func editInPlace(e *editor, paths []string, backup string) error {
+
+	for _, path := range paths {
+		if path == cli.Stdin {
+			return cli.Usagef("cannot edit standard input in place")
+		}
+
+		e.reset()
+		if err := replaceFile(path, backup, func(out io.Writer) error {
+			return edit(e, path, out, true)
+		}); err != nil {
+			return err
+		}
+
+		if e.quit {
+			break
+		}
+	}
+	return nil
+}
+
+func replaceFile(path string, backup string, write func(io.Writer) error) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	if !info.Mode().IsRegular() {
+		return &cli.FileError{File: path, Err: errors.New("not a regular file")}
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".ccsed-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if err := write(temp); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(info.Mode().Perm()); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if backup != "" {
+		if err := os.Rename(path, path+backup); err != nil {
+			return err
+		}
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,92 @@
+package main
+
+import "strings"
+
+// translateBasic rewrites a POSIX basic regular expression into Go's
+// syntax. In a basic expression, ( ) { } + ? and | are literal, and only
+// become operators when escaped; Go's syntax, like POSIX extended
+// expressions, has it the other way around. Bracket expressions are
+// copied as they are.
+func translateBasic(pattern string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			next := pattern[i]
+			switch next {
+			case '(', ')', '{', '}', '+', '?', '|':
+				b.WriteByte(next)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			}
+
+		case c == '(' || c == ')' || c == '{' || c == '}' || c == '+' || c == '?' || c == '|':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+
+		// A * at the start of an expression or group is literal
+		case c == '*' && atStart(b.String()):
+			b.WriteString(`\*`)
+
+		case c == '[':
+			end := bracketEnd(pattern, i)
+			b.WriteString(pattern[i:end])
+			i = end - 1
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// atStart reports whether translated ends where an expression or group
+// starts, so nothing precedes for a * to repeat.
+func atStart(translated string) bool {
+	if translated == "" {
+		return true
+	}
+	for _, start := range []string{"(", "^", "|"} {
+		if strings.HasSuffix(translated, start) && !strings.HasSuffix(translated, `\`+start) {
+			return true
+		}
+	}
+	return false
+}
+
+// bracketEnd returns the offset just past the bracket expression starting
+// at start, where a ] first in the list, after any ^, is a member rather
+// than the end, as are the ] of [:class:] names.
+func bracketEnd(pattern string, start int) int {
+
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+
+	for i < len(pattern) {
+		switch {
+		case strings.HasPrefix(pattern[i:], "[:"):
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				i += end + 4
+				continue
+			}
+		case pattern[i] == ']':
+			return i + 1
+		}
+		i++
+	}
+
+	// Unterminated, which the regexp package will report
+	return len(pattern)
+}
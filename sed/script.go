@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// addressKind is what an address matches.
+type addressKind int
+
+const (
+	addressNone addressKind = iota
+	addressLine
+	addressLast
+	addressRegexp
+)
+
+// address selects lines: by number, the last line, or lines matching a
+// regular expression.
+type address struct {
+	kind addressKind
+	line int
+	re   *regexp.Regexp
+}
+
+// command is one command of a script with the addresses that select the
+// lines it applies to.
+type command struct {
+	start, end address
+	negate     bool
+	name       byte
+
+	// inRange records that a range has started and not yet ended
+	inRange bool
+
+	// subst holds the arguments of an s command
+	subst *substitution
+
+	// exitCode is the exit status of a q command
+	exitCode int
+}
+
+// substitution is an s command: s/regexp/replacement/flags.
+type substitution struct {
+	re          *regexp.Regexp
+	replacement []replacementPart
+
+	// occurrence is which match to replace, counting from 1, and with
+	// global, the first of the matches to replace
+	occurrence int
+	global     bool
+	print      bool
+}
+
+// replacementPart is a piece of a replacement: literal text, or with
+// group at least 0, a matched group, where 0 is the whole match.
+type replacementPart struct {
+	text  string
+	group int
+}
+
+// scriptError is an error in a script, at a byte offset in it.
+type scriptError struct {
+	pos int
+	msg string
+}
+
+func (e *scriptError) Error() string {
+	return fmt.Sprintf("char %d: %s", e.pos+1, e.msg)
+}
+
+// scriptParser parses a script into commands.
+type scriptParser struct {
+	script   string
+	pos      int
+	extended bool
+}
+
+// parseScript parses a script: commands separated by newlines or
+// semicolons. Regular expressions are POSIX basic ones unless extended is
+// set, when they are extended ones.
+func parseScript(script string, extended bool) ([]*command, error) {
+
+	p := &scriptParser{script: script, extended: extended}
+	var commands []*command
+
+	for {
+		p.skip(" \t\n;")
+		if p.done() {
+			return commands, nil
+		}
+
+		c, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, c)
+
+		p.skip(" \t")
+		if !p.done() && p.peek() != '\n' && p.peek() != ';' {
+			return nil, p.errorf("extra characters after command")
+		}
+	}
+}
+
+func (p *scriptParser) done() bool {
+	return p.pos >= len(p.script)
+}
+
+func (p *scriptParser) peek() byte {
+	return p.script[p.pos]
+}
+
+func (p *scriptParser) skip(chars string) {
+	for !p.done() && contains(chars, p.peek()) {
+		p.pos++
+	}
+}
+
+func contains(chars string, c byte) bool {
+	for i := range len(chars) {
+		if chars[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *scriptParser) errorf(format string, args ...any) error {
+	return &scriptError{pos: p.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *scriptParser) command() (*command, error) {
+
+	c := &command{}
+
+	var err error
+	if c.start, err = p.address(); err != nil {
+		return nil, err
+	}
+	if c.start.kind != addressNone && !p.done() && p.peek() == ',' {
+		p.pos++
+		p.skip(" \t")
+		if c.end, err = p.address(); err != nil {
+			return nil, err
+		}
+		if c.end.kind == addressNone {
+			return nil, p.errorf("unexpected ','")
+		}
+	}
+
+	p.skip(" \t")
+	if !p.done() && p.peek() == '!' {
+		c.negate = true
+		p.pos++
+		p.skip(" \t")
+	}
+
+	if p.done() {
+		return nil, p.errorf("missing command")
+	}
+	c.name = p.peek()
+	p.pos++
+
+	switch c.name {
+	case 'p', 'd', '=':
+	case 'q':
+		if c.end.kind != addressNone {
+			return nil, p.errorf("command only uses one address")
+		}
+		p.skip(" \t")
+		start := p.pos
+		for !p.done() && isDigit(p.peek()) {
+			p.pos++
+		}
+		if start < p.pos {
+			c.exitCode, _ = strconv.Atoi(p.script[start:p.pos])
+		}
+	case 's':
+		if c.subst, err = p.substitution(); err != nil {
+			return nil, err
+		}
+	default:
+		p.pos--
+		return nil, p.errorf("unknown command: '%c'", c.name)
+	}
+	return c, nil
+}
+
+// address parses an optional address: a line number, $ for the last line,
+// or a regular expression as /regexp/ or \cregexpc for any delimiter c.
+func (p *scriptParser) address() (address, error) {
+
+	if p.done() {
+		return address{}, nil
+	}
+
+	switch c := p.peek(); {
+	case isDigit(c):
+		start := p.pos
+		for !p.done() && isDigit(p.peek()) {
+			p.pos++
+		}
+		line, err := strconv.Atoi(p.script[start:p.pos])
+		if err != nil || line == 0 {
+			return address{}, &scriptError{pos: start, msg: "invalid line number"}
+		}
+		return address{kind: addressLine, line: line}, nil
+
+	case c == '$':
+		p.pos++
+		return address{kind: addressLast}, nil
+
+	case c == '/' || c == '\\':
+		if c == '\\' {
+			p.pos++
+			if p.done() {
+				return address{}, p.errorf("unterminated address regex")
+			}
+		}
+		delimiter := p.peek()
+		p.pos++
+		re, err := p.regexp(delimiter, "")
+		if err != nil {
+			return address{}, err
+		}
+		return address{kind: addressRegexp, re: re}, nil
+	}
+
+	return address{}, nil
+}
+
+// delimited reads up to the next unescaped delimiter, and skips past it.
+// An escaped delimiter stands for the delimiter itself, and \n for a
+// newline; other escapes are kept for the caller.
+func (p *scriptParser) delimited(delimiter byte) (string, error) {
+
+	start := p.pos
+	var text []byte
+	for !p.done() {
+		c := p.peek()
+		p.pos++
+
+		switch {
+		case c == delimiter:
+			return string(text), nil
+		case c == '\n' && delimiter != '\n':
+			return "", &scriptError{pos: start, msg: fmt.Sprintf("unterminated '%c'", delimiter)}
+		case c == '\\' && !p.done():
+			next := p.peek()
+			p.pos++
+			switch next {
+			case delimiter:
+				text = append(text, delimiter)
+			case 'n':
+				text = append(text, '\n')
+			default:
+				text = append(text, '\\', next)
+			}
+		default:
+			text = append(text, c)
+		}
+	}
+	return "", &scriptError{pos: start, msg: fmt.Sprintf("unterminated '%c'", delimiter)}
+}
+
+// regexp reads and compiles a regular expression ending at delimiter,
+// with flags in Go's syntax, such as "i".
+func (p *scriptParser) regexp(delimiter byte, flags string) (*regexp.Regexp, error) {
+
+	start := p.pos
+	pattern, err := p.delimited(delimiter)
+	if err != nil {
+		return nil, err
+	}
+	if pattern == "" {
+		return nil, &scriptError{pos: start, msg: "empty regular expression"}
+	}
+
+	if !p.extended {
+		pattern = translateBasic(pattern)
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &scriptError{pos: start, msg: err.Error()}
+	}
+	return re, nil
+}
+
+// substitution parses the arguments of an s command, after the s.
+func (p *scriptParser) substitution() (*substitution, error) {
+
+	if p.done() || p.peek() == '\n' || p.peek() == '\\' {
+		return nil, p.errorf("unterminated 's' command")
+	}
+	delimiter := p.peek()
+	p.pos++
+
+	// The flags come after the regular expression, so find them first
+	patternStart := p.pos
+	if _, err := p.delimited(delimiter); err != nil {
+		return nil, err
+	}
+	replacementStart := p.pos
+	replacement, err := p.delimited(delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &substitution{occurrence: 1}
+	var reFlags string
+
+flags:
+	for !p.done() {
+		switch c := p.peek(); {
+		case c == 'g':
+			s.global = true
+		case c == 'p':
+			s.print = true
+		case c == 'i' || c == 'I':
+			reFlags = "i"
+		case isDigit(c):
+			start := p.pos
+			for !p.done() && isDigit(p.peek()) {
+				p.pos++
+			}
+			n, err := strconv.Atoi(p.script[start:p.pos])
+			if err != nil || n == 0 {
+				return nil, &scriptError{pos: start, msg: "number option to 's' command may not be zero"}
+			}
+			s.occurrence = n
+			continue
+		default:
+			break flags
+		}
+		p.pos++
+	}
+
+	end := p.pos
+	p.pos = patternStart
+	if s.re, err = p.regexp(delimiter, reFlags); err != nil {
+		return nil, err
+	}
+	if s.replacement, err = parseReplacement(replacement, s.re.NumSubexp()); err != nil {
+		return nil, &scriptError{pos: replacementStart, msg: err.Error()}
+	}
+	p.pos = end
+
+	return s, nil
+}
+
+// parseReplacement splits the replacement of an s command into parts: &
+// for the whole match, \1 to \9 for groups, and \& and \\ for themselves.
+func parseReplacement(text string, groups int) ([]replacementPart, error) {
+
+	var parts []replacementPart
+	var literal []byte
+
+	flush := func() {
+		if len(literal) > 0 {
+			parts = append(parts, replacementPart{text: string(literal), group: -1})
+			literal = nil
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '&':
+			flush()
+			parts = append(parts, replacementPart{group: 0})
+		case c == '\\' && i+1 < len(text):
+			i++
+			next := text[i]
+			switch {
+			case '1' <= next && next <= '9':
+				group := int(next - '0')
+				if group > groups {
+					return nil, fmt.Errorf("invalid reference \\%d on 's' command's RHS", group)
+				}
+				flush()
+				parts = append(parts, replacementPart{group: group})
+			case next == 't':
+				literal = append(literal, '\t')
+			default:
+				literal = append(literal, next)
+			}
+		default:
+			literal = append(literal, c)
+		}
+	}
+	flush()
+
+	return parts, nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runScript(t *testing.T, script string, extended, quiet bool, input string) (string, *editor) {
+	t.Helper()
+
+	commands, err := parseScript(script, extended)
+	if err != nil {
+		t.Fatalf("parseScript(%q): %v", script, err)
+	}
+	e := &editor{commands: commands, quiet: quiet}
+
+	var out bytes.Buffer
+	if err := e.run(strings.NewReader(input), 16, &out, true); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), e
+}
+
+func TestEditor(t *testing.T) {
+
+	const lines = "one\ntwo\nthree\nfour\nfive\n"
+
+	tests := []struct {
+		script   string
+		extended bool
+		quiet    bool
+		input    string
+		want     string
+	}{
+		{"s/o/0/", false, false, "foo\nbar\n", "f0o\nbar\n"},
+		{"s/o/0/g", false, false, "foo\nboo\n", "f00\nb00\n"},
+		{"s/o/0/2", false, false, "fooo\n", "fo0o\n"},
+		{"s/o/0/2g", false, false, "fooo\n", "fo00\n"},
+		{"s/O/0/gi", false, false, "fOo\n", "f00\n"},
+		{"s/[aeiou]/<&>/g", false, false, "cat\n", "c<a>t\n"},
+		{`s/\(.*\)-\(.*\)/\2-\1/`, false, false, "a-b\n", "b-a\n"},
+		{`s/(.*)-(.*)/\2-\1/`, true, false, "a-b\n", "b-a\n"},
+		{`s/(a)/x/`, false, false, "(a)\n", "x\n"},
+		{`s/a\+/x/`, false, false, "caaat\n", "cxt\n"},
+		{`s/a+/x/`, false, false, "a+aa\n", "xaa\n"},
+		{`s/*/x/`, false, false, "a*b\n", "axb\n"},
+		{`s|/usr|/opt|`, false, false, "/usr/bin\n", "/opt/bin\n"},
+		{`s/\//|/g`, false, false, "a/b/c\n", "a|b|c\n"},
+		{`s/a/\&\\/`, false, false, "a\n", "&\\\n"},
+		{`s/ /\n/`, false, false, "a b\n", "a\nb\n"},
+		{"s/x/y/p", true, true, "x\nz\n", "y\n"},
+		{"p", false, true, "a\nb\n", "a\nb\n"},
+		{"p", false, false, "a\n", "a\na\n"},
+		{"2p", false, true, lines, "two\n"},
+		{"$p", false, true, lines, "five\n"},
+		{"2,4p", false, true, lines, "two\nthree\nfour\n"},
+		{"4,2p", false, true, lines, "four\n"},
+		{"/two/,/four/d", false, false, lines, "one\nfive\n"},
+		{"/^t/,3p", false, true, lines, "two\nthree\n"},
+		{"3,$d", false, false, lines, "one\ntwo\n"},
+		{"2,4!d", false, false, lines, "two\nthree\nfour\n"},
+		{"/e$/!s/^/-/", false, false, lines, "one\n-two\nthree\n-four\nfive\n"},
+		{"\\,o,d", false, false, lines, "three\nfive\n"},
+		{"3q", false, false, lines, "one\ntwo\nthree\n"},
+		{"2=", false, false, "a\nb\n", "a\n2\nb\n"},
+		{"s/a/b/;s/b/c/", false, false, "a\n", "c\n"},
+		{"$s/$/!/", false, false, "a\nb", "a\nb!"},
+	}
+
+	for _, tt := range tests {
+		if got, _ := runScript(t, tt.script, tt.extended, tt.quiet, tt.input); got != tt.want {
+			t.Errorf("%q on %q = %q, want %q", tt.script, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQuit(t *testing.T) {
+
+	out, e := runScript(t, "2q5", false, false, "a\nb\nc\n")
+	if out != "a\nb\n" || !e.quit || e.exitCode != 5 {
+		t.Errorf("2q5 = %q, quit %v, exit code %d", out, e.quit, e.exitCode)
+	}
+
+	if out, _ := runScript(t, "2q", false, true, "a\nb\nc\n"); out != "" {
+		t.Errorf("2q with -n = %q, want nothing", out)
+	}
+}
+
+func TestAcrossInputs(t *testing.T) {
+
+	commands, err := parseScript("$!s/$/,/;=", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &editor{commands: commands}
+
+	// Line numbers carry on, and $ is only the end of the last input,
+	// which gets a newline added to the end of an earlier one
+	var out bytes.Buffer
+	e.run(strings.NewReader("a\nb"), 16, &out, false)
+	e.run(strings.NewReader("c\n"), 16, &out, true)
+
+	if want := "1\na,\n2\nb,\n3\nc\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"x", "char 1: unknown command: 'x'"},
+		{"p x", "char 3: extra characters after command"},
+		{"2", "char 2: missing command"},
+		{"0p", "char 1: invalid line number"},
+		{"1,p", "char 3: unexpected ','"},
+		{"1,2q", "char 5: command only uses one address"},
+		{"s/a/b", "char 5: unterminated '/'"},
+		{"s/a", "char 3: unterminated '/'"},
+		{"s", "char 2: unterminated 's' command"},
+		{"s//b/", "char 3: empty regular expression"},
+		{"s/a/b/0", "char 7: number option to 's' command may not be zero"},
+		{`s/a/\1/`, "char 5: invalid reference \\1 on 's' command's RHS"},
+		{"s/a/b/x", "char 7: extra characters after command"},
+		{"/a/,+3p", "char 5: unexpected ','"},
+		{`s/\(/x/`, "char 3: error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseScript(tt.script, false)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("parseScript(%q) = %v, want %q", tt.script, err, tt.want)
+		}
+	}
+}
+
+func TestTranslateBasic(t *testing.T) {
+
+	tests := []struct {
+		basic, want string
+	}{
+		{`a\(b\)c`, `a(b)c`},
+		{`(a)`, `\(a\)`},
+		{`a\{2,3\}`, `a{2,3}`},
+		{`a{2}`, `a\{2\}`},
+		{`a\+b\?`, `a+b?`},
+		{`a\|b`, `a|b`},
+		{`*a`, `\*a`},
+		{`^*a`, `^\*a`},
+		{`\(*a\)`, `(\*a)`},
+		{`(*`, `\(*`},
+		{`[(+]`, `[(+]`},
+		{`[]()]`, `[]()]`},
+		{`[[:alpha:](]x+`, `[[:alpha:](]x\+`},
+		{`\.`, `\.`},
+	}
+
+	for _, tt := range tests {
+		if got := translateBasic(tt.basic); got != tt.want {
+			t.Errorf("translateBasic(%q) = %q, want %q", tt.basic, got, tt.want)
+		}
+	}
+}
+
+func TestEditInPlace(t *testing.T) {
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("one\ntwo\n"), 0o640)
+	os.WriteFile(b, []byte("three\nfour"), 0o644)
+
+	commands, err := parseScript("1d;s/o/0/g", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &editor{commands: commands}
+	if err := editInPlace(e, []string{a, b}, ".orig"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each file is edited on its own, so line 1 is the first of each
+	for path, want := range map[string]string{
+		a:           "tw0\n",
+		b:           "f0ur",
+		a + ".orig": "one\ntwo\n",
+		b + ".orig": "three\nfour",
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+
+	if info, err := os.Stat(a); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("mode of edited file = %v, %v, want 0640", info.Mode().Perm(), err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 4 {
+		t.Errorf("directory holds %d files, want 4; temporary files left behind?", len(entries))
+	}
+
+	err = editInPlace(e, []string{filepath.Join(dir, "missing")}, "")
+	if err == nil {
+		t.Error("editing a missing file succeeded")
+	}
+}
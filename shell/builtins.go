@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// builtin is a command the shell runs itself, because it changes the
+// shell or reads its state. It writes to stdio and returns a status.
+type builtin func(s *shell, args []string, stdio [3]*os.File) int
+
+var builtins = map[string]builtin{
+	"cd":     builtinCd,
+	"exit":   builtinExit,
+	"export": builtinExport,
+	"pwd":    builtinPwd,
+	"unset":  builtinUnset,
+}
+
+// builtinCd changes the working directory to its argument, to $HOME
+// without one, or back to $OLDPWD with -.
+func builtinCd(s *shell, args []string, stdio [3]*os.File) int {
+
+	if len(args) > 2 {
+		s.errorf(stdio[2], "cd: too many arguments")
+		return 1
+	}
+
+	var dir string
+	switch {
+	case len(args) == 1:
+		dir = s.vars["HOME"]
+		if dir == "" {
+			s.errorf(stdio[2], "cd: HOME not set")
+			return 1
+		}
+	case args[1] == "-":
+		dir = s.vars["OLDPWD"]
+		if dir == "" {
+			s.errorf(stdio[2], "cd: OLDPWD not set")
+			return 1
+		}
+		fmt.Fprintln(stdio[1], dir)
+	default:
+		dir = args[1]
+	}
+
+	path := s.path(dir)
+	info, err := os.Stat(path)
+	if err != nil {
+		s.errorf(stdio[2], "cd: %s: %v", dir, unwrapPathError(err))
+		return 1
+	}
+	if !info.IsDir() {
+		s.errorf(stdio[2], "cd: %s: not a directory", dir)
+		return 1
+	}
+
+	s.setExported("OLDPWD", s.dir)
+	s.dir = path
+	s.setExported("PWD", path)
+	return 0
+}
+
+// builtinExit makes the shell exit with the given status, or with the
+// status of the last command.
+func builtinExit(s *shell, args []string, stdio [3]*os.File) int {
+
+	status := s.status
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			s.errorf(stdio[2], "exit: %s: numeric argument required", args[1])
+			n = statusSyntax
+		}
+		status = n & 0xff
+	}
+	s.exiting = true
+	return status
+}
+
+// builtinExport marks variables for the environment of commands, setting
+// them first when given as NAME=value. Without arguments it lists them.
+func builtinExport(s *shell, args []string, stdio [3]*os.File) int {
+
+	if len(args) == 1 {
+		for _, name := range slices.Sorted(maps.Keys(s.exported)) {
+			fmt.Fprintf(stdio[1], "export %s=%s\n", name, quote(s.vars[name]))
+		}
+		return 0
+	}
+
+	status := 0
+	for _, arg := range args[1:] {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			s.errorf(stdio[2], "export: %s: not a valid identifier", arg)
+			status = 1
+			continue
+		}
+		if hasValue {
+			s.vars[name] = value
+		}
+		s.exported[name] = true
+	}
+	return status
+}
+
+// builtinPwd prints the working directory.
+func builtinPwd(s *shell, args []string, stdio [3]*os.File) int {
+	fmt.Fprintln(stdio[1], s.dir)
+	return 0
+}
+
+// builtinUnset removes variables.
+func builtinUnset(s *shell, args []string, stdio [3]*os.File) int {
+	for _, name := range args[1:] {
+		delete(s.vars, name)
+		delete(s.exported, name)
+	}
+	return 0
+}
+
+// quote quotes value so that the shell reads it back unchanged.
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// statusNotExecutable and statusNotFound are the statuses of a command
+	// that could not be run, as in other shells
+	statusNotExecutable = 126
+	statusNotFound      = 127
+
+	// statusSignaled is added to the number of the signal that killed a
+	// command for its status
+	statusSignaled = 128
+
+	// statusSyntax is the status of a line with a syntax error
+	statusSyntax = 2
+)
+
+// shell holds the state commands run in: variables, the working directory
+// and the status of the last command. The working directory is the
+// shell's own rather than the process's, so that a builtin in a pipeline
+// can run on a copy of the shell without changing it.
+type shell struct {
+	vars     map[string]string
+	exported map[string]bool
+	dir      string
+	status   int
+
+	// exiting is set by the exit builtin
+	exiting bool
+
+	stdin, stdout, stderr *os.File
+}
+
+// newShell returns a shell with the environment and working directory of
+// the process, reading and writing the given files.
+func newShell(stdin, stdout, stderr *os.File) *shell {
+
+	s := &shell{
+		vars:     make(map[string]string),
+		exported: make(map[string]bool),
+		stdin:    stdin,
+		stdout:   stdout,
+		stderr:   stderr,
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && isName(name) {
+			s.vars[name] = value
+			s.exported[name] = true
+		}
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "/"
+	}
+	s.dir = dir
+	s.setExported("PWD", dir)
+	return s
+}
+
+// clone returns a copy of s for a builtin in a pipeline, which cannot
+// change the shell it was run from.
+func (s *shell) clone() *shell {
+	c := *s
+	c.vars = maps.Clone(s.vars)
+	c.exported = maps.Clone(s.exported)
+	return &c
+}
+
+func (s *shell) setExported(name, value string) {
+	s.vars[name] = value
+	s.exported[name] = true
+}
+
+// errorf prints an error from the shell itself.
+func (s *shell) errorf(stderr *os.File, format string, args ...any) {
+	fmt.Fprintf(stderr, "ccsh: "+format+"\n", args...)
+}
+
+// run parses and runs a command line or script, returning the status of
+// the last command.
+func (s *shell) run(input string) int {
+
+	l, err := parse(input)
+	if err != nil {
+		s.errorf(s.stderr, "%v", err)
+		s.status = statusSyntax
+		return s.status
+	}
+	return s.runList(l)
+}
+
+// runList runs the pipelines of l in turn, skipping those whose && or ||
+// does not hold, until the end or an exit.
+func (s *shell) runList(l list) int {
+
+	for _, st := range l {
+		if st.op == "&&" && s.status != 0 || st.op == "||" && s.status == 0 {
+			continue
+		}
+		s.status = s.runPipeline(st.pipeline)
+		if s.exiting {
+			break
+		}
+	}
+	return s.status
+}
+
+// process is a command of a pipeline once started: an external program,
+// or a builtin running in a goroutine that sends its status on done.
+type process struct {
+	cmd  *exec.Cmd
+	done chan int
+
+	// status is the status of a command that failed to start
+	status int
+}
+
+func (p *process) wait() int {
+	switch {
+	case p.cmd != nil:
+		return exitStatus(p.cmd.Wait())
+	case p.done != nil:
+		return <-p.done
+	}
+	return p.status
+}
+
+// runPipeline runs the commands of pl at the same time, each reading the
+// output of the one before, and returns the status of the last. A lone
+// builtin runs in the shell itself, so that cd and exit work.
+func (s *shell) runPipeline(pl pipeline) int {
+
+	if len(pl) == 1 {
+		c := pl[0]
+		if len(c.args) == 0 || builtins[s.expand(c.args[0])] != nil {
+			return s.runInShell(c)
+		}
+	}
+
+	procs := make([]*process, len(pl))
+	stdin := s.stdin
+	var readEnd *os.File
+	for i, c := range pl {
+		stdio := [3]*os.File{stdin, s.stdout, s.stderr}
+		var owned []*os.File
+		if readEnd != nil {
+			owned = append(owned, readEnd)
+			readEnd = nil
+		}
+		if i < len(pl)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				s.errorf(s.stderr, "%v", err)
+				closeAll(owned)
+				for _, p := range procs[:i] {
+					p.wait()
+				}
+				return 1
+			}
+			stdio[1] = w
+			owned = append(owned, w)
+			readEnd = r
+		}
+		procs[i] = s.start(c, stdio, owned)
+		stdin = readEnd
+	}
+
+	status := 0
+	for _, p := range procs {
+		status = p.wait()
+	}
+	return status
+}
+
+// start starts c with the given standard files, closing owned once they
+// are no longer needed: as soon as an external program has its own copies,
+// or when a builtin finishes.
+func (s *shell) start(c *command, stdio [3]*os.File, owned []*os.File) *process {
+
+	stdio, opened, err := s.redirect(c.redirects, stdio)
+	owned = append(owned, opened...)
+	if err != nil {
+		s.errorf(stdio[2], "%v", err)
+		closeAll(owned)
+		return &process{status: 1}
+	}
+
+	args := s.expandArgs(c.args)
+	if len(args) == 0 {
+		closeAll(owned)
+		return &process{}
+	}
+
+	if b := builtins[args[0]]; b != nil {
+		sub := s.clone()
+		p := &process{done: make(chan int, 1)}
+		go func() {
+			status := b(sub, args, stdio)
+			closeAll(owned)
+			p.done <- status
+		}()
+		return p
+	}
+
+	defer closeAll(owned)
+	cmd, status := s.command(args, c.assigns, stdio)
+	if cmd == nil {
+		return &process{status: status}
+	}
+	if err := cmd.Start(); err != nil {
+		s.errorf(stdio[2], "%s: %v", args[0], unwrapPathError(err))
+		return &process{status: statusNotExecutable}
+	}
+	return &process{cmd: cmd}
+}
+
+// runInShell runs a command that is a builtin or has no program, which
+// may change the shell: a command of only assignments sets shell
+// variables.
+func (s *shell) runInShell(c *command) int {
+
+	stdio, opened, err := s.redirect(c.redirects, [3]*os.File{s.stdin, s.stdout, s.stderr})
+	defer closeAll(opened)
+	if err != nil {
+		s.errorf(stdio[2], "%v", err)
+		return 1
+	}
+
+	args := s.expandArgs(c.args)
+	if len(args) == 0 {
+		for _, a := range c.assigns {
+			s.vars[a.name] = s.expand(a.value)
+		}
+		return 0
+	}
+	return builtins[args[0]](s, args, stdio)
+}
+
+// command prepares an external program to run with args, finding it on
+// PATH unless its name has a slash. If it cannot be run, command reports
+// why and returns the status to use instead.
+func (s *shell) command(args []string, assigns []assignment, stdio [3]*os.File) (*exec.Cmd, int) {
+
+	path, err := s.lookPath(args[0])
+	if err != nil {
+		switch {
+		case errors.Is(err, errNotFound):
+			s.errorf(stdio[2], "%s: command not found", args[0])
+			return nil, statusNotFound
+		case errors.Is(err, fs.ErrNotExist):
+			s.errorf(stdio[2], "%s: no such file or directory", args[0])
+			return nil, statusNotFound
+		}
+		s.errorf(stdio[2], "%s: %v", args[0], unwrapPathError(err))
+		return nil, statusNotExecutable
+	}
+
+	env := make(map[string]string)
+	for name := range s.exported {
+		if value, ok := s.vars[name]; ok {
+			env[name] = value
+		}
+	}
+	for _, a := range assigns {
+		env[a.name] = s.expand(a.value)
+	}
+
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   args,
+		Dir:    s.dir,
+		Stdin:  stdio[0],
+		Stdout: stdio[1],
+		Stderr: stdio[2],
+	}
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		cmd.Env = append(cmd.Env, name+"="+env[name])
+	}
+	return cmd, 0
+}
+
+// errNotFound is returned by lookPath for a program that is not on PATH.
+var errNotFound = errors.New("command not found")
+
+// lookPath finds the program name, searching the shell's own PATH, which
+// may differ from the process's.
+func (s *shell) lookPath(name string) (string, error) {
+
+	if strings.Contains(name, "/") {
+		path := s.path(name)
+		return path, checkExecutable(path)
+	}
+
+	for _, dir := range filepath.SplitList(s.vars["PATH"]) {
+		if dir == "" {
+			dir = "."
+		}
+		path := s.path(filepath.Join(dir, name))
+		if checkExecutable(path) == nil {
+			return path, nil
+		}
+	}
+	return "", errNotFound
+}
+
+// checkExecutable returns an error unless path is a file someone may
+// execute.
+func checkExecutable(path string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a directory")
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// path resolves name against the shell's working directory.
+func (s *shell) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+// redirect applies redirections to stdio in order, returning the result
+// and the files it opened, which the caller must close. Only the standard
+// descriptors 0, 1 and 2 can be redirected.
+func (s *shell) redirect(redirects []redirect, stdio [3]*os.File) ([3]*os.File, []*os.File, error) {
+
+	var opened []*os.File
+	for _, r := range redirects {
+		if r.fd > 2 {
+			return stdio, opened, fmt.Errorf("%d: bad file descriptor", r.fd)
+		}
+		target := s.expand(r.target)
+
+		var f *os.File
+		var err error
+		switch r.op {
+		case "<":
+			f, err = os.Open(s.path(target))
+		case ">":
+			f, err = os.OpenFile(s.path(target), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+		case ">>":
+			f, err = os.OpenFile(s.path(target), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+		case "<&", ">&":
+			fd, convErr := strconv.Atoi(target)
+			if convErr != nil || fd < 0 || fd > 2 {
+				return stdio, opened, fmt.Errorf("%s: bad file descriptor", target)
+			}
+			stdio[r.fd] = stdio[fd]
+			continue
+		}
+		if err != nil {
+			return stdio, opened, fmt.Errorf("%s: %w", target, unwrapPathError(err))
+		}
+		opened = append(opened, f)
+		stdio[r.fd] = f
+	}
+	return stdio, opened, nil
+}
+
+// expand returns the text of w with its variables substituted.
+func (s *shell) expand(w word) string {
+
+	var b strings.Builder
+	for _, p := range w {
+		if !p.variable {
+			b.WriteString(p.text)
+			continue
+		}
+		switch p.text {
+		case "?":
+			b.WriteString(strconv.Itoa(s.status))
+		case "$":
+			b.WriteString(strconv.Itoa(os.Getpid()))
+		default:
+			b.WriteString(s.vars[p.text])
+		}
+	}
+	return b.String()
+}
+
+// expandArgs expands each word to one argument, without splitting on
+// blanks or matching file names. An unquoted word made only of variables
+// that are empty is dropped, as other shells do.
+func (s *shell) expandArgs(words []word) []string {
+
+	var args []string
+	for _, w := range words {
+		arg := s.expand(w)
+		if arg == "" && !slices.ContainsFunc(w, func(p part) bool { return p.quoted || !p.variable }) {
+			continue
+		}
+		args = append(args, arg)
+	}
+	return args
+}
+
+// exitStatus returns the status of a command from the error Wait returned
+// for it.
+func exitStatus(err error) int {
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return statusSignaled + int(ws.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+// unwrapPathError drops the operation and path from err, which shell
+// messages give their own way.
+func unwrapPathError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+func closeAll(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
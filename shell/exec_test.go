@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testShell returns a shell working in a new directory, with a minimal
+// environment and its output going to files.
+func testShell(t *testing.T) (s *shell, stdout, stderr *os.File) {
+	t.Helper()
+
+	for _, program := range []string{"cat", "tr", "sh"} {
+		if _, err := exec.LookPath(program); err != nil {
+			t.Skipf("%s is not available", program)
+		}
+	}
+
+	dir := t.TempDir()
+	var err error
+	if stdout, err = os.Create(filepath.Join(t.TempDir(), "stdout")); err != nil {
+		t.Fatal(err)
+	}
+	if stderr, err = os.Create(filepath.Join(t.TempDir(), "stderr")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stdout.Close()
+		stderr.Close()
+	})
+
+	stdin, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stdin.Close() })
+
+	s = newShell(stdin, stdout, stderr)
+	s.vars = map[string]string{"PATH": os.Getenv("PATH"), "HOME": dir}
+	s.exported = map[string]bool{"PATH": true, "HOME": true}
+	s.dir = dir
+	return s, stdout, stderr
+}
+
+// contents returns what has been written to f.
+func contents(t *testing.T, f *os.File) string {
+	t.Helper()
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRun(t *testing.T) {
+
+	tests := []struct {
+		script     string
+		wantOut    string
+		wantStatus int
+	}{
+		{"sh -c 'echo hello'", "hello\n", 0},
+		{"sh -c 'echo $0 $1' a 'b c'", "a b c\n", 0},
+		{"sh -c 'exit 7'", "", 7},
+		{"sh -c 'kill -9 $$'", "", 137},
+		{"X=1; sh -c 'echo x$X'", "x\n", 0},
+		{"X=1 sh -c 'echo x$X'; sh -c 'echo y$X'", "x1\ny\n", 0},
+		{"export X=2; sh -c 'echo $X'", "2\n", 0},
+		{"X=3; export X; sh -c 'echo $X'; unset X; sh -c 'echo [$X]'", "3\n[]\n", 0},
+		{"X='a b'; sh -c 'echo $#' - $X \"$X\" $UNSET \"$UNSET\"", "3\n", 0},
+		{"sh -c 'exit 3'; sh -c \"echo $?\"", "3\n", 0},
+		{"sh -c 'exit 1' && sh -c 'echo no'; sh -c 'echo $0' yes", "yes\n", 0},
+		{"sh -c 'exit 1' || sh -c 'echo $0' yes", "yes\n", 0},
+		{"sh -c 'echo hi' || sh -c 'echo no'", "hi\n", 0},
+		{"sh -c 'echo abc' | tr a-z A-Z | tr B b", "AbC\n", 0},
+		{"sh -c 'exit 1' | cat", "", 0},
+		{"cat /missing | cat", "", 0},
+		{"sh -c 'echo out; echo err >&2' 2>&1 | tr a-z A-Z", "OUT\nERR\n", 0},
+		{"sh -c 'echo a' > f; sh -c 'echo b' >> f; cat < f", "a\nb\n", 0},
+		{"> empty; cat empty", "", 0},
+		{"cat < missing", "", 1},
+		{"nosuchcommand", "", statusNotFound},
+		{"./nosuchfile", "", statusNotFound},
+		{"exit 4; sh -c 'echo not reached'", "", 4},
+		{"sh -c 'exit 5'; exit", "", 5},
+		{"exit 4 | cat; sh -c 'echo still here'", "still here\n", 0},
+		{"mkdir sub; cd sub; pwd | tr / :; sh -c pwd | tr / :", "", 0},
+	}
+
+	for _, test := range tests {
+		s, stdout, _ := testShell(t)
+		status := s.run(test.script)
+
+		got := contents(t, stdout)
+		if strings.HasPrefix(test.script, "mkdir") {
+			test.wantOut = strings.Repeat(strings.ReplaceAll(filepath.Join(s.vars["HOME"], "sub"), "/", ":")+"\n", 2)
+		}
+		if got != test.wantOut || status != test.wantStatus {
+			t.Errorf("run(%q) = %q, %d, want %q, %d", test.script, got, status, test.wantOut, test.wantStatus)
+		}
+	}
+}
+
+func TestCd(t *testing.T) {
+
+	s, stdout, stderr := testShell(t)
+	home := s.dir
+	if err := os.Mkdir(filepath.Join(home, "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		script  string
+		wantDir string
+	}{
+		{"cd a", filepath.Join(home, "a")},
+		{"cd ..", home},
+		{"cd -", filepath.Join(home, "a")},
+		{"cd", home},
+		{"cd missing", home},
+		{"cd a | cat", home},
+	}
+	for _, step := range steps {
+		s.run(step.script)
+		if s.dir != step.wantDir {
+			t.Errorf("after %q dir = %s, want %s", step.script, s.dir, step.wantDir)
+		}
+	}
+
+	if got := s.vars["PWD"]; got != home {
+		t.Errorf("PWD = %s, want %s", got, home)
+	}
+	if got, want := contents(t, stdout), filepath.Join(home, "a")+"\n"; got != want {
+		t.Errorf("cd - printed %q, want %q", got, want)
+	}
+	if got := contents(t, stderr); !strings.Contains(got, "cd: missing:") {
+		t.Errorf("stderr = %q, want an error for the missing directory", got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+
+	tests := []struct {
+		script     string
+		wantErr    string
+		wantStatus int
+	}{
+		{"nosuchcommand", "ccsh: nosuchcommand: command not found\n", statusNotFound},
+		{"echo a |", "ccsh: syntax error: unexpected end of input\n", statusSyntax},
+		{"cat < missing", "ccsh: missing: no such file or directory\n", 1},
+		{"cat 3< f", "ccsh: 3: bad file descriptor\n", 1},
+		{"cat 1>&x", "ccsh: x: bad file descriptor\n", 1},
+		{"exit x", "ccsh: exit: x: numeric argument required\n", statusSyntax},
+		{"export 1x=2", "ccsh: export: 1x=2: not a valid identifier\n", 1},
+	}
+
+	for _, test := range tests {
+		s, _, stderr := testShell(t)
+		status := s.run(test.script)
+		if got := contents(t, stderr); got != test.wantErr || status != test.wantStatus {
+			t.Errorf("run(%q) printed %q, %d, want %q, %d", test.script, got, status, test.wantErr, test.wantStatus)
+		}
+	}
+}
+
+func TestRepl(t *testing.T) {
+
+	s, stdout, _ := testShell(t)
+	input := "sh -c 'echo \"$0\"' \"multi\nline\" |\n  tr a-z A-Z\n\necho 'never\nexit 6\nsh -c 'echo not reached'\n"
+	if err := repl(s, strings.NewReader(input), stdout, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unterminated quote swallows the rest, up to the end of the input
+	if got, want := contents(t, stdout), "MULTI\nLINE\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if s.status != statusSyntax {
+		t.Errorf("status = %d, want %d", s.status, statusSyntax)
+	}
+
+	s, stdout, _ = testShell(t)
+	if err := repl(s, strings.NewReader("X=1\nexit 6\nsh -c 'echo not reached'\n"), stdout, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(t, stdout); got != "" || s.status != 6 {
+		t.Errorf("output = %q, status %d, want nothing and 6", got, s.status)
+	}
+}
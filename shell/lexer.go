@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenOperator
+	tokenNewline
+	tokenEOF
+)
+
+// part is a piece of a word: literal text, or a variable whose value is
+// substituted when the command runs. Quoted text is literal however it
+// looks, so an assignment is only recognized in unquoted text.
+type part struct {
+	text     string
+	variable bool
+	quoted   bool
+}
+
+// word is a word of a command line, in the parts it is made of.
+type word []part
+
+// String returns the word as it could be written, with variables shown
+// as $NAME, for messages and tests.
+func (w word) String() string {
+	var b strings.Builder
+	for _, p := range w {
+		if p.variable {
+			b.WriteString("$" + p.text)
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return b.String()
+}
+
+type token struct {
+	kind tokenKind
+
+	// op is the operator of an operator token, such as "|" or ">>"
+	op string
+
+	// fd is the file descriptor number written before a redirection
+	// operator, as in 2>, or -1
+	fd int
+
+	word word
+	pos  int
+}
+
+// operators are the operators a command line can use, longest first so
+// that ">>" is not read as two ">".
+var operators = []string{"&&", "||", ">>", ">&", "<&", "|", ";", "<", ">", "&"}
+
+// syntaxError is an error in a command line. An incomplete command line,
+// such as one with an unterminated quote, may be finished by the lines
+// after it.
+type syntaxError struct {
+	msg        string
+	incomplete bool
+}
+
+func (e *syntaxError) Error() string {
+	return "syntax error: " + e.msg
+}
+
+// lexer splits a command line into words and operators, resolving quotes
+// and escapes as it goes.
+type lexer struct {
+	input string
+	pos   int
+}
+
+// tokenize splits all of input into tokens, ending with tokenEOF.
+func tokenize(input string) ([]token, error) {
+
+	l := &lexer{input: input}
+	var tokens []token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+
+	// Skip blanks, line continuations and comments
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+			continue
+		case c == '\\' && l.pos+1 < len(l.input) && l.input[l.pos+1] == '\n':
+			l.pos += 2
+			continue
+		case c == '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	start := l.pos
+	if l.pos == len(l.input) {
+		return token{kind: tokenEOF, fd: -1, pos: start}, nil
+	}
+	if l.input[l.pos] == '\n' {
+		l.pos++
+		return token{kind: tokenNewline, fd: -1, pos: start}, nil
+	}
+
+	// A number right before < or > is the descriptor it redirects
+	end := l.pos
+	for end < len(l.input) && '0' <= l.input[end] && l.input[end] <= '9' {
+		end++
+	}
+	if end > l.pos && end < len(l.input) && (l.input[end] == '<' || l.input[end] == '>') {
+		fd := 0
+		fmt.Sscan(l.input[l.pos:end], &fd)
+		l.pos = end
+		t := l.operator()
+		t.fd, t.pos = fd, start
+		return t, nil
+	}
+
+	if t := l.operator(); t.kind == tokenOperator {
+		if t.op == "&" {
+			return token{}, &syntaxError{msg: "running commands in the background with & is not supported"}
+		}
+		return t, nil
+	}
+
+	w, err := l.word()
+	if err != nil {
+		return token{}, err
+	}
+	return token{kind: tokenWord, word: w, fd: -1, pos: start}, nil
+}
+
+// operator reads an operator at the current position, returning a token
+// of another kind if there is none.
+func (l *lexer) operator() token {
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOperator, op: op, fd: -1}
+		}
+	}
+	return token{kind: tokenWord}
+}
+
+// word reads a word, up to an unquoted blank, newline or operator.
+func (l *lexer) word() (word, error) {
+
+	var w word
+	var literal strings.Builder
+	quotedLiteral := false
+
+	flush := func() {
+		if literal.Len() > 0 {
+			w = append(w, part{text: literal.String(), quoted: quotedLiteral})
+			literal.Reset()
+		}
+	}
+	add := func(text string, quoted bool) {
+		if literal.Len() > 0 && quoted != quotedLiteral {
+			flush()
+		}
+		quotedLiteral = quoted
+		literal.WriteString(text)
+	}
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+
+		switch {
+		case strings.IndexByte(" \t\r\n;|&<>", c) >= 0:
+			flush()
+			return w, nil
+
+		case c == '\\':
+			l.pos++
+			if l.pos == len(l.input) {
+				return nil, &syntaxError{msg: "unexpected end of input after \\", incomplete: true}
+			}
+			if l.input[l.pos] != '\n' {
+				add(l.input[l.pos:l.pos+1], true)
+			}
+			l.pos++
+
+		case c == '\'':
+			end := strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				return nil, &syntaxError{msg: "unterminated single quote", incomplete: true}
+			}
+			add(l.input[l.pos+1:l.pos+1+end], true)
+			l.pos += end + 2
+
+		case c == '"':
+			l.pos++
+			if err := l.doubleQuoted(&w, add, flush); err != nil {
+				return nil, err
+			}
+
+		case c == '$':
+			if name, ok := l.variable(); ok {
+				flush()
+				w = append(w, part{text: name, variable: true})
+			} else {
+				add("$", false)
+				l.pos++
+			}
+
+		case c == '~' && len(w) == 0 && literal.Len() == 0 && l.tildeEnds():
+			l.pos++
+			w = append(w, part{text: "HOME", variable: true})
+
+		default:
+			add(string(c), false)
+			l.pos++
+		}
+	}
+
+	flush()
+	return w, nil
+}
+
+// doubleQuoted reads the rest of a double-quoted string, where variables
+// are still substituted and a backslash only escapes $, `, ", \ and
+// newline.
+func (l *lexer) doubleQuoted(w *word, add func(string, bool), flush func()) error {
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+
+			// An empty string is still a word
+			add("", true)
+			if len(*w) == 0 {
+				flush()
+				*w = append(*w, part{quoted: true})
+			}
+			return nil
+
+		case c == '\\' && l.pos+1 < len(l.input) && strings.IndexByte("$`\"\\\n", l.input[l.pos+1]) >= 0:
+			if l.input[l.pos+1] != '\n' {
+				add(l.input[l.pos+1:l.pos+2], true)
+			}
+			l.pos += 2
+
+		case c == '$':
+			if name, ok := l.variable(); ok {
+				flush()
+				*w = append(*w, part{text: name, variable: true, quoted: true})
+			} else {
+				add("$", true)
+				l.pos++
+			}
+
+		default:
+			add(string(c), true)
+			l.pos++
+		}
+	}
+	return &syntaxError{msg: "unterminated double quote", incomplete: true}
+}
+
+// variable reads a variable reference at a $: $NAME, ${NAME}, or one of the
+// special parameters $? and $$. A $ that starts none of them is literal,
+// and is left for the caller.
+func (l *lexer) variable() (string, bool) {
+
+	rest := l.input[l.pos+1:]
+	switch {
+	case rest == "":
+		return "", false
+	case rest[0] == '?' || rest[0] == '$':
+		l.pos += 2
+		return rest[:1], true
+	case rest[0] == '{':
+		end := strings.IndexByte(rest, '}')
+		if end < 2 || !isName(rest[1:end]) {
+			return "", false
+		}
+		l.pos += end + 2
+		return rest[1:end], true
+	}
+
+	n := 0
+	for n < len(rest) && isNameChar(rest[n], n == 0) {
+		n++
+	}
+	if n == 0 {
+		return "", false
+	}
+	l.pos += n + 1
+	return rest[:n], true
+}
+
+// tildeEnds reports whether a ~ at the start of a word stands alone or
+// before a slash, which is when it means the home directory.
+func (l *lexer) tildeEnds() bool {
+	next := l.pos + 1
+	return next == len(l.input) || strings.IndexByte("/ \t\r\n;|&<>", l.input[next]) >= 0
+}
+
+func isName(s string) bool {
+	for i := range len(s) {
+		if !isNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// describe renders tokens compactly: words in brackets, operators bare,
+// with the descriptor of a redirection before it.
+func describe(tokens []token) string {
+
+	var parts []string
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenWord:
+			parts = append(parts, "["+t.word.String()+"]")
+		case tokenOperator:
+			if t.fd >= 0 {
+				parts = append(parts, strconv.Itoa(t.fd)+t.op)
+			} else {
+				parts = append(parts, t.op)
+			}
+		case tokenNewline:
+			parts = append(parts, "NL")
+		case tokenEOF:
+			parts = append(parts, "EOF")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTokenize(t *testing.T) {
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "EOF"},
+		{"echo hello  world", "[echo] [hello] [world] EOF"},
+		{"a|b||c&&d;e", "[a] | [b] || [c] && [d] ; [e] EOF"},
+		{"cat <in >out >>log", "[cat] < [in] > [out] >> [log] EOF"},
+		{"cmd 2>err 2>&1 1>&2", "[cmd] 2> [err] 2>& [1] 1>& [2] EOF"},
+		{"echo a2>f", "[echo] [a2] > [f] EOF"},
+		{"echo 'a b' \"c d\"", "[echo] [a b] [c d] EOF"},
+		{`echo a\ b \$x`, "[echo] [a b] [$x] EOF"},
+		{"echo $HOME ${USER}x $? $$", "[echo] [$HOME] [$USERx] [$?] [$$] EOF"},
+		{"echo $ $1 ${}", "[echo] [$] [$1] [${}] EOF"},
+		{"echo ~ ~/bin a~", "[echo] [$HOME] [$HOME/bin] [a~] EOF"},
+		{"echo '' \"\"", "[echo] [] [] EOF"},
+		{"a # comment ; b\nc", "[a] NL [c] EOF"},
+		{"echo a \\\nb", "[echo] [a] [b] EOF"},
+		{"echo a\\\nb", "[echo] [ab] EOF"},
+		{`echo "\$x \"q\" \n"`, `[echo] [$x "q" \n] EOF`},
+	}
+
+	for _, test := range tests {
+		tokens, err := tokenize(test.input)
+		if err != nil {
+			t.Errorf("tokenize(%q): %v", test.input, err)
+			continue
+		}
+		if got := describe(tokens); got != test.want {
+			t.Errorf("tokenize(%q) = %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestTokenizeQuoting(t *testing.T) {
+
+	// Quoted text stays literal and marked as quoted, so that the parser
+	// can tell NAME=value from 'NAME=value', and variables in double
+	// quotes are still variables
+	tokens, err := tokenize(`a"$B c"'$D'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := word{
+		{text: "a"},
+		{text: "B", variable: true, quoted: true},
+		{text: " c$D", quoted: true},
+	}
+	got := tokens[0].word
+	if len(got) != len(want) {
+		t.Fatalf("word = %#v, want %#v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("part %d = %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"echo 'abc", true},
+		{`echo "abc`, true},
+		{`echo abc\`, true},
+		{"sleep 1 &", false},
+	}
+
+	for _, test := range tests {
+		_, err := tokenize(test.input)
+		var syntaxErr *syntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("tokenize(%q) error = %v, want a syntax error", test.input, err)
+			continue
+		}
+		if syntaxErr.incomplete != test.incomplete {
+			t.Errorf("tokenize(%q) incomplete = %v, want %v", test.input, syntaxErr.incomplete, test.incomplete)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Command ccsh is a small Unix shell. It runs commands and pipelines of
+// them, with redirections, variables, quoting, the ;, && and || operators,
+// and the builtins cd, exit, export, pwd and unset. It reads commands from
+// -c, a script file, or standard input, prompting for them on a terminal.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+	"codechallenge/internal/textutil"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccsh [flags] [script]")
+	fmt.Fprintln(out, "Run commands from script, or from standard input.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccsh"
+
+	commands := flag.String("c", "", "run the `commands` given instead of reading them")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		cli.Exit(cli.Usagef("too many arguments"))
+	}
+
+	// An interrupt is meant for the command running in the foreground,
+	// which gets it too, so the shell only needs to survive it. Unlike
+	// ignoring it, handling it lets the commands the shell runs see the
+	// default behavior.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+		}
+	}()
+
+	s := newShell(os.Stdin, os.Stdout, os.Stderr)
+
+	if *commands != "" {
+		os.Exit(s.run(*commands))
+	}
+
+	input, interactive := os.Stdin, cli.IsTerminal(os.Stdin)
+	if flag.NArg() == 1 {
+		name := flag.Arg(0)
+		f, err := os.Open(name)
+		if err != nil {
+			cli.Report(&cli.FileError{File: name, Err: err})
+			os.Exit(statusNotFound)
+		}
+		defer f.Close()
+		input, interactive = f, false
+	}
+
+	if err := repl(s, input, os.Stdout, interactive); err != nil {
+		cli.Report(err)
+		os.Exit(cli.ExitFailure)
+	}
+	os.Exit(s.status)
+}
+
+// repl runs the commands of input a line at a time, until its end or the
+// exit builtin. A line that leaves a command unfinished, such as inside a
+// quote or after a |, is joined with the lines after it.
+func repl(s *shell, input io.Reader, out io.Writer, interactive bool) error {
+
+	reader := textutil.NewLineReader(input, streamio.StreamBufferSize)
+	var pending string
+	for !s.exiting {
+		if interactive {
+			if pending == "" {
+				fmt.Fprint(out, "ccsh> ")
+			} else {
+				fmt.Fprint(out, "> ")
+			}
+		}
+
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			if interactive {
+				fmt.Fprintln(out)
+			}
+			if pending != "" {
+				// Report what is still unfinished
+				s.run(pending)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if pending != "" {
+			pending += "\n"
+		}
+		pending += string(line)
+
+		l, err := parse(pending)
+		var syntaxErr *syntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.incomplete {
+			continue
+		}
+		if err != nil {
+			s.errorf(s.stderr, "%v", err)
+			s.status = statusSyntax
+		} else {
+			s.runList(l)
+		}
+		pending = ""
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// redirect redirects a file descriptor of a command.
+type redirect struct {
+	fd int
+
+	// op is one of <, >, >>, <& and >&
+	op string
+
+	// target is the file name, or the descriptor to duplicate for <&
+	// and >&
+	target word
+}
+
+// assignment sets a variable, for the shell or for one command.
+type assignment struct {
+	name  string
+	value word
+}
+
+// command is a simple command: assignments, then a program and its
+// arguments, with redirections anywhere among them.
+type command struct {
+	assigns   []assignment
+	args      []word
+	redirects []redirect
+}
+
+// pipeline is commands with the output of each sent to the next.
+type pipeline []*command
+
+// step is a pipeline of a list, with the operator that decides whether it
+// runs after the one before: ";" always, "&&" if that succeeded and "||"
+// if it failed. The first step's operator is ";".
+type step struct {
+	op       string
+	pipeline pipeline
+}
+
+// list is the commands of a line or script, in order.
+type list []step
+
+// parse parses a command line or script.
+func parse(input string) (list, error) {
+
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	return p.list()
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// unexpected reports t where it is not allowed.
+func unexpected(t token) error {
+	switch t.kind {
+	case tokenEOF:
+		return &syntaxError{msg: "unexpected end of input", incomplete: true}
+	case tokenNewline:
+		return &syntaxError{msg: "unexpected newline"}
+	case tokenWord:
+		return &syntaxError{msg: fmt.Sprintf("unexpected word %q", t.word.String())}
+	}
+	return &syntaxError{msg: fmt.Sprintf("unexpected %q", t.op)}
+}
+
+// list parses pipelines separated by ;, &&, || and newlines. A ; or newline
+// may end the list, but && and || need a pipeline after them, which may
+// be on the next line. Blank lines are skipped, but an empty command
+// before a ; is an error.
+func (p *parser) list() (list, error) {
+
+	var l list
+	op := ";"
+	for {
+		for p.peek().kind == tokenNewline {
+			p.advance()
+		}
+		if p.peek().kind == tokenEOF {
+			if op != ";" {
+				return nil, unexpected(p.peek())
+			}
+			return l, nil
+		}
+
+		pl, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, step{op: op, pipeline: pl})
+
+		t := p.advance()
+		switch {
+		case t.kind == tokenEOF:
+			return l, nil
+		case t.kind == tokenNewline || t.kind == tokenOperator && t.op == ";":
+			op = ";"
+		case t.kind == tokenOperator && (t.op == "&&" || t.op == "||"):
+			op = t.op
+		default:
+			return nil, unexpected(t)
+		}
+	}
+}
+
+// pipeline parses commands separated by |.
+func (p *parser) pipeline() (pipeline, error) {
+
+	var pl pipeline
+	for {
+		c, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		pl = append(pl, c)
+
+		if t := p.peek(); t.kind != tokenOperator || t.op != "|" {
+			return pl, nil
+		}
+		p.advance()
+		for p.peek().kind == tokenNewline {
+			p.advance()
+		}
+	}
+}
+
+// command parses a simple command, which must have at least one word or
+// redirection.
+func (p *parser) command() (*command, error) {
+
+	c := &command{}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenWord:
+			p.advance()
+			if name, value, ok := splitAssignment(t.word); ok && len(c.args) == 0 {
+				c.assigns = append(c.assigns, assignment{name: name, value: value})
+			} else {
+				c.args = append(c.args, t.word)
+			}
+
+		case t.kind == tokenOperator && strings.ContainsAny(t.op, "<>"):
+			p.advance()
+			target := p.advance()
+			if target.kind != tokenWord {
+				return nil, unexpected(target)
+			}
+			fd := t.fd
+			if fd < 0 {
+				fd = 1
+				if t.op[0] == '<' {
+					fd = 0
+				}
+			}
+			c.redirects = append(c.redirects, redirect{fd: fd, op: t.op, target: target.word})
+
+		default:
+			if len(c.assigns) == 0 && len(c.args) == 0 && len(c.redirects) == 0 {
+				return nil, unexpected(t)
+			}
+			return c, nil
+		}
+	}
+}
+
+// splitAssignment splits a word of the form NAME=value, where the name and
+// = are unquoted.
+func splitAssignment(w word) (name string, value word, ok bool) {
+
+	if len(w) == 0 || w[0].variable || w[0].quoted {
+		return "", nil, false
+	}
+	name, rest, found := strings.Cut(w[0].text, "=")
+	if !found || !isName(name) {
+		return "", nil, false
+	}
+
+	value = append(value, w[1:]...)
+	if rest != "" {
+		value = append(word{{text: rest}}, value...)
+	}
+	return name, value, true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format renders a list compactly, with each command in braces showing
+// its assignments, arguments and redirections.
+func format(l list) string {
+
+	var b strings.Builder
+	for i, st := range l {
+		if i > 0 {
+			fmt.Fprintf(&b, " %s ", st.op)
+		}
+		for j, c := range st.pipeline {
+			if j > 0 {
+				b.WriteString(" | ")
+			}
+			var fields []string
+			for _, a := range c.assigns {
+				fields = append(fields, a.name+"="+a.value.String())
+			}
+			for _, arg := range c.args {
+				fields = append(fields, arg.String())
+			}
+			for _, r := range c.redirects {
+				fields = append(fields, fmt.Sprintf("%d%s%s", r.fd, r.op, r.target))
+			}
+			b.WriteString("{" + strings.Join(fields, " ") + "}")
+		}
+	}
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"\n \n", ""},
+		{"ls -l", "{ls -l}"},
+		{"a | b | c", "{a} | {b} | {c}"},
+		{"a; b && c || d", "{a} ; {b} && {c} || {d}"},
+		{"a\nb;", "{a} ; {b}"},
+		{"a &&\n b |\n c", "{a} && {b} | {c}"},
+		{"sort <in >out", "{sort 0<in 1>out}"},
+		{">out echo 2>>err hi", "{echo hi 1>out 2>>err}"},
+		{"cmd 2>&1 <&0", "{cmd 2>&1 0<&0}"},
+		{"A=1 B=$x cmd C=2", "{A=1 B=$x cmd C=2}"},
+		{"A=1", "{A=1}"},
+		{"A= cmd", "{A= cmd}"},
+		{"A='x y'z cmd", "{A=x yz cmd}"},
+		{"'A=1' cmd", "{A=1 cmd}"},
+		{"1A=1", "{1A=1}"},
+		{">file", "{1>file}"},
+	}
+
+	for _, test := range tests {
+		l, err := parse(test.input)
+		if err != nil {
+			t.Errorf("parse(%q): %v", test.input, err)
+			continue
+		}
+		if got := format(l); got != test.want {
+			t.Errorf("parse(%q) = %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+
+	// Quoting the name or = makes the word an argument
+	l, err := parse("A=1 'B=2' C\\=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := l[0].pipeline[0]
+	if len(c.assigns) != 1 || c.assigns[0].name != "A" {
+		t.Errorf("assignments = %v, want only A", c.assigns)
+	}
+	if len(c.args) != 2 {
+		t.Errorf("arguments = %v, want 2", c.args)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"| a", false},
+		{"a || || b", false},
+		{"a ;; b", false},
+		{"; a", false},
+		{"a > | b", false},
+		{"a >", true},
+		{"a |", true},
+		{"a &&", true},
+		{"a ||\n", true},
+		{"echo 'a", true},
+	}
+
+	for _, test := range tests {
+		_, err := parse(test.input)
+		var syntaxErr *syntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("parse(%q) error = %v, want a syntax error", test.input, err)
+			continue
+		}
+		if syntaxErr.incomplete != test.incomplete {
+			t.Errorf("parse(%q) incomplete = %v, want %v", test.input, syntaxErr.incomplete, test.incomplete)
+		}
+	}
+}
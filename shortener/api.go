@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxRequestSize bounds the body of an API request, which only carries a
+// URL.
+const maxRequestSize = 64 * 1024
+
+// shortenRequest is the body of a request to shorten a URL.
+type shortenRequest struct {
+	URL string `json:"url"`
+}
+
+// link is how the API describes a short URL.
+type link struct {
+	Key      string `json:"key"`
+	ShortURL string `json:"short_url"`
+	LongURL  string `json:"long_url"`
+}
+
+// errorResponse is the body of an API error.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// shortener serves the redirects and the API that manages them.
+type shortener struct {
+	store store
+	keys  *keyGenerator
+
+	// base is what keys are appended to to make short URLs, such as
+	// "https://sho.rt/"
+	base string
+}
+
+func (s *shortener) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/urls", s.create)
+	mux.HandleFunc("GET /api/urls/{key}", s.show)
+	mux.HandleFunc("DELETE /api/urls/{key}", s.remove)
+	mux.HandleFunc("GET /{key}", s.redirect)
+	return mux
+}
+
+// create shortens the URL in the request body, answering 201 Created with
+// the new short URL, or 200 OK with the existing one if the URL has been
+// shortened before.
+func (s *shortener) create(w http.ResponseWriter, r *http.Request) {
+
+	var req shortenRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if decoder.More() {
+		writeError(w, http.StatusBadRequest, "invalid request body: more than one JSON value")
+		return
+	}
+
+	if err := validateURL(req.URL); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if key, ok := s.store.find(req.URL); ok {
+		writeJSON(w, http.StatusOK, s.link(key, req.URL))
+		return
+	}
+
+	key, err := s.keys.shorten(s.store, req.URL)
+	if err != nil {
+		log.Printf("ccshorten: shortening %s: %v", req.URL, err)
+		writeError(w, http.StatusInternalServerError, "could not store the URL")
+		return
+	}
+
+	w.Header().Set("Location", "/api/urls/"+key)
+	writeJSON(w, http.StatusCreated, s.link(key, req.URL))
+}
+
+func (s *shortener) show(w http.ResponseWriter, r *http.Request) {
+
+	key := r.PathValue("key")
+	long, err := s.store.get(key)
+	if err != nil {
+		s.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.link(key, long))
+}
+
+func (s *shortener) remove(w http.ResponseWriter, r *http.Request) {
+
+	if err := s.store.delete(r.PathValue("key")); err != nil {
+		s.storeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// redirect sends the client on to the long URL for a key. The redirect is
+// 302 Found rather than permanent, so that clients keep asking and a
+// deleted key stops working.
+func (s *shortener) redirect(w http.ResponseWriter, r *http.Request) {
+
+	long, err := s.store.get(r.PathValue("key"))
+	if errors.Is(err, errNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("ccshorten: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, long, http.StatusFound)
+}
+
+func (s *shortener) link(key, long string) link {
+	return link{Key: key, ShortURL: s.base + key, LongURL: long}
+}
+
+func (s *shortener) storeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	log.Printf("ccshorten: %v", err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+// validateURL accepts absolute http and https URLs, which are all a
+// browser can usefully be redirected to.
+func validateURL(raw string) error {
+
+	if raw == "" {
+		return errors.New("url is required")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return fmt.Errorf("invalid url %q: must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid url %q: no host", raw)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// alphabet is the characters keys are made of: letters and digits, which
+// need no escaping in a URL.
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// keyGenerator makes random keys. With 62 choices per character, the
+// chance that a new key collides stays small until a large share of the
+// keys of a length are used, and a collision is only a retry away.
+type keyGenerator struct {
+	length int
+
+	// random is the source of randomness, crypto/rand's Reader if nil
+	random io.Reader
+}
+
+// maxAttempts is how many keys of one length are tried before moving on
+// to longer ones.
+const maxAttempts = 5
+
+var errNoKey = errors.New("could not find an unused key")
+
+// shorten stores url under a new random key and returns the key. A key
+// that turns out to be taken, including by another server sharing the
+// store, is simply replaced by another; after repeated collisions, keys
+// get longer.
+func (g *keyGenerator) shorten(s store, url string) (string, error) {
+
+	for length := g.length; length < g.length+3; length++ {
+		for range maxAttempts {
+			key, err := g.key(length)
+			if err != nil {
+				return "", err
+			}
+
+			err = s.put(key, url)
+			if err == nil {
+				return key, nil
+			}
+			if !errors.Is(err, errTaken) {
+				return "", err
+			}
+		}
+	}
+	return "", errNoKey
+}
+
+func (g *keyGenerator) key(length int) (string, error) {
+
+	random := g.random
+	if random == nil {
+		random = rand.Reader
+	}
+
+	key := make([]byte, length)
+	size := big.NewInt(int64(len(alphabet)))
+	for i := range key {
+		n, err := rand.Int(random, size)
+		if err != nil {
+			return "", err
+		}
+		key[i] = alphabet[n.Int64()]
+	}
+	return string(key), nil
+}
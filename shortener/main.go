@@ -0,0 +1,88 @@
+// Command ccshorten is a URL shortening service. It hands out short random
+// keys for long URLs through a JSON API, redirects requests for a key to
+// its URL, and keeps the mapping in memory or in a file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccshorten [flags]")
+	fmt.Fprintln(out, "Serve POST /api/urls with {\"url\": \"...\"} to shorten a URL, GET and DELETE")
+	fmt.Fprintln(out, "/api/urls/KEY to look one up or remove it, and GET /KEY to be redirected.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccshorten"
+
+	addr := flag.String("addr", ":8080", "listen on this `address`")
+	base := flag.String("base-url", "", "make short URLs by appending keys to this `URL` (default http://localhost plus the port of -addr)")
+	dataFile := flag.String("data", "", "keep short URLs in this `file`, rather than only in memory")
+	length := flag.Int("length", 7, "make keys this many characters long")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		cli.Exit(cli.Usagef("unexpected argument %q", flag.Arg(0)))
+	}
+	if *length < 1 {
+		cli.Exit(cli.Usagef("-length must be positive"))
+	}
+
+	if *base == "" {
+		*base = "http://localhost"
+		if i := strings.LastIndex(*addr, ":"); i >= 0 {
+			*base += (*addr)[i:]
+		}
+	}
+	if !strings.HasSuffix(*base, "/") {
+		*base += "/"
+	}
+
+	var s store = newMemoryStore()
+	if *dataFile != "" {
+		fs, err := openFileStore(*dataFile)
+		if err != nil {
+			cli.Exit(err)
+		}
+		defer fs.Close()
+		s = fs
+	}
+
+	app := &shortener{store: s, keys: &keyGenerator{length: *length}, base: *base}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: *addr, Handler: app.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	log.Printf("ccshorten: serving %s on %s", *base, *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		cli.Exit(err)
+	}
+}
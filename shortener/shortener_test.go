@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+
+	stores := map[string]func(t *testing.T) store{
+		"memory": func(t *testing.T) store { return newMemoryStore() },
+		"file": func(t *testing.T) store {
+			s, err := openFileStore(filepath.Join(t.TempDir(), "urls"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+
+			if err := s.put("abc", "https://example.com/"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.put("abc", "https://example.org/"); !errors.Is(err, errTaken) {
+				t.Errorf("put of a taken key = %v, want errTaken", err)
+			}
+			if got, err := s.get("abc"); err != nil || got != "https://example.com/" {
+				t.Errorf("get = %q, %v", got, err)
+			}
+			if key, ok := s.find("https://example.com/"); !ok || key != "abc" {
+				t.Errorf("find = %q, %v", key, ok)
+			}
+
+			if err := s.delete("abc"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.get("abc"); !errors.Is(err, errNotFound) {
+				t.Errorf("get after delete = %v, want errNotFound", err)
+			}
+			if _, ok := s.find("https://example.com/"); ok {
+				t.Error("find after delete found the URL")
+			}
+			if err := s.delete("abc"); !errors.Is(err, errNotFound) {
+				t.Errorf("second delete = %v, want errNotFound", err)
+			}
+		})
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "urls")
+
+	s, err := openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.put("a", "https://a.example/")
+	s.put("b", "https://b.example/")
+	s.delete("a")
+	s.Close()
+
+	s, err = openFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.get("a"); !errors.Is(err, errNotFound) {
+		t.Errorf("deleted key survived reopening: %v", err)
+	}
+	if got, err := s.get("b"); err != nil || got != "https://b.example/" {
+		t.Errorf("get(b) = %q, %v", got, err)
+	}
+}
+
+func TestShortenRetriesCollisions(t *testing.T) {
+
+	s := newMemoryStore()
+
+	// A source of zeros always makes the same key, so every key after the
+	// first collides until keys get longer
+	g := &keyGenerator{length: 2, random: zeros{}}
+
+	first, err := g.shorten(s, "https://a.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := g.shorten(s, "https://b.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != "00" || second != "000" {
+		t.Errorf("keys = %q, %q, want \"00\", \"000\"", first, second)
+	}
+
+	s.put("0000", "taken")
+	if _, err := g.shorten(s, "https://c.example/"); !errors.Is(err, errNoKey) {
+		t.Errorf("shorten with every length taken = %v, want errNoKey", err)
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(buffer []byte) (int, error) {
+	clear(buffer)
+	return len(buffer), nil
+}
+
+func TestShortenConcurrently(t *testing.T) {
+
+	s := newMemoryStore()
+	g := &keyGenerator{length: 1}
+
+	// With one-character keys collisions are certain, yet no two URLs may
+	// share a key
+	const n = 100
+	keys := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := g.shorten(s, "https://example.com/"+strings.Repeat("x", i))
+			if err != nil {
+				t.Error(err)
+			}
+			keys[i] = key
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if seen[key] {
+			t.Fatalf("key %q handed out twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestAPI(t *testing.T) {
+
+	app := &shortener{store: newMemoryStore(), keys: &keyGenerator{length: 7}, base: "https://sho.rt/"}
+	server := httptest.NewServer(app.routes())
+	defer server.Close()
+
+	client := server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	post := func(body string) (*http.Response, link) {
+		t.Helper()
+		resp, err := client.Post(server.URL+"/api/urls", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var l link
+		json.NewDecoder(resp.Body).Decode(&l)
+		return resp, l
+	}
+
+	resp, created := post(`{"url": "https://example.com/a/long/path?q=1"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201", resp.StatusCode)
+	}
+	if len(created.Key) != 7 || created.ShortURL != "https://sho.rt/"+created.Key || created.LongURL != "https://example.com/a/long/path?q=1" {
+		t.Errorf("created = %+v", created)
+	}
+
+	resp, again := post(`{"url": "https://example.com/a/long/path?q=1"}`)
+	if resp.StatusCode != http.StatusOK || again != created {
+		t.Errorf("shortening again = %d %+v, want 200 %+v", resp.StatusCode, again, created)
+	}
+
+	resp, err := client.Get(server.URL + "/" + created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != created.LongURL {
+		t.Errorf("redirect = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`not json`, http.StatusBadRequest},
+		{`{"url": "https://a.example/", "extra": 1}`, http.StatusBadRequest},
+		{`{"url": "https://a.example/"} {}`, http.StatusBadRequest},
+		{`{"url": ""}`, http.StatusUnprocessableEntity},
+		{`{"url": "ftp://a.example/"}`, http.StatusUnprocessableEntity},
+		{`{"url": "https:///nohost"}`, http.StatusUnprocessableEntity},
+		{`{"url": "` + strings.Repeat("x", maxRequestSize) + `"}`, http.StatusBadRequest},
+	} {
+		if resp, _ := post(tt.body); resp.StatusCode != tt.status {
+			t.Errorf("POST %.40s: status = %d, want %d", tt.body, resp.StatusCode, tt.status)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/urls/"+created.Key, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", resp.StatusCode)
+	}
+
+	for _, path := range []string{"/" + created.Key, "/api/urls/" + created.Key} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s after delete = %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, raw := range []string{"http://a.example", "HTTPS://a.example/x"} {
+		if err := validateURL(raw); err != nil {
+			t.Errorf("validateURL(%q) = %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	errNotFound = errors.New("no such short URL")
+	errTaken    = errors.New("key is already taken")
+)
+
+// store keeps the mapping from keys to long URLs. put must fail with
+// errTaken, atomically, if the key is in use, which is what makes key
+// generation safe from collisions however many servers share a store.
+type store interface {
+	put(key, url string) error
+	get(key string) (string, error)
+	delete(key string) error
+
+	// find returns the key of url, if it has been shortened
+	find(url string) (string, bool)
+}
+
+// memoryStore keeps the mapping in memory, lost when the server stops.
+type memoryStore struct {
+	mu   sync.RWMutex
+	urls map[string]string
+	keys map[string]string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{urls: make(map[string]string), keys: make(map[string]string)}
+}
+
+func (s *memoryStore) put(key, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.urls[key]; ok {
+		return errTaken
+	}
+	s.urls[key] = url
+	s.keys[url] = key
+	return nil
+}
+
+func (s *memoryStore) get(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	url, ok := s.urls[key]
+	if !ok {
+		return "", errNotFound
+	}
+	return url, nil
+}
+
+func (s *memoryStore) delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[key]
+	if !ok {
+		return errNotFound
+	}
+	delete(s.urls, key)
+	if s.keys[url] == key {
+		delete(s.keys, url)
+	}
+	return nil
+}
+
+func (s *memoryStore) find(url string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[url]
+	return key, ok
+}
+
+// fileStore keeps the mapping in memory and logs every change to a file,
+// one JSON object per line, which is replayed when the store is opened
+// again. Each change is synced to disk before it is acknowledged.
+type fileStore struct {
+	*memoryStore
+
+	// mu serializes writes to the file, and with them changes to the
+	// mapping, so the log records them in the order they were made
+	mu   sync.Mutex
+	file *os.File
+}
+
+// change is one line of a fileStore's log.
+type change struct {
+	Op  string `json:"op"`
+	Key string `json:"key"`
+	URL string `json:"url,omitempty"`
+}
+
+func openFileStore(path string) (*fileStore, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fileStore{memoryStore: newMemoryStore(), file: file}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var c change
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+
+		switch c.Op {
+		case "put":
+			s.memoryStore.put(c.Key, c.URL)
+		case "delete":
+			s.memoryStore.delete(c.Key)
+		default:
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: unknown operation %q", path, line, c.Op)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *fileStore) put(key, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memoryStore.get(key); err == nil {
+		return errTaken
+	}
+	if err := s.log(change{Op: "put", Key: key, URL: url}); err != nil {
+		return err
+	}
+	return s.memoryStore.put(key, url)
+}
+
+func (s *fileStore) delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.memoryStore.get(key); err != nil {
+		return err
+	}
+	if err := s.log(change{Op: "delete", Key: key}); err != nil {
+		return err
+	}
+	return s.memoryStore.delete(key)
+}
+
+func (s *fileStore) log(c change) error {
+
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *fileStore) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// mergeFanIn is the most runs merged at once. More runs than this are
+// first merged in groups into longer runs, to keep the number of open files
+// bounded.
+const mergeFanIn = 64
+
+// parallelSortMin is the fewest lines worth sorting in parallel pieces.
+const parallelSortMin = 16 * 1024
+
+// lineOverhead is roughly the memory each line held costs beyond its bytes,
+// for its slice header and allocation.
+const lineOverhead = 32
+
+// sorter sorts inputs of any size. Lines are read into chunks of about
+// chunkSize bytes; while input fits in one chunk it is sorted in memory,
+// and otherwise each chunk is sorted, up to parallel of them at once, and
+// written to a temporary file as a run, and the runs are then merged.
+type sorter struct {
+	order     order
+	unique    bool
+	chunkSize int
+	parallel  int
+	tempDir   string
+}
+
+// sort writes the lines of inputs to out in order.
+func (s *sorter) sort(inputs []io.Reader, out io.Writer) (err error) {
+
+	var chunk [][]byte
+	size := 0
+
+	// Runs are only set up once input turns out not to fit in one chunk
+	var runs *runWriter
+	defer func() {
+		if runs != nil {
+			if cleanErr := runs.cleanUp(); err == nil {
+				err = cleanErr
+			}
+		}
+	}()
+
+	for _, input := range inputs {
+		reader := bufio.NewReaderSize(input, 64*1024)
+
+		for {
+			line, readErr := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				line = bytes.TrimSuffix(line, []byte{'\n'})
+				chunk = append(chunk, line)
+				size += len(line) + lineOverhead
+			}
+
+			if size >= s.chunkSize {
+				if runs == nil {
+					if runs, err = s.newRunWriter(); err != nil {
+						return err
+					}
+				}
+				if err := runs.spill(chunk); err != nil {
+					return err
+				}
+				chunk, size = nil, 0
+			}
+
+			if readErr == io.EOF {
+				break
+			}
+			if readErr != nil {
+				return readErr
+			}
+		}
+	}
+
+	if runs == nil {
+		s.sortChunk(chunk, s.parallel)
+		w := bufio.NewWriter(out)
+		if err := s.writeLines(w, chunk); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	if len(chunk) > 0 {
+		if err := runs.spill(chunk); err != nil {
+			return err
+		}
+	}
+
+	paths, err := runs.wait()
+	if err != nil {
+		return err
+	}
+
+	// Merge in groups until few enough runs are left for one final merge.
+	// Each group is merged into a run in its place, keeping the runs in
+	// input order.
+	for len(paths) > mergeFanIn {
+		var merged []string
+
+		for start := 0; start < len(paths); start += mergeFanIn {
+			group := paths[start:min(start+mergeFanIn, len(paths))]
+			if len(group) == 1 {
+				merged = append(merged, group[0])
+				continue
+			}
+
+			path, err := s.mergeRuns(runs, group)
+			if err != nil {
+				return err
+			}
+			merged = append(merged, path)
+		}
+
+		paths = merged
+	}
+
+	w := bufio.NewWriter(out)
+	if err := s.merge(paths, w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// mergeRuns merges the runs at paths into a new run, returning its path.
+func (s *sorter) mergeRuns(runs *runWriter, paths []string) (string, error) {
+
+	merged, err := runs.create()
+	if err != nil {
+		return "", err
+	}
+
+	w := bufio.NewWriter(merged)
+	err = s.merge(paths, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := merged.Close(); err == nil {
+		err = closeErr
+	}
+	return merged.Name(), err
+}
+
+// compare orders lines for output. With -u only the keys are compared, so
+// that lines with equal keys keep their input order and the first of them
+// is the one printed, as GNU sort does.
+func (s *sorter) compare(a, b []byte) int {
+	if s.unique {
+		return s.order.compareKeys(a, b)
+	}
+	return s.order.compare(a, b)
+}
+
+// sortChunk sorts lines in place, keeping lines that compare equal in
+// their input order. Large chunks are split into up to parts pieces that
+// are sorted concurrently and then merged.
+func (s *sorter) sortChunk(lines [][]byte, parts int) {
+
+	if parts <= 1 || len(lines) < parallelSortMin {
+		slices.SortStableFunc(lines, s.compare)
+		return
+	}
+
+	width := (len(lines) + parts - 1) / parts
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(lines); start += width {
+		part := lines[start:min(start+width, len(lines))]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slices.SortStableFunc(part, s.compare)
+		}()
+	}
+	wg.Wait()
+
+	// Merge neighbouring sorted pieces, doubling their width each time
+	merged := make([][]byte, len(lines))
+	for ; width < len(lines); width *= 2 {
+		for start := 0; start < len(lines); start += 2 * width {
+			middle := min(start+width, len(lines))
+			end := min(start+2*width, len(lines))
+			s.mergeSlices(merged[start:start], lines[start:middle], lines[middle:end])
+		}
+		copy(lines, merged)
+	}
+}
+
+// mergeSlices appends the sorted lines of a and b to out in order.
+func (s *sorter) mergeSlices(out, a, b [][]byte) [][]byte {
+
+	for len(a) > 0 && len(b) > 0 {
+		if s.compare(b[0], a[0]) < 0 {
+			out, b = append(out, b[0]), b[1:]
+		} else {
+			out, a = append(out, a[0]), a[1:]
+		}
+	}
+	out = append(out, a...)
+	return append(out, b...)
+}
+
+// writeLines writes sorted lines to w, each followed by a newline, leaving
+// out lines whose keys repeat the previous one's with -u.
+func (s *sorter) writeLines(w *bufio.Writer, lines [][]byte) error {
+
+	var last []byte
+	for i, line := range lines {
+		if s.unique && i > 0 && s.order.compareKeys(last, line) == 0 {
+			continue
+		}
+		last = line
+
+		w.Write(line)
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runWriter sorts chunks and writes them to temporary files in the
+// background, holding at most parallel chunks at once.
+type runWriter struct {
+	s   *sorter
+	dir string
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	// paths holds the path of each run in the order of its chunk in the
+	// input, whichever is written first
+	mu    sync.Mutex
+	paths []string
+	err   error
+}
+
+func (s *sorter) newRunWriter() (*runWriter, error) {
+
+	dir, err := os.MkdirTemp(s.tempDir, "ccsort-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &runWriter{s: s, dir: dir, slots: make(chan struct{}, s.parallel)}, nil
+}
+
+// spill sorts lines and writes them out as a run, waiting first if parallel
+// chunks are already being sorted. It returns the error of any earlier run
+// that failed.
+func (r *runWriter) spill(lines [][]byte) error {
+
+	r.slots <- struct{}{}
+
+	r.mu.Lock()
+	err := r.err
+	index := len(r.paths)
+	r.paths = append(r.paths, "")
+	r.mu.Unlock()
+	if err != nil {
+		<-r.slots
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() { <-r.slots }()
+
+		path, err := r.write(lines)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err != nil && r.err == nil {
+			r.err = err
+		}
+		r.paths[index] = path
+	}()
+
+	return nil
+}
+
+func (r *runWriter) write(lines [][]byte) (string, error) {
+
+	// Chunks are already sorted in parallel with each other
+	r.s.sortChunk(lines, 1)
+
+	file, err := r.create()
+	if err != nil {
+		return "", err
+	}
+
+	w := bufio.NewWriter(file)
+	err = r.s.writeLines(w, lines)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return file.Name(), err
+}
+
+// create creates a new temporary file for a run.
+func (r *runWriter) create() (*os.File, error) {
+	return os.CreateTemp(r.dir, "run-")
+}
+
+// wait waits for the runs being written and returns the paths of all of
+// them.
+func (r *runWriter) wait() ([]string, error) {
+	r.wg.Wait()
+	return r.paths, r.err
+}
+
+// cleanUp removes the runs once any still being written are done.
+func (r *runWriter) cleanUp() error {
+	r.wg.Wait()
+	if err := os.RemoveAll(r.dir); err != nil {
+		return fmt.Errorf("removing temporary files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// key is a -k key definition: the fields from start to end, counting from
+// 1, where an end of 0 means to the end of the line. A key may carry its
+// own ordering options, which then replace the global ones for it.
+type key struct {
+	start, end int
+	numeric    bool
+	reverse    bool
+}
+
+// parseKey parses a -k value such as 2, 2,3 or 3n,3.
+func parseKey(value string) (key, error) {
+
+	startField, endField, hasEnd := strings.Cut(value, ",")
+
+	var k key
+	var err error
+
+	k.start, err = parseField(startField, &k)
+	if err != nil || k.start == 0 {
+		return key{}, fmt.Errorf("invalid key %q", value)
+	}
+	if hasEnd {
+		k.end, err = parseField(endField, &k)
+		if err != nil || k.end == 0 || k.end < k.start {
+			return key{}, fmt.Errorf("invalid key %q", value)
+		}
+	}
+
+	return k, nil
+}
+
+// parseField parses a field number followed by ordering letters, which it
+// sets on k.
+func parseField(field string, k *key) (int, error) {
+
+	digits := strings.TrimRight(field, "nr")
+	for _, option := range field[len(digits):] {
+		switch option {
+		case 'n':
+			k.numeric = true
+		case 'r':
+			k.reverse = true
+		}
+	}
+
+	return strconv.Atoi(digits)
+}
+
+// fields returns the fields from start to end of line, counting from 1.
+// Fields are separated by the empty string before runs of blanks, as sort
+// does by default, so each field but the first keeps its leading blanks.
+func fields(line []byte, start, end int) []byte {
+
+	from, to := -1, len(line)
+	field := 0
+
+	for i := 0; i <= len(line); {
+		// Each field is its leading blanks and the non-blanks after them
+		j := i
+		for j < len(line) && isBlank(line[j]) {
+			j++
+		}
+		for j < len(line) && !isBlank(line[j]) {
+			j++
+		}
+
+		field++
+		if field == start {
+			from = i
+		}
+		if end > 0 && field == end {
+			to = j
+			break
+		}
+
+		if j == len(line) {
+			break
+		}
+		i = j
+	}
+
+	if from < 0 {
+		return nil
+	}
+	return line[from:to]
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// numericPrefix parses the number at the start of s, after any blanks, as
+// sort -n does: an optional minus sign, then digits with at most one
+// decimal point. Text
+// that does not start with a number counts as zero.
+func numericPrefix(s []byte) float64 {
+
+	s = bytes.TrimLeft(s, " \t")
+
+	end := 0
+	if end < len(s) && s[end] == '-' {
+		end++
+	}
+	point := false
+	for ; end < len(s); end++ {
+		if s[end] == '.' && !point {
+			point = true
+		} else if s[end] < '0' || s[end] > '9' {
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(string(s[:end]), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+// Command ccsort sorts the lines of files, or of standard input, like sort.
+// Inputs larger than memory are sorted in chunks that are written to
+// temporary files and then merged.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"codechallenge/internal/cli"
+)
+
+// keyList collects the -k flags in the order given.
+type keyList []key
+
+func (k *keyList) String() string {
+	return ""
+}
+
+func (k *keyList) Set(value string) error {
+	parsed, err := parseKey(value)
+	if err != nil {
+		return err
+	}
+	*k = append(*k, parsed)
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccsort [flags] [file ...]")
+	fmt.Fprintln(out, "Write the sorted lines of all files to standard output, reading standard input when")
+	fmt.Fprintln(out, "there are none or the file is -.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccsort"
+
+	var s sorter
+	var keys keyList
+
+	flag.BoolVar(&s.order.numeric, "n", false, "compare by numeric value")
+	flag.BoolVar(&s.order.reverse, "r", false, "reverse the order")
+	flag.BoolVar(&s.unique, "u", false, "print only the first of lines with equal keys")
+	flag.Var(&keys, "k", "sort by the fields `start[,end]`, counting from 1, with n or r after a field to order that key by itself; may be repeated")
+	bufferSize := flag.String("S", "256M", "use about this much `memory` for lines before sorting in chunks on disk")
+	flag.StringVar(&s.tempDir, "T", os.TempDir(), "write temporary files to `dir`")
+	flag.IntVar(&s.parallel, "parallel", runtime.NumCPU(), "sort up to `N` chunks at once")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	s.order.keys = keys
+
+	size, err := cli.ParseCount(*bufferSize)
+	if err != nil || size == 0 {
+		cli.Exit(cli.Usagef("invalid buffer size %q", *bufferSize))
+	}
+	if s.parallel < 1 {
+		cli.Exit(cli.Usagef("-parallel must be at least 1"))
+	}
+
+	// Up to parallel chunks are held at once
+	s.chunkSize = int(max(size/int64(s.parallel), 1))
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{cli.Stdin}
+	}
+
+	// Nothing is sorted unless every input can be read
+	inputs := make([]io.Reader, len(paths))
+	for i, path := range paths {
+		file, closeFile, err := cli.Open(path)
+		if err != nil {
+			cli.Exit(&cli.FileError{File: path, Err: err})
+		}
+		defer closeFile()
+		inputs[i] = file
+	}
+
+	if err := s.sort(inputs, os.Stdout); err != nil {
+		cli.Report(err)
+		os.Exit(cli.ExitFailure)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"io"
+	"os"
+)
+
+// run is a sorted temporary file being merged, with the line it is at.
+type run struct {
+	reader *bufio.Reader
+	line   []byte
+
+	// index is the place of the run among those being merged, which are in
+	// input order
+	index int
+}
+
+// next reads the next line of the run, returning false at its end.
+func (r *run) next() (bool, error) {
+
+	line, err := r.reader.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return false, nil
+	}
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	r.line = bytes.TrimSuffix(line, []byte{'\n'})
+	return true, nil
+}
+
+// mergeHeap holds the runs being merged, ordered by their current lines,
+// and equal lines by the order of their runs, so the merge is stable.
+type mergeHeap struct {
+	runs    []*run
+	compare func(a, b []byte) int
+}
+
+func (h *mergeHeap) Len() int      { return len(h.runs) }
+func (h *mergeHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+func (h *mergeHeap) Push(x any)    { h.runs = append(h.runs, x.(*run)) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.compare(h.runs[i].line, h.runs[j].line); c != 0 {
+		return c < 0
+	}
+	return h.runs[i].index < h.runs[j].index
+}
+
+func (h *mergeHeap) Pop() any {
+	last := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return last
+}
+
+// merge merges the sorted runs at paths, which are in input order, into w,
+// leaving out lines whose keys repeat the previous one's with -u.
+func (s *sorter) merge(paths []string, w *bufio.Writer) error {
+
+	h := &mergeHeap{compare: s.compare}
+
+	for i, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		r := &run{reader: bufio.NewReaderSize(file, 64*1024), index: i}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.runs = append(h.runs, r)
+		}
+	}
+	heap.Init(h)
+
+	var last []byte
+	written := false
+
+	for h.Len() > 0 {
+		r := h.runs[0]
+
+		if !s.unique || !written || s.order.compareKeys(last, r.line) != 0 {
+			w.Write(r.line)
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+			last, written = r.line, true
+		}
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+)
+
+// order is how lines are compared: by the keys if there are any, or by the
+// whole line, numerically or as bytes, and possibly reversed.
+type order struct {
+	keys    []key
+	numeric bool
+	reverse bool
+}
+
+// compareKeys compares a and b by the keys alone, which is also what
+// decides whether two lines are duplicates for -u.
+func (o *order) compareKeys(a, b []byte) int {
+
+	if len(o.keys) == 0 {
+		return o.compareValues(a, b, o.numeric, o.reverse)
+	}
+
+	for _, k := range o.keys {
+		// A key with ordering options of its own ignores the global ones
+		numeric, reverse := o.numeric, o.reverse
+		if k.numeric || k.reverse {
+			numeric, reverse = k.numeric, k.reverse
+		}
+
+		c := o.compareValues(fields(a, k.start, k.end), fields(b, k.start, k.end), numeric, reverse)
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compare orders a and b completely: lines with equal keys are ordered by
+// their bytes as a last resort, as GNU sort does unless -u is given.
+func (o *order) compare(a, b []byte) int {
+
+	if c := o.compareKeys(a, b); c != 0 {
+		return c
+	}
+	if len(o.keys) == 0 && !o.numeric {
+		return 0
+	}
+	return o.compareValues(a, b, false, o.reverse)
+}
+
+func (o *order) compareValues(a, b []byte, numeric, reverse bool) int {
+
+	var c int
+	if numeric {
+		c = cmp.Compare(numericPrefix(a), numericPrefix(b))
+	} else {
+		c = bytes.Compare(a, b)
+	}
+
+	if reverse {
+		return -c
+	}
+	return c
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+
+	tests := map[string]key{
+		"2":     {start: 2},
+		"2,3":   {start: 2, end: 3},
+		"3n,3":  {start: 3, end: 3, numeric: true},
+		"1,1nr": {start: 1, end: 1, numeric: true, reverse: true},
+	}
+	for value, want := range tests {
+		if got, err := parseKey(value); err != nil || got != want {
+			t.Errorf("parseKey(%q) = %+v, %v, want %+v", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"", "0", "x", "3,2", "1,"} {
+		if _, err := parseKey(value); err == nil {
+			t.Errorf("parseKey(%q) succeeded", value)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+
+	line := []byte("a  bb\tc")
+
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{1, 0, "a  bb\tc"},
+		{1, 1, "a"},
+		{2, 2, "  bb"},
+		{2, 0, "  bb\tc"},
+		{3, 3, "\tc"},
+		{4, 0, ""},
+	}
+	for _, test := range tests {
+		if got := fields(line, test.start, test.end); string(got) != test.want {
+			t.Errorf("fields(%d, %d) = %q, want %q", test.start, test.end, got, test.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+
+	input := "10 b\n9 a\n-1.5 c\n10 a\nx d\n9 a\n"
+
+	tests := []struct {
+		order  order
+		unique bool
+		want   string
+	}{
+		{order{}, false, "-1.5 c\n10 a\n10 b\n9 a\n9 a\nx d\n"},
+		{order{reverse: true}, false, "x d\n9 a\n9 a\n10 b\n10 a\n-1.5 c\n"},
+		{order{numeric: true}, false, "-1.5 c\nx d\n9 a\n9 a\n10 a\n10 b\n"},
+		{order{keys: []key{{start: 2}}}, false, "10 a\n9 a\n9 a\n10 b\n-1.5 c\nx d\n"},
+
+		// With -u, the first of the lines with equal keys is kept, however
+		// their bytes compare, as in GNU sort
+		{order{numeric: true}, true, "-1.5 c\nx d\n9 a\n10 b\n"},
+		{order{numeric: true, reverse: true}, true, "10 b\n9 a\nx d\n-1.5 c\n"},
+		{order{keys: []key{{start: 2}}}, true, "9 a\n10 b\n-1.5 c\nx d\n"},
+		{order{keys: []key{{start: 2, end: 2, reverse: true}, {start: 1, end: 1, numeric: true}}}, false, "x d\n-1.5 c\n10 b\n9 a\n9 a\n10 a\n"},
+	}
+
+	for _, test := range tests {
+		s := &sorter{order: test.order, unique: test.unique, chunkSize: 1 << 20, parallel: 1}
+
+		var out bytes.Buffer
+		if err := s.sort([]io.Reader{strings.NewReader(input)}, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%+v unique=%v: got %q, want %q", test.order, test.unique, out.String(), test.want)
+		}
+	}
+}
+
+// TestExternalSort checks that sorting in many small chunks on disk, with
+// enough runs to need more than one round of merging, and sorting in
+// parallel pieces in memory both give the same output as a plain sort.
+func TestExternalSort(t *testing.T) {
+
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var input strings.Builder
+	var lines []string
+	for range 50000 {
+		line := fmt.Sprintf("%d %x", rng.IntN(1000), rng.Uint32())
+		input.WriteString(line + "\n")
+		lines = append(lines, line)
+	}
+
+	o := order{keys: []key{{start: 1, end: 1, numeric: true}}}
+	slices.SortFunc(lines, func(a, b string) int { return o.compare([]byte(a), []byte(b)) })
+	want := strings.Join(lines, "\n") + "\n"
+
+	tests := map[string]*sorter{
+		"external": {order: o, chunkSize: 4096, parallel: 4, tempDir: t.TempDir()},
+		"parallel": {order: o, chunkSize: 1 << 30, parallel: 4},
+	}
+
+	for name, s := range tests {
+		var out bytes.Buffer
+		if err := s.sort([]io.Reader{strings.NewReader(input.String())}, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != want {
+			t.Errorf("%s sort differs from a plain sort", name)
+		}
+	}
+
+	// With -u, the first line of each key is kept across chunks and rounds
+	// of merging
+	var first []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(input.String(), "\n"), "\n") {
+		number, _, _ := strings.Cut(line, " ")
+		if !seen[number] {
+			seen[number] = true
+			first = append(first, line)
+		}
+	}
+	slices.SortFunc(first, func(a, b string) int { return o.compareKeys([]byte(a), []byte(b)) })
+	wantUnique := strings.Join(first, "\n") + "\n"
+
+	unique := &sorter{order: o, unique: true, chunkSize: 4096, parallel: 4, tempDir: t.TempDir()}
+	var out bytes.Buffer
+	if err := unique.sort([]io.Reader{strings.NewReader(input.String())}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != wantUnique {
+		t.Errorf("external sort with -u kept other lines than the first of each key")
+	}
+
+	// Temporary files are removed
+	if entries, _ := os.ReadDir(tests["external"].tempDir); len(entries) > 0 {
+		t.Errorf("%d temporary files left behind", len(entries))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// filter is a Bloom filter: a set that can answer "definitely not in the
+// set" or "probably in the set" in a fixed number of bits, by setting
+// hashes bits for each item added.
+type filter struct {
+	bits   []uint64
+	size   uint64 // number of bits
+	hashes uint32
+
+	// items is how many items were added, and rate the false positive
+	// rate the filter was sized for; both are only informational
+	items uint64
+	rate  float64
+}
+
+// newFilter returns a filter sized to hold items with the given false
+// positive rate, which must be between 0 and 1. The optimal size is
+// -n ln p / (ln 2)² bits with (m/n) ln 2 hashes.
+func newFilter(items int, rate float64) *filter {
+
+	n := float64(max(items, 1))
+	size := uint64(math.Ceil(-n * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	size = max(size, 64)
+	hashes := uint32(max(1, math.Round(float64(size)/n*math.Ln2)))
+
+	return &filter{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+		rate:   rate,
+	}
+}
+
+// add adds word to the filter.
+func (f *filter) add(word string) {
+	h1, h2 := hashPair(word)
+	for i := range uint64(f.hashes) {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.items++
+}
+
+// mayContain reports whether word may have been added. It is never wrong
+// when it says no.
+func (f *filter) mayContain(word string) bool {
+	h1, h2 := hashPair(word)
+	for i := range uint64(f.hashes) {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// expectedRate returns the false positive rate to expect with the items
+// added so far: (1 - e^(-kn/m))^k.
+func (f *filter) expectedRate() float64 {
+	k, n, m := float64(f.hashes), float64(f.items), float64(f.size)
+	return math.Pow(1-math.Exp(-k*n/m), k)
+}
+
+// hashPair returns two hashes of word, from which the filter derives as
+// many as it needs as h1 + i·h2, which is as good as independent hashes
+// (Kirsch and Mitzenmacher). The second is made odd, since a zero would
+// set the same bit every time.
+func hashPair(word string) (uint64, uint64) {
+
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	h1 := h.Sum64()
+
+	// A splitmix64 finalizer decorrelates the second hash from the first
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ h2>>30) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ h2>>27) * 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+	return h1, h2 | 1
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// A filter file starts with a header, in little-endian order:
+//
+//	offset  size  field
+//	0       4     magic "CCBF"
+//	4       2     format version, currently 1
+//	6       2     reserved, zero
+//	8       4     number of hashes
+//	12      4     reserved, zero
+//	16      8     number of bits
+//	24      8     number of items added
+//	32      8     false positive rate sized for, as an IEEE 754 double
+//
+// The bits follow as 64-bit words, and a CRC-32 of everything before it
+// ends the file.
+const (
+	magic         = "CCBF"
+	formatVersion = 1
+	headerSize    = 40
+
+	// maxBits bounds the size a header may claim, so that a damaged file
+	// cannot make the reader allocate without limit
+	maxBits = 1 << 36
+)
+
+var (
+	errNotFilter = errors.New("not a Bloom filter file")
+	errCorrupt   = errors.New("Bloom filter file is damaged")
+)
+
+// writeFilter writes f in the file format.
+func writeFilter(out io.Writer, f *filter) error {
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(out, crc))
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint16(header[4:], formatVersion)
+	binary.LittleEndian.PutUint32(header[8:], f.hashes)
+	binary.LittleEndian.PutUint64(header[16:], f.size)
+	binary.LittleEndian.PutUint64(header[24:], f.items)
+	binary.LittleEndian.PutUint64(header[32:], math.Float64bits(f.rate))
+	w.Write(header)
+
+	var word [8]byte
+	for _, bits := range f.bits {
+		binary.LittleEndian.PutUint64(word[:], bits)
+		w.Write(word[:])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := out.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	return err
+}
+
+// readFilter reads a filter in the file format, checking its version and
+// checksum.
+func readFilter(input io.Reader) (*filter, error) {
+
+	crc := crc32.NewIEEE()
+	r := io.TeeReader(bufio.NewReader(input), crc)
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errNotFilter
+		}
+		return nil, err
+	}
+	if string(header[:4]) != magic {
+		return nil, errNotFilter
+	}
+	if version := binary.LittleEndian.Uint16(header[4:]); version != formatVersion {
+		return nil, fmt.Errorf("unsupported Bloom filter file version %d, expected %d", version, formatVersion)
+	}
+
+	f := &filter{
+		hashes: binary.LittleEndian.Uint32(header[8:]),
+		size:   binary.LittleEndian.Uint64(header[16:]),
+		items:  binary.LittleEndian.Uint64(header[24:]),
+		rate:   math.Float64frombits(binary.LittleEndian.Uint64(header[32:])),
+	}
+	if f.hashes == 0 || f.size == 0 || f.size > maxBits {
+		return nil, errCorrupt
+	}
+
+	data := make([]byte, (f.size+63)/64*8)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, truncated(err)
+	}
+	f.bits = make([]uint64, len(data)/8)
+	for i := range f.bits {
+		f.bits[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+
+	sum := crc.Sum32()
+	var trailer [4]byte
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return nil, truncated(err)
+	}
+	if binary.LittleEndian.Uint32(trailer[:]) != sum {
+		return nil, errCorrupt
+	}
+	return f, nil
+}
+
+// truncated reports the end of a file that stops short as damage.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return errCorrupt
+	}
+	return err
+}
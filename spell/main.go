@@ -0,0 +1,204 @@
+// Command ccspell checks spelling with a Bloom filter. With -build it
+// reads a dictionary of one word per line into a filter and saves it; then
+// it reads text and prints each word the filter has certainly never seen.
+// The filter takes a fraction of the dictionary's space, at the cost of
+// letting through a misspelling now and then, at a rate chosen with -p.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+	"codechallenge/internal/textutil"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccspell -build dictionary [flags]")
+	fmt.Fprintln(out, "       ccspell [flags] [file...]")
+	fmt.Fprintln(out, "Build a filter from a dictionary, or print the misspelled words of each file or standard input.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccspell"
+
+	build := flag.String("build", "", "build the filter from this `dictionary`, one word per line")
+	path := flag.String("f", "words.bf", "the filter `file` to build or check against")
+	rate := flag.Float64("p", 0.01, "with -build, size the filter for this false positive `rate`")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *build != "" {
+		if flag.NArg() > 0 {
+			cli.Exit(cli.Usagef("-build takes no file arguments"))
+		}
+		if !(*rate > 0 && *rate < 1) {
+			cli.Exit(cli.Usagef("-p must be between 0 and 1"))
+		}
+		if err := buildFilter(*build, *path, *rate, os.Stderr); err != nil {
+			cli.Exit(err)
+		}
+		return
+	}
+
+	f, err := loadFilter(*path)
+	if err != nil {
+		cli.Exit(err)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{cli.Stdin}
+	}
+
+	misspelled := false
+	seen := make(map[string]bool)
+	for _, name := range files {
+		found, err := checkFile(name, f, seen, os.Stdout)
+		misspelled = misspelled || found
+		if err != nil {
+			cli.Report(err)
+			os.Exit(cli.ExitFailure)
+		}
+	}
+	if misspelled {
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+// buildFilter reads the dictionary, sizes a filter for its distinct words
+// and rate, and saves it to path, reporting what it built to log. The
+// filter replaces any file at path only once it is complete.
+func buildFilter(dictionary, path string, rate float64, log io.Writer) error {
+
+	words, err := readDictionary(dictionary)
+	if err != nil {
+		return err
+	}
+
+	f := newFilter(len(words), rate)
+	for _, word := range words {
+		f.add(word)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".ccspell-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if err := writeFilter(temp, f); err != nil {
+		temp.Close()
+		return &cli.FileError{File: path, Err: err}
+	}
+	if err := temp.Chmod(0o644); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(log, "%s: %d words in %s: %d bits (%s), %d hashes, false positive rate %.4g\n",
+		cli.Name, f.items, path, f.size, streamio.FormatBytes(float64(len(f.bits)*8)), f.hashes, f.expectedRate())
+	return nil
+}
+
+// readDictionary returns the distinct words of a dictionary, one per line,
+// skipping blank lines.
+func readDictionary(name string) ([]string, error) {
+
+	file, closeFile, err := cli.Open(name)
+	if err != nil {
+		return nil, &cli.FileError{File: name, Err: err}
+	}
+	defer closeFile()
+
+	var words []string
+	seen := make(map[string]bool)
+	reader := textutil.NewLineReader(file, streamio.BufferSizeFor(file))
+	for {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			return words, nil
+		}
+		if err != nil {
+			return nil, &cli.FileError{File: name, Err: err}
+		}
+
+		word := normalize(strings.TrimSpace(string(line)))
+		if word != "" && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+}
+
+// loadFilter reads the filter file at path.
+func loadFilter(path string) (*filter, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: no such filter; build one with -build", path)
+		}
+		return nil, &cli.FileError{File: path, Err: err}
+	}
+	defer file.Close()
+
+	f, err := readFilter(file)
+	if err != nil {
+		return nil, &cli.FileError{File: path, Err: err}
+	}
+	return f, nil
+}
+
+// checkFile prints each word of the named file that is not in the filter,
+// unless seen already holds it, and reports whether it found any.
+func checkFile(name string, f *filter, seen map[string]bool, out io.Writer) (bool, error) {
+
+	file, closeFile, err := cli.Open(name)
+	if err != nil {
+		return false, &cli.FileError{File: name, Err: err}
+	}
+	defer closeFile()
+
+	found := false
+	reader := textutil.NewLineReader(file, streamio.BufferSizeFor(file))
+	for {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			return found, nil
+		}
+		if err != nil {
+			return found, &cli.FileError{File: name, Err: err}
+		}
+
+		eachWord(string(line), func(word string) {
+			key := normalize(word)
+			if f.mayContain(key) {
+				return
+			}
+			found = true
+			if !seen[key] {
+				seen[key] = true
+				fmt.Fprintln(out, word)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilterSizing(t *testing.T) {
+
+	tests := []struct {
+		items      int
+		rate       float64
+		wantSize   uint64
+		wantHashes uint32
+	}{
+		// The textbook figures: 9.59 bits and 7 hashes per item for 1%
+		{1000, 0.01, 9586, 7},
+		{1000, 0.001, 14378, 10},
+		{100000, 0.05, 623523, 4},
+		{0, 0.01, 64, 44},
+	}
+
+	for _, test := range tests {
+		f := newFilter(test.items, test.rate)
+		if f.size != test.wantSize || f.hashes != test.wantHashes {
+			t.Errorf("newFilter(%d, %g) = %d bits, %d hashes, want %d, %d",
+				test.items, test.rate, f.size, f.hashes, test.wantSize, test.wantHashes)
+		}
+		if got := uint64(len(f.bits)) * 64; got < f.size || got >= f.size+64 {
+			t.Errorf("newFilter(%d, %g) has %d bits of storage for %d", test.items, test.rate, got, f.size)
+		}
+	}
+}
+
+func TestFilterFalsePositives(t *testing.T) {
+
+	const items = 20000
+	for _, rate := range []float64{0.1, 0.01, 0.001} {
+		f := newFilter(items, rate)
+		for i := range items {
+			f.add(fmt.Sprintf("word%d", i))
+		}
+
+		// A Bloom filter never forgets
+		for i := range items {
+			if !f.mayContain(fmt.Sprintf("word%d", i)) {
+				t.Fatalf("rate %g: word%d was added but is missing", rate, i)
+			}
+		}
+
+		const trials = 200000
+		positives := 0
+		for i := range trials {
+			if f.mayContain(fmt.Sprintf("other%d", i)) {
+				positives++
+			}
+		}
+		got := float64(positives) / trials
+		if got > rate*1.5 {
+			t.Errorf("rate %g: measured false positive rate %g", rate, got)
+		}
+		if expected := f.expectedRate(); expected > rate*1.1 {
+			t.Errorf("rate %g: expected rate once full is %g", rate, expected)
+		}
+	}
+}
+
+func TestFilterFile(t *testing.T) {
+
+	f := newFilter(100, 0.01)
+	for _, word := range []string{"alpha", "beta", "gamma"} {
+		f.add(word)
+	}
+
+	var buf bytes.Buffer
+	if err := writeFilter(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if got, want := len(data), headerSize+len(f.bits)*8+4; got != want {
+		t.Errorf("file is %d bytes, want %d", got, want)
+	}
+
+	read, err := readFilter(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.size != f.size || read.hashes != f.hashes || read.items != 3 || read.rate != 0.01 || !slices.Equal(read.bits, f.bits) {
+		t.Errorf("read filter %+v, want %+v", read, f)
+	}
+	if !read.mayContain("beta") {
+		t.Error("read filter lost a word")
+	}
+
+	damage := func(fn func(b []byte) []byte) []byte {
+		return fn(bytes.Clone(data))
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"empty", nil, errNotFilter.Error()},
+		{"short header", data[:10], errNotFilter.Error()},
+		{"text", []byte(strings.Repeat("not a filter ", 10)), errNotFilter.Error()},
+		{"version", damage(func(b []byte) []byte { b[4] = 2; return b }), "unsupported Bloom filter file version 2, expected 1"},
+		{"flipped bit", damage(func(b []byte) []byte { b[headerSize+3] ^= 0x10; return b }), errCorrupt.Error()},
+		{"truncated", data[:len(data)-1], errCorrupt.Error()},
+		{"no hashes", damage(func(b []byte) []byte { clear(b[8:12]); return b }), errCorrupt.Error()},
+		{"huge", damage(func(b []byte) []byte { b[23] = 0xff; return b }), errCorrupt.Error()},
+	}
+	for _, test := range tests {
+		_, err := readFilter(bytes.NewReader(test.data))
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: error = %v, want %s", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestEachWord(t *testing.T) {
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"Hello, world!", []string{"Hello", "world"}},
+		{"don't 'quoted' dogs' l’été", []string{"don't", "quoted", "dogs", "l’été"}},
+		{"abc123def 42 -- x_y", []string{"abc", "def", "x", "y"}},
+		{"naïve café", []string{"naïve", "café"}},
+	}
+
+	for _, test := range tests {
+		var got []string
+		eachWord(test.line, func(word string) { got = append(got, word) })
+		if !slices.Equal(got, test.want) {
+			t.Errorf("eachWord(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestBuildAndCheck(t *testing.T) {
+
+	dir := t.TempDir()
+	dictionary := filepath.Join(dir, "dict.txt")
+	if err := os.WriteFile(dictionary, []byte("The\nquick\n\nbrown\nfox\n  jumps  \nthe\ndon't\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "words.bf")
+
+	var log bytes.Buffer
+	if err := buildFilter(dictionary, path, 0.0001, &log); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "6 words") {
+		t.Errorf("build log = %q, want 6 distinct words", log.String())
+	}
+
+	f, err := loadFilter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := filepath.Join(dir, "text.txt")
+	if err := os.WriteFile(text, []byte("The quikc brown fox\nDon't jumpz, quikc!\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	found, err := checkFile(text, f, make(map[string]bool), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || out.String() != "quikc\njumpz\n" {
+		t.Errorf("checkFile = %v, %q, want true and the two misspellings once each", found, out.String())
+	}
+
+	if _, err := loadFilter(dictionary); !errors.Is(err, errNotFilter) {
+		t.Errorf("loading a dictionary as a filter: error = %v, want %v", err, errNotFilter)
+	}
+}
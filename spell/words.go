@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalize returns the form words are stored and looked up in, so that
+// a capitalized word at the start of a sentence matches the dictionary.
+func normalize(word string) string {
+	return strings.ToLower(word)
+}
+
+// eachWord calls fn with each word of line: a run of letters, which may
+// hold apostrophes between letters, as in "don't". Anything else, such as
+// digits and punctuation, separates words.
+func eachWord(line string, fn func(word string)) {
+
+	start := -1
+	for i, r := range line {
+		inWord := unicode.IsLetter(r) || start >= 0 && isApostrophe(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			fn(trimApostrophes(line[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fn(trimApostrophes(line[start:]))
+	}
+}
+
+// trimApostrophes drops apostrophes at the end of a word, which close a
+// quote or mark a plural possessive rather than belonging to the word.
+func trimApostrophes(word string) string {
+	for {
+		r, size := utf8.DecodeLastRuneInString(word)
+		if !isApostrophe(r) {
+			return word
+		}
+		word = word[:len(word)-size]
+	}
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+// followed is a file whose new data is printed as it is appended.
+type followed struct {
+	path   string
+	file   *os.File
+	offset int64
+}
+
+// watcher waits for followed files to change.
+type watcher interface {
+	// Wait returns when any of the files may have changed, or when the
+	// polling interval has passed regardless
+	Wait(ctx context.Context) error
+	Close() error
+}
+
+// follow prints whatever is appended to files until ctx is done, starting
+// from each file's offset. A file that shrinks is taken to have been
+// truncated and is printed again from its start. When headers is set, a
+// header naming the file precedes output from a different file than the
+// one last printed, which is current.
+func follow(ctx context.Context, out *bufio.Writer, files []*followed, interval time.Duration, headers bool, current *followed) error {
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+
+	watch := newWatcher(paths, interval)
+	defer watch.Close()
+
+	for {
+		if err := watch.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		for _, f := range files {
+			info, err := f.file.Stat()
+			if err != nil {
+				return &cli.FileError{File: f.path, Err: err}
+			}
+
+			size := info.Size()
+			if size < f.offset {
+				cli.Warn("%s: file truncated", f.path)
+				f.offset = 0
+			}
+			if size == f.offset {
+				continue
+			}
+
+			if headers && f != current {
+				fmt.Fprintf(out, "\n==> %s <==\n", f.path)
+				current = f
+			}
+
+			if err := copyRange(out, f.file, f.offset, size); err != nil {
+				return &cli.FileError{File: f.path, Err: err}
+			}
+			f.offset = size
+		}
+
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// pollWatcher checks for changes every interval, for systems and files
+// that cannot report them.
+type pollWatcher struct {
+	interval time.Duration
+}
+
+func (w *pollWatcher) Wait(ctx context.Context) error {
+
+	timer := time.NewTimer(w.interval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (w *pollWatcher) Close() error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// inotifyWatcher waits for the kernel to report that a file has been
+// written to, truncated, moved or deleted, checking at least every interval
+// in case a change goes unreported, as on some network filesystems.
+type inotifyWatcher struct {
+	events   *os.File
+	interval time.Duration
+	buffer   []byte
+}
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVE_SELF | syscall.IN_DELETE_SELF
+
+// newWatcher watches paths with inotify, falling back to polling if that is
+// not available.
+func newWatcher(paths []string, interval time.Duration) watcher {
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return &pollWatcher{interval: interval}
+	}
+
+	for _, path := range paths {
+		if _, err := syscall.InotifyAddWatch(fd, path, inotifyMask); err != nil {
+			syscall.Close(fd)
+			return &pollWatcher{interval: interval}
+		}
+	}
+
+	// A non-blocking descriptor is read through the runtime's poller, so
+	// reads can be given a deadline
+	return &inotifyWatcher{
+		events:   os.NewFile(uintptr(fd), "inotify"),
+		interval: interval,
+		buffer:   make([]byte, 4096),
+	}
+}
+
+func (w *inotifyWatcher) Wait(ctx context.Context) error {
+
+	deadline := time.Now().Add(w.interval)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	w.events.SetReadDeadline(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		w.events.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	// Which file changed and how does not matter, since every file is
+	// checked afterwards
+	_, err := w.events.Read(w.buffer)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.events.Close()
+}
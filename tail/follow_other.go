@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// newWatcher polls paths every interval, since change notification is only
+// implemented for Linux.
+func newWatcher(paths []string, interval time.Duration) watcher {
+	return &pollWatcher{interval: interval}
+}
@@ -0,0 +1,146 @@
+// Command cctail prints the last lines or bytes of each file, or of
+// standard input, like tail, and can follow files as they grow.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cctail [flags] [file ...]")
+	fmt.Fprintln(out, "Print the last 10 lines of each file, or of standard input when there are none")
+	fmt.Fprintln(out, "or the file is -.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cctail"
+
+	lines := flag.String("n", "10", "print the last `N` lines, or those from line N on with +N")
+	bytes := flag.String("c", "", "print the last `N` bytes, or those from byte N on with +N")
+	followFiles := flag.Bool("f", false, "keep printing data as it is appended to the files")
+	interval := flag.Duration("s", time.Second, "with -f, check for changes at least this often")
+	quiet := flag.Bool("q", false, "never print headers giving file names")
+	verbose := flag.Bool("v", false, "always print headers giving file names")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	count := *lines
+	copyTail := tailLines
+	if *bytes != "" {
+		count, copyTail = *bytes, tailBytes
+	}
+
+	at, err := parseOffset(count)
+	if err != nil {
+		cli.Exit(cli.Usagef("invalid count %q: %v", count, err))
+	}
+	if *interval <= 0 {
+		cli.Exit(cli.Usagef("-s must be positive"))
+	}
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{cli.Stdin}
+	}
+
+	headers := *verbose || (len(paths) > 1 && !*quiet)
+
+	out := bufio.NewWriter(os.Stdout)
+	ok := true
+
+	var files []*followed
+	for i, path := range paths {
+		if headers {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "==> %s <==\n", cli.DisplayName(path))
+		}
+
+		f, err := tail(out, path, at, copyTail, *followFiles)
+		if err != nil {
+			cli.Report(err)
+			ok = false
+		}
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		cli.Exit(err)
+	}
+
+	if len(files) > 0 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := follow(ctx, out, files, *interval, headers, files[len(files)-1]); err != nil {
+			cli.Report(err)
+			ok = false
+		}
+	}
+
+	if !ok {
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+// tail prints the end of path selected by at. With followFile, a regular
+// file is left open and returned, ready to be followed from where printing
+// stopped.
+func tail(out io.Writer, path string, at offset, copyTail func(io.Writer, io.Reader, offset) error, followFile bool) (*followed, error) {
+
+	file, closeFile, err := cli.Open(path)
+	if err != nil {
+		return nil, &cli.FileError{File: path, Err: err}
+	}
+
+	if err := copyTail(out, file, at); err != nil {
+		closeFile()
+		return nil, &cli.FileError{File: path, Err: err}
+	}
+
+	// Only regular files can be followed; what is appended to a pipe has
+	// already been printed
+	if followFile {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			if position, err := file.Seek(0, io.SeekCurrent); err == nil {
+				return &followed{path: cli.DisplayName(path), file: file, offset: position}, nil
+			}
+		}
+	}
+
+	closeFile()
+	return nil, nil
+}
+
+// parseOffset parses the value of -n or -c, where a leading + counts from
+// the start rather than the end.
+func parseOffset(value string) (offset, error) {
+
+	// A leading - is accepted, and ignored, for compatibility
+	rest, fromStart := strings.CutPrefix(value, "+")
+	if !fromStart {
+		rest = strings.TrimPrefix(rest, "-")
+	}
+
+	n, err := cli.ParseCount(rest)
+	return offset{n: n, fromStart: fromStart}, err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+)
+
+// reverseBlockSize is how much of a file is read at a time when scanning
+// backwards from its end for the start of the last lines.
+const reverseBlockSize = 64 * 1024
+
+// offset is the value of -n or -c: either the last N lines or bytes, or,
+// written +N, everything from the Nth one on.
+type offset struct {
+	n         int64
+	fromStart bool
+}
+
+// tailLines copies the lines of input selected by at to out. Inputs that can
+// be read at any position are scanned backwards from the end, so only the
+// lines printed are read; other inputs are read through, holding back the
+// most recent lines.
+func tailLines(out io.Writer, input io.Reader, at offset) error {
+
+	if at.fromStart {
+		return copyFromLine(out, input, at.n)
+	}
+
+	if file, start, size, ok := seekable(input); ok {
+		from, err := lastLinesStart(file, start, size, at.n)
+		if err != nil {
+			return err
+		}
+		return copyRange(out, file, from, size)
+	}
+
+	return lastLines(out, input, at.n)
+}
+
+// tailBytes copies the bytes of input selected by at to out.
+func tailBytes(out io.Writer, input io.Reader, at offset) error {
+
+	if at.fromStart {
+		if at.n > 1 {
+			if _, err := io.CopyN(io.Discard, input, at.n-1); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+		_, err := io.Copy(out, input)
+		return err
+	}
+
+	if file, start, size, ok := seekable(input); ok {
+		return copyRange(out, file, max(size-at.n, start), size)
+	}
+
+	return lastBytes(out, input, at.n)
+}
+
+// randomAccess is an input that can be read at any position.
+type randomAccess interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+// seekable reports whether input can be read backwards, and if so the range
+// of it that is left to read. Only regular files qualify among open files,
+// since devices and pipes may seek without having a meaningful end.
+func seekable(input io.Reader) (file randomAccess, start, size int64, ok bool) {
+
+	if f, isFile := input.(*os.File); isFile {
+		info, err := f.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, 0, 0, false
+		}
+	}
+
+	file, ok = input.(randomAccess)
+	if !ok {
+		return nil, 0, 0, false
+	}
+
+	start, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	size, err = file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+
+	return file, start, size, true
+}
+
+// lastLinesStart scans file backwards from size, a block at a time, and
+// returns the offset of the first of its last n lines, or start if it has
+// no more than n lines after start.
+func lastLinesStart(file io.ReaderAt, start, size int64, n int64) (int64, error) {
+
+	if n == 0 {
+		return size, nil
+	}
+
+	block := make([]byte, reverseBlockSize)
+	newlines := int64(0)
+
+	for end := size; end > start; {
+		from := max(end-reverseBlockSize, start)
+		chunk := block[:end-from]
+
+		if _, err := file.ReadAt(chunk, from); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for rest := chunk; ; {
+			i := bytes.LastIndexByte(rest, '\n')
+			if i < 0 {
+				break
+			}
+			rest = rest[:i]
+
+			// The newline at the very end finishes the last line rather
+			// than separating it from the next
+			if from+int64(i) == size-1 {
+				continue
+			}
+
+			newlines++
+			if newlines == n {
+				return from + int64(i) + 1, nil
+			}
+		}
+
+		end = from
+	}
+
+	return start, nil
+}
+
+// copyRange copies the bytes of file from from up to to to out.
+func copyRange(out io.Writer, file io.ReaderAt, from, to int64) error {
+	_, err := io.Copy(out, io.NewSectionReader(file, from, to-from))
+	return err
+}
+
+// copyFromLine copies input to out starting at line n, counting from 1.
+func copyFromLine(out io.Writer, input io.Reader, n int64) error {
+
+	reader := bufio.NewReader(input)
+
+	for line := int64(1); line < n; {
+		_, err := reader.ReadSlice('\n')
+
+		switch err {
+		case nil:
+			line++
+		case bufio.ErrBufferFull:
+			// The rest of a long line follows
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	_, err := io.Copy(out, reader)
+	return err
+}
+
+// lastLines copies the last n lines of input to out, reading all of it and
+// holding only the most recent n lines.
+func lastLines(out io.Writer, input io.Reader, n int64) error {
+
+	reader := bufio.NewReader(input)
+	var held [][]byte
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && n > 0 {
+			if int64(len(held)) == n {
+				held = held[1:]
+			}
+			held = append(held, line)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, line := range held {
+		if _, err := out.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastBytes copies the last n bytes of input to out, reading all of it and
+// holding only the most recent n bytes.
+func lastBytes(out io.Writer, input io.Reader, n int64) error {
+
+	var held bytes.Buffer
+	chunk := make([]byte, 64*1024)
+
+	for {
+		read, err := input.Read(chunk)
+		held.Write(chunk[:read])
+
+		if excess := int64(held.Len()) - n; excess > 0 {
+			held.Next(int(excess))
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := out.Write(held.Bytes())
+	return err
+}
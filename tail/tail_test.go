@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stream hides the Seek and ReadAt methods of a reader, so it is read like
+// a pipe.
+type stream struct {
+	io.Reader
+}
+
+func TestTail(t *testing.T) {
+
+	input := "one\ntwo\nthree\nfour\n"
+
+	tests := []struct {
+		copyTail func(io.Writer, io.Reader, offset) error
+		at       offset
+		want     string
+	}{
+		{tailLines, offset{n: 2}, "three\nfour\n"},
+		{tailLines, offset{n: 10}, input},
+		{tailLines, offset{n: 0}, ""},
+		{tailLines, offset{n: 2, fromStart: true}, "two\nthree\nfour\n"},
+		{tailLines, offset{n: 0, fromStart: true}, input},
+		{tailLines, offset{n: 9, fromStart: true}, ""},
+		{tailBytes, offset{n: 5}, "four\n"},
+		{tailBytes, offset{n: 100}, input},
+		{tailBytes, offset{n: 15, fromStart: true}, "four\n"},
+	}
+
+	for i, test := range tests {
+		for _, seekable := range []bool{true, false} {
+			var input io.Reader = strings.NewReader(input)
+			if !seekable {
+				input = stream{input}
+			}
+
+			var out bytes.Buffer
+			if err := test.copyTail(&out, input, test.at); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Errorf("%d: %+v seekable=%v got %q, want %q", i, test.at, seekable, out.String(), test.want)
+			}
+		}
+	}
+}
+
+func TestTailUnterminated(t *testing.T) {
+
+	var out bytes.Buffer
+	if err := tailLines(&out, strings.NewReader("a\nb\nc"), offset{n: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "b\nc" {
+		t.Errorf("got %q, want %q", out.String(), "b\nc")
+	}
+}
+
+// TestTailReverse checks the backwards scan across many blocks, against
+// reading the whole input through.
+func TestTailReverse(t *testing.T) {
+
+	var input strings.Builder
+	for i := 0; input.Len() < 5*reverseBlockSize; i++ {
+		input.WriteString(strings.Repeat("x", i%200))
+		input.WriteByte('\n')
+	}
+
+	for _, n := range []int64{1, 100, 1000, 4000, 1 << 20} {
+		var reverse, through bytes.Buffer
+
+		if err := tailLines(&reverse, strings.NewReader(input.String()), offset{n: n}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tailLines(&through, stream{strings.NewReader(input.String())}, offset{n: n}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(reverse.Bytes(), through.Bytes()) {
+			t.Errorf("n=%d: reverse scan printed %d bytes, reading through %d", n, reverse.Len(), through.Len())
+		}
+	}
+}
+
+// lockedBuffer is a buffer that follow can write to while the test reads.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollow(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(path, []byte("first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var out lockedBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		f := &followed{path: path, file: file, offset: 6}
+		done <- follow(ctx, bufio.NewWriter(&out), []*followed{f}, 50*time.Millisecond, false, f)
+	}()
+
+	writer, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteString("second\n")
+	writer.Close()
+
+	waitFor(t, &out, "second\n")
+
+	// Truncating starts the file over
+	if err := os.WriteFile(path, []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, &out, "second\nnew\n")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, out *lockedBuffer, want string) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if out.String() == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("followed output is %q, want %q", out.String(), want)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// writer writes an archive as a stream: a header, then exactly the size
+// it gives of content, for each entry, then the end-of-archive marker.
+type writer struct {
+	out io.Writer
+
+	// remaining is how much content the current entry still needs
+	remaining int64
+
+	// pad is how many bytes of padding follow the current entry's content
+	pad int64
+
+	// written counts what has been written, to pad the archive out to
+	// a whole record
+	written int64
+}
+
+func (w *writer) write(data []byte) error {
+	n, err := w.out.Write(data)
+	w.written += int64(n)
+	return err
+}
+
+// writeHeader starts an entry, finishing the last one.
+func (w *writer) writeHeader(h *header) error {
+
+	if w.remaining > 0 {
+		return fmt.Errorf("entry is %d bytes short", w.remaining)
+	}
+	if err := w.write(make([]byte, w.pad)); err != nil {
+		return err
+	}
+
+	block, err := h.marshal()
+	if err != nil {
+		return err
+	}
+	if err := w.write(block); err != nil {
+		return err
+	}
+
+	w.remaining = h.size
+	w.pad = (blockSize - h.size%blockSize) % blockSize
+	return nil
+}
+
+var errTooLong = errors.New("more content than the header says")
+
+// Write writes content of the current entry.
+func (w *writer) Write(data []byte) (int, error) {
+
+	if int64(len(data)) > w.remaining {
+		return 0, errTooLong
+	}
+	n, err := w.out.Write(data)
+	w.remaining -= int64(n)
+	w.written += int64(n)
+	return n, err
+}
+
+// close finishes the archive with two zero blocks, padded to a whole
+// record.
+func (w *writer) close() error {
+
+	if w.remaining > 0 {
+		return fmt.Errorf("entry is %d bytes short", w.remaining)
+	}
+	if err := w.write(make([]byte, w.pad)); err != nil {
+		return err
+	}
+	w.pad = 0
+
+	end := 2 * blockSize
+	if rest := (w.written + int64(end)) % recordSize; rest != 0 {
+		end += int(recordSize - rest)
+	}
+	return w.write(make([]byte, end))
+}
+
+// reader reads an archive as a stream, an entry at a time.
+type reader struct {
+	in io.Reader
+
+	// remaining is how much of the current entry's content is unread
+	remaining int64
+	pad       int64
+
+	// started is set once a header has been read
+	started bool
+}
+
+// next skips what is left of the current entry and reads the header of
+// the next. At the end of the archive, it returns io.EOF.
+func (r *reader) next() (*header, error) {
+
+	if _, err := io.CopyN(io.Discard, r.in, r.remaining+r.pad); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	r.remaining, r.pad = 0, 0
+
+	block := make([]byte, blockSize)
+	if _, err := io.ReadFull(r.in, block); err != nil {
+		if err == io.EOF && !r.started {
+			return nil, errNotArchive
+		}
+		return nil, unexpectedEOF(err)
+	}
+
+	// A zero block marks the end; a second should follow, but archives
+	// cut short after the first are common enough to accept
+	if isZero(block) {
+		return nil, io.EOF
+	}
+
+	h, err := parseHeader(block)
+	if err != nil {
+		return nil, err
+	}
+	r.started = true
+
+	// Links and directories have no content, whatever their size says
+	if h.typeflag != typeHardLink && h.typeflag != typeSymlink && h.typeflag != typeDir {
+		r.remaining = h.size
+		r.pad = (blockSize - h.size%blockSize) % blockSize
+	}
+	return h, nil
+}
+
+// Read reads content of the current entry.
+func (r *reader) Read(buffer []byte) (int, error) {
+
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(buffer)) > r.remaining {
+		buffer = buffer[:r.remaining]
+	}
+	n, err := r.in.Read(buffer)
+	r.remaining -= int64(n)
+	if err == io.EOF && r.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("unexpected end of archive")
+	}
+	return err
+}
+
+func isZero(block []byte) bool {
+	for _, b := range block {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// creator adds files to an archive.
+type creator struct {
+	w *writer
+
+	// skip is the archive being written, when it is a file, so that
+	// archiving the directory it is in does not take it in too
+	skip os.FileInfo
+
+	// verbose, when set, is told the name of each entry added
+	verbose func(name string)
+
+	// warn reports a file that could not be added; creating carries on
+	warn func(err error)
+}
+
+// add adds the file at path, and everything under it if it is a
+// directory. Leading slashes are dropped from entry names, so the archive
+// always extracts under the current directory.
+func (c *creator) add(path string) error {
+
+	return filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			c.warn(err)
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			c.warn(err)
+			return nil
+		}
+		if c.skip != nil && os.SameFile(info, c.skip) {
+			return nil
+		}
+
+		if err := c.addFile(name, info); err != nil {
+			var archiveErr *writeError
+			if errors.As(err, &archiveErr) {
+				return err
+			}
+			c.warn(fmt.Errorf("%s: %v", name, err))
+		}
+		return nil
+	})
+}
+
+// writeError is a failure to write the archive, which unlike a problem
+// with one file, stops it being created.
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *writeError) Unwrap() error {
+	return e.err
+}
+
+func (c *creator) addFile(path string, info os.FileInfo) error {
+
+	name := filepath.ToSlash(path)
+	name = strings.TrimLeft(name, "/")
+	if name == "" || name == "." {
+		if !info.IsDir() {
+			return fmt.Errorf("cannot archive a file with no name")
+		}
+		// The root of a walk from "." or "/" has nothing to name
+		return nil
+	}
+
+	h := &header{
+		name:    name,
+		mode:    int64(info.Mode().Perm()),
+		modTime: info.ModTime(),
+	}
+	h.uid, h.gid, h.uname, h.gname = owner(info)
+
+	switch {
+	case info.Mode().IsRegular():
+		h.typeflag = typeRegular
+		h.size = info.Size()
+	case info.IsDir():
+		h.typeflag = typeDir
+		h.name += "/"
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		h.typeflag = typeSymlink
+		h.linkname = target
+	default:
+		return fmt.Errorf("skipping %v, which is not a regular file, directory or symbolic link", info.Mode().Type())
+	}
+
+	if info.Mode()&os.ModeSetuid != 0 {
+		h.mode |= 0o4000
+	}
+	if info.Mode()&os.ModeSetgid != 0 {
+		h.mode |= 0o2000
+	}
+	if info.Mode()&os.ModeSticky != 0 {
+		h.mode |= 0o1000
+	}
+
+	// Open before writing the header, so a file that cannot be read is
+	// left out rather than leaving a header without its content
+	var file *os.File
+	if h.typeflag == typeRegular {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	if _, _, err := splitName(h.name); err != nil {
+		return err
+	}
+	if err := c.w.writeHeader(h); err != nil {
+		return &writeError{err}
+	}
+	if c.verbose != nil {
+		c.verbose(h.name)
+	}
+
+	if file != nil {
+		archive := archiveWriter{c.w}
+
+		// Copy exactly the size in the header, padding with zeros if the
+		// file shrank or could not be read to the end, since the header
+		// is already out
+		n, readErr := io.CopyN(archive, file, h.size)
+		var archiveErr *writeError
+		if errors.As(readErr, &archiveErr) {
+			return archiveErr
+		}
+		if n < h.size {
+			if _, err := io.CopyN(archive, zeros{}, h.size-n); err != nil {
+				return err
+			}
+			if readErr == io.EOF {
+				return fmt.Errorf("file shrank by %d bytes while being read", h.size-n)
+			}
+			return readErr
+		}
+	}
+	return nil
+}
+
+// archiveWriter tags the errors of writing content to the archive, to
+// tell them from errors reading the file being added.
+type archiveWriter struct {
+	w *writer
+}
+
+func (a archiveWriter) Write(data []byte) (int, error) {
+	n, err := a.w.Write(data)
+	if err != nil {
+		err = &writeError{err}
+	}
+	return n, err
+}
+
+type zeros struct{}
+
+func (zeros) Read(buffer []byte) (int, error) {
+	clear(buffer)
+	return len(buffer), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// extractor writes the entries of an archive under a directory. Entries
+// that would land outside it, through .. in their names or through
+// symbolic links, are refused.
+type extractor struct {
+	dest string
+
+	// verbose, when set, is told the name of each entry extracted
+	verbose func(name string)
+
+	// warn reports an entry that could not be extracted; extracting
+	// carries on with the next
+	warn func(err error)
+
+	// dirs are the directories extracted, whose modes and times are set
+	// at the end, since extracting into them changes their times and a
+	// read-only one could not be filled
+	dirs []extractedDir
+
+	warnedAbsolute bool
+}
+
+type extractedDir struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// extract extracts each entry of r that selected accepts.
+func (e *extractor) extract(r *reader, selected func(name string) bool) error {
+
+	for {
+		h, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !selected(h.name) {
+			continue
+		}
+
+		if err := e.entry(r, h); err != nil {
+			var archiveErr *readError
+			if errors.As(err, &archiveErr) {
+				return archiveErr.err
+			}
+			e.warn(fmt.Errorf("%s: %v", h.name, err))
+			continue
+		}
+		if e.verbose != nil {
+			e.verbose(h.name)
+		}
+	}
+
+	// Innermost first, so setting a parent's time comes last
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		d := e.dirs[i]
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			e.warn(err)
+		}
+		os.Chtimes(d.path, d.modTime, d.modTime)
+	}
+	return nil
+}
+
+// readError is a failure to read the archive, which unlike a problem with
+// one entry, stops extraction.
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string {
+	return e.err.Error()
+}
+
+func (e *extractor) entry(r *reader, h *header) error {
+
+	name, err := e.localName(h.name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return nil
+	}
+	path := filepath.Join(e.dest, name)
+
+	if err := e.checkParents(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	mode := fs.FileMode(h.mode & 0o777)
+	if h.mode&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if h.mode&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if h.mode&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	switch h.typeflag {
+	case typeDir:
+		if err := os.Mkdir(path, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		if info, err := os.Lstat(path); err != nil || !info.IsDir() {
+			return fmt.Errorf("cannot replace a file with a directory")
+		}
+		e.dirs = append(e.dirs, extractedDir{path: path, mode: mode, modTime: h.modTime})
+		return nil
+
+	case typeRegular, typeRegularV7:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, &contentReader{r}); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Chmod(mode); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(path, h.modTime, h.modTime)
+
+	case typeSymlink:
+		if err := checkLinkTarget(name, h.linkname); err != nil {
+			return err
+		}
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		return os.Symlink(h.linkname, path)
+
+	case typeHardLink:
+		target, err := e.localName(h.linkname)
+		if err != nil {
+			return fmt.Errorf("link target: %v", err)
+		}
+		if err := e.checkParents(target); err != nil {
+			return fmt.Errorf("link target: %v", err)
+		}
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		return os.Link(filepath.Join(e.dest, target), path)
+	}
+
+	return fmt.Errorf("unsupported entry type %q", h.typeflag)
+}
+
+// contentReader tags errors reading an entry's content as errors reading
+// the archive, to tell them from errors writing the file.
+type contentReader struct {
+	r *reader
+}
+
+func (c *contentReader) Read(buffer []byte) (int, error) {
+	n, err := c.r.Read(buffer)
+	if err != nil && err != io.EOF {
+		err = &readError{unexpectedEOF(err)}
+	}
+	return n, err
+}
+
+// localName turns an entry name into a relative path under the
+// destination, dropping leading slashes, as tar does, and refusing names
+// that climb out with "..".
+func (e *extractor) localName(name string) (string, error) {
+
+	if trimmed := strings.TrimLeft(name, "/"); trimmed != name {
+		if !e.warnedAbsolute {
+			e.warn(errors.New("removing leading '/' from member names"))
+			e.warnedAbsolute = true
+		}
+		name = trimmed
+	}
+
+	local := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(local) && local != "." {
+		return "", errors.New("refusing to extract outside the destination")
+	}
+	return local, nil
+}
+
+// checkParents refuses to extract name through a symbolic link, which an
+// earlier entry of the archive could have planted to point anywhere.
+func (e *extractor) checkParents(name string) error {
+
+	dir := e.dest
+	parts := strings.Split(filepath.Dir(name), string(filepath.Separator))
+	for _, part := range parts {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to extract through symbolic link %s", dir)
+		}
+	}
+	return nil
+}
+
+// checkLinkTarget refuses symbolic links that point outside the
+// destination, absolutely or by climbing out of it.
+func checkLinkTarget(name, target string) error {
+
+	if target == "" || filepath.IsAbs(target) {
+		return fmt.Errorf("refusing symbolic link to %q outside the destination", target)
+	}
+	resolved := filepath.Join(filepath.Dir(name), filepath.FromSlash(target))
+	if !filepath.IsLocal(resolved) && resolved != "." {
+		return fmt.Errorf("refusing symbolic link to %q outside the destination", target)
+	}
+	return nil
+}
+
+// removeExisting removes what is at path, other than a directory, so that
+// extracting replaces it rather than writing through it if it is a link.
+func removeExisting(path string) error {
+
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot replace a directory with a file")
+	}
+	return os.Remove(path)
+}
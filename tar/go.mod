@@ -0,0 +1,9 @@
+module codechallenge/tar
+
+go 1.23.2
+
+require codechallenge/internal v0.0.0
+
+require github.com/klauspost/compress v1.17.11 // indirect
+
+replace codechallenge/internal => ../internal
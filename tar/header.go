@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blockSize is the unit a tar archive is made of: each header is one
+// block, and each file's content is padded to a whole number of them.
+const blockSize = 512
+
+// recordSize is what the archive as a whole is padded to, twenty blocks,
+// as tar has always written it.
+const recordSize = 20 * blockSize
+
+// Entry types.
+const (
+	typeRegular   = '0'
+	typeRegularV7 = '\x00'
+	typeHardLink  = '1'
+	typeSymlink   = '2'
+	typeDir       = '5'
+)
+
+// Offsets and lengths of the fields of a ustar header.
+const (
+	nameOffset, nameLength         = 0, 100
+	modeOffset, modeLength         = 100, 8
+	uidOffset, uidLength           = 108, 8
+	gidOffset, gidLength           = 116, 8
+	sizeOffset, sizeLength         = 124, 12
+	mtimeOffset, mtimeLength       = 136, 12
+	checksumOffset, checksumLength = 148, 8
+	typeOffset                     = 156
+	linkOffset, linkLength         = 157, 100
+	magicOffset, magicLength       = 257, 8
+	unameOffset, unameLength       = 265, 32
+	gnameOffset, gnameLength       = 297, 32
+	prefixOffset, prefixLength     = 345, 155
+)
+
+// magic is the ustar magic and version. GNU tar writes "ustar  \x00"
+// instead, which is read too.
+var (
+	magicUstar = []byte("ustar\x0000")
+	magicGNU   = []byte("ustar  \x00")
+)
+
+// header describes one entry of an archive.
+type header struct {
+	name     string
+	mode     int64
+	uid, gid int
+	size     int64
+	modTime  time.Time
+	typeflag byte
+	linkname string
+	uname    string
+	gname    string
+}
+
+var errNotArchive = errors.New("does not look like a tar archive")
+
+// marshal encodes h as a ustar header block. Names that do not fit the
+// format, which holds up to 255 bytes split at a slash, are an error.
+func (h *header) marshal() ([]byte, error) {
+
+	block := make([]byte, blockSize)
+
+	prefix, name, err := splitName(h.name)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.linkname) > linkLength {
+		return nil, fmt.Errorf("link target is longer than %d bytes", linkLength)
+	}
+
+	copy(block[nameOffset:], name)
+	copy(block[prefixOffset:], prefix)
+	copy(block[linkOffset:], h.linkname)
+	copy(block[unameOffset:unameOffset+unameLength-1], h.uname)
+	copy(block[gnameOffset:gnameOffset+gnameLength-1], h.gname)
+	copy(block[magicOffset:], magicUstar)
+	block[typeOffset] = h.typeflag
+
+	fields := []struct {
+		value          int64
+		offset, length int
+		what           string
+	}{
+		{h.mode & 0o7777, modeOffset, modeLength, "mode"},
+		{int64(h.uid), uidOffset, uidLength, "user ID"},
+		{int64(h.gid), gidOffset, gidLength, "group ID"},
+		{h.size, sizeOffset, sizeLength, "size"},
+		{h.modTime.Unix(), mtimeOffset, mtimeLength, "modification time"},
+	}
+	for _, f := range fields {
+		if err := putOctal(block[f.offset:f.offset+f.length], f.value); err != nil {
+			return nil, fmt.Errorf("%s %v", f.what, err)
+		}
+	}
+
+	putChecksum(block)
+	return block, nil
+}
+
+// splitName splits a name too long for the name field at a slash, putting
+// the start in the prefix field.
+func splitName(full string) (prefix, name string, err error) {
+
+	if len(full) <= nameLength {
+		return "", full, nil
+	}
+
+	// The last slash that leaves a prefix and a name that both fit
+	for i := min(len(full)-1, prefixLength); i > 0; i-- {
+		if full[i] == '/' && len(full)-i-1 <= nameLength && len(full)-i-1 > 0 {
+			return full[:i], full[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("name is too long for the ustar format")
+}
+
+// putOctal writes value as zero-padded octal digits ending in a NUL,
+// filling the field.
+func putOctal(field []byte, value int64) error {
+
+	digits := strconv.FormatInt(value, 8)
+	if value < 0 || len(digits) > len(field)-1 {
+		return fmt.Errorf("%d does not fit the ustar format", value)
+	}
+	copy(field, strings.Repeat("0", len(field)-1-len(digits))+digits)
+	field[len(field)-1] = 0
+	return nil
+}
+
+// checksum sums the bytes of block, counting the checksum field as spaces.
+func checksum(block []byte) int64 {
+	var sum int64
+	for i, b := range block {
+		if i >= checksumOffset && i < checksumOffset+checksumLength {
+			b = ' '
+		}
+		sum += int64(b)
+	}
+	return sum
+}
+
+// putChecksum fills in the checksum, as six octal digits, a NUL and a
+// space, as tar writes it.
+func putChecksum(block []byte) {
+	field := block[checksumOffset : checksumOffset+checksumLength]
+	copy(field, fmt.Sprintf("%06o\x00 ", checksum(block)))
+}
+
+// parseHeader decodes a header block.
+func parseHeader(block []byte) (*header, error) {
+
+	want, err := parseNumber(block[checksumOffset : checksumOffset+checksumLength])
+	if err != nil || want != checksum(block) {
+		return nil, errNotArchive
+	}
+
+	h := &header{
+		name:     cString(block[nameOffset : nameOffset+nameLength]),
+		typeflag: block[typeOffset],
+		linkname: cString(block[linkOffset : linkOffset+linkLength]),
+	}
+
+	magic := block[magicOffset : magicOffset+magicLength]
+	if bytes.Equal(magic, magicUstar) || bytes.Equal(magic, magicGNU) {
+		h.uname = cString(block[unameOffset : unameOffset+unameLength])
+		h.gname = cString(block[gnameOffset : gnameOffset+gnameLength])
+		if prefix := cString(block[prefixOffset : prefixOffset+prefixLength]); prefix != "" && bytes.Equal(magic, magicUstar) {
+			h.name = prefix + "/" + h.name
+		}
+	}
+
+	var mtime, uid, gid int64
+	fields := []struct {
+		value          *int64
+		offset, length int
+	}{
+		{&h.mode, modeOffset, modeLength},
+		{&uid, uidOffset, uidLength},
+		{&gid, gidOffset, gidLength},
+		{&h.size, sizeOffset, sizeLength},
+		{&mtime, mtimeOffset, mtimeLength},
+	}
+	for _, f := range fields {
+		if *f.value, err = parseNumber(block[f.offset : f.offset+f.length]); err != nil {
+			return nil, fmt.Errorf("invalid header for %s: %v", h.name, err)
+		}
+	}
+	h.uid, h.gid = int(uid), int(gid)
+	h.modTime = time.Unix(mtime, 0)
+
+	if h.size < 0 {
+		return nil, fmt.Errorf("invalid header for %s: negative size", h.name)
+	}
+	return h, nil
+}
+
+// parseNumber decodes a numeric field: octal digits, optionally surrounded
+// by spaces and NULs, or for values too large for octal, as GNU tar writes
+// them, a big-endian binary number after a byte with the high bit set.
+func parseNumber(field []byte) (int64, error) {
+
+	if len(field) > 0 && field[0]&0x80 != 0 {
+		if field[0]&0x40 != 0 {
+			return 0, errors.New("negative binary number")
+		}
+		value := int64(field[0] & 0x3f)
+		for _, b := range field[1:] {
+			if value > (1<<63-1)>>8 {
+				return 0, errors.New("binary number out of range")
+			}
+			value = value<<8 | int64(b)
+		}
+		return value, nil
+	}
+
+	text := strings.Trim(string(field), " \x00")
+	if text == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(text, 8, 64)
+}
+
+// cString returns the text of a NUL-terminated field.
+func cString(field []byte) string {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		field = field[:i]
+	}
+	return string(field)
+}
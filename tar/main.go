@@ -0,0 +1,279 @@
+// Command cctar creates, lists and extracts tar archives in the ustar
+// format, like tar. Archives are read and written as streams, so they can
+// come from and go to pipes, and compressed archives are read without
+// being told. Extraction refuses entries that would land outside the
+// destination directory.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: cctar -c [flags] file ...")
+	fmt.Fprintln(out, "       cctar -t [flags] [member ...]")
+	fmt.Fprintln(out, "       cctar -x [flags] [member ...]")
+	fmt.Fprintln(out, "Create an archive of the files, list the members of an archive, or extract")
+	fmt.Fprintln(out, "them, all of them or the members named and what is under them.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "cctar"
+
+	create := flag.Bool("c", false, "create an archive")
+	list := flag.Bool("t", false, "list the members of an archive")
+	extract := flag.Bool("x", false, "extract the members of an archive")
+	archivePath := flag.String("f", cli.Stdin, "read or write the archive `file`, or - for standard input or output")
+	verbose := flag.Bool("v", false, "name each file as it is processed, and list members in detail")
+	dir := flag.String("C", "", "change to `dir` before adding or extracting files")
+	compress := flag.Bool("z", false, "compress the archive created with gzip")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	modes := 0
+	for _, set := range []bool{*create, *list, *extract} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		cli.Exit(cli.Usagef("need exactly one of -c, -t and -x"))
+	}
+	if *compress && !*create {
+		cli.Exit(cli.Usagef("-z only applies to -c; compressed archives are read without it"))
+	}
+
+	failed := false
+	warn := func(err error) {
+		cli.Report(err)
+		failed = true
+	}
+
+	var err error
+	switch {
+	case *create:
+		if flag.NArg() == 0 {
+			cli.Exit(cli.Usagef("refusing to create an empty archive"))
+		}
+		err = createArchive(*archivePath, flag.Args(), *dir, *compress, *verbose, warn)
+	case *list:
+		err = readArchive(*archivePath, func(r *reader) error {
+			return listArchive(os.Stdout, r, selector(flag.Args()), *verbose)
+		})
+	case *extract:
+		dest := *dir
+		if dest == "" {
+			dest = "."
+		}
+		e := &extractor{dest: dest, warn: warn}
+		if *verbose {
+			e.verbose = func(name string) { fmt.Println(name) }
+		}
+		err = readArchive(*archivePath, func(r *reader) error {
+			return e.extract(r, selector(flag.Args()))
+		})
+	}
+
+	if err != nil {
+		cli.Exit(err)
+	}
+	if failed {
+		os.Exit(cli.ExitFailure)
+	}
+}
+
+// createArchive writes an archive of paths, relative to dir if set, to
+// the file at archivePath.
+func createArchive(archivePath string, paths []string, dir string, compress bool, verbose bool, warn func(error)) error {
+
+	var out io.Writer = os.Stdout
+	var skip os.FileInfo
+	if archivePath != cli.Stdin {
+		file, err := os.Create(archivePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+		skip, _ = file.Stat()
+	} else if cli.IsTerminal(os.Stdout) {
+		return cli.Usagef("refusing to write an archive to a terminal; use -f")
+	}
+
+	buffered := bufio.NewWriterSize(out, recordSize)
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(buffered)
+		out = gz
+	} else {
+		out = buffered
+	}
+
+	c := &creator{w: &writer{out: out}, skip: skip, warn: warn}
+	if verbose {
+		// Keep the names off standard output when the archive is on it
+		names := os.Stdout
+		if archivePath == cli.Stdin {
+			names = os.Stderr
+		}
+		c.verbose = func(name string) { fmt.Fprintln(names, name) }
+	}
+
+	if dir != "" {
+		if err := os.Chdir(dir); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		if err := c.add(path); err != nil {
+			return err
+		}
+	}
+
+	if err := c.w.close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// readArchive opens the archive at path, decompressing it if need be, and
+// passes it to read.
+func readArchive(path string, read func(*reader) error) error {
+
+	file, closeFile, err := cli.Open(path)
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	defer closeFile()
+
+	input, err := streamio.Decompress(bufio.NewReaderSize(file, streamio.BufferSizeFor(file)))
+	if err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	defer input.Close()
+
+	if err := read(&reader{in: input}); err != nil {
+		return &cli.FileError{File: path, Err: err}
+	}
+	return nil
+}
+
+// selector returns whether a member is one of names, or under one of
+// them; with no names, every member is.
+func selector(names []string) func(string) bool {
+
+	return func(member string) bool {
+		if len(names) == 0 {
+			return true
+		}
+		member = strings.TrimSuffix(member, "/")
+		for _, name := range names {
+			name = strings.TrimSuffix(name, "/")
+			if member == name || strings.HasPrefix(member, name+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// listArchive prints the members of r, with verbose, in the long format
+// of ls: mode, owner, size, time and name.
+func listArchive(out io.Writer, r *reader, selected func(string) bool, verbose bool) error {
+
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	for {
+		h, err := r.next()
+		if err == io.EOF {
+			return w.Flush()
+		}
+		if err != nil {
+			w.Flush()
+			return err
+		}
+		if !selected(h.name) {
+			continue
+		}
+
+		if !verbose {
+			fmt.Fprintln(w, h.name)
+			continue
+		}
+
+		owner := h.uname
+		if owner == "" {
+			owner = fmt.Sprint(h.uid)
+		}
+		group := h.gname
+		if group == "" {
+			group = fmt.Sprint(h.gid)
+		}
+
+		name := h.name
+		switch h.typeflag {
+		case typeSymlink:
+			name += " -> " + h.linkname
+		case typeHardLink:
+			name += " link to " + h.linkname
+		}
+		fmt.Fprintf(w, "%s %s/%s %8d %s %s\n", modeString(h), owner, group, h.size, h.modTime.Format("2006-01-02 15:04"), name)
+	}
+}
+
+// modeString formats the type and permissions of an entry as ls does.
+func modeString(h *header) string {
+
+	mode := fs.FileMode(h.mode & 0o777)
+	switch h.typeflag {
+	case typeDir:
+		mode |= fs.ModeDir
+	case typeSymlink:
+		mode |= fs.ModeSymlink
+	}
+
+	text := []byte(mode.String())
+	if text[0] == 'L' {
+		text[0] = 'l'
+	}
+
+	// Special bits show in the execute positions, as ls shows them
+	special := []struct {
+		bit int64
+		at  int
+		c   byte
+	}{{0o4000, 3, 's'}, {0o2000, 6, 's'}, {0o1000, 9, 't'}}
+	for _, s := range special {
+		if h.mode&s.bit == 0 {
+			continue
+		}
+		if text[s.at] == 'x' {
+			text[s.at] = s.c
+		} else {
+			text[s.at] = s.c - 'a' + 'A'
+		}
+	}
+	return string(text)
+}
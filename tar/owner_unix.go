@@ -0,0 +1,47 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// names caches user and group names by ID, since a tree's files mostly
+// share a few owners and looking one up may read /etc/passwd each time.
+var names sync.Map
+
+// owner returns the numeric and named owner and group of a file.
+func owner(info os.FileInfo) (uid, gid int, uname, gname string) {
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, "", ""
+	}
+	uid, gid = int(stat.Uid), int(stat.Gid)
+
+	return uid, gid, lookupName("u", uid), lookupName("g", gid)
+}
+
+func lookupName(kind string, id int) string {
+
+	key := kind + strconv.Itoa(id)
+	if name, ok := names.Load(key); ok {
+		return name.(string)
+	}
+
+	name := ""
+	if kind == "u" {
+		if u, err := user.LookupId(strconv.Itoa(id)); err == nil {
+			name = u.Username
+		}
+	} else if g, err := user.LookupGroupId(strconv.Itoa(id)); err == nil {
+		name = g.Name
+	}
+
+	names.Store(key, name)
+	return name
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildArchive writes an archive of entries, each a header and its
+// content.
+func buildArchive(t *testing.T, entries ...any) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	w := &writer{out: &out}
+	for i := 0; i < len(entries); i += 2 {
+		h := entries[i].(*header)
+		content := entries[i+1].(string)
+		h.size = int64(len(content))
+		if h.mode == 0 {
+			h.mode = 0o644
+		}
+		if h.modTime.IsZero() {
+			h.modTime = time.Unix(0, 0)
+		}
+		if err := w.writeHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+
+	long := strings.Repeat("d/", 60) + strings.Repeat("f", 90)
+	h := &header{
+		name:     long,
+		mode:     0o4755,
+		uid:      1000,
+		gid:      100,
+		size:     12345,
+		modTime:  time.Unix(1700000000, 0),
+		typeflag: typeRegular,
+		uname:    "alice",
+		gname:    "users",
+	}
+
+	block, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block) != blockSize {
+		t.Fatalf("header is %d bytes, want %d", len(block), blockSize)
+	}
+
+	got, err := parseHeader(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.modTime.Equal(h.modTime) {
+		t.Errorf("modification time = %v, want %v", got.modTime, h.modTime)
+	}
+	got.modTime = h.modTime
+	if *got != *h {
+		t.Errorf("parseHeader(marshal()) = %+v, want %+v", got, h)
+	}
+
+	block[10] ^= 1
+	if _, err := parseHeader(block); err != errNotArchive {
+		t.Errorf("parseHeader of a corrupt header = %v, want errNotArchive", err)
+	}
+
+	h.name = strings.Repeat("x", 101)
+	if _, err := h.marshal(); err == nil {
+		t.Error("marshal accepted a 101 byte name with no slash")
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+
+	tests := []struct {
+		field string
+		want  int64
+	}{
+		{"0000644\x00", 0o644},
+		{"   644 \x00", 0o644},
+		{"\x00\x00\x00\x00", 0},
+		{"\x80\x00\x00\x00\x00\x00\x00\x02\x00\x00\x00\x00", 1 << 33},
+	}
+	for _, tt := range tests {
+		got, err := parseNumber([]byte(tt.field))
+		if err != nil || got != tt.want {
+			t.Errorf("parseNumber(%q) = %d, %v, want %d", tt.field, got, err, tt.want)
+		}
+	}
+	if _, err := parseNumber([]byte("12a\x00")); err == nil {
+		t.Error("parseNumber accepted a non-octal digit")
+	}
+}
+
+func TestArchiveLayout(t *testing.T) {
+
+	data := buildArchive(t, &header{name: "a", typeflag: typeRegular}, "hello")
+
+	// A header, a padded block of content and the end, in one record
+	if len(data) != recordSize {
+		t.Errorf("archive is %d bytes, want %d", len(data), recordSize)
+	}
+
+	r := &reader{in: bytes.NewReader(data)}
+	h, err := r.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil || string(content) != "hello" || h.name != "a" {
+		t.Errorf("entry %q = %q, %v", h.name, content, err)
+	}
+	if _, err := r.next(); err != io.EOF {
+		t.Errorf("next at the end = %v, want io.EOF", err)
+	}
+
+	// Writing more or less than the header says fails
+	w := &writer{out: io.Discard}
+	if err := w.writeHeader(&header{name: "b", size: 2, typeflag: typeRegular, modTime: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("abc")); err != errTooLong {
+		t.Errorf("writing past the size = %v, want errTooLong", err)
+	}
+	if err := w.close(); err == nil {
+		t.Error("close accepted an entry short of its size")
+	}
+
+	// So does reading an archive cut short, in an entry or between them
+	r = &reader{in: bytes.NewReader(data[:blockSize+3])}
+	r.next()
+	if _, err := io.ReadAll(r); err != io.ErrUnexpectedEOF {
+		t.Errorf("reading a truncated entry = %v, want io.ErrUnexpectedEOF", err)
+	}
+	r = &reader{in: bytes.NewReader(data[:2*blockSize])}
+	r.next()
+	if _, err := r.next(); err == nil || err == io.EOF || err == errNotArchive {
+		t.Errorf("next after the last entry of a truncated archive = %v, want an error", err)
+	}
+
+	r = &reader{in: strings.NewReader("")}
+	if _, err := r.next(); err != errNotArchive {
+		t.Errorf("next on empty input = %v, want errNotArchive", err)
+	}
+}
+
+func TestCreateAndExtract(t *testing.T) {
+
+	src := t.TempDir()
+	mtime := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	files := map[string]string{
+		"top.txt":          "top\n",
+		"dir/inner.txt":    "inner\n",
+		"dir/empty":        "",
+		"dir/deep/big.bin": strings.Repeat("0123456789", 1000),
+		strings.Repeat("n", 60) + "/" + strings.Repeat("m", 60): "long name\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, "tree", name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mtime, mtime)
+	}
+	os.Chmod(filepath.Join(src, "tree", "top.txt"), 0o755)
+	os.Symlink("../top.txt", filepath.Join(src, "tree", "dir", "link"))
+
+	// Create from inside src, as -C does, so names are relative
+	wd, _ := os.Getwd()
+	os.Chdir(src)
+	var archive bytes.Buffer
+	c := &creator{w: &writer{out: &archive}, warn: func(err error) { t.Error(err) }}
+	err := c.add("tree")
+	os.Chdir(wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.w.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var listing bytes.Buffer
+	if err := listArchive(&listing, &reader{in: bytes.NewReader(archive.Bytes())}, selector([]string{"tree/dir"}), false); err != nil {
+		t.Fatal(err)
+	}
+	want := "tree/dir/\ntree/dir/deep/\ntree/dir/deep/big.bin\ntree/dir/empty\ntree/dir/inner.txt\ntree/dir/link\n"
+	if listing.String() != want {
+		t.Errorf("listing of tree/dir = %q, want %q", listing.String(), want)
+	}
+
+	dest := t.TempDir()
+	e := &extractor{dest: dest, warn: func(err error) { t.Error(err) }}
+	if err := e.extract(&reader{in: bytes.NewReader(archive.Bytes())}, selector(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dest, "tree", name)
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != content {
+			t.Errorf("%s = %.20q, %v, want %.20q", name, got, err, content)
+		}
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Equal(mtime) {
+			t.Errorf("%s modified %v, want %v", name, info.ModTime(), mtime)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dest, "tree", "top.txt")); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("top.txt mode = %v, %v, want 0755", info.Mode().Perm(), err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "tree", "dir", "link")); err != nil || target != "../top.txt" {
+		t.Errorf("link = %q, %v", target, err)
+	}
+}
+
+func TestExtractRefusesEscapes(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		entries []any
+		want    string
+	}{
+		{
+			name:    "dot dot",
+			entries: []any{&header{name: "../evil", typeflag: typeRegular}, "x"},
+			want:    "refusing to extract outside the destination",
+		},
+		{
+			name:    "dot dot inside",
+			entries: []any{&header{name: "a/../../evil", typeflag: typeRegular}, "x"},
+			want:    "refusing to extract outside the destination",
+		},
+		{
+			name:    "absolute symlink",
+			entries: []any{&header{name: "link", typeflag: typeSymlink, linkname: "/etc"}, ""},
+			want:    "refusing symbolic link",
+		},
+		{
+			name:    "climbing symlink",
+			entries: []any{&header{name: "a/link", typeflag: typeSymlink, linkname: "../../x"}, ""},
+			want:    "refusing symbolic link",
+		},
+		{
+			name:    "hard link out",
+			entries: []any{&header{name: "link", typeflag: typeHardLink, linkname: "../x"}, ""},
+			want:    "link target: refusing to extract outside the destination",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "dest")
+			os.Mkdir(dest, 0o755)
+
+			var warnings []error
+			e := &extractor{dest: dest, warn: func(err error) { warnings = append(warnings, err) }}
+			data := buildArchive(t, tt.entries...)
+			if err := e.extract(&reader{in: bytes.NewReader(data)}, selector(nil)); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), tt.want) {
+				t.Errorf("warnings = %v, want one containing %q", warnings, tt.want)
+			}
+			if entries, _ := os.ReadDir(root); len(entries) != 1 {
+				t.Errorf("extraction wrote outside the destination: %v", entries)
+			}
+		})
+	}
+
+	// A symlink planted inside the destination, pointing out of it, is not
+	// followed by a later entry
+	root := t.TempDir()
+	dest := filepath.Join(root, "dest")
+	os.Mkdir(dest, 0o755)
+	os.Symlink(root, filepath.Join(dest, "planted"))
+
+	var warnings []error
+	e := &extractor{dest: dest, warn: func(err error) { warnings = append(warnings, err) }}
+	data := buildArchive(t, &header{name: "planted/evil", typeflag: typeRegular}, "x")
+	e.extract(&reader{in: bytes.NewReader(data)}, selector(nil))
+	if _, err := os.Stat(filepath.Join(root, "evil")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("extraction followed a symbolic link out of the destination")
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "through symbolic link") {
+		t.Errorf("warnings = %v", warnings)
+	}
+
+	// Leading slashes are dropped, with a warning, and the entry lands
+	// inside
+	warnings = nil
+	data = buildArchive(t, &header{name: "/abs/file", typeflag: typeRegular}, "x")
+	e.extract(&reader{in: bytes.NewReader(data)}, selector(nil))
+	if _, err := os.Stat(filepath.Join(dest, "abs", "file")); err != nil {
+		t.Errorf("absolute entry not extracted under the destination: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "removing leading '/'") {
+		t.Errorf("warnings = %v", warnings)
+	}
+}
+
+func TestModeString(t *testing.T) {
+
+	tests := []struct {
+		h    header
+		want string
+	}{
+		{header{mode: 0o644, typeflag: typeRegular}, "-rw-r--r--"},
+		{header{mode: 0o755, typeflag: typeDir}, "drwxr-xr-x"},
+		{header{mode: 0o777, typeflag: typeSymlink}, "lrwxrwxrwx"},
+		{header{mode: 0o4755, typeflag: typeRegular}, "-rwsr-xr-x"},
+		{header{mode: 0o1777, typeflag: typeDir}, "drwxrwxrwt"},
+		{header{mode: 0o2644, typeflag: typeRegular}, "-rw-r-Sr--"},
+	}
+	for _, tt := range tests {
+		if got := modeString(&tt.h); got != tt.want {
+			t.Errorf("modeString(%o) = %s, want %s", tt.h.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Command ccuniq collapses adjacent repeated lines of a file, or of
+// standard input, like uniq. Sorting the input first, with ccsort, makes
+// every repeat adjacent.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccuniq [flags] [input [output]]")
+	fmt.Fprintln(out, "Write input, or standard input when it is missing or -, to output, or standard output,")
+	fmt.Fprintln(out, "with each run of adjacent equal lines collapsed into its first line.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccuniq"
+
+	var opts options
+	flag.BoolVar(&opts.count, "c", false, "prefix lines with how many times they occurred")
+	flag.BoolVar(&opts.repeated, "d", false, "print only lines that are repeated")
+	flag.BoolVar(&opts.unique, "u", false, "print only lines that are not repeated")
+	flag.BoolVar(&opts.ignoreCase, "i", false, "ignore differences in case when comparing")
+	flag.IntVar(&opts.skipFields, "f", 0, "skip the first `N` fields when comparing")
+	flag.IntVar(&opts.skipChars, "s", 0, "skip the first `N` characters, after any skipped fields, when comparing")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if opts.skipFields < 0 || opts.skipChars < 0 {
+		cli.Exit(cli.Usagef("-f and -s must not be negative"))
+	}
+	if flag.NArg() > 2 {
+		cli.Exit(cli.Usagef("extra operand %q", flag.Arg(2)))
+	}
+
+	inputPath := cli.Stdin
+	if flag.NArg() > 0 {
+		inputPath = flag.Arg(0)
+	}
+
+	input, closeInput, err := cli.Open(inputPath)
+	if err != nil {
+		cli.Exit(&cli.FileError{File: inputPath, Err: err})
+	}
+	defer closeInput()
+
+	var out io.Writer = os.Stdout
+	if outputPath := flag.Arg(1); outputPath != "" && outputPath != cli.Stdin {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			cli.Exit(&cli.FileError{File: outputPath, Err: err})
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := uniq(out, input, opts); err != nil {
+		cli.Report(err)
+		os.Exit(cli.ExitFailure)
+	}
+}
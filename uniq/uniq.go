@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// options are how uniq compares lines and which groups it prints.
+type options struct {
+	count      bool
+	repeated   bool
+	unique     bool
+	ignoreCase bool
+	skipFields int
+	skipChars  int
+}
+
+// uniq copies input to out, collapsing each group of adjacent equal lines
+// into its first line. Only the current group's first line is held, so
+// input of any length is processed as it streams.
+func uniq(out io.Writer, input io.Reader, opts options) error {
+
+	reader := bufio.NewReader(input)
+	w := bufio.NewWriter(out)
+
+	var first []byte
+	count := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(line, []byte{'\n'})
+
+			if count > 0 && opts.equal(first, line) {
+				count++
+			} else {
+				if count > 0 {
+					opts.writeGroup(w, first, count)
+				}
+				first, count = line, 1
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if count > 0 {
+		opts.writeGroup(w, first, count)
+	}
+	return w.Flush()
+}
+
+// writeGroup writes the first line of a group of count equal lines, if the
+// options select groups of that size.
+func (opts options) writeGroup(w *bufio.Writer, line []byte, count int) {
+
+	if opts.repeated && count == 1 || opts.unique && count > 1 {
+		return
+	}
+
+	if opts.count {
+		fmt.Fprintf(w, "%7d ", count)
+	}
+	w.Write(line)
+	w.WriteByte('\n')
+}
+
+// equal reports whether a and b are equal once the skipped fields and
+// characters are left out.
+func (opts options) equal(a, b []byte) bool {
+
+	a, b = opts.compared(a), opts.compared(b)
+
+	if opts.ignoreCase {
+		return bytes.EqualFold(a, b)
+	}
+	return bytes.Equal(a, b)
+}
+
+// compared returns the part of line that is compared: what follows the
+// first skipFields fields, each a run of blanks and the non-blanks after
+// it, and then skipChars more bytes.
+func (opts options) compared(line []byte) []byte {
+
+	for range opts.skipFields {
+		line = bytes.TrimLeft(line, " \t")
+		if i := bytes.IndexAny(line, " \t"); i >= 0 {
+			line = line[i:]
+		} else {
+			line = nil
+		}
+	}
+
+	return line[min(opts.skipChars, len(line)):]
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUniq(t *testing.T) {
+
+	input := "a\na\nA\nb\nx 1 c\ny 2 c\na"
+
+	tests := []struct {
+		opts options
+		want string
+	}{
+		{options{}, "a\nA\nb\nx 1 c\ny 2 c\na\n"},
+		{options{count: true}, "      2 a\n      1 A\n      1 b\n      1 x 1 c\n      1 y 2 c\n      1 a\n"},
+		{options{repeated: true}, "a\n"},
+		{options{unique: true}, "A\nb\nx 1 c\ny 2 c\na\n"},
+		{options{ignoreCase: true, count: true}, "      3 a\n      1 b\n      1 x 1 c\n      1 y 2 c\n      1 a\n"},
+		{options{skipFields: 2}, "a\nx 1 c\na\n"},
+		{options{skipFields: 1, skipChars: 2}, "a\nx 1 c\na\n"},
+		{options{skipChars: 10}, "a\n"},
+		{options{repeated: true, unique: true}, ""},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := uniq(&out, strings.NewReader(input), test.opts); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%+v: got %q, want %q", test.opts, out.String(), test.want)
+		}
+	}
+}
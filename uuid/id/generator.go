@@ -0,0 +1,169 @@
+package id
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Generator makes identifiers. Its time-ordered identifiers are
+// monotonic: each sorts after the one before, even within a millisecond,
+// or when the clock steps back. It is safe for concurrent use.
+type Generator struct {
+	// Rand is the source of random bits, crypto/rand's Reader if nil
+	Rand io.Reader
+
+	// Now reads the clock, time.Now if nil
+	Now func() time.Time
+
+	mu sync.Mutex
+
+	// lastV7 and lastULID are the last time-ordered identifiers made,
+	// which the next ones must sort after
+	lastV7   UUID
+	lastULID ULID
+}
+
+// ErrMonotonicOverflow is returned when so many ULIDs are made in one
+// millisecond that the random part cannot be incremented again, as the
+// ULID specification requires.
+var ErrMonotonicOverflow = errors.New("ULID random part overflowed within one millisecond")
+
+var defaultGenerator Generator
+
+// NewV4 returns a random version 4 UUID from a default Generator.
+func NewV4() (UUID, error) {
+	return defaultGenerator.NewV4()
+}
+
+// NewV7 returns a time-ordered version 7 UUID from a default Generator.
+func NewV7() (UUID, error) {
+	return defaultGenerator.NewV7()
+}
+
+// NewULID returns a ULID from a default Generator.
+func NewULID() (ULID, error) {
+	return defaultGenerator.NewULID()
+}
+
+func (g *Generator) random(b []byte) error {
+	source := g.Rand
+	if source == nil {
+		source = rand.Reader
+	}
+	_, err := io.ReadFull(source, b)
+	return err
+}
+
+func (g *Generator) now() uint64 {
+	if g.Now != nil {
+		return uint64(g.Now().UnixMilli())
+	}
+	return uint64(time.Now().UnixMilli())
+}
+
+// NewV4 returns a version 4 UUID: 122 random bits.
+func (g *Generator) NewV4() (UUID, error) {
+
+	var u UUID
+	if err := g.random(u[:]); err != nil {
+		return Nil, err
+	}
+	setVersion(&u, 4)
+	return u, nil
+}
+
+// NewV7 returns a version 7 UUID: a millisecond timestamp and 74 random
+// bits. Within a millisecond, or when the clock is behind the last UUID,
+// the random bits of the last one are incremented instead, as RFC 9562
+// suggests, carrying into the timestamp if they run out.
+func (g *Generator) NewV7() (UUID, error) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var u UUID
+	ms := g.now()
+	if last := timestamp(g.lastV7[:]); g.lastV7 != Nil && ms <= last {
+		u = g.lastV7
+		incrementV7(&u)
+	} else {
+		if err := g.random(u[6:]); err != nil {
+			return Nil, err
+		}
+		putTimestamp(u[:], ms)
+		setVersion(&u, 7)
+	}
+
+	g.lastV7 = u
+	return u, nil
+}
+
+// incrementV7 adds one to the 74 random bits of u, skipping the version
+// and variant bits, and carries into the timestamp on overflow.
+func incrementV7(u *UUID) {
+
+	// The random bits are rand_b, the low 62 bits of bytes 8 to 15, then
+	// rand_a, the low 12 bits of bytes 6 and 7
+	for i := 15; i >= 8; i-- {
+		mask := byte(0xff)
+		if i == 8 {
+			mask = 0x3f
+		}
+		if u[i]&mask != mask {
+			u[i] = u[i]&^mask | (u[i]&mask + 1)
+			return
+		}
+		u[i] &^= mask
+	}
+	for i := 7; i >= 6; i-- {
+		mask := byte(0xff)
+		if i == 6 {
+			mask = 0x0f
+		}
+		if u[i]&mask != mask {
+			u[i] = u[i]&^mask | (u[i]&mask + 1)
+			return
+		}
+		u[i] &^= mask
+	}
+	putTimestamp(u[:], timestamp(u[:])+1)
+}
+
+// NewULID returns a ULID. Within a millisecond, or when the clock is
+// behind the last ULID, the random part of the last one is incremented
+// instead, as the ULID specification's monotonic mode does.
+func (g *Generator) NewULID() (ULID, error) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var u ULID
+	ms := g.now()
+	if last := timestamp(g.lastULID[:]); g.lastULID != (ULID{}) && ms <= last {
+		u = g.lastULID
+		i := 15
+		for ; i >= 6 && u[i] == 0xff; i-- {
+			u[i] = 0
+		}
+		if i < 6 {
+			return ULID{}, ErrMonotonicOverflow
+		}
+		u[i]++
+	} else {
+		putTimestamp(u[:], ms)
+		if err := g.random(u[6:]); err != nil {
+			return ULID{}, err
+		}
+	}
+
+	g.lastULID = u
+	return u, nil
+}
+
+func setVersion(u *UUID, version byte) {
+	u[6] = u[6]&0x0f | version<<4
+	u[8] = u[8]&0x3f | 0x80
+}
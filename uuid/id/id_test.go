@@ -0,0 +1,207 @@
+package id
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUUIDFormats(t *testing.T) {
+
+	// The version 7 example from RFC 9562 appendix A.6
+	const canonical = "017f22e2-79b0-7cc3-98c4-dc0c0c07398f"
+
+	u, err := ParseUUID(canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Version() != 7 || !u.IsRFC9562() {
+		t.Errorf("version %d, RFC 9562 variant %v, want 7, true", u.Version(), u.IsRFC9562())
+	}
+	if ts, ok := u.Time(); !ok || !ts.Equal(time.Date(2022, 2, 22, 19, 22, 22, 0, time.UTC)) {
+		t.Errorf("Time() = %v, %v", ts, ok)
+	}
+
+	forms := []string{
+		canonical,
+		strings.ToUpper(canonical),
+		"{" + canonical + "}",
+		"urn:uuid:" + canonical,
+		strings.ReplaceAll(canonical, "-", ""),
+		u.Base32(),
+	}
+	for _, form := range forms {
+		got, err := ParseUUID(form)
+		if err != nil || got != u {
+			t.Errorf("ParseUUID(%q) = %v, %v, want %v", form, got, err, u)
+		}
+	}
+
+	if u.String() != canonical || u.Hex() != strings.ReplaceAll(canonical, "-", "") {
+		t.Errorf("String() = %s, Hex() = %s", u.String(), u.Hex())
+	}
+
+	for _, bad := range []string{"", "017f22e2-79b0-7cc3-98c4-dc0c0c07398", "017f22e2_79b0-7cc3-98c4-dc0c0c07398f", "g17f22e279b07cc398c4dc0c0c07398f", "{017f22e2-79b0-7cc3-98c4-dc0c0c07398f"} {
+		if _, err := ParseUUID(bad); !errors.Is(err, errUUIDSyntax) {
+			t.Errorf("ParseUUID(%q) = %v, want a syntax error", bad, err)
+		}
+	}
+}
+
+func TestULIDFormats(t *testing.T) {
+
+	tests := []struct {
+		text string
+		want ULID
+	}{
+		{"00000000000000000000000000", ULID{}},
+		{"7ZZZZZZZZZZZZZZZZZZZZZZZZZ", ULID(Max)},
+		{"0000000000000000000000000Z", ULID{15: 31}},
+		{"00000000000000000000000010", ULID{15: 32}},
+	}
+	for _, tt := range tests {
+		got, err := ParseULID(tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseULID(%q) = %x, %v, want %x", tt.text, got, err, tt.want)
+		}
+		if tt.want.String() != tt.text {
+			t.Errorf("String() = %s, want %s", tt.want.String(), tt.text)
+		}
+	}
+
+	// Decoding is case insensitive and forgives look-alike letters
+	u, err := ParseULID("01arz3ndektsv4rrffq69g5fav")
+	if err != nil || u.String() != "01ARZ3NDEKTSV4RRFFQ69G5FAV" {
+		t.Errorf("lower case ULID = %v, %v", u, err)
+	}
+	if !u.Time().Equal(time.UnixMilli(1469922850259)) {
+		t.Errorf("Time() = %v", u.Time())
+	}
+	if a, _ := ParseULID("0O0000000000000000000000IL"); a != (ULID{15: 33}) {
+		t.Errorf("ParseULID with aliases = %x", a)
+	}
+
+	for _, bad := range []string{"01ARZ3NDEKTSV4RRFFQ69G5FA", "01ARZ3NDEKTSV4RRFFQ69G5FAU", "80000000000000000000000000"} {
+		if _, err := ParseULID(bad); !errors.Is(err, errULIDSyntax) {
+			t.Errorf("ParseULID(%q) = %v, want a syntax error", bad, err)
+		}
+	}
+}
+
+// fixedClock is a clock that only moves when told to.
+type fixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fixedClock) read() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fixedClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func TestV4(t *testing.T) {
+
+	g := &Generator{Rand: bytes.NewReader(bytes.Repeat([]byte{0xff}, 16))}
+	u, err := g.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.String() != "ffffffff-ffff-4fff-bfff-ffffffffffff" {
+		t.Errorf("NewV4 from all ones = %s", u)
+	}
+
+	if _, err := g.NewV4(); err == nil {
+		t.Error("NewV4 succeeded with no randomness left")
+	}
+}
+
+func TestMonotonicV7(t *testing.T) {
+
+	clock := &fixedClock{now: time.UnixMilli(1_700_000_000_000)}
+	g := &Generator{Now: clock.read}
+
+	var last UUID
+	for i := range 1000 {
+		// The clock stalls, then steps back, then moves on
+		switch i {
+		case 400:
+			clock.set(clock.read().Add(-time.Second))
+		case 800:
+			clock.set(clock.read().Add(time.Hour))
+		}
+
+		u, err := g.NewV7()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Version() != 7 || !u.IsRFC9562() {
+			t.Fatalf("%s is not a version 7 UUID", u)
+		}
+		if u.String() <= last.String() {
+			t.Fatalf("%s does not sort after %s", u, last)
+		}
+		last = u
+	}
+
+	// Running out of random bits carries into the timestamp
+	u := UUID{0, 0, 0, 0, 0, 1, 0x7f, 0xff, 0xbf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	incrementV7(&u)
+	if u.String() != "00000000-0002-7000-8000-000000000000" {
+		t.Errorf("incrementing the largest random part = %s", u)
+	}
+}
+
+func TestMonotonicULID(t *testing.T) {
+
+	clock := &fixedClock{now: time.UnixMilli(1_700_000_000_000)}
+	g := &Generator{Now: clock.read}
+
+	var wg sync.WaitGroup
+	made := make(chan ULID, 1000)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				u, err := g.NewULID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				made <- u
+			}
+		}()
+	}
+	wg.Wait()
+	close(made)
+
+	seen := make(map[ULID]bool)
+	for u := range made {
+		if seen[u] {
+			t.Fatalf("%s made twice", u)
+		}
+		seen[u] = true
+		if !u.Time().Equal(clock.read()) {
+			t.Errorf("%s has time %v", u, u.Time())
+		}
+	}
+
+	// The random part cannot be incremented past all ones
+	full := &Generator{Now: clock.read, Rand: bytes.NewReader(bytes.Repeat([]byte{0xff}, 10))}
+	if _, err := full.NewULID(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := full.NewULID(); err != ErrMonotonicOverflow {
+		t.Errorf("NewULID after the largest random part = %v, want ErrMonotonicOverflow", err)
+	}
+}
@@ -0,0 +1,114 @@
+package id
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ULID is a universally unique lexicographically sortable identifier: a
+// 48-bit millisecond timestamp followed by 80 random bits, written in 26
+// characters of Crockford's base32, which sort in time order.
+type ULID [16]byte
+
+// Time returns when u was made, to the millisecond.
+func (u ULID) Time() time.Time {
+	return time.UnixMilli(int64(timestamp(u[:])))
+}
+
+// String returns u in its canonical form, 26 characters of Crockford's
+// base32.
+func (u ULID) String() string {
+	return encodeBase32(u)
+}
+
+// Hex returns u as 32 hex digits.
+func (u ULID) Hex() string {
+	return hex.EncodeToString(u[:])
+}
+
+// UUID returns the same 128 bits as a UUID, as ULIDs are often stored.
+func (u ULID) UUID() UUID {
+	return UUID(u)
+}
+
+var errULIDSyntax = errors.New("not a ULID")
+
+// ParseULID parses a ULID in its canonical form, in either case, reading
+// I and L as 1 and O as 0 as Crockford's base32 does.
+func ParseULID(s string) (ULID, error) {
+
+	if len(s) != 26 {
+		return ULID{}, fmt.Errorf("%w: %q: must be 26 characters", errULIDSyntax, s)
+	}
+	decoded, err := decodeBase32(s)
+	if err != nil {
+		return ULID{}, fmt.Errorf("%w: %q: %v", errULIDSyntax, s, err)
+	}
+	return ULID(decoded), nil
+}
+
+// crockford is the alphabet of Crockford's base32: digits and letters
+// without I, L, O and U, which are easily mistaken.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var crockfordValues = func() [256]int8 {
+	var values [256]int8
+	for i := range values {
+		values[i] = -1
+	}
+	for i := range len(crockford) {
+		c := crockford[i]
+		values[c] = int8(i)
+		values[c|0x20] = int8(i)
+	}
+	for _, alias := range []struct {
+		c     byte
+		value int8
+	}{{'I', 1}, {'i', 1}, {'L', 1}, {'l', 1}, {'O', 0}, {'o', 0}} {
+		values[alias.c] = alias.value
+	}
+	return values
+}()
+
+// encodeBase32 writes 128 bits as 26 base32 characters, which hold 130
+// bits, so the first character only carries the top 3 bits.
+func encodeBase32(b [16]byte) string {
+
+	var out [26]byte
+	hi := uint64(b[0])<<56 | uint64(b[1])<<48 | uint64(b[2])<<40 | uint64(b[3])<<32 | uint64(b[4])<<24 | uint64(b[5])<<16 | uint64(b[6])<<8 | uint64(b[7])
+	lo := uint64(b[8])<<56 | uint64(b[9])<<48 | uint64(b[10])<<40 | uint64(b[11])<<32 | uint64(b[12])<<24 | uint64(b[13])<<16 | uint64(b[14])<<8 | uint64(b[15])
+
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+var errOverflow = errors.New("value does not fit in 128 bits")
+
+func decodeBase32(s string) ([16]byte, error) {
+
+	var hi, lo uint64
+	for i := range len(s) {
+		v := crockfordValues[s[i]]
+		if v < 0 {
+			return [16]byte{}, fmt.Errorf("invalid character %q", s[i])
+		}
+		if i == 0 && v > 7 {
+			return [16]byte{}, errOverflow
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+
+	var b [16]byte
+	for i := range 8 {
+		b[i] = byte(hi >> (56 - 8*i))
+		b[8+i] = byte(lo >> (56 - 8*i))
+	}
+	return b, nil
+}
@@ -0,0 +1,122 @@
+// Package id generates, formats and parses unique identifiers: random
+// version 4 UUIDs, time-ordered version 7 UUIDs and ULIDs. Identifiers from
+// one Generator sort in the order they were made, even when many are made
+// within the same millisecond.
+package id
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UUID is a universally unique identifier, as RFC 9562 specifies.
+type UUID [16]byte
+
+// Nil and Max are the UUIDs with every bit zero and every bit one, which
+// stand for no UUID and for one past all others.
+var (
+	Nil UUID
+	Max = UUID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// Version returns the version of u, from 1 to 8 for the versions RFC 9562
+// defines.
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// IsRFC9562 reports whether u has the variant RFC 9562 defines, which all
+// the versions it lists use.
+func (u UUID) IsRFC9562() bool {
+	return u[8]&0xc0 == 0x80
+}
+
+// Time returns when a version 7 UUID was made, to the millisecond.
+func (u UUID) Time() (time.Time, bool) {
+	if u.Version() != 7 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(timestamp(u[:]))), true
+}
+
+// String returns u in the canonical form: 32 hex digits in groups of 8, 4,
+// 4, 4 and 12, separated by hyphens.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// Hex returns u as 32 hex digits without hyphens.
+func (u UUID) Hex() string {
+	return hex.EncodeToString(u[:])
+}
+
+// Base32 returns u in the 26 characters of Crockford's base32, as ULIDs
+// are written.
+func (u UUID) Base32() string {
+	return encodeBase32(u)
+}
+
+var errUUIDSyntax = errors.New("not a UUID")
+
+// ParseUUID parses a UUID in canonical form, optionally in braces or after
+// "urn:uuid:", as 32 hex digits without hyphens, or in base32. Hex digits
+// may be in either case.
+func ParseUUID(s string) (UUID, error) {
+
+	var u UUID
+	text := s
+
+	switch {
+	case len(text) == 38 && text[0] == '{' && text[37] == '}':
+		text = text[1:37]
+	case len(text) == 45 && strings.EqualFold(text[:9], "urn:uuid:"):
+		text = text[9:]
+	}
+
+	switch len(text) {
+	case 36:
+		if text[8] != '-' || text[13] != '-' || text[18] != '-' || text[23] != '-' {
+			return Nil, fmt.Errorf("%w: %q", errUUIDSyntax, s)
+		}
+		text = text[0:8] + text[9:13] + text[14:18] + text[19:23] + text[24:]
+		fallthrough
+	case 32:
+		if _, err := hex.Decode(u[:], []byte(text)); err != nil {
+			return Nil, fmt.Errorf("%w: %q", errUUIDSyntax, s)
+		}
+		return u, nil
+	case 26:
+		decoded, err := decodeBase32(text)
+		if err != nil {
+			return Nil, fmt.Errorf("%w: %q: %v", errUUIDSyntax, s, err)
+		}
+		return UUID(decoded), nil
+	}
+
+	return Nil, fmt.Errorf("%w: %q", errUUIDSyntax, s)
+}
+
+// timestamp reads the 48-bit big-endian millisecond timestamp that
+// version 7 UUIDs and ULIDs both start with.
+func timestamp(b []byte) uint64 {
+	return uint64(b[0])<<40 | uint64(b[1])<<32 | uint64(b[2])<<24 | uint64(b[3])<<16 | uint64(b[4])<<8 | uint64(b[5])
+}
+
+func putTimestamp(b []byte, ms uint64) {
+	for i := range 6 {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+}
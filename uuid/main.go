@@ -0,0 +1,167 @@
+// Command ccuuid generates and checks unique identifiers: random version 4
+// UUIDs, time-ordered version 7 UUIDs and ULIDs, one or many at a time, in
+// canonical, raw hex or base32 form.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"codechallenge/internal/cli"
+	"codechallenge/uuid/id"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccuuid [flags]")
+	fmt.Fprintln(out, "       ccuuid -parse [flags] id ...")
+	fmt.Fprintln(out, "Print new identifiers, one per line, or check and describe the ones given.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccuuid"
+
+	kind := flag.String("type", "v4", "make identifiers of this type: v4, v7 or ulid")
+	count := flag.Int("n", 1, "make `N` identifiers")
+	format := flag.String("format", "canonical", "print identifiers as canonical, raw (hex digits only) or base32")
+	parse := flag.Bool("parse", false, "check the identifiers given and describe them rather than making new ones")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if err := cli.Choice(*kind, "v4", "v7", "ulid"); err != nil {
+		cli.Exit(cli.Usagef("invalid -type %q: %v", *kind, err))
+	}
+	if err := cli.Choice(*format, "canonical", "raw", "base32"); err != nil {
+		cli.Exit(cli.Usagef("invalid -format %q: %v", *format, err))
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+
+	if *parse {
+		if flag.NArg() == 0 {
+			cli.Exit(cli.Usagef("-parse needs identifiers to check"))
+		}
+		ok := true
+		for _, arg := range flag.Args() {
+			if err := describe(out, arg, *kind == "ulid", *format); err != nil {
+				out.Flush()
+				cli.Report(err)
+				ok = false
+			}
+		}
+		if err := out.Flush(); err != nil {
+			cli.Exit(err)
+		}
+		if !ok {
+			os.Exit(cli.ExitFailure)
+		}
+		return
+	}
+
+	if flag.NArg() > 0 {
+		cli.Exit(cli.Usagef("unexpected argument %q; use -parse to check identifiers", flag.Arg(0)))
+	}
+	if *count < 0 {
+		cli.Exit(cli.Usagef("-n must not be negative"))
+	}
+
+	if err := generate(out, *kind, *count, *format); err != nil {
+		cli.Exit(err)
+	}
+	if err := out.Flush(); err != nil {
+		cli.Exit(err)
+	}
+}
+
+// generate writes count new identifiers of kind, each on its own line.
+// They come from one generator, so time-ordered ones sort in the order
+// they are written.
+func generate(out io.Writer, kind string, count int, format string) error {
+
+	var g id.Generator
+	for range count {
+		var text string
+		switch kind {
+		case "v4", "v7":
+			newUUID := g.NewV4
+			if kind == "v7" {
+				newUUID = g.NewV7
+			}
+			u, err := newUUID()
+			if err != nil {
+				return err
+			}
+			text = formatUUID(u, format)
+		case "ulid":
+			u, err := g.NewULID()
+			if err != nil {
+				return err
+			}
+			text = formatUUID(u.UUID(), format)
+			if format == "canonical" {
+				text = u.String()
+			}
+		}
+		if _, err := fmt.Fprintln(out, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatUUID(u id.UUID, format string) string {
+	switch format {
+	case "raw":
+		return u.Hex()
+	case "base32":
+		return u.Base32()
+	}
+	return u.String()
+}
+
+// describe checks one identifier and prints it in format, with its type
+// and, for time-ordered identifiers, when it was made.
+func describe(out io.Writer, text string, ulid bool, format string) error {
+
+	if ulid {
+		u, err := id.ParseULID(text)
+		if err != nil {
+			return err
+		}
+		formatted := u.String()
+		if format != "canonical" {
+			formatted = formatUUID(u.UUID(), format)
+		}
+		_, err = fmt.Fprintf(out, "%s\tulid\t%s\n", formatted, u.Time().UTC().Format(time.RFC3339Nano))
+		return err
+	}
+
+	u, err := id.ParseUUID(text)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case u == id.Nil || u == id.Max:
+		_, err = fmt.Fprintf(out, "%s\tspecial\n", formatUUID(u, format))
+		return err
+	case !u.IsRFC9562() || u.Version() < 1 || u.Version() > 8:
+		return fmt.Errorf("%s: not an RFC 9562 UUID", text)
+	}
+
+	fmt.Fprintf(out, "%s\tv%d", formatUUID(u, format), u.Version())
+	if t, ok := u.Time(); ok {
+		fmt.Fprintf(out, "\t%s", t.UTC().Format(time.RFC3339Nano))
+	}
+	_, err = fmt.Fprintln(out)
+	return err
+}
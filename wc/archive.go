@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+)
+
+// tarSuffixes name tar archives, compressed in any of the formats
+// streamio.Decompress reads.
+var tarSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.zst"}
+
+// archiveKind returns "zip" or "tar" for a path whose name marks it as an
+// archive of that kind, or "" for any other path.
+func archiveKind(path string) string {
+
+	name := strings.ToLower(path)
+	if strings.HasSuffix(name, ".zip") {
+		return "zip"
+	}
+	for _, suffix := range tarSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return "tar"
+		}
+	}
+	return ""
+}
+
+// memberName names a member of an archive in results, as archive:member.
+func memberName(archive, member string) string {
+	return archive + ":" + member
+}
+
+// countArchive counts each regular file in the archive at path, in the
+// order the archive lists them, without extracting anything to disk.
+func countArchive(ctx context.Context, path string, passes []column, opts options) ([]Counts, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, &cli.FileError{File: path, Err: err}
+	}
+	defer file.Close()
+
+	var results []Counts
+	if archiveKind(path) == "zip" {
+		results, err = countZip(ctx, file, path, passes, opts)
+	} else {
+		results, err = countTar(ctx, file, path, passes, opts)
+	}
+
+	// Errors counting a member already name it
+	var fileErr *cli.FileError
+	if err != nil && !errors.As(err, &fileErr) {
+		err = &cli.FileError{File: path, Err: err}
+	}
+	return results, err
+}
+
+// countZip counts the members of a zip archive, which is read from its
+// central directory at the end of the file.
+func countZip(ctx context.Context, file *os.File, path string, passes []column, opts options) ([]Counts, error) {
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Counts
+	for _, member := range archive.File {
+		if !member.Mode().IsRegular() {
+			continue
+		}
+		content, err := member.Open()
+		if err != nil {
+			return nil, &cli.FileError{File: memberName(path, member.Name), Err: err}
+		}
+		counts, err := countReader(ctx, content, memberName(path, member.Name), passes, opts)
+		content.Close()
+		if opts.binary == "skip" && errors.Is(err, errBinary) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, counts)
+	}
+	return results, nil
+}
+
+// countTar counts the members of a tar archive as it streams past,
+// decompressing it first if it is compressed.
+func countTar(ctx context.Context, file *os.File, path string, passes []column, opts options) ([]Counts, error) {
+
+	input, err := streamio.Decompress(streamio.ContextReader(ctx, file))
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var results []Counts
+	archive := tar.NewReader(input)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		counts, err := countReader(ctx, archive, memberName(path, header.Name), passes, opts)
+		if opts.binary == "skip" && errors.Is(err, errBinary) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, counts)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codechallenge/wc/count"
+)
+
+// BenchmarkCorpus counts each of the shared corpora generated by the
+// benchmarks runner in the repository's benchmarks directory, which names
+// their directory in CODECHALLENGE_CORPUS.
+func BenchmarkCorpus(b *testing.B) {
+
+	dir := os.Getenv("CODECHALLENGE_CORPUS")
+	if dir == "" {
+		b.Skip("CODECHALLENGE_CORPUS is not set; run the benchmarks runner")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	modes := []struct {
+		name    string
+		columns []column
+	}{
+		{"default", []column{colLines, colWords, colBytes}},
+		{"chars", []column{colChars}},
+	}
+
+	opts := options{
+		wordSplit:   count.ScanWords,
+		delimiter:   []byte{'\n'},
+		utf8Chars:   true,
+		invalidUTF8: "runes",
+		jobs:        1,
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, mode := range modes {
+			b.Run(filepath.Base(path)+"/"+mode.name, func(b *testing.B) {
+				b.SetBytes(info.Size())
+				for range b.N {
+					if _, err := countPath(context.Background(), path, mode.columns, opts); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+// lineKind is what countBlank has seen of the current line so far.
+type lineKind int
+
+const (
+	lineEmpty lineKind = iota
+	lineWhitespace
+	lineText
+)
+
+// countBlank breaks the lines of input down into blank lines, lines of
+// nothing but whitespace, and lines with any other content, with -blank.
+// A final line without a newline is counted unless it is empty.
+func countBlank(input io.Reader) (blank int, whitespace int, nonBlank int, err error) {
+
+	reader := bufio.NewReader(input)
+	kind := lineEmpty
+
+	add := func() {
+		switch kind {
+		case lineEmpty:
+			blank++
+		case lineWhitespace:
+			whitespace++
+		default:
+			nonBlank++
+		}
+		kind = lineEmpty
+	}
+
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return blank, whitespace, nonBlank, err
+		}
+
+		switch b {
+		case '\n':
+			add()
+		case '\r':
+			// Part of a CRLF, so a line of just "\r\n" is still blank
+		case ' ', '\t', '\v', '\f':
+			if kind == lineEmpty {
+				kind = lineWhitespace
+			}
+		default:
+			kind = lineText
+		}
+	}
+
+	// Only a final line with content is a line
+	if kind != lineEmpty {
+		add()
+	}
+
+	return blank, whitespace, nonBlank, nil
+}
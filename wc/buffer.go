@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"codechallenge/internal/cli"
+)
+
+// Bounds of -buffer-size. The minimum keeps reads efficient, and large
+// enough for the bufio.Reader that counters share; the maximum keeps a
+// mistyped size from exhausting memory, well past where larger reads stop
+// paying off. Sizes chosen when -buffer-size is auto come from
+// streamio.BufferSizeFor.
+const (
+	minBufferSize = 4 * 1024
+	maxBufferSize = 64 * 1024 * 1024
+)
+
+// parseBufferSize parses a -buffer-size value: a number of bytes with an
+// optional K, M or G suffix, in either case, or auto, which is returned as
+// 0.
+func parseBufferSize(value string) (int, error) {
+
+	if value == "auto" {
+		return 0, nil
+	}
+
+	size, err := cli.ParseCount(strings.ToUpper(value))
+	if err != nil {
+		return 0, errors.New("not a number of bytes")
+	}
+
+	if size < minBufferSize || size > maxBufferSize {
+		return 0, errors.New("must be from 4K to 64M")
+	}
+
+	return int(size), nil
+}
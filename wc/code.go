@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"codechallenge/internal/streamio"
+	"codechallenge/internal/textutil"
+)
+
+// language holds the comment markers of a programming language.
+type language struct {
+	// line starts a comment that runs to the end of the line
+	line []string
+
+	// block holds the start and end of a comment that may span lines
+	block [][2]string
+
+	// quotes holds the characters that delimit string literals, in which
+	// comment markers are not looked for
+	quotes string
+}
+
+var (
+	cLike  = &language{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, quotes: "\"'`"}
+	hash   = &language{line: []string{"#"}, quotes: "\"'"}
+	dashes = &language{line: []string{"--"}, quotes: "\""}
+	markup = &language{block: [][2]string{{"<!--", "-->"}}}
+)
+
+// languages maps a file extension to the comment markers used by -code.
+var languages = map[string]*language{
+	".go":    cLike,
+	".c":     cLike,
+	".h":     cLike,
+	".cc":    cLike,
+	".cpp":   cLike,
+	".hpp":   cLike,
+	".cs":    cLike,
+	".java":  cLike,
+	".kt":    cLike,
+	".scala": cLike,
+	".swift": cLike,
+	".rs":    {line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, quotes: "\""},
+	".js":    cLike,
+	".jsx":   cLike,
+	".ts":    cLike,
+	".tsx":   cLike,
+	".proto": cLike,
+	".css":   {block: [][2]string{{"/*", "*/"}}, quotes: "\"'"},
+	".py":    hash,
+	".rb":    hash,
+	".pl":    hash,
+	".sh":    hash,
+	".bash":  hash,
+	".r":     hash,
+	".yaml":  hash,
+	".yml":   hash,
+	".toml":  hash,
+	".sql":   {line: []string{"--"}, block: [][2]string{{"/*", "*/"}}, quotes: "'\""},
+	".lua":   {line: []string{"--"}, block: [][2]string{{"--[[", "]]"}}, quotes: "\"'"},
+	".hs":    {line: []string{"--"}, block: [][2]string{{"{-", "-}"}}, quotes: "\""},
+	".ada":   dashes,
+	".html":  markup,
+	".xml":   markup,
+	".md":    markup,
+	".tex":   {line: []string{"%"}},
+	".lisp":  {line: []string{";"}, quotes: "\""},
+	".clj":   {line: []string{";"}, quotes: "\""},
+	".vim":   {line: []string{"\""}},
+}
+
+// languageOf returns the comment markers for the extension of name, or nil
+// if the language is not recognized.
+func languageOf(name string) *language {
+	return languages[strings.ToLower(filepath.Ext(name))]
+}
+
+// countCode classifies each line of input as code, comment or blank, with
+// -code. A line with any code outside a comment is code, a line that is not
+// blank but only holds comments is a comment, and a line of nothing but
+// whitespace is blank, even inside a block comment. Markers inside string
+// literals are skipped, but strings are not followed across lines, which is
+// enough for a summary.
+func countCode(input io.Reader, lang *language) (code int, comment int, blank int, err error) {
+
+	reader := textutil.NewLineReader(input, streamio.StreamBufferSize)
+
+	// The end marker of the block comment the current line starts in, if
+	// any
+	var blockEnd string
+
+	for {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			return code, comment, blank, nil
+		}
+		if err != nil {
+			return code, comment, blank, err
+		}
+
+		var hasCode, hasComment bool
+		hasCode, hasComment, blockEnd = classifyLine(string(bytes.TrimSpace(line)), lang, blockEnd)
+
+		switch {
+		case hasCode:
+			code++
+		case hasComment:
+			comment++
+		default:
+			blank++
+		}
+	}
+}
+
+// classifyLine reports whether line, trimmed of surrounding whitespace,
+// holds any code or comments, given the end marker of the block comment it
+// starts in, and returns the end marker of the block comment it ends in.
+func classifyLine(line string, lang *language, blockEnd string) (hasCode bool, hasComment bool, end string) {
+
+	for line != "" {
+		if blockEnd != "" {
+			hasComment = true
+
+			i := strings.Index(line, blockEnd)
+			if i < 0 {
+				return hasCode, hasComment, blockEnd
+			}
+			line = strings.TrimSpace(line[i+len(blockEnd):])
+			blockEnd = ""
+			continue
+		}
+
+		// Find whichever comment starts first
+		start, lineComment := len(line), false
+		for _, marker := range lang.line {
+			if i := indexOutsideStrings(line, marker, lang.quotes); i >= 0 && i < start {
+				start, lineComment = i, true
+			}
+		}
+		var startLen int
+		for _, markers := range lang.block {
+			// A block marker that begins with a line marker, such as
+			// Lua's --[[, wins over it
+			if i := indexOutsideStrings(line, markers[0], lang.quotes); i >= 0 && i <= start {
+				start, lineComment, startLen, blockEnd = i, false, len(markers[0]), markers[1]
+			}
+		}
+
+		if start > 0 {
+			hasCode = true
+		}
+		if start == len(line) {
+			break
+		}
+
+		hasComment = true
+		if lineComment {
+			break
+		}
+		line = strings.TrimSpace(line[start+startLen:])
+	}
+
+	return hasCode, hasComment, blockEnd
+}
+
+// indexOutsideStrings returns the index of the first marker in line that is
+// not inside a string literal delimited by one of quotes, or -1. A string
+// left open runs to the end of the line.
+func indexOutsideStrings(line string, marker string, quotes string) int {
+
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote != 0:
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == quote {
+				quote = 0
+			}
+		case strings.HasPrefix(line[i:], marker):
+			return i
+		case strings.IndexByte(quotes, line[i]) >= 0:
+			quote = line[i]
+		}
+	}
+
+	return -1
+}
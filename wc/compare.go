@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"codechallenge/internal/streamio"
+)
+
+// signed renders n with an explicit sign.
+func signed(n int) string {
+	if n > 0 {
+		return "+" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// signedBytes renders a byte difference with an explicit sign and a binary
+// unit, such as -3.0 KiB.
+func signedBytes(n int) string {
+	switch {
+	case n > 0:
+		return "+" + streamio.FormatBytes(float64(n))
+	case n < 0:
+		return "-" + streamio.FormatBytes(float64(-n))
+	default:
+		return "0 B"
+	}
+}
+
+// writeCompare prints the counts of two inputs followed by the difference
+// from the first to the second.
+func writeCompare(out io.Writer, format string, before Counts, after Counts, columns []column, opts options) error {
+
+	results := []Counts{before, after}
+
+	if format == "text" {
+		writeText(out, results, columns, opts.style, opts.width)
+
+		var deltas []string
+		for _, col := range columns {
+			delta := col.value(after) - col.value(before)
+			if col == colBytes || col == colCompressed {
+				deltas = append(deltas, col.name()+" "+signedBytes(delta))
+			} else {
+				deltas = append(deltas, col.name()+" "+signed(delta))
+			}
+		}
+
+		_, err := fmt.Fprintf(out, "delta: %s\n", strings.Join(deltas, ", "))
+		return err
+	}
+
+	comma := ','
+	if format == "tsv" {
+		comma = '\t'
+	}
+
+	if err := writeDelimited(out, results, columns, comma); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	record := []string{"delta"}
+	for _, col := range columns {
+		record = append(record, signed(col.value(after)-col.value(before)))
+	}
+	writer.Write(record)
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,307 @@
+// Package count implements the counters of ccwc over any io.Reader, so that
+// programs embedding them can count files, bytes.Buffers, network streams
+// or archive entries alike. Each counter reads its input to the end.
+package count
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+
+	"codechallenge/internal/textutil"
+)
+
+// DefaultBufferSize is the read size used when a counter is given a buffer
+// size of zero.
+const DefaultBufferSize = 64 * 1024
+
+func bufferOf(size int) []byte {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return make([]byte, size)
+}
+
+// Bytes counts the bytes in input, reading bufferSize bytes at a time.
+func Bytes(input io.Reader, bufferSize int) (int, error) {
+
+	count := 0
+
+	buffer := bufferOf(bufferSize)
+
+	for {
+		chunk_count, err := input.Read(buffer)
+		count += chunk_count
+
+		if err != nil {
+			if err == io.EOF {
+				break // End of file
+			}
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// Lines counts the records in input terminated by delimiter, which is a
+// newline for ordinary line counting. As with POSIX wc, a final record with no
+// trailing delimiter is not included unless countPartial is set. The input is
+// read bufferSize bytes at a time.
+func Lines(input io.Reader, delimiter []byte, countPartial bool, bufferSize int) (int, error) {
+
+	count := 0
+	buffer := bufferOf(bufferSize)
+
+	// Bytes from the end of the previous chunk that may begin a delimiter
+	// split across the chunk boundary
+	var carry []byte
+
+	// Whether the input ended part way through a record
+	partial := false
+
+	for {
+		chunk_count, err := input.Read(buffer)
+		if chunk_count > 0 {
+			window := buffer[:chunk_count]
+			if len(carry) > 0 {
+				window = append(carry, window...)
+			}
+
+			end := 0
+			for {
+				index := bytes.Index(window[end:], delimiter)
+				if index < 0 {
+					break
+				}
+				count++
+				end += index + len(delimiter)
+			}
+
+			partial = end < len(window)
+
+			keep := max(end, len(window)-(len(delimiter)-1))
+			carry = append(carry[:0:0], window[keep:]...)
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				return count, err
+			}
+			break
+		}
+	}
+
+	if countPartial && partial {
+		count++
+	}
+
+	return count, nil
+
+}
+
+// MaxWordSize is the most of a word that Words holds in memory. A longer
+// word is still counted once, but given to each in pieces of this size.
+const MaxWordSize = 64 * 1024
+
+// Words counts the words in input as split by split, such as ScanWords or
+// ScanUnicodeWords, calling each with every word when it is not nil. Unlike
+// a bufio.Scanner, it has no limit on the length of a word, so an input that
+// is one enormous word is counted in constant memory. The tokens split
+// returns must be slices of the data it is given.
+func Words(input io.Reader, split bufio.SplitFunc, each func(word []byte)) (int, error) {
+
+	count := 0
+
+	buffer := make([]byte, MaxWordSize)
+	start, end := 0, 0
+	atEOF := false
+
+	// Whether the data from start continues a word cut short at the end of
+	// a full buffer
+	cut := false
+
+	add := func(word []byte, continued bool) {
+		if !continued {
+			count++
+		}
+		if each != nil {
+			each(word)
+		}
+	}
+
+	for {
+		data := buffer[start:end]
+
+		advance, token, err := split(data, atEOF)
+		if err == bufio.ErrFinalToken {
+			if token != nil {
+				add(token, cut && cap(token) == cap(data))
+			}
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		if token != nil {
+			add(token, cut && cap(token) == cap(data))
+		}
+		if advance > 0 || token != nil {
+			start += advance
+			cut = false
+			continue
+		}
+
+		if atEOF {
+			return count, nil
+		}
+
+		// Make room to read more of the word in progress
+		if start > 0 {
+			end = copy(buffer, data)
+			start = 0
+		}
+
+		if end == len(buffer) {
+			// The buffer holds part of a single word, which is taken as
+			// it stands and continued by what follows
+			if _, token, err := split(buffer, true); err == nil && token != nil {
+				add(token, cut)
+				cut = true
+			}
+			end = 0
+		}
+
+		n, err := input.Read(buffer[end:])
+		end += n
+		if err == io.EOF {
+			atEOF = true
+		} else if err != nil {
+			return count, err
+		}
+	}
+}
+
+// ScanWords is a split function that yields runs of characters separated by
+// whitespace, like bufio.ScanWords. As in GNU wc, control characters and
+// invalid UTF-8 neither begin nor end a word, so runs made up only of them
+// are skipped.
+func ScanWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+
+	for {
+		n, word, err := bufio.ScanWords(data[advance:], atEOF)
+		if err != nil || word == nil {
+			return advance + n, word, err
+		}
+
+		advance += n
+		if hasPrintable(word) {
+			return advance, word, nil
+		}
+	}
+}
+
+func hasPrintable(word []byte) bool {
+	for len(word) > 0 {
+		r, size := utf8.DecodeRune(word)
+		invalid := r == utf8.RuneError && size == 1
+		if !invalid && !unicode.IsControl(r) {
+			return true
+		}
+		word = word[size:]
+	}
+	return false
+}
+
+// ScanUnicodeWords is a split function that yields the word-like segments of
+// the input as defined by the UAX #29 word boundary rules. Segments made up
+// only of whitespace or punctuation are skipped.
+func ScanUnicodeWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+
+	for advance < len(data) {
+		word, rest, _ := uniseg.FirstWord(data[advance:], -1)
+
+		// The last segment in the buffer may continue past it
+		if len(rest) == 0 && !atEOF {
+			return advance, nil, nil
+		}
+
+		advance += len(word)
+		if isWordLike(word) {
+			return advance, word, nil
+		}
+	}
+
+	return advance, nil, nil
+}
+
+func isWordLike(segment []byte) bool {
+	for _, r := range string(segment) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Chars counts the runes in input along with the bytes read and the
+// number of invalid UTF-8 sequences found. Each invalid byte counts as a rune.
+func Chars(input io.Reader) (runes int, bytes int, invalid int, err error) {
+	return textutil.CountRunes(input)
+}
+
+// Newlines tallies the line terminators in input by style. A CR
+// immediately followed by LF counts once, as CRLF.
+func Newlines(input io.Reader) (lf int, crlf int, cr int, err error) {
+
+	reader := bufio.NewReader(input)
+
+	pendingCR := false
+
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				return lf, crlf, cr, err
+			}
+			break
+		}
+
+		switch b {
+		case '\n':
+			if pendingCR {
+				crlf++
+			} else {
+				lf++
+			}
+			pendingCR = false
+		case '\r':
+			if pendingCR {
+				cr++
+			}
+			pendingCR = true
+		default:
+			if pendingCR {
+				cr++
+			}
+			pendingCR = false
+		}
+	}
+
+	if pendingCR {
+		cr++
+	}
+
+	return lf, crlf, cr, nil
+}
+
+// SkipBOM returns a reader over input that omits a leading UTF-8 byte order
+// mark, if there is one.
+func SkipBOM(input io.Reader) io.Reader {
+	return textutil.SkipBOM(input)
+}
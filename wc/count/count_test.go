@@ -0,0 +1,126 @@
+package count
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLinesPartialLine(t *testing.T) {
+	tests := []struct {
+		input        string
+		posix        int
+		countPartial int
+	}{
+		{"", 0, 0},
+		{"abc", 0, 1},
+		{"abc\n", 1, 1},
+		{"abc\ndef", 1, 2},
+		{"\n\n", 2, 2},
+		{"abc\r\n", 1, 1},
+		{"abc\x00", 0, 1},
+	}
+
+	for _, test := range tests {
+		if got, _ := Lines(strings.NewReader(test.input), []byte{'\n'}, false, 64*1024); got != test.posix {
+			t.Errorf("Lines(%q, false) = %d, want %d", test.input, got, test.posix)
+		}
+		if got, _ := Lines(strings.NewReader(test.input), []byte{'\n'}, true, 64*1024); got != test.countPartial {
+			t.Errorf("Lines(%q, true) = %d, want %d", test.input, got, test.countPartial)
+		}
+	}
+}
+
+func TestLinesDelimiter(t *testing.T) {
+	// Place a delimiter across the boundary of the 64 KiB read chunks
+	spanning := strings.Repeat("x", 64*1024-1) + ";;" + "y;;"
+
+	tests := []struct {
+		input     string
+		delimiter string
+		want      int
+	}{
+		{"a;b;c", ";", 2},
+		{"a;;b;;c;;", ";;", 3},
+		{"a;;;b", ";;", 1},
+		{spanning, ";;", 2},
+	}
+
+	for _, test := range tests {
+		got, _ := Lines(strings.NewReader(test.input), []byte(test.delimiter), false, 64*1024)
+		if got != test.want {
+			t.Errorf("Lines(%.20q, %q) = %d, want %d", test.input, test.delimiter, got, test.want)
+		}
+	}
+}
+
+func TestWordsBuffer(t *testing.T) {
+	var buffer bytes.Buffer
+	buffer.WriteString("one two\x01 \x01\x02 three\n")
+
+	var words []string
+	got, err := Words(&buffer, ScanWords, func(word []byte) {
+		words = append(words, string(word))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 || len(words) != 3 {
+		t.Errorf("Words = %d %q, want 3 words", got, words)
+	}
+}
+
+func FuzzCounters(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("hello world\n"))
+	f.Add([]byte("caf\xc3\xa9 \xff\xfe\x00\x00 na\xefve\r\n\r\r\n"))
+	f.Add([]byte("\xef\xbb\xbf;;a;;;b"))
+	f.Add(bytes.Repeat([]byte("x"), 100*1024))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+
+		runes, n, invalid, err := Chars(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(data) || runes > n || invalid > runes {
+			t.Errorf("Chars = %d runes, %d bytes, %d invalid for %d bytes", runes, n, invalid, len(data))
+		}
+
+		// Small reads must not change the counts of chunked counters
+		for _, size := range []int{1, 3, 4096} {
+			if got, _ := Bytes(bytes.NewReader(data), size); got != len(data) {
+				t.Errorf("Bytes(size %d) = %d, want %d", size, got, len(data))
+			}
+
+			newlines := bytes.Count(data, []byte{'\n'})
+			if got, _ := Lines(bytes.NewReader(data), []byte{'\n'}, false, size); got != newlines {
+				t.Errorf("Lines(size %d) = %d, want %d", size, got, newlines)
+			}
+
+			records := bytes.Count(data, []byte(";;"))
+			if got, _ := Lines(bytes.NewReader(data), []byte(";;"), false, size); got != records {
+				t.Errorf("Lines(;;, size %d) = %d, want %d", size, got, records)
+			}
+		}
+
+		lf, crlf, _, err := Newlines(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lf+crlf != bytes.Count(data, []byte{'\n'}) {
+			t.Errorf("Newlines = %d lf + %d crlf, want %d", lf, crlf, bytes.Count(data, []byte{'\n'}))
+		}
+
+		for _, split := range []bufio.SplitFunc{ScanWords, ScanUnicodeWords} {
+			words, err := Words(bytes.NewReader(data), split, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if words > runes {
+				t.Errorf("Words = %d, more than the %d runes", words, runes)
+			}
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+
+	"codechallenge/internal/cli"
+)
+
+// encodingSample is how much of the start of an input its encoding is
+// guessed from.
+const encodingSample = 8192
+
+// errBinary is returned for input that looks binary when -binary is skip
+// or fail.
+var errBinary = errors.New("binary file")
+
+// Encodings reported by -detect-encoding.
+const (
+	encodingASCII   = "ascii"
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8-bom"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingUTF32LE = "utf-32le"
+	encodingUTF32BE = "utf-32be"
+	encodingBinary  = "binary"
+
+	// encodingUnknown is text in some 8-bit encoding other than UTF-8,
+	// which cannot be told apart from the bytes alone
+	encodingUnknown = "unknown"
+
+	// encodingMixed is the encoding of a total over inputs that differ
+	encodingMixed = "mixed"
+)
+
+// byteOrderMarks are the byte order marks detectEncoding recognizes. The
+// UTF-32 marks come first, since the little-endian one starts with the
+// UTF-16 one.
+var byteOrderMarks = []struct {
+	mark     []byte
+	encoding string
+}{
+	{[]byte{0xFF, 0xFE, 0x00, 0x00}, encodingUTF32LE},
+	{[]byte{0x00, 0x00, 0xFE, 0xFF}, encodingUTF32BE},
+	{[]byte{0xEF, 0xBB, 0xBF}, encodingUTF8BOM},
+	{[]byte{0xFF, 0xFE}, encodingUTF16LE},
+	{[]byte{0xFE, 0xFF}, encodingUTF16BE},
+}
+
+// detectEncoding guesses the encoding of an input from sample, its first
+// bytes, and atEOF, whether the sample is all of it. A byte order mark
+// decides; otherwise input with NUL bytes is binary, and the rest is ASCII,
+// UTF-8 or unknown by whether it is valid UTF-8.
+func detectEncoding(sample []byte, atEOF bool) string {
+
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(sample, bom.mark) {
+			return bom.encoding
+		}
+	}
+
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return encodingBinary
+	}
+
+	// A character may be cut off at the end of the sample
+	if !atEOF {
+		for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+			if utf8.RuneStart(sample[len(sample)-i]) {
+				if !utf8.FullRune(sample[len(sample)-i:]) {
+					sample = sample[:len(sample)-i]
+				}
+				break
+			}
+		}
+	}
+
+	switch {
+	case !utf8.Valid(sample):
+		return encodingUnknown
+	case bytes.IndexFunc(sample, func(r rune) bool { return r >= utf8.RuneSelf }) >= 0:
+		return encodingUTF8
+	}
+	return encodingASCII
+}
+
+// decodeUTF16 returns input as UTF-8 if encoding is UTF-16, without its
+// byte order mark, and returns any other input as it is.
+func decodeUTF16(input io.Reader, encoding string) io.Reader {
+
+	var endianness unicode.Endianness
+	switch encoding {
+	case encodingUTF16LE:
+		endianness = unicode.LittleEndian
+	case encodingUTF16BE:
+		endianness = unicode.BigEndian
+	default:
+		return input
+	}
+
+	return transform.NewReader(input, unicode.UTF16(endianness, unicode.ExpectBOM).NewDecoder())
+}
+
+// mergeEncoding returns the encoding of a total that already covers
+// inputs in encoding total and now adds one in encoding.
+func mergeEncoding(total, encoding string) string {
+	if total == "" || total == encoding {
+		return encoding
+	}
+	return encodingMixed
+}
+
+func writeEncodingText(out io.Writer, results []Counts) {
+
+	fmt.Fprintln(out)
+
+	for _, counts := range results {
+		fmt.Fprintf(out, "%s: %s\n", cli.DisplayName(counts.File), counts.Encoding)
+	}
+}
+
+func writeEncodingDelimited(out io.Writer, results []Counts, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	writer.Write([]string{"file", "encoding"})
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+		writer.Write([]string{name, counts.Encoding})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"codechallenge/internal/cli"
+	"codechallenge/wc/count"
+)
+
+const (
+	// estimateBlocks blocks of estimateBlockSize bytes are sampled from
+	// each input by -estimate
+	estimateBlocks    = 64
+	estimateBlockSize = 64 * 1024
+
+	// Inputs smaller than this are counted exactly, as sampling would save
+	// little
+	minEstimateSize = 4 * estimateBlocks * estimateBlockSize
+
+	// z95 is the z-score of a two-sided 95% confidence interval
+	z95 = 1.96
+)
+
+// estimateColumns are the columns -estimate can estimate.
+var estimateColumns = []column{colLines, colWords, colChars, colBytes}
+
+// Estimate describes counts estimated from samples of an input, with
+// -estimate.
+type Estimate struct {
+	// Blocks is the number of blocks sampled
+	Blocks int
+
+	// margins is the 95% margin of error of each estimated column
+	margins map[column]float64
+}
+
+// Margin returns the 95% margin of error of the count of col.
+func (estimate *Estimate) Margin(col column) int {
+	return int(math.Round(estimate.margins[col]))
+}
+
+func (estimate *Estimate) merge(other *Estimate) {
+	estimate.Blocks += other.Blocks
+
+	// Errors of independent estimates add in quadrature
+	for col, margin := range other.margins {
+		estimate.margins[col] = math.Hypot(estimate.margins[col], margin)
+	}
+}
+
+// estimateFile estimates the counts of a file of size bytes from blocks
+// read at evenly spaced offsets, scaling up the mean count per block. The
+// margin of error comes from the spread of the block counts, with the
+// correction for sampling a finite population. Words and lines crossing
+// the edges of a block make for a small bias that the margin does not
+// cover.
+func estimateFile(ctx context.Context, file *os.File, name string, size int64, passes []column, opts options) (Counts, error) {
+
+	counts := Counts{File: name, Bytes: int(size)}
+	estimate := &Estimate{Blocks: estimateBlocks, margins: make(map[column]float64)}
+	counts.Estimate = estimate
+
+	samples := make(map[column][]float64)
+	block := make([]byte, estimateBlockSize)
+
+	for i := range int64(estimateBlocks) {
+		if err := ctx.Err(); err != nil {
+			return counts, &cli.FileError{File: name, Err: context.Cause(ctx)}
+		}
+
+		offset := i * (size - estimateBlockSize) / (estimateBlocks - 1)
+		if _, err := file.ReadAt(block, offset); err != nil && err != io.EOF {
+			return counts, &cli.FileError{File: name, Err: err}
+		}
+
+		for _, col := range passes {
+			var n int
+			switch col {
+			case colLines:
+				n = bytes.Count(block, opts.delimiter)
+			case colWords:
+				n, _ = count.Words(bytes.NewReader(block), opts.wordSplit, nil)
+			case colChars:
+				if opts.utf8Chars {
+					n = utf8.RuneCount(block)
+				} else {
+					n = len(block)
+				}
+			default:
+				continue
+			}
+			samples[col] = append(samples[col], float64(n))
+		}
+	}
+
+	scale := float64(size) / estimateBlockSize
+	correction := math.Sqrt(1 - float64(estimateBlocks*estimateBlockSize)/float64(size))
+
+	for col, values := range samples {
+		var sum, squares float64
+		for _, value := range values {
+			sum += value
+		}
+		mean := sum / float64(len(values))
+		for _, value := range values {
+			squares += (value - mean) * (value - mean)
+		}
+		stddev := math.Sqrt(squares / float64(len(values)-1))
+
+		switch col {
+		case colLines:
+			counts.Lines = int(math.Round(mean * scale))
+		case colWords:
+			counts.Words = int(math.Round(mean * scale))
+		case colChars:
+			counts.Chars = int(math.Round(mean * scale))
+		}
+		estimate.margins[col] = z95 * stddev / math.Sqrt(float64(len(values))) * correction * scale
+	}
+
+	return counts, nil
+}
+
+func writeEstimateText(out io.Writer, results []Counts) {
+
+	fmt.Fprintln(out)
+
+	for _, counts := range results {
+		estimate := counts.Estimate
+		if estimate == nil {
+			fmt.Fprintf(out, "%s: counted exactly\n", cli.DisplayName(counts.File))
+			continue
+		}
+
+		var margins []string
+		for _, col := range estimateColumns {
+			if _, ok := estimate.margins[col]; ok {
+				margins = append(margins, fmt.Sprintf("%s ±%d", col.name(), estimate.Margin(col)))
+			}
+		}
+		fmt.Fprintf(out, "%s: estimated from %d blocks, %s at 95%% confidence\n",
+			cli.DisplayName(counts.File), estimate.Blocks, strings.Join(margins, ", "))
+	}
+}
+
+func writeEstimateDelimited(out io.Writer, results []Counts, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	header := []string{"file", "blocks"}
+	for _, col := range estimateColumns {
+		header = append(header, col.name()+"_margin")
+	}
+	writer.Write(header)
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+
+		record := []string{name}
+		estimate := counts.Estimate
+		if estimate == nil {
+			estimate = &Estimate{}
+		}
+		record = append(record, strconv.Itoa(estimate.Blocks))
+		for _, col := range estimateColumns {
+			record = append(record, strconv.Itoa(estimate.Margin(col)))
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
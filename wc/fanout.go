@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"sync"
+
+	"codechallenge/internal/streamio"
+)
+
+// fanOut reads input once, bufferSize bytes at a time, and runs count for
+// each of passes concurrently, each over its own copy of the stream, so
+// inputs that cannot be rewound, such as pipes and decompressors, can still
+// be counted in several ways. It returns the first error from reading input
+// or from any pass.
+func fanOut(input io.Reader, passes []column, bufferSize int, count func(column, io.Reader) error) error {
+
+	if len(passes) == 1 {
+		return count(passes[0], input)
+	}
+
+	writers := make([]io.Writer, len(passes))
+	pipes := make([]*io.PipeWriter, len(passes))
+	errs := make([]error, len(passes))
+
+	var wg sync.WaitGroup
+
+	for i, col := range passes {
+		reader, writer := io.Pipe()
+		writers[i] = writer
+		pipes[i] = writer
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errs[i] = count(col, reader)
+
+			// Keep consuming a pass that stopped early so the others
+			// are not blocked
+			io.Copy(io.Discard, reader)
+		}()
+	}
+
+	copies := io.MultiWriter(writers...)
+	readErr := streamio.ForEachChunk(input, bufferSize, func(chunk []byte) error {
+		_, err := copies.Write(chunk)
+		return err
+	})
+
+	for _, pipe := range pipes {
+		pipe.CloseWithError(readErr)
+	}
+	wg.Wait()
+
+	// A read error is also seen by every pass, so report it first
+	if readErr != nil {
+		return readErr
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+// followedFile is a file watched by -f. It stays open between updates, and
+// only the bytes appended since the last update are read.
+type followedFile struct {
+	path string
+
+	file *os.File
+	info fs.FileInfo
+
+	// counted is how far file has been counted into counts, always just
+	// past a newline, so no word, character or line is split between two
+	// updates. The partial line after it is counted afresh each time.
+	counted int64
+	counts  Counts
+
+	// encoding is the encoding found at the start of the file
+	encoding string
+
+	// current is the counts of the whole file as of the last update
+	current Counts
+
+	// reported is the last error reported, so a file that stays missing is
+	// only reported once
+	reported string
+}
+
+// update counts whatever has changed in the file since the last update,
+// and reports whether anything had. A file moved away, as in log rotation,
+// is still counted until another takes its place, which is then counted
+// from the start, as is a file that was truncated.
+func (f *followedFile) update(ctx context.Context, passes []column, opts options) (bool, error) {
+
+	info, err := os.Stat(f.path)
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && f.file != nil:
+		info, err = f.file.Stat()
+
+	case err == nil && (f.file == nil || !os.SameFile(info, f.info)):
+		file, err := os.Open(f.path)
+		if err != nil {
+			return false, &cli.FileError{File: f.path, Err: err}
+		}
+		if f.file != nil {
+			f.file.Close()
+		}
+		f.file, f.info = file, nil
+		f.counted, f.counts = 0, Counts{}
+	}
+
+	if err != nil {
+		return false, &cli.FileError{File: f.path, Err: err}
+	}
+
+	if f.info != nil && info.Size() == f.info.Size() && info.ModTime().Equal(f.info.ModTime()) {
+		return false, nil
+	}
+
+	if info.Size() < f.counted {
+		f.counted, f.counts = 0, Counts{}
+	}
+
+	end, err := lastLineEnd(f.file, f.counted, info.Size())
+	if err != nil {
+		return false, &cli.FileError{File: f.path, Err: err}
+	}
+
+	lines, err := f.count(ctx, f.counted, end, passes, opts)
+	if err != nil {
+		return false, err
+	}
+	tail, err := f.count(ctx, end, info.Size(), passes, opts)
+	if err != nil {
+		return false, err
+	}
+
+	addCounts(&f.counts, lines)
+	f.counted = end
+	f.info = info
+
+	f.current = Counts{File: f.path}
+	addCounts(&f.current, f.counts)
+	addCounts(&f.current, tail)
+	f.current.Encoding = f.encoding
+
+	return true, nil
+}
+
+// count counts the bytes of the file from one offset to another. Byte
+// order marks, encodings and binary content are only looked for at the
+// start of the file, not in what is appended to it.
+func (f *followedFile) count(ctx context.Context, from int64, to int64, passes []column, opts options) (Counts, error) {
+
+	if from > 0 {
+		opts.skipBOM = false
+		opts.detectEncoding, opts.binary = "", "count"
+	}
+
+	counts, err := countReader(ctx, io.NewSectionReader(f.file, from, to-from), f.path, passes, opts)
+	if from == 0 {
+		f.encoding = counts.Encoding
+	}
+	counts.Encoding = ""
+
+	return counts, err
+}
+
+// lastLineEnd returns the offset just past the last newline in file
+// between from and to, or from if there is none, reading backwards from to.
+func lastLineEnd(file io.ReaderAt, from int64, to int64) (int64, error) {
+
+	block := make([]byte, 64*1024)
+
+	for end := to; end > from; {
+		start := max(from, end-int64(len(block)))
+
+		n, err := file.ReadAt(block[:end-start], start)
+		if err != nil && err != io.EOF {
+			return from, err
+		}
+		if i := bytes.LastIndexByte(block[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+
+		end = start
+	}
+
+	return from, nil
+}
+
+// follower counts a set of files as they grow, for -f.
+type follower struct {
+	files   []*followedFile
+	passes  []column
+	opts    options
+	timeout time.Duration
+}
+
+func newFollower(paths []string, passes []column, opts options, timeout time.Duration) *follower {
+
+	f := &follower{passes: passes, opts: opts, timeout: timeout}
+	for _, path := range paths {
+		f.files = append(f.files, &followedFile{path: path})
+	}
+
+	return f
+}
+
+// update brings the counts of every file up to date, reporting errors as
+// they change, and returns the counts, with a total when there are several
+// files. -timeout applies to each update on its own, so one slow update
+// does not end the run.
+func (f *follower) update() (results []Counts, changed bool) {
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if f.timeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, f.timeout, &timeoutError{f.timeout})
+	}
+	defer cancel()
+
+	total := Counts{File: "total"}
+
+	for _, file := range f.files {
+		fileChanged, err := file.update(ctx, f.passes, f.opts)
+		switch {
+		case err == nil:
+			file.reported = ""
+		case f.opts.binary == "skip" && errors.Is(err, errBinary):
+		case err.Error() != file.reported:
+			cli.Report(err)
+			file.reported = err.Error()
+		}
+		changed = changed || fileChanged
+
+		// Like countPaths, leave out files that could not be counted
+		if file.info == nil {
+			continue
+		}
+		results = append(results, file.current)
+		addCounts(&total, file.current)
+	}
+
+	if len(f.files) > 1 {
+		results = append(results, total)
+	}
+
+	return results, changed
+}
+
+// run reports the counts of the files, then checks the files every
+// interval and reports them again whenever any has changed. It runs until
+// the process is stopped.
+func (f *follower) run(interval time.Duration, report func(results []Counts)) {
+
+	results, _ := f.update()
+	report(results)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if results, changed := f.update(); changed {
+			report(results)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/template"
+	"text/template/parse"
+)
+
+// templateFields maps the fields of Counts that a -format template can
+// use to the column that counts them.
+var templateFields = map[string]column{
+	"Lines":      colLines,
+	"Words":      colWords,
+	"Chars":      colChars,
+	"Bytes":      colBytes,
+	"LF":         colLF,
+	"CRLF":       colLF,
+	"CR":         colLF,
+	"Invalid":    colInvalid,
+	"Blank":      colBlank,
+	"Whitespace": colBlank,
+	"NonBlank":   colBlank,
+}
+
+// parseFormat parses a -format template, which is executed with the Counts
+// of each input and of the total.
+func parseFormat(text string) (*template.Template, error) {
+	return template.New("format").Parse(text)
+}
+
+// templateColumns returns the columns needed for the fields of Counts that
+// tmpl uses, so that only those are counted.
+func templateColumns(tmpl *template.Template) []column {
+
+	var columns []column
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, child := range node.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if col, ok := templateFields[node.Ident[0]]; ok && !slices.Contains(columns, col) {
+				columns = append(columns, col)
+			}
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
+		}
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	return columns
+}
+
+// writeTemplate prints a line per result, formatted by tmpl.
+func writeTemplate(out io.Writer, results []Counts, tmpl *template.Template) error {
+
+	for _, counts := range results {
+		if err := tmpl.Execute(out, counts); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,9 +2,17 @@ module codechallenge/wc
 
 go 1.23.2
 
-require rsc.io/quote v1.5.2
+require (
+	codechallenge/internal v0.0.0
+	github.com/rivo/uniseg v0.4.7
+	github.com/rogpeppe/go-internal v1.13.1
+	golang.org/x/text v0.21.0
+)
 
 require (
-	golang.org/x/text v0.0.0-20170915032832-14c0d48ead0c // indirect
-	rsc.io/sampler v1.3.0 // indirect
+	github.com/klauspost/compress v1.17.11 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
 )
+
+replace codechallenge/internal => ../internal
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"codechallenge/internal/cli"
+)
+
+// runeClasses lists the histogram buckets of classes mode in report order.
+var runeClasses = []string{"letter", "mark", "digit", "space", "punct", "symbol", "control", "other", "invalid"}
+
+func runeClass(r rune, size int) string {
+	switch {
+	case r == utf8.RuneError && size == 1:
+		return "invalid"
+	case unicode.IsLetter(r):
+		return "letter"
+	case unicode.IsMark(r):
+		return "mark"
+	case unicode.IsNumber(r):
+		return "digit"
+	case unicode.IsSpace(r):
+		return "space"
+	case unicode.IsControl(r):
+		return "control"
+	case unicode.IsPunct(r):
+		return "punct"
+	case unicode.IsSymbol(r):
+		return "symbol"
+	default:
+		return "other"
+	}
+}
+
+// countHistogram tallies input by rune class, or by byte value when mode is
+// "bytes".
+func countHistogram(input io.Reader, mode string) (map[string]int, error) {
+
+	histogram := make(map[string]int)
+
+	reader := bufio.NewReader(input)
+
+	for {
+		var bucket string
+
+		if mode == "bytes" {
+			b, err := reader.ReadByte()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return histogram, err
+			}
+			bucket = fmt.Sprintf("0x%02X", b)
+		} else {
+			r, size, err := reader.ReadRune()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return histogram, err
+			}
+			bucket = runeClass(r, size)
+		}
+
+		histogram[bucket]++
+	}
+
+	return histogram, nil
+}
+
+// histogramBuckets returns the buckets to report in order: every rune class,
+// or the byte values that occur.
+func histogramBuckets(histogram map[string]int, mode string) []string {
+
+	if mode != "bytes" {
+		return runeClasses
+	}
+
+	var buckets []string
+	for bucket := range histogram {
+		buckets = append(buckets, bucket)
+	}
+	slices.Sort(buckets)
+
+	return buckets
+}
+
+func writeHistogramText(out io.Writer, results []Counts, mode string) {
+
+	for _, counts := range results {
+		fmt.Fprintf(out, "\n%s\n", cli.DisplayName(counts.File))
+
+		total := 0
+		for _, n := range counts.Histogram {
+			total += n
+		}
+
+		for _, bucket := range histogramBuckets(counts.Histogram, mode) {
+			n := counts.Histogram[bucket]
+
+			percent := 0.0
+			if total > 0 {
+				percent = float64(n) * 100 / float64(total)
+			}
+
+			fmt.Fprintf(out, "  %-8s %12d %6.2f%%\n", bucket, n, percent)
+		}
+	}
+}
+
+func writeHistogramDelimited(out io.Writer, results []Counts, mode string, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	writer.Write([]string{"file", "bucket", "count"})
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+
+		for _, bucket := range histogramBuckets(counts.Histogram, mode) {
+			writer.Write([]string{name, bucket, strconv.Itoa(counts.Histogram[bucket])})
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// textStyle controls how counts are rendered by the text output.
+type textStyle struct {
+	// human is "" for plain numbers, "grouped" for 1_234_567 or "short"
+	// for 1.2M
+	human string
+
+	// color highlights counts and file names with ANSI escapes
+	color bool
+}
+
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiCount = "\033[36m"
+	ansiFile  = "\033[34m"
+)
+
+// count renders n right aligned to width.
+func (style textStyle) count(n int, width int) string {
+
+	var text string
+	switch style.human {
+	case "grouped":
+		text = groupDigits(n)
+	case "short":
+		text = shortNumber(n)
+	default:
+		text = strconv.Itoa(n)
+	}
+
+	text = fmt.Sprintf("%*s", width, text)
+
+	if style.color {
+		return ansiCount + text + ansiReset
+	}
+	return text
+}
+
+func (style textStyle) file(name string, total bool) string {
+
+	if !style.color || name == "" {
+		return name
+	}
+
+	if total {
+		return ansiBold + name + ansiReset
+	}
+	return ansiFile + name + ansiReset
+}
+
+// groupDigits renders n with an underscore between groups of three digits.
+func groupDigits(n int) string {
+
+	digits := strconv.Itoa(n)
+
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte('_')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + grouped.String()
+}
+
+// shortNumber renders n with one decimal place and an SI suffix, such as
+// 1.2M, once it reaches a thousand.
+func shortNumber(n int) string {
+
+	value := float64(n)
+	suffixes := []string{"", "k", "M", "G", "T", "P"}
+
+	suffix := 0
+	for (value >= 1000 || value <= -1000) && suffix < len(suffixes)-1 {
+		value /= 1000
+		suffix++
+	}
+
+	if suffix == 0 {
+		return strconv.Itoa(n)
+	}
+
+	// Rounding can reach a thousand, as for 999,950, which is shown in the
+	// next unit instead
+	text := strconv.FormatFloat(value, 'f', 1, 64)
+	if strings.TrimPrefix(text, "-") == "1000.0" && suffix < len(suffixes)-1 {
+		value /= 1000
+		suffix++
+		text = strconv.FormatFloat(value, 'f', 1, 64)
+	}
+
+	return text + suffixes[suffix]
+}
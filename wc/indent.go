@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"codechallenge/internal/cli"
+)
+
+// IndentStats classifies the indentation of the non-blank lines of an
+// input, with -indent-stats.
+type IndentStats struct {
+	Tab        int
+	Space      int
+	Mixed      int
+	Unindented int
+
+	// widths counts the space-indented lines by indent width
+	widths map[int]int
+}
+
+func newIndentStats() *IndentStats {
+	return &IndentStats{widths: make(map[int]int)}
+}
+
+// countIndent reads input a byte at a time, looking only at the whitespace
+// at the start of each line, so long lines cost no memory.
+func countIndent(input io.Reader) (*IndentStats, error) {
+
+	stats := newIndentStats()
+
+	reader := bufio.NewReader(input)
+
+	// Whether the current line is still in its leading whitespace
+	leading := true
+	tabs, spaces := 0, 0
+
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if b == '\n' {
+			leading = true
+			tabs, spaces = 0, 0
+			continue
+		}
+		if !leading {
+			continue
+		}
+
+		switch b {
+		case '\t':
+			tabs++
+		case ' ':
+			spaces++
+		case '\r':
+			// Part of a CRLF, or trailing whitespace on a blank line
+		default:
+			stats.add(tabs, spaces)
+			leading = false
+		}
+	}
+
+	return stats, nil
+}
+
+func (stats *IndentStats) add(tabs int, spaces int) {
+	switch {
+	case tabs > 0 && spaces > 0:
+		stats.Mixed++
+	case tabs > 0:
+		stats.Tab++
+	case spaces > 0:
+		stats.Space++
+		stats.widths[spaces]++
+	default:
+		stats.Unindented++
+	}
+}
+
+func (stats *IndentStats) merge(other *IndentStats) {
+
+	stats.Tab += other.Tab
+	stats.Space += other.Space
+	stats.Mixed += other.Mixed
+	stats.Unindented += other.Unindented
+
+	for width, n := range other.widths {
+		stats.widths[width] += n
+	}
+}
+
+// CommonWidth is the most common width of the space-indented lines, the
+// narrower winning a tie, or 0 when there are none.
+func (stats *IndentStats) CommonWidth() int {
+
+	common := 0
+	for width, n := range stats.widths {
+		if n > stats.widths[common] || (n == stats.widths[common] && width < common) {
+			common = width
+		}
+	}
+
+	return common
+}
+
+func writeIndentStatsText(out io.Writer, results []Counts) {
+
+	fmt.Fprintln(out)
+
+	for _, counts := range results {
+		stats := counts.IndentStats
+		fmt.Fprintf(out, "%s: tab %d, space %d, mixed %d, unindented %d, most common indent %d spaces\n",
+			cli.DisplayName(counts.File), stats.Tab, stats.Space, stats.Mixed, stats.Unindented, stats.CommonWidth())
+	}
+}
+
+func writeIndentStatsDelimited(out io.Writer, results []Counts, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	writer.Write([]string{"file", "tab", "space", "mixed", "unindented", "common_width"})
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+
+		stats := counts.IndentStats
+		writer.Write([]string{
+			name,
+			strconv.Itoa(stats.Tab),
+			strconv.Itoa(stats.Space),
+			strconv.Itoa(stats.Mixed),
+			strconv.Itoa(stats.Unindented),
+			strconv.Itoa(stats.CommonWidth()),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
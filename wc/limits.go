@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"codechallenge/internal/cli"
+)
+
+// limits are the thresholds of the -max-* flags. A negative limit is not
+// checked.
+type limits struct {
+	lines int
+	words int
+	bytes int
+}
+
+// columns returns the counts that must be gathered to check the limits.
+func (lim limits) columns() []column {
+
+	var columns []column
+
+	if lim.lines >= 0 {
+		columns = append(columns, colLines)
+	}
+	if lim.words >= 0 {
+		columns = append(columns, colWords)
+	}
+	if lim.bytes >= 0 {
+		columns = append(columns, colBytes)
+	}
+
+	return columns
+}
+
+// check returns an error for each input in inputs that exceeds a limit.
+func (lim limits) check(inputs []Counts) []error {
+
+	var errs []error
+
+	exceeds := func(counts Counts, value int, limit int, what string) {
+		if limit >= 0 && value > limit {
+			errs = append(errs, &cli.FileError{
+				File: counts.File,
+				Err:  fmt.Errorf("%d %s exceeds -max-%s %d", value, what, what, limit),
+			})
+		}
+	}
+
+	for _, counts := range inputs {
+		exceeds(counts, counts.Lines, lim.lines, "lines")
+		exceeds(counts, counts.Words, lim.words, "words")
+		exceeds(counts, counts.Bytes, lim.bytes, "bytes")
+	}
+
+	return errs
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+
+	"codechallenge/internal/cli"
+)
+
+// tabWidth is the tab stop interval used when measuring lines in columns.
+const tabWidth = 8
+
+// LineLengthStats is the distribution of line lengths of an input, with
+// -line-length-stats. Lengths are kept as a histogram, so memory grows with
+// the number of distinct lengths rather than the number of lines.
+type LineLengthStats struct {
+	lengths map[int]int
+	lines   int
+}
+
+func newLineLengthStats() *LineLengthStats {
+	return &LineLengthStats{lengths: make(map[int]int)}
+}
+
+// countLineLengths measures each line of input, not counting its line
+// terminator, in runes or, when unit is "columns", in terminal columns.
+func countLineLengths(input io.Reader, unit string) (*LineLengthStats, error) {
+
+	stats := newLineLengthStats()
+
+	reader := bufio.NewReader(input)
+
+	length := 0
+	pendingCR := false
+	partial := false
+
+	for {
+		r, size, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if r == '\n' {
+			stats.add(length)
+			length, pendingCR, partial = 0, false, false
+			continue
+		}
+
+		// A CR only adds to the length if it is not part of a CRLF
+		if pendingCR {
+			length++
+		}
+		pendingCR = r == '\r'
+		partial = true
+		if pendingCR {
+			continue
+		}
+
+		switch {
+		case unit != "columns":
+			length++
+		case r == '\t':
+			length += tabWidth - length%tabWidth
+		case r == utf8.RuneError && size == 1:
+			length++
+		default:
+			length += uniseg.StringWidth(string(r))
+		}
+	}
+
+	if pendingCR {
+		length++
+	}
+	if partial {
+		stats.add(length)
+	}
+
+	return stats, nil
+}
+
+func (stats *LineLengthStats) add(length int) {
+	stats.lengths[length]++
+	stats.lines++
+}
+
+func (stats *LineLengthStats) merge(other *LineLengthStats) {
+	for length, n := range other.lengths {
+		stats.lengths[length] += n
+	}
+	stats.lines += other.lines
+}
+
+// Percentile returns the smallest length that at least p percent of the
+// lines do not exceed, using the nearest-rank method, or 0 for no lines.
+func (stats *LineLengthStats) Percentile(p float64) int {
+
+	if stats.lines == 0 {
+		return 0
+	}
+
+	rank := max(1, int(math.Ceil(p/100*float64(stats.lines))))
+
+	lengths := make([]int, 0, len(stats.lengths))
+	for length := range stats.lengths {
+		lengths = append(lengths, length)
+	}
+	slices.Sort(lengths)
+
+	seen := 0
+	for _, length := range lengths {
+		seen += stats.lengths[length]
+		if seen >= rank {
+			return length
+		}
+	}
+
+	return lengths[len(lengths)-1]
+}
+
+func (stats *LineLengthStats) Min() int    { return stats.Percentile(0) }
+func (stats *LineLengthStats) Median() int { return stats.Percentile(50) }
+func (stats *LineLengthStats) P95() int    { return stats.Percentile(95) }
+func (stats *LineLengthStats) Max() int    { return stats.Percentile(100) }
+
+func writeLineLengthStatsText(out io.Writer, results []Counts, unit string) {
+
+	fmt.Fprintln(out)
+
+	for _, counts := range results {
+		stats := counts.LineLengthStats
+		fmt.Fprintf(out, "%s: line length min %d, median %d, p95 %d, max %d %s\n",
+			cli.DisplayName(counts.File), stats.Min(), stats.Median(), stats.P95(), stats.Max(), unit)
+	}
+}
+
+func writeLineLengthStatsDelimited(out io.Writer, results []Counts, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	writer.Write([]string{"file", "min_length", "median_length", "p95_length", "max_length"})
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+
+		stats := counts.LineLengthStats
+		writer.Write([]string{
+			name,
+			strconv.Itoa(stats.Min()),
+			strconv.Itoa(stats.Median()),
+			strconv.Itoa(stats.P95()),
+			strconv.Itoa(stats.Max()),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/rogpeppe/go-internal/testscript"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of failing scripts")
+
+// TestMain lets the scripts run this test binary as ccwc.
+func TestMain(m *testing.M) {
+	os.Exit(testscript.RunMain(m, map[string]func() int{
+		"ccwc": func() int {
+			// Leave out the flags of the test binary
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			main()
+			return 0
+		},
+	}))
+}
+
+// TestScripts runs the end-to-end cases in testdata/script, each a txtar
+// archive of ccwc invocations with their expected output and exit status.
+// See https://pkg.go.dev/github.com/rogpeppe/go-internal/testscript for the
+// script language, to which status is added. Run with -update to rewrite
+// the expected output of failing cases.
+func TestScripts(t *testing.T) {
+	testscript.Run(t, testscript.Params{
+		Dir:           "testdata/script",
+		UpdateScripts: *update,
+		Cmds: map[string]func(ts *testscript.TestScript, neg bool, args []string){
+			"status": execStatus,
+		},
+	})
+}
+
+// execStatus runs a program as exec does, and checks that it exits with
+// exactly the status given: status N program [args...]. ! exec only
+// checks that the status is not 0.
+func execStatus(ts *testscript.TestScript, neg bool, args []string) {
+
+	if neg || len(args) < 2 {
+		ts.Fatalf("usage: status N program [args...]")
+	}
+	want, err := strconv.Atoi(args[0])
+	if err != nil {
+		ts.Fatalf("invalid exit status %q", args[0])
+	}
+
+	got := 0
+	var exitErr *exec.ExitError
+	switch err := ts.Exec(args[1], args[2:]...); {
+	case errors.As(err, &exitErr):
+		got = exitErr.ExitCode()
+	case err != nil:
+		ts.Fatalf("%v", err)
+	}
+
+	if got != want {
+		ts.Fatalf("exit status %d, want %d", got, want)
+	}
+}
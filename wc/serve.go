@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+)
+
+// errOutsideRoot is returned for a path that leads out of -serve-root.
+var errOutsideRoot = errors.New("path is outside the served directory")
+
+// countServer counts inputs sent over HTTP with the columns and options
+// given on the command line, answering with JSON: the body of a
+// POST /count, or the files named by GET /count?path=... under root.
+type countServer struct {
+	columns []column
+	passes  []column
+	opts    options
+
+	// root is the directory paths are resolved in, with symbolic links
+	// resolved, or "" if paths are not served
+	root string
+
+	// timeout bounds the counting of each request, if positive
+	timeout time.Duration
+
+	// maxBody is the largest body counted, if positive
+	maxBody int64
+}
+
+// countResult is the counts of one input, by column name.
+type countResult struct {
+	File   string         `json:"file,omitempty"`
+	Counts map[string]int `json:"counts"`
+}
+
+// countResponse is the answer to a request: a result per input, and their
+// total when there is more than one.
+type countResponse struct {
+	Results []countResult  `json:"results"`
+	Total   map[string]int `json:"total,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func newCountServer(columns, passes []column, opts options, root string, timeout time.Duration, maxBody int64) (*countServer, error) {
+
+	s := &countServer{columns: columns, passes: passes, opts: opts, timeout: timeout, maxBody: maxBody}
+
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err == nil {
+			abs, err = filepath.EvalSymlinks(abs)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.root = abs
+	}
+
+	// Progress lines would go to the server's terminal, not the client
+	s.opts.progress = false
+
+	return s, nil
+}
+
+func (s *countServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /count", s.countBody)
+	mux.HandleFunc("GET /count", s.countFiles)
+	return mux
+}
+
+// countBody counts the body of the request.
+func (s *countServer) countBody(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	var body io.Reader = r.Body
+	if s.maxBody > 0 {
+		body = streamio.LimitReader(body, s.maxBody)
+	}
+
+	counts, err := countReader(ctx, body, "", s.passes, s.opts)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, countResponse{Results: []countResult{s.result(counts)}})
+}
+
+// countFiles counts the files named by the path parameters, relative to
+// the served directory.
+func (s *countServer) countFiles(w http.ResponseWriter, r *http.Request) {
+
+	if s.root == "" {
+		writeJSON(w, http.StatusForbidden, errorResponse{"paths are not served; start ccwc with -serve-root"})
+		return
+	}
+	paths := r.URL.Query()["path"]
+	if len(paths) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"give the files to count as path parameters, or POST a body"})
+		return
+	}
+
+	ctx, cancel := s.context(r)
+	defer cancel()
+
+	var response countResponse
+	total := Counts{File: "total"}
+	for _, name := range paths {
+		counts, err := s.countFile(ctx, name)
+		if err != nil {
+			s.fail(w, err)
+			return
+		}
+		addCounts(&total, counts)
+		response.Results = append(response.Results, s.result(counts))
+	}
+	if len(paths) > 1 {
+		response.Total = s.result(total).Counts
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// countFile counts the file name in the served directory, refusing paths
+// that lead out of it, through .. or a symbolic link.
+func (s *countServer) countFile(ctx context.Context, name string) (Counts, error) {
+
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return Counts{}, errOutsideRoot
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(s.root, local))
+	if err != nil {
+		return Counts{}, err
+	}
+	if rel, err := filepath.Rel(s.root, path); err != nil || !filepath.IsLocal(rel) {
+		return Counts{}, errOutsideRoot
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Counts{}, err
+	}
+	defer file.Close()
+
+	return countFile(ctx, file, name, s.passes, s.opts)
+}
+
+// context returns the context to count a request in, limited to the
+// timeout if there is one.
+func (s *countServer) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeoutCause(r.Context(), s.timeout, &timeoutError{s.timeout})
+}
+
+func (s *countServer) result(counts Counts) countResult {
+
+	result := countResult{File: counts.File, Counts: make(map[string]int)}
+	for _, col := range s.columns {
+		result.Counts[col.name()] = col.value(counts)
+	}
+	return result
+}
+
+// fail answers with err and a status that fits it.
+func (s *countServer) fail(w http.ResponseWriter, err error) {
+
+	status := http.StatusBadRequest
+	var timeout *timeoutError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, errOutsideRoot), errors.Is(err, fs.ErrPermission):
+		status = http.StatusForbidden
+	case errors.As(err, &timeout), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, streamio.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	}
+
+	// Name files as the client did, not by where they are on the server
+	message := err.Error()
+	if s.root != "" {
+		message = strings.ReplaceAll(message, s.root+string(filepath.Separator), "")
+	}
+	writeJSON(w, status, errorResponse{message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// serveCounts serves s on addr until the process is interrupted.
+func serveCounts(addr string, s *countServer) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: addr, Handler: s.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	cli.Warn("serving counts on %s", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+// runSummary is the machine-readable account of a run written by -summary.
+type runSummary struct {
+	Files          int            `json:"files"`
+	Failures       int            `json:"failures"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+	Totals         map[string]int `json:"totals"`
+}
+
+func newRunSummary(inputs []Counts, failures int, total Counts, columns []column, elapsed time.Duration) runSummary {
+
+	summary := runSummary{
+		Files:          len(inputs),
+		Failures:       failures,
+		ElapsedSeconds: elapsed.Seconds(),
+		Totals:         make(map[string]int),
+	}
+
+	for _, col := range columns {
+		summary.Totals[col.name()] = col.value(total)
+	}
+
+	return summary
+}
+
+// writeSummary writes summary as JSON to target, which is a file path, or
+// fd:N for a file descriptor inherited from the caller, such as fd:3.
+func writeSummary(target string, summary runSummary) error {
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if fd, found := strings.CutPrefix(target, "fd:"); found {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid summary file descriptor %q", fd)
+		}
+
+		file := os.NewFile(uintptr(n), target)
+		if _, err := file.Write(data); err != nil {
+			return &cli.FileError{File: target, Err: err}
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(target, data, 0o644); err != nil {
+		return &cli.FileError{File: target, Err: err}
+	}
+	return nil
+}
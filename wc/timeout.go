@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+// errSkipped marks a file that was not counted because counting was
+// cancelled before it started.
+var errSkipped = errors.New("skipped")
+
+// timeoutGrace is how long a timed out run waits for reads to fail on their
+// own before it gives up on them.
+const timeoutGrace = time.Second
+
+// withTimeout returns a context that is cancelled after timeout, if it is
+// positive. Reads that cannot be interrupted, such as of a terminal or a
+// blocking pipe, may keep counting from returning, so shortly after the
+// timeout the run is ended with a report of it.
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), timeout, &timeoutError{timeout})
+
+	context.AfterFunc(ctx, func() {
+		if ctx.Err() != context.DeadlineExceeded {
+			return
+		}
+		time.Sleep(timeoutGrace)
+		cli.Exit(context.Cause(ctx))
+	})
+
+	return ctx, cancel
+}
+
+// timeoutError reports that counting took longer than -timeout.
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (err *timeoutError) Error() string {
+	return "timed out after " + err.timeout.String()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"hash/maphash"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// sketchPrecision is the number of hash bits that pick a register of the
+// approximate sketch: 2^14 registers, a standard error of about 0.8%.
+const sketchPrecision = 14
+
+// sketchSeed is shared so that the sketches of several inputs can be merged.
+var sketchSeed = maphash.MakeSeed()
+
+// UniqueLines collects the distinct lines of an input. Lines are kept in a
+// set unless approximate, in which case they are only hashed into a
+// HyperLogLog sketch whose size does not grow with the input.
+type UniqueLines struct {
+	lines     map[string]struct{}
+	registers []uint8
+}
+
+func newUniqueLines(approx bool) *UniqueLines {
+	if approx {
+		return &UniqueLines{registers: make([]uint8, 1<<sketchPrecision)}
+	}
+	return &UniqueLines{lines: make(map[string]struct{})}
+}
+
+func (unique *UniqueLines) add(line []byte) {
+
+	if unique.registers == nil {
+		unique.lines[string(line)] = struct{}{}
+		return
+	}
+
+	hash := maphash.Bytes(sketchSeed, line)
+
+	// The leading bits pick the register, which keeps the longest run of
+	// leading zeros seen in the remaining bits
+	register := hash >> (64 - sketchPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<sketchPrecision|1<<(sketchPrecision-1))) + 1
+	unique.registers[register] = max(unique.registers[register], rank)
+}
+
+func (unique *UniqueLines) merge(other *UniqueLines) {
+
+	for line := range other.lines {
+		unique.lines[line] = struct{}{}
+	}
+
+	for i, rank := range other.registers {
+		unique.registers[i] = max(unique.registers[i], rank)
+	}
+}
+
+// Count returns the number of distinct lines, estimated if approximate.
+func (unique *UniqueLines) Count() int {
+
+	if unique.registers == nil {
+		return len(unique.lines)
+	}
+
+	m := float64(len(unique.registers))
+
+	var sum float64
+	var empty int
+	for _, rank := range unique.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			empty++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	// Small cardinalities are estimated better by linear counting
+	if estimate <= 2.5*m && empty > 0 {
+		estimate = m * math.Log(m/float64(empty))
+	}
+
+	return int(math.Round(estimate))
+}
+
+// countUniqueLines collects the distinct lines of input, split at
+// delimiter. A final line without a delimiter is one of the lines.
+func countUniqueLines(input *bufio.Reader, delimiter []byte, approx bool) (*UniqueLines, error) {
+
+	unique := newUniqueLines(approx)
+	last := delimiter[len(delimiter)-1]
+
+	var line []byte
+
+	for {
+		chunk, err := input.ReadSlice(last)
+		line = append(line, chunk...)
+
+		if err == nil && bytes.HasSuffix(line, delimiter) {
+			unique.add(line[:len(line)-len(delimiter)])
+			line = line[:0]
+		}
+
+		switch err {
+		case nil, bufio.ErrBufferFull:
+		case io.EOF:
+			if len(line) > 0 {
+				unique.add(line)
+			}
+			return unique, nil
+		default:
+			return unique, err
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codechallenge/internal/cli"
+)
+
+// patternList is a flag.Value collecting a repeatable glob pattern flag.
+type patternList []string
+
+func (patterns *patternList) String() string {
+	return strings.Join(*patterns, ",")
+}
+
+func (patterns *patternList) Set(value string) error {
+	if _, err := filepath.Match(value, ""); err != nil {
+		return err
+	}
+	*patterns = append(*patterns, value)
+	return nil
+}
+
+// matches reports whether name matches any of the patterns.
+func (patterns patternList) matches(name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// walkOptions selects the files counted when descending into directories.
+// Patterns are matched against base names; excluded directories are not
+// descended into.
+type walkOptions struct {
+	include patternList
+	exclude patternList
+
+	// followSymlinks descends into and counts the targets of symbolic links
+	// found while walking
+	followSymlinks bool
+
+	// oneFileSystem keeps the walk on the file system of each root
+	oneFileSystem bool
+}
+
+// fileKey identifies a file independently of the path used to reach it.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// walker collects the files beneath a single root directory.
+type walker struct {
+	opts    walkOptions
+	rootDev uint64
+	files   []string
+	ok      bool
+
+	// ancestors holds the directories on the path from the root to the
+	// directory being walked, so a symbolic link back to one of them is
+	// recognised as a cycle
+	ancestors map[fileKey]bool
+}
+
+// expandPaths replaces each directory in paths with the regular files found
+// beneath it, in lexical order. Other paths are returned unchanged, so files
+// named on the command line are always counted. Directories that cannot be
+// read are reported on stderr, and ok is false if there were any.
+func expandPaths(paths []string, opts walkOptions) (files []string, ok bool) {
+
+	ok = true
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil || !info.IsDir() {
+			// Leave reporting the error to the counting loop
+			files = append(files, root)
+			continue
+		}
+
+		w := walker{opts: opts, ok: true, ancestors: make(map[fileKey]bool)}
+		if key, ok := fileKeyOf(info); ok {
+			w.rootDev = key.dev
+		}
+
+		w.walk(root, info)
+		files = append(files, w.files...)
+		ok = ok && w.ok
+	}
+
+	return files, ok
+}
+
+func (w *walker) walk(dir string, info fs.FileInfo) {
+
+	if key, ok := fileKeyOf(info); ok {
+		if w.ancestors[key] {
+			cli.Warn("%s: directory cycle detected, not descending", dir)
+			return
+		}
+		w.ancestors[key] = true
+		defer delete(w.ancestors, key)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		cli.Report(&cli.FileError{File: dir, Err: err})
+		w.ok = false
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		var info fs.FileInfo
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if !w.opts.followSymlinks {
+				continue
+			}
+
+			info, err = os.Stat(path)
+			if err != nil {
+				cli.Warn("%s: skipping broken symbolic link", path)
+				continue
+			}
+		} else {
+			info, err = entry.Info()
+			if err != nil {
+				cli.Report(&cli.FileError{File: path, Err: err})
+				w.ok = false
+				continue
+			}
+		}
+
+		if info.IsDir() {
+			if w.opts.exclude.matches(entry.Name()) {
+				continue
+			}
+			if key, ok := fileKeyOf(info); ok && w.opts.oneFileSystem && key.dev != w.rootDev {
+				continue
+			}
+
+			w.walk(path, info)
+			continue
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if len(w.opts.include) > 0 && !w.opts.include.matches(entry.Name()) {
+			continue
+		}
+		if w.opts.exclude.matches(entry.Name()) {
+			continue
+		}
+
+		w.files = append(w.files, path)
+	}
+}
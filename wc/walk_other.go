@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileKeyOf is not supported on this platform, so cycle detection and
+// -one-file-system are unavailable.
+func fileKeyOf(info fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func fileKeyOf(info fs.FileInfo) (fileKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
@@ -2,161 +2,1226 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+	"codechallenge/wc/count"
+)
+
+// utf8Locale reports whether the current locale uses UTF-8, consulting
+// LC_ALL, LC_CTYPE and LANG in the order POSIX gives them precedence. An
+// unset locale is treated as UTF-8, the native encoding of Go strings.
+func utf8Locale() bool {
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+
+		value = strings.ToLower(value)
+		return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+	}
+	return true
+}
+
+// Counts holds the tallies gathered for a single input.
+type Counts struct {
+	File  string
+	Lines int
+	Words int
+	Chars int
+	Bytes int
+
+	// Line terminators broken down by style
+	LF   int
+	CRLF int
+	CR   int
+
+	// Invalid UTF-8 sequences
+	Invalid int
+
+	// Size of the input before decompression
+	Compressed int
+
+	// Distinct lines, estimated with -unique-lines=approx
+	Unique int
+
+	// Lines broken down into empty, whitespace only and other lines. With
+	// -code, Blank also counts the whitespace-only lines.
+	Blank      int
+	Whitespace int
+	NonBlank   int
+
+	// Lines of code and of comments, with -code
+	Code    int
+	Comment int
+
+	// Frequencies by rune class or byte value, with -histogram
+	Histogram map[string]int
+
+	// Word lengths and vocabulary, with -word-stats
+	WordStats *WordStats
+
+	// Indentation of lines, with -indent-stats
+	IndentStats *IndentStats
+
+	// Distribution of line lengths, with -line-length-stats
+	LineLengthStats *LineLengthStats
+
+	// Set or sketch of the distinct lines, with -unique-lines
+	UniqueLines *UniqueLines
+
+	// Likely encoding of the input, with -detect-encoding
+	Encoding string
+
+	// How the counts were estimated, with -estimate, or nil if they were
+	// counted exactly
+	Estimate *Estimate
+}
+
+// column identifies one of the counts that can be reported.
+type column int
+
+const (
+	colLines column = iota
+	colWords
+	colChars
+	colBytes
+	colLF
+	colCRLF
+	colCR
+	colInvalid
+	colCompressed
+	colUnique
+	colBlank
+	colWhitespace
+	colNonBlank
+	colCode
+	colComment
+	colCodeBlank
+
+	// These are counted but reported separately from the columns
+	colHistogram
+	colWordStats
+	colIndentStats
+	colLineLengthStats
 )
 
-func countBytes(file *os.File) int {
+func (col column) name() string {
+	switch col {
+	case colLines:
+		return "lines"
+	case colWords:
+		return "words"
+	case colChars:
+		return "chars"
+	case colLF:
+		return "lf"
+	case colCRLF:
+		return "crlf"
+	case colCR:
+		return "cr"
+	case colInvalid:
+		return "invalid"
+	case colCompressed:
+		return "compressed"
+	case colUnique:
+		return "unique"
+	case colBlank, colCodeBlank:
+		return "blank"
+	case colWhitespace:
+		return "whitespace"
+	case colNonBlank:
+		return "nonblank"
+	case colCode:
+		return "code"
+	case colComment:
+		return "comment"
+	default:
+		return "bytes"
+	}
+}
+
+func (col column) value(counts Counts) int {
+	switch col {
+	case colLines:
+		return counts.Lines
+	case colWords:
+		return counts.Words
+	case colChars:
+		return counts.Chars
+	case colLF:
+		return counts.LF
+	case colCRLF:
+		return counts.CRLF
+	case colCR:
+		return counts.CR
+	case colInvalid:
+		return counts.Invalid
+	case colCompressed:
+		return counts.Compressed
+	case colUnique:
+		return counts.Unique
+	case colBlank, colCodeBlank:
+		return counts.Blank
+	case colWhitespace:
+		return counts.Whitespace
+	case colNonBlank:
+		return counts.NonBlank
+	case colCode:
+		return counts.Code
+	case colComment:
+		return counts.Comment
+	default:
+		return counts.Bytes
+	}
+}
+
+// options holds the settings that change how inputs are counted.
+type options struct {
+	wordSplit bufio.SplitFunc
+
+	// delimiter terminates the records counted by -l
+	delimiter []byte
+
+	// countPartialLine counts a final line that has no trailing newline
+	countPartialLine bool
+
+	// skipBOM leaves a leading byte order mark out of the character and
+	// word counts
+	skipBOM bool
+
+	// decompress counts the decompressed content of gzip, bzip2 and zstd
+	// inputs
+	decompress bool
+
+	// progress shows the bytes read and throughput on stderr
+	progress bool
+
+	// style controls how the text output renders counts
+	style textStyle
 
-	reader := bufio.NewReader(file)
+	// bufferSize is how many bytes are read at a time, or 0 to choose a
+	// size suited to each input
+	bufferSize int
 
-	count := 0
+	// jobs is how many files are counted at once
+	jobs int
 
-	buffer := make([]byte, 1024) // Read in 1KB chunks
+	// width is the width the text output pads counts to
+	width int
 
-	for {
-		chunk_count, err := reader.Read(buffer)
-		count += chunk_count
+	// histogram selects the buckets of the histogram: "classes" for rune
+	// classes or "bytes" for byte values
+	histogram string
 
+	// wordStats collects word lengths and vocabulary while counting words
+	wordStats bool
+
+	// indentStats classifies the indentation of lines
+	indentStats bool
+
+	// lineLengthUnit measures line lengths in "runes" or "columns", or is
+	// empty when line lengths are not wanted
+	lineLengthUnit string
+
+	// uniqueLines counts distinct lines "exact"ly or "approx"imately
+	uniqueLines string
+
+	// template, if set, formats each result in place of the output format
+	template *template.Template
+
+	// estimate samples large files rather than reading them in full
+	estimate bool
+
+	// normalize is the Unicode normalization form, "nfc" or "nfd", applied
+	// to the text before characters and words are counted, if any
+	normalize string
+
+	// utf8Chars makes -m count runes rather than bytes
+	utf8Chars bool
+
+	// invalidUTF8 selects what -m reports for input that is not valid
+	// UTF-8: "bytes" falls back to the byte count, "runes" keeps counting
+	// each invalid byte as a character
+	invalidUTF8 string
+
+	// members counts each file in zip and tar archives in place of the
+	// archive itself
+	members bool
+
+	// detectEncoding guesses the encoding of each input when set: "report"
+	// only reports it, and "transcode" also counts UTF-16 input as the text
+	// it encodes
+	detectEncoding string
+
+	// binary is what to do with input that looks binary: "count" it like
+	// any other, "skip" it or "fail" on it
+	binary string
+}
+
+// countFile counts file with countReader, sizing reads to the file and
+// showing progress if asked to.
+func countFile(ctx context.Context, file *os.File, name string, passes []column, opts options) (Counts, error) {
+
+	streamio.SetReadDeadline(ctx, file)
+
+	if opts.estimate {
+		info, err := file.Stat()
+		if err == nil && info.Mode().IsRegular() && info.Size() >= minEstimateSize {
+			return estimateFile(ctx, file, name, info.Size(), passes, opts)
+		}
+	}
+
+	if opts.bufferSize == 0 {
+		opts.bufferSize = streamio.BufferSizeFor(file)
+	}
+
+	var source io.Reader = file
+
+	if opts.progress {
+		progress := streamio.NewProgressReader(file, cli.DisplayName(name), os.Stderr)
+		defer progress.Finish()
+		source = progress
+	}
+
+	return countReader(ctx, source, name, passes, opts)
+}
+
+// countReader reads source once, making each of passes over its content,
+// and returns the counts gathered. Counting stops at the first error, or
+// once ctx is done, with the error returned as a *cli.FileError.
+func countReader(ctx context.Context, source io.Reader, name string, passes []column, opts options) (Counts, error) {
+
+	counts := Counts{File: name}
+
+	if opts.bufferSize == 0 {
+		opts.bufferSize = streamio.StreamBufferSize
+	}
+
+	raw := &streamio.CountingReader{Reader: streamio.ContextReader(ctx, source)}
+	var input io.Reader = raw
+
+	if opts.decompress {
+		reader, err := streamio.Decompress(raw)
 		if err != nil {
-			if err == io.EOF {
-				break // End of file
+			return counts, &cli.FileError{File: name, Err: err}
+		}
+		defer reader.Close()
+		input = reader
+	}
+
+	if opts.detectEncoding != "" || opts.binary == "skip" || opts.binary == "fail" {
+		buffered := bufio.NewReaderSize(input, encodingSample)
+		sample, err := buffered.Peek(encodingSample)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return counts, &cli.FileError{File: name, Err: err}
+		}
+		encoding := detectEncoding(sample, err == io.EOF)
+		if encoding == encodingBinary && (opts.binary == "skip" || opts.binary == "fail") {
+			return counts, &cli.FileError{File: name, Err: errBinary}
+		}
+		if opts.detectEncoding != "" {
+			counts.Encoding = encoding
+		}
+		input = buffered
+	}
+
+	// colCompressed is counted as a side effect of reading the input
+	var contentPasses []column
+	for _, col := range passes {
+		if col != colCompressed {
+			contentPasses = append(contentPasses, col)
+		}
+	}
+
+	err := fanOut(input, contentPasses, opts.bufferSize, func(col column, input io.Reader) error {
+		return countColumn(&counts, col, input, opts)
+	})
+	if err != nil {
+		return counts, &cli.FileError{File: name, Err: err}
+	}
+
+	if slices.Contains(passes, colCompressed) {
+		counts.Compressed = int(raw.N)
+	}
+
+	return counts, nil
+}
+
+func countColumn(counts *Counts, col column, input io.Reader, opts options) error {
+
+	// Only the byte count is of the input as stored
+	if opts.detectEncoding == "transcode" && col != colBytes {
+		input = decodeUTF16(input, counts.Encoding)
+	}
+
+	// Counters that read through a bufio.Reader reuse this one, sized to
+	// the buffer size chosen for the input
+	buffered := bufio.NewReaderSize(input, opts.bufferSize)
+
+	// The byte order mark is still included in the byte count
+	text := func() io.Reader {
+		var text io.Reader = buffered
+		if opts.skipBOM {
+			text = count.SkipBOM(text)
+		}
+		switch opts.normalize {
+		case "nfc":
+			text = norm.NFC.Reader(text)
+		case "nfd":
+			text = norm.NFD.Reader(text)
+		}
+		return text
+	}
+
+	var err error
+
+	switch col {
+	case colLines:
+		counts.Lines, err = count.Lines(input, opts.delimiter, opts.countPartialLine, opts.bufferSize)
+	case colWords, colWordStats:
+		var addWord func(word []byte)
+		if opts.wordStats {
+			counts.WordStats = newWordStats()
+			addWord = counts.WordStats.add
+		}
+		counts.Words, err = count.Words(text(), opts.wordSplit, addWord)
+	case colChars:
+		var runes, bytes, invalid int
+		runes, bytes, invalid, err = count.Chars(text())
+
+		switch {
+		case !opts.utf8Chars:
+			counts.Chars = bytes
+		case invalid > 0 && opts.invalidUTF8 == "bytes":
+			cli.Warn("%s: input is not valid UTF-8, counting bytes for -m", cli.DisplayName(counts.File))
+			counts.Chars = bytes
+		default:
+			counts.Chars = runes
+		}
+	case colBytes:
+		counts.Bytes, err = count.Bytes(input, opts.bufferSize)
+	case colLF:
+		counts.LF, counts.CRLF, counts.CR, err = count.Newlines(buffered)
+	case colBlank:
+		counts.Blank, counts.Whitespace, counts.NonBlank, err = countBlank(buffered)
+	case colCode:
+		lang := languageOf(counts.File)
+		if lang == nil {
+			cli.Warn("%s: unrecognized language, lines not classified", cli.DisplayName(counts.File))
+			break
+		}
+		// colCode fills in the blank lines of colCodeBlank
+		counts.Code, counts.Comment, counts.Blank, err = countCode(buffered, lang)
+	case colInvalid:
+		_, _, counts.Invalid, err = count.Chars(buffered)
+	case colHistogram:
+		counts.Histogram, err = countHistogram(buffered, opts.histogram)
+	case colIndentStats:
+		counts.IndentStats, err = countIndent(buffered)
+	case colLineLengthStats:
+		counts.LineLengthStats, err = countLineLengths(buffered, opts.lineLengthUnit)
+	case colUnique:
+		counts.UniqueLines, err = countUniqueLines(buffered, opts.delimiter, opts.uniqueLines == "approx")
+		counts.Unique = counts.UniqueLines.Count()
+	}
+
+	return err
+}
+
+func addCounts(total *Counts, counts Counts) {
+	total.Lines += counts.Lines
+	total.Words += counts.Words
+	total.Chars += counts.Chars
+	total.Bytes += counts.Bytes
+	total.LF += counts.LF
+	total.CRLF += counts.CRLF
+	total.CR += counts.CR
+	total.Invalid += counts.Invalid
+	total.Compressed += counts.Compressed
+	total.Blank += counts.Blank
+	total.Whitespace += counts.Whitespace
+	total.NonBlank += counts.NonBlank
+	total.Code += counts.Code
+	total.Comment += counts.Comment
+	total.Encoding = mergeEncoding(total.Encoding, counts.Encoding)
+
+	if counts.Histogram != nil && total.Histogram == nil {
+		total.Histogram = make(map[string]int)
+	}
+	for bucket, n := range counts.Histogram {
+		total.Histogram[bucket] += n
+	}
+
+	if counts.WordStats != nil {
+		if total.WordStats == nil {
+			total.WordStats = newWordStats()
+		}
+		total.WordStats.merge(counts.WordStats)
+	}
+
+	if counts.IndentStats != nil {
+		if total.IndentStats == nil {
+			total.IndentStats = newIndentStats()
+		}
+		total.IndentStats.merge(counts.IndentStats)
+	}
+
+	if counts.LineLengthStats != nil {
+		if total.LineLengthStats == nil {
+			total.LineLengthStats = newLineLengthStats()
+		}
+		total.LineLengthStats.merge(counts.LineLengthStats)
+	}
+
+	// Lines repeated across inputs are only counted once in the total
+	if counts.UniqueLines != nil {
+		if total.UniqueLines == nil {
+			total.UniqueLines = newUniqueLines(counts.UniqueLines.registers != nil)
+		}
+		total.UniqueLines.merge(counts.UniqueLines)
+		total.Unique = total.UniqueLines.Count()
+	}
+
+	if counts.Estimate != nil {
+		if total.Estimate == nil {
+			total.Estimate = &Estimate{margins: make(map[column]float64)}
+		}
+		total.Estimate.merge(counts.Estimate)
+	}
+}
+
+// writeText prints a line of counts per result in the layout of GNU wc: each
+// count right aligned to width and separated by a space, then the file name
+// unless the input was standard input.
+func writeText(out io.Writer, results []Counts, columns []column, style textStyle, width int) {
+
+	for i, counts := range results {
+		for j, col := range columns {
+			if j > 0 {
+				fmt.Fprint(out, " ")
 			}
-			log.Fatal(err)
+			fmt.Fprint(out, style.count(col.value(counts), width))
 		}
+
+		// A total is always the last of several results
+		total := len(results) > 1 && i == len(results)-1
+		if counts.File != "" {
+			fmt.Fprintf(out, " %s", style.file(counts.File, total))
+		}
+		fmt.Fprintln(out)
 	}
+}
 
-	return count
+// statInput describes the named input, which is standard input for "-".
+func statInput(path string) (os.FileInfo, error) {
+	if path == cli.Stdin {
+		return os.Stdin.Stat()
+	}
+	return os.Stat(path)
 }
 
-func countLines(file *os.File) int {
+// numberWidth picks the width of the text output's counts as GNU wc does:
+// wide enough for the combined size of the regular files among paths, and at
+// least 7 when any input is standard input or another kind of file, whose
+// size is not known in advance. A single count of a single input is not
+// padded.
+func numberWidth(paths []string, columns []column) int {
 
-	count := 0
+	if len(paths) <= 1 && len(columns) == 1 {
+		return 1
+	}
 
-	// Read the file contents
-	scanner := bufio.NewScanner(file)
+	if len(paths) == 0 {
+		return 7
+	}
 
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		count++
+	// Like GNU wc, give up on padding when the first input is missing
+	if _, err := statInput(paths[0]); err != nil {
+		return 1
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.SetOutput(os.Stderr)
-		log.Printf("reading input to count lines: %v\n", err)
+	minimum := 1
+	var size int64
+
+	for _, path := range paths {
+		info, err := statInput(path)
+		if err != nil {
+			continue
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		} else {
+			minimum = 7
+		}
 	}
 
-	return count
+	return max(len(strconv.FormatInt(size, 10)), minimum)
+}
+
+func writeDelimited(out io.Writer, results []Counts, columns []column, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	header := []string{"file"}
+	for _, col := range columns {
+		header = append(header, col.name())
+	}
+	writer.Write(header)
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+
+		record := []string{name}
+		for _, col := range columns {
+			record = append(record, strconv.Itoa(col.value(counts)))
+		}
+		writer.Write(record)
+	}
 
+	writer.Flush()
+	return writer.Error()
 }
 
-func countWords(file *os.File) int {
+// countPaths counts each of the named files, up to opts.jobs at a time,
+// adding a total when there is more than one or when archive members are
+// counted. Results are in the order of paths however the work is
+// scheduled, with the members of an archive in its place. A file that
+// cannot be counted is reported on stderr and skipped, and failed counts
+// it once all the others have been counted. Files not yet started when ctx
+// is done are skipped with a single report of why.
+func countPaths(ctx context.Context, paths []string, passes []column, opts options) (results []Counts, total Counts, failed int) {
 
-	count := 0
+	counted := make([][]Counts, len(paths))
+	errs := make([]error, len(paths))
 
-	// Read the file contents
-	scanner := bufio.NewScanner(file)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
 
-	scanner.Split(bufio.ScanWords)
-	for scanner.Scan() {
-		count++
+	for range min(max(opts.jobs, 1), len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					errs[i] = errSkipped
+					continue
+				}
+				if opts.members && archiveKind(paths[i]) != "" {
+					counted[i], errs[i] = countArchive(ctx, paths[i], passes, opts)
+					continue
+				}
+				var counts Counts
+				counts, errs[i] = countPath(ctx, paths[i], passes, opts)
+				counted[i] = []Counts{counts}
+			}
+		}()
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.SetOutput(os.Stderr)
-		log.Printf("reading input to count words: %v\n", err)
+	for i := range paths {
+		indexes <- i
 	}
+	close(indexes)
+	wg.Wait()
 
-	return count
+	total = Counts{File: "total"}
 
+	skipped := false
+
+	for i, members := range counted {
+		if errs[i] == errSkipped {
+			skipped = true
+			failed++
+			continue
+		}
+		if opts.binary == "skip" && errors.Is(errs[i], errBinary) {
+			continue
+		}
+		if errs[i] != nil {
+			cli.Report(errs[i])
+			failed++
+			continue
+		}
+
+		for _, counts := range members {
+			addCounts(&total, counts)
+			results = append(results, counts)
+		}
+	}
+
+	if skipped {
+		cli.Report(context.Cause(ctx))
+	}
+
+	if hasTotal(paths, opts) {
+		results = append(results, total)
+	}
+
+	return results, total, failed
+}
+
+// hasTotal reports whether the results of counting paths end with a
+// total.
+func hasTotal(paths []string, opts options) bool {
+	return len(paths) > 1 || opts.members
+}
+
+func countPath(ctx context.Context, filePath string, passes []column, opts options) (Counts, error) {
+
+	// Open the file, or standard input for "-"
+	file, closeFile, file_err := cli.Open(filePath)
+
+	if file_err != nil {
+		return Counts{File: filePath}, &cli.FileError{File: filePath, Err: file_err}
+	}
+	defer closeFile()
+
+	return countFile(ctx, file, filePath, passes, opts)
+}
+
+// section is a statistic reported after the counts, in a table of its own.
+type section struct {
+	writeText      func(out io.Writer, results []Counts)
+	writeDelimited func(out io.Writer, results []Counts, comma rune) error
+}
+
+// sections returns the statistics requested by opts in report order.
+func sections(opts options) []section {
+
+	var sections []section
+
+	if opts.histogram != "" {
+		sections = append(sections, section{
+			writeText: func(out io.Writer, results []Counts) {
+				writeHistogramText(out, results, opts.histogram)
+			},
+			writeDelimited: func(out io.Writer, results []Counts, comma rune) error {
+				return writeHistogramDelimited(out, results, opts.histogram, comma)
+			},
+		})
+	}
+	if opts.wordStats {
+		sections = append(sections, section{writeWordStatsText, writeWordStatsDelimited})
+	}
+	if opts.indentStats {
+		sections = append(sections, section{writeIndentStatsText, writeIndentStatsDelimited})
+	}
+	if opts.lineLengthUnit != "" {
+		sections = append(sections, section{
+			writeText: func(out io.Writer, results []Counts) {
+				writeLineLengthStatsText(out, results, opts.lineLengthUnit)
+			},
+			writeDelimited: writeLineLengthStatsDelimited,
+		})
+	}
+	if opts.estimate {
+		sections = append(sections, section{writeEstimateText, writeEstimateDelimited})
+	}
+	if opts.detectEncoding != "" {
+		sections = append(sections, section{writeEncodingText, writeEncodingDelimited})
+	}
+
+	return sections
 }
 
-func countChars(file *os.File) int {
+func writeResults(out io.Writer, format string, results []Counts, columns []column, opts options) error {
 
-	count := 0
+	if opts.template != nil {
+		return writeTemplate(out, results, opts.template)
+	}
 
-	// Read the file contents
-	scanner := bufio.NewScanner(file)
+	if format == "text" {
+		writeText(out, results, columns, opts.style, opts.width)
+		for _, section := range sections(opts) {
+			section.writeText(out, results)
+		}
+		return nil
+	}
 
-	scanner.Split(bufio.ScanRunes)
-	for scanner.Scan() {
-		count++
+	comma := ','
+	if format == "tsv" {
+		comma = '\t'
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.SetOutput(os.Stderr)
-		log.Printf("reading input to count chars: %v\n", err)
+	if err := writeDelimited(out, results, columns, comma); err != nil {
+		return err
+	}
+	for _, section := range sections(opts) {
+		if err := section.writeDelimited(out, results, comma); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return count
+func usage() {
+	out := flag.CommandLine.Output()
 
+	fmt.Fprintln(out, "Usage: ccwc [flags] [file ...]")
+	fmt.Fprintln(out, "Count the lines, words, characters and bytes of each file, or of standard input")
+	fmt.Fprintln(out, "when there are none or the file is -.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Exit status:")
+	fmt.Fprintf(out, "  %d  every input was counted\n", cli.ExitOK)
+	fmt.Fprintf(out, "  %d  an input could not be counted, exceeded a -max-* limit, was binary with -binary=fail, or -fail-invalid-utf8 found invalid UTF-8\n", cli.ExitFailure)
+	fmt.Fprintf(out, "  %d  invalid flags or arguments\n", cli.ExitUsage)
+}
+
+// parseDelimiter parses a -delimiter value. The escapes \n, \r, \t, \0,
+// \\ and \xHH stand for the bytes they name, and any other character,
+// quotes included, stands for itself. Any other escape is an error.
+func parseDelimiter(value string) ([]byte, error) {
+
+	escapes := map[byte]byte{'n': '\n', 'r': '\r', 't': '\t', '0': 0, '\\': '\\'}
+
+	var delim []byte
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			delim = append(delim, value[i])
+			continue
+		}
+
+		if i+1 == len(value) {
+			return nil, errors.New("trailing backslash")
+		}
+
+		if b, ok := escapes[value[i+1]]; ok {
+			delim = append(delim, b)
+			i++
+			continue
+		}
+
+		if value[i+1] != 'x' {
+			return nil, fmt.Errorf("unknown escape \\%c", value[i+1])
+		}
+
+		hex := value[i+2 : min(i+4, len(value))]
+		b, err := strconv.ParseUint(hex, 16, 8)
+		if err != nil || len(hex) != 2 {
+			return nil, errors.New("\\x needs two hex digits")
+		}
+		delim = append(delim, byte(b))
+		i += 3
+	}
+
+	if len(delim) == 0 {
+		return nil, errors.New("delimiter is empty")
+	}
+	return delim, nil
 }
 
 func main() {
 
+	cli.Name = "ccwc"
+
 	// Define flags
 	c := flag.Bool("c", false, "print no of bytes in file")
 	l := flag.Bool("l", false, "print no of lines in file")
 	w := flag.Bool("w", false, "print no of words in file")
 	m := flag.Bool("m", false, "print no of characters in file")
+	output := flag.String("output", "text", "output format: text, csv or tsv")
+	words := flag.String("words", "ascii", "word segmentation: ascii (whitespace) or unicode (UAX #29)")
+	newlineStats := flag.Bool("newline-stats", false, "also print line terminators broken down by LF, CRLF and CR")
+	countPartial := flag.Bool("count-partial-line", false, "count a final line that has no trailing newline")
+	delimiter := flag.String("delimiter", `\n`, "record delimiter counted by -l, a byte or string in which \\n, \\r, \\t, \\0, \\\\ and \\xHH stand for those bytes")
+	skipBOMFlag := flag.Bool("skip-bom", false, "do not count a leading UTF-8 byte order mark as a character or word")
+	strictUTF8 := flag.Bool("strict-utf8", false, "also print the number of invalid UTF-8 sequences")
+	failInvalid := flag.Bool("fail-invalid-utf8", false, "like -strict-utf8, and exit non-zero if any are found")
+	var recursive bool
+	flag.BoolVar(&recursive, "r", false, "count the files in directories recursively")
+	flag.BoolVar(&recursive, "recursive", false, "same as -r")
+	var walkOpts walkOptions
+	flag.Var(&walkOpts.include, "include", "in recursive mode, only count files whose name matches this glob (repeatable)")
+	flag.Var(&walkOpts.exclude, "exclude", "in recursive mode, skip files and directories whose name matches this glob (repeatable)")
+	flag.BoolVar(&walkOpts.followSymlinks, "follow-symlinks", false, "in recursive mode, follow symbolic links, skipping any that form a cycle")
+	flag.BoolVar(&walkOpts.oneFileSystem, "one-file-system", false, "in recursive mode, do not descend into directories on other file systems")
+	decompressFlag := flag.Bool("decompress", false, "count the decompressed content of gzip, bzip2 and zstd inputs")
+	compressedBytes := flag.Bool("compressed-bytes", false, "with -decompress, also print the size of each input before decompression")
+	progressFlag := flag.Bool("progress", false, "show bytes processed and throughput on stderr when it is a terminal")
+	var followFlag bool
+	flag.BoolVar(&followFlag, "f", false, "keep watching the files and print updated counts as they change")
+	flag.BoolVar(&followFlag, "follow", false, "same as -f")
+	interval := flag.Duration("interval", time.Second, "with -f, how often to check the files for changes")
+	histogram := flag.String("histogram", "", "also print frequencies by rune class (classes) or byte value (bytes)")
+	wordStats := flag.Bool("word-stats", false, "also print the longest word, average word length and vocabulary size")
+	var lim limits
+	flag.IntVar(&lim.lines, "max-lines", -1, "exit non-zero if any input has more than this many lines")
+	flag.IntVar(&lim.words, "max-words", -1, "exit non-zero if any input has more than this many words")
+	flag.IntVar(&lim.bytes, "max-bytes", -1, "exit non-zero if any input has more than this many bytes")
+	human := &cli.OptionalFlag{Implied: "short", Allowed: []string{"", "short", "grouped"}}
+	flag.Var(human, "human", "in text output, humanize large counts: short (1.2M, the default) or grouped (1_234_567)")
+	color := &cli.OptionalFlag{Implied: "auto", Allowed: []string{"", "auto", "always", "never"}}
+	flag.Var(color, "color", "in text output, colorize counts and file names: auto (on a terminal, the default), always or never")
+	bufferSize := flag.String("buffer-size", "auto", "bytes to read at a time, such as 64K or 1M, or auto to size for each input")
+	indentStats := flag.Bool("indent-stats", false, "also print counts of tab- and space-indented lines and the most common indent width")
+	lineLengthStats := &cli.OptionalFlag{Implied: "runes", Allowed: []string{"", "runes", "columns"}}
+	flag.Var(lineLengthStats, "line-length-stats", "also print min, median, p95 and max line lengths in runes (the default) or columns")
+	jobs := flag.Int("j", runtime.GOMAXPROCS(0), "count up to this many files at once; -progress counts one at a time")
+	uniqueLines := &cli.OptionalFlag{Implied: "exact", Allowed: []string{"", "exact", "approx"}}
+	flag.Var(uniqueLines, "u", "also count distinct lines: exact (the default) or approx, estimated in constant memory")
+	flag.Var(uniqueLines, "unique-lines", "same as -u")
+	blank := flag.Bool("blank", false, "also print the number of blank, whitespace-only and other lines")
+	code := flag.Bool("code", false, "also classify lines as code, comment or blank by the comment markers of the file's language")
+	format := flag.String("format", "", "print each result with this Go template over its counts, such as '{{.Lines}} {{.File}}'")
+	summary := flag.String("summary", "", "when done, write a JSON summary of the run to this file, or to a descriptor such as fd:3")
+	timeout := flag.Duration("timeout", 0, "give up counting after this long, such as 30s, for inputs that may hang; 0 waits forever")
+	estimate := flag.Bool("estimate", false, "estimate -l, -w and -m for large files from sampled blocks, with a 95% margin of error")
+	normalize := flag.String("normalize", "", "normalize text to nfc or nfd before counting characters and words, so counts do not depend on how combining characters were encoded")
+	compare := flag.Bool("compare", false, "count exactly two files and print the difference from the first to the second")
+	invalidUTF8 := flag.String("invalid-utf8", "bytes", "what -m counts for input that is not valid UTF-8: bytes or runes")
+	detectEncodingFlag := &cli.OptionalFlag{Implied: "report", Allowed: []string{"", "report", "transcode"}}
+	flag.Var(detectEncodingFlag, "detect-encoding", "also print the likely encoding of each input from its byte order mark and first block: report (the default), or transcode to also count UTF-16 input as the text it encodes for all but -c")
+	binary := flag.String("binary", "count", "what to do with inputs that look binary, having NUL bytes in their first block: count, skip or fail")
+	members := flag.Bool("members", false, "count each file in .zip and .tar archives, which may be compressed, in place of the archive, as archive:member")
+	serve := flag.String("serve", "", "rather than counting files, serve counts as JSON over HTTP on this `address`: of the body POSTed to /count, or of the files named by GET /count?path=...")
+	serveRoot := flag.String("serve-root", "", "with -serve, count files named by path only if they are under this `dir`")
+	serveMaxBody := flag.String("serve-max-body", "0", "with -serve, refuse POSTed bodies larger than this many bytes, such as 10M; 0 accepts any size")
+
+	flag.Usage = usage
 
 	// Parse flags
 	flag.Parse()
 
+	if *output != "text" && *output != "csv" && *output != "tsv" {
+		cli.Exit(cli.Usagef("unknown output format %q", *output))
+	}
+
+	delim, err := parseDelimiter(*delimiter)
+	if err != nil {
+		cli.Exit(cli.Usagef("invalid delimiter %q: %v", *delimiter, err))
+	}
+
+	size, err := parseBufferSize(*bufferSize)
+	if err != nil {
+		cli.Exit(cli.Usagef("invalid buffer size %q: %v", *bufferSize, err))
+	}
+
+	if *jobs < 1 {
+		cli.Exit(cli.Usagef("-j must be at least 1"))
+	}
+
+	opts := options{
+		bufferSize:       size,
+		jobs:             *jobs,
+		delimiter:        delim,
+		countPartialLine: *countPartial,
+		skipBOM:          *skipBOMFlag,
+		decompress:       *decompressFlag,
+		progress:         *progressFlag && cli.IsTerminal(os.Stderr),
+		style:            textStyle{human: human.Value, color: cli.UseColor(color.Value, os.Stdout)},
+		histogram:        *histogram,
+		wordStats:        *wordStats,
+		indentStats:      *indentStats,
+		lineLengthUnit:   lineLengthStats.Value,
+		uniqueLines:      uniqueLines.Value,
+		utf8Chars:        utf8Locale(),
+		invalidUTF8:      *invalidUTF8,
+		members:          *members,
+		detectEncoding:   detectEncodingFlag.Value,
+		binary:           *binary,
+	}
+
+	switch *words {
+	case "ascii":
+		opts.wordSplit = count.ScanWords
+	case "unicode":
+		opts.wordSplit = count.ScanUnicodeWords
+	default:
+		cli.Exit(cli.Usagef("unknown word segmentation %q", *words))
+	}
+
+	if err := cli.Choice(*binary, "count", "skip", "fail"); err != nil {
+		cli.Exit(cli.Usagef("invalid -binary %q: %v", *binary, err))
+	}
+
+	if *invalidUTF8 != "bytes" && *invalidUTF8 != "runes" {
+		cli.Exit(cli.Usagef("unknown -invalid-utf8 mode %q", *invalidUTF8))
+	}
+
+	if *normalize != "" && *normalize != "nfc" && *normalize != "nfd" {
+		cli.Exit(cli.Usagef("unknown normalization form %q", *normalize))
+	}
+	opts.normalize = *normalize
+
+	var columns []column
+	if *l {
+		columns = append(columns, colLines)
+	}
+	if *w {
+		columns = append(columns, colWords)
+	}
+	if *m {
+		columns = append(columns, colChars)
+	}
+	if *c {
+		columns = append(columns, colBytes)
+	}
+	if *format != "" {
+		tmpl, err := parseFormat(*format)
+		if err != nil {
+			cli.Exit(cli.Usagef("invalid format: %v", err))
+		}
+		opts.template = tmpl
+
+		// Count what the template uses, as well as any counts asked for
+		for _, col := range templateColumns(tmpl) {
+			if !slices.Contains(columns, col) {
+				columns = append(columns, col)
+			}
+		}
+	}
+	if len(columns) == 0 && *format == "" {
+		columns = []column{colLines, colWords, colBytes}
+	}
+	if *newlineStats {
+		// colLF fills in all three newline styles in one pass
+		columns = append(columns, colLF, colCRLF, colCR)
+	}
+	if *strictUTF8 || *failInvalid {
+		columns = append(columns, colInvalid)
+	}
+	if *decompressFlag && *compressedBytes {
+		columns = append(columns, colCompressed)
+	}
+	if uniqueLines.Value != "" {
+		columns = append(columns, colUnique)
+	}
+	if *blank && *code {
+		cli.Exit(cli.Usagef("-blank and -code cannot be combined"))
+	}
+	if *blank {
+		// colBlank fills in all three kinds of line in one pass
+		columns = append(columns, colBlank, colWhitespace, colNonBlank)
+	}
+	if *code {
+		columns = append(columns, colCode, colComment, colCodeBlank)
+	}
+
+	// The passes made over each input: the reported columns and any
+	// separately reported statistics
+	passes := slices.Clone(columns)
+
+	if *histogram != "" {
+		if *histogram != "classes" && *histogram != "bytes" {
+			cli.Exit(cli.Usagef("unknown histogram mode %q", *histogram))
+		}
+		passes = append(passes, colHistogram)
+	}
+
+	if *indentStats {
+		passes = append(passes, colIndentStats)
+	}
+	if lineLengthStats.Value != "" {
+		passes = append(passes, colLineLengthStats)
+	}
+
+	for _, col := range lim.columns() {
+		if !slices.Contains(passes, col) {
+			passes = append(passes, col)
+		}
+	}
+
+	// Word statistics are gathered in the same pass as the word count
+	if *wordStats && !slices.Contains(passes, colWords) {
+		passes = append(passes, colWordStats)
+	}
+
+	if *estimate {
+		for _, col := range passes {
+			if !slices.Contains(estimateColumns, col) {
+				cli.Exit(cli.Usagef("-estimate only estimates -l, -w, -m and -c"))
+			}
+		}
+		if *decompressFlag {
+			cli.Exit(cli.Usagef("-estimate cannot sample compressed inputs"))
+		}
+		if opts.detectEncoding != "" || opts.binary != "count" {
+			cli.Exit(cli.Usagef("-estimate cannot be combined with -detect-encoding or -binary"))
+		}
+		opts.estimate = true
+	}
+
 	// The remaining arguments after flags are parsed
 	args := flag.Args()
 
-	var file *os.File
-	var file_err error
-	var filePath string
+	if *serveRoot != "" && *serve == "" {
+		cli.Exit(cli.Usagef("-serve-root only applies to -serve"))
+	}
+	if *serveMaxBody != "0" && *serve == "" {
+		cli.Exit(cli.Usagef("-serve-max-body only applies to -serve"))
+	}
+	if *serve != "" {
+		if len(args) > 0 || recursive || followFlag || *compare || *summary != "" {
+			cli.Exit(cli.Usagef("-serve counts what is sent to it, and takes no files, -r, -f, -compare or -summary"))
+		}
+		maxBody, err := cli.ParseCount(*serveMaxBody)
+		if err != nil {
+			cli.Exit(cli.Usagef("invalid maximum body size %q: %v", *serveMaxBody, err))
+		}
+		server, err := newCountServer(columns, passes, opts, *serveRoot, *timeout, maxBody)
+		if err != nil {
+			cli.Exit(err)
+		}
+		cli.Exit(serveCounts(*serve, server))
+	}
 
-	if len(args) == 0 {
+	ok := true
 
-		file = os.Stdin
+	if recursive {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
 
-	} else if len(args) == 1 {
-		filePath = args[0]
+		args, ok = expandPaths(args, walkOpts)
+	}
 
-		// Open the file
-		file, file_err = os.Open(filePath)
+	if len(args) == 0 && !recursive && followFlag {
+		cli.Exit(cli.Usagef("cannot follow standard input"))
+	}
+	// -f counts only what is appended to each file, which these would need
+	// to read from the start
+	if followFlag && (*decompressFlag || *estimate || *members || *summary != "" || opts.detectEncoding == "transcode") {
+		cli.Exit(cli.Usagef("-f cannot be combined with -decompress, -estimate, -members, -summary or -detect-encoding=transcode"))
+	}
+	// Appended text is counted from the end of the last complete line, so a
+	// delimiter must not span one
+	if followFlag && bytes.Contains(opts.delimiter[:len(opts.delimiter)-1], []byte("\n")) {
+		cli.Exit(cli.Usagef("-f cannot count records whose delimiter has a newline before its end"))
+	}
 
-		if file_err != nil {
-			log.Fatalf("Failed to open the file: %v", file_err)
-		}
-		defer file.Close()
+	if *compare && (len(args) != 2 || recursive || followFlag || *members) {
+		cli.Exit(cli.Usagef("-compare takes exactly two files"))
+	}
+	// Skipping either file would leave nothing to compare it against
+	if *compare && opts.binary == "skip" {
+		cli.Exit(cli.Usagef("-compare cannot skip binary files; use -binary=count or -binary=fail"))
+	}
+	if *members && len(args) == 0 {
+		cli.Exit(cli.Usagef("-members needs archives to count; it cannot read standard input"))
 	}
 
-	if flag.NFlag() == 1 {
+	opts.width = numberWidth(args, columns)
 
-		if *c {
-			count := countBytes(file)
-			fmt.Printf("%d %s\n", count, filePath)
+	// The size of an archive says little about the size of its members
+	if *members && slices.ContainsFunc(args, func(path string) bool { return archiveKind(path) != "" }) {
+		opts.width = max(opts.width, 7)
+	}
 
-		} else if *l {
-			count := countLines(file)
-			fmt.Printf("%d %s\n", count, filePath)
+	// Progress lines for several files at once would overwrite each other
+	if opts.progress {
+		opts.jobs = 1
+	}
 
-		} else if *w {
-			count := countWords(file)
-			fmt.Printf("%d %s\n", count, filePath)
+	if followFlag {
+		newFollower(args, passes, opts, *timeout).run(*interval, func(results []Counts) {
+			if err := writeResults(os.Stdout, *output, results, columns, opts); err != nil {
+				cli.Exit(err)
+			}
+
+			inputs := results
+			if hasTotal(args, opts) {
+				inputs = results[:len(results)-1]
+			}
+
+			errs := lim.check(inputs)
+			for _, err := range errs {
+				cli.Report(err)
+			}
+			if len(errs) > 0 {
+				os.Exit(cli.ExitFailure)
+			}
+		})
+	}
 
-		} else if *m {
-			count := countChars(file)
-			fmt.Printf("%d %s\n", count, filePath)
+	start := time.Now()
 
+	var results []Counts
+	var failures int
+
+	total := Counts{File: "total"}
+
+	if len(args) == 0 && !recursive {
+
+		ctx, cancel := withTimeout(*timeout)
+		counts, err := countFile(ctx, os.Stdin, "", passes, opts)
+		cancel()
+		switch {
+		case opts.binary == "skip" && errors.Is(err, errBinary):
+		case err != nil:
+			cli.Exit(err)
+		default:
+			addCounts(&total, counts)
+			results = append(results, counts)
+		}
+
+	} else {
+		ctx, cancel := withTimeout(*timeout)
+		results, total, failures = countPaths(ctx, args, passes, opts)
+		cancel()
+		ok = ok && failures == 0
+	}
+
+	if *compare {
+		if !ok {
+			os.Exit(cli.ExitFailure)
+		}
+		cli.Exit(writeCompare(os.Stdout, *output, results[0], results[1], columns, opts))
+	}
+
+	if err := writeResults(os.Stdout, *output, results, columns, opts); err != nil {
+		cli.Exit(err)
+	}
+
+	// Leave out the total when checking the limits
+	inputs := results
+	if hasTotal(args, opts) {
+		inputs = results[:len(results)-1]
+	}
+
+	if *summary != "" {
+		err := writeSummary(*summary, newRunSummary(inputs, failures, total, columns, time.Since(start)))
+		if err != nil {
+			cli.Report(err)
+			ok = false
 		}
-	} else if flag.NFlag() == 0 {
+	}
 
-		byte_count := countBytes(file)
-		file.Seek(0, io.SeekStart)
-		line_count := countLines(file)
-		file.Seek(0, io.SeekStart)
-		word_count := countWords(file)
+	for _, err := range lim.check(inputs) {
+		cli.Report(err)
+		ok = false
+	}
 
-		fmt.Printf("%d %d %d %s\n", byte_count, line_count, word_count, filePath)
+	if *failInvalid && total.Invalid > 0 {
+		cli.Exit(fmt.Errorf("found %d invalid UTF-8 sequences", total.Invalid))
+	}
 
+	if !ok {
+		os.Exit(cli.ExitFailure)
 	}
 }
@@ -0,0 +1,1139 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"codechallenge/internal/streamio"
+	"codechallenge/wc/count"
+)
+
+// TestGoldenDefaultOutput compares the default output for each fixture in
+// testdata/golden with the output of GNU wc recorded in
+// testdata/golden/expected, which must match byte for byte. The recordings
+// were made with GNU coreutils 9.1 by running, from this directory,
+// LC_ALL=C.UTF-8 wc testdata/golden/<name> for each fixture and
+// LC_ALL=C.UTF-8 wc testdata/golden/*.* for all.out.
+func TestGoldenDefaultOutput(t *testing.T) {
+
+	fixtures, err := filepath.Glob("testdata/golden/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{"all": fixtures}
+	for _, fixture := range fixtures {
+		cases[filepath.Base(fixture)] = []string{fixture}
+	}
+
+	columns := []column{colLines, colWords, colBytes}
+	opts := options{
+		wordSplit:   count.ScanWords,
+		delimiter:   []byte{'\n'},
+		utf8Chars:   true,
+		invalidUTF8: "bytes",
+	}
+
+	for name, paths := range cases {
+		t.Run(name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata/golden/expected", name+".out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, _, failed := countPaths(context.Background(), paths, columns, opts)
+			if failed > 0 {
+				t.Fatalf("counting %v failed", paths)
+			}
+
+			var got bytes.Buffer
+			writeText(&got, results, columns, textStyle{}, numberWidth(paths, columns))
+
+			if got.String() != string(want) {
+				t.Errorf("output differs from GNU wc\ngot:\n%s\nwant:\n%s", got.String(), want)
+			}
+		})
+	}
+}
+
+// FuzzSinglePass checks that counting several columns in one pass over the
+// input gives the same counts as counting each column on its own.
+func FuzzSinglePass(f *testing.F) {
+	f.Add([]byte("hello world\n"))
+	f.Add([]byte("\xef\xbb\xbfcaf\xc3\xa9 \xff\x00\r\n  \n\t\r\rend"))
+	f.Add(bytes.Repeat([]byte("word "), 1024))
+
+	passes := []column{colLines, colWords, colChars, colBytes, colLF, colInvalid, colBlank, colUnique}
+	opts := options{
+		wordSplit:   count.ScanWords,
+		delimiter:   []byte{'\n'},
+		skipBOM:     true,
+		utf8Chars:   true,
+		invalidUTF8: "runes",
+		uniqueLines: "exact",
+		bufferSize:  7,
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+
+		ctx := context.Background()
+
+		all, err := countReader(ctx, bytes.NewReader(data), "", passes, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if all.Chars > all.Bytes {
+			t.Errorf("%d chars, more than the %d bytes", all.Chars, all.Bytes)
+		}
+
+		for _, col := range passes {
+			alone, err := countReader(ctx, bytes.NewReader(data), "", []column{col}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if col.value(alone) != col.value(all) {
+				t.Errorf("%s = %d alone, %d with the other columns", col.name(), col.value(alone), col.value(all))
+			}
+		}
+	})
+}
+
+// repeatReader yields size copies of b without allocating.
+type repeatReader struct {
+	b    byte
+	size int64
+}
+
+func (reader *repeatReader) Read(buffer []byte) (int, error) {
+	if reader.size == 0 {
+		return 0, io.EOF
+	}
+
+	n := min(int64(len(buffer)), reader.size)
+	for i := range n {
+		buffer[i] = reader.b
+	}
+	reader.size -= n
+	return int(n), nil
+}
+
+// TestPathologicalInputMemory counts an input that is a single line, and a
+// single word, with no newline, checking that the memory allocated while
+// counting stays bounded rather than growing with the line. The default
+// counts are made over 2 GiB, or 64 MiB with -short, and the rest over 64
+// MiB as they are slower.
+func TestPathologicalInputMemory(t *testing.T) {
+
+	large := int64(2 << 30)
+	if testing.Short() {
+		large = 64 << 20
+	}
+
+	tests := []struct {
+		name   string
+		size   int64
+		passes []column
+	}{
+		{"default", large, []column{colLines, colWords, colBytes}},
+		{"all", 64 << 20, []column{colLines, colWords, colChars, colBytes, colLF, colInvalid, colBlank, colIndentStats, colLineLengthStats}},
+	}
+
+	opts := options{
+		wordSplit:      count.ScanWords,
+		delimiter:      []byte{'\n'},
+		utf8Chars:      true,
+		invalidUTF8:    "runes",
+		lineLengthUnit: "runes",
+		bufferSize:     streamio.StreamBufferSize,
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			counts, err := countReader(context.Background(), &repeatReader{b: 'x', size: test.size}, "", test.passes, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runtime.ReadMemStats(&after)
+
+			size := int(test.size)
+			if counts.Bytes != size || counts.Words != 1 || counts.Lines != 0 {
+				t.Errorf("got %d lines, %d words, %d bytes, want 0, 1, %d", counts.Lines, counts.Words, counts.Bytes, size)
+			}
+
+			// Every allocation counts, even if it was since collected, so
+			// this also catches buffers that are allocated for each chunk
+			const limit = 16 << 20
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > limit {
+				t.Errorf("allocated %d bytes counting %d bytes, want at most %d", allocated, size, limit)
+			}
+		})
+	}
+}
+
+// TestServe counts request bodies and served files over HTTP, and checks
+// that paths cannot reach outside the served directory.
+func TestServe(t *testing.T) {
+
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one two\nthree\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("four\n"), 0o644)
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret\n"), 0o644)
+	os.Symlink(outside, filepath.Join(root, "link.txt"))
+
+	columns := []column{colLines, colWords, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes"}
+	s, err := newCountServer(columns, columns, opts, root, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s.routes())
+	defer server.Close()
+
+	tests := []struct {
+		method, target, body string
+		status               int
+		want                 string
+	}{
+		{"POST", "/count", "a b c\nd\n", http.StatusOK, `{"results":[{"counts":{"bytes":8,"lines":2,"words":4}}]}`},
+		{"GET", "/count?path=a.txt", "", http.StatusOK, `{"results":[{"file":"a.txt","counts":{"bytes":14,"lines":2,"words":3}}]}`},
+		{"GET", "/count?path=a.txt&path=b.txt", "", http.StatusOK, `{"results":[{"file":"a.txt","counts":{"bytes":14,"lines":2,"words":3}},` +
+			`{"file":"b.txt","counts":{"bytes":5,"lines":1,"words":1}}],"total":{"bytes":19,"lines":3,"words":4}}`},
+		{"GET", "/count?path=missing.txt", "", http.StatusNotFound, `{"error":"lstat missing.txt: no such file or directory"}`},
+		{"GET", "/count?path=../secret.txt", "", http.StatusForbidden, `{"error":"path is outside the served directory"}`},
+		{"GET", "/count?path=link.txt", "", http.StatusForbidden, `{"error":"path is outside the served directory"}`},
+		{"GET", "/count", "", http.StatusBadRequest, `{"error":"give the files to count as path parameters, or POST a body"}`},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, server.URL+test.target, strings.NewReader(test.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status || strings.TrimSpace(string(body)) != test.want {
+			t.Errorf("%s %s: %d %s\nwant %d %s", test.method, test.target, resp.StatusCode, body, test.status, test.want)
+		}
+	}
+
+	// Bodies past -serve-max-body are refused rather than counted in part
+	s.maxBody = 4
+	for body, want := range map[string]int{"abcd": http.StatusOK, "abcde": http.StatusRequestEntityTooLarge} {
+		resp, err := http.Post(server.URL+"/count", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("POST of %d bytes with -serve-max-body 4: status %d, want %d", len(body), resp.StatusCode, want)
+		}
+	}
+	s.maxBody = 0
+
+	// Without a served directory, only bodies are counted
+	s.root = ""
+	resp, err := http.Get(server.URL + "/count?path=a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("path without -serve-root: status %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+// TestCountArchiveMembers counts the members of zip and compressed tar
+// archives in place of the archives, alongside a plain file.
+func TestCountArchiveMembers(t *testing.T) {
+
+	dir := t.TempDir()
+	members := []struct{ name, content string }{
+		{"a.txt", "one two\nthree\n"},
+		{"sub/b.txt", "four\n"},
+	}
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	zw.Create("sub/")
+	for _, m := range members {
+		w, _ := zw.Create(m.name)
+		io.WriteString(w, m.content)
+	}
+	zw.Close()
+	os.WriteFile(filepath.Join(dir, "m.zip"), zipped.Bytes(), 0o644)
+
+	var tarred bytes.Buffer
+	gz := gzip.NewWriter(&tarred)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, m := range members {
+		tw.WriteHeader(&tar.Header{Name: m.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(m.content))})
+		io.WriteString(tw, m.content)
+	}
+	tw.Close()
+	gz.Close()
+	os.WriteFile(filepath.Join(dir, "m.tar.gz"), tarred.Bytes(), 0o644)
+
+	os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("x\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "broken.zip"), []byte("not a zip"), 0o644)
+
+	columns := []column{colLines, colWords, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes", members: true}
+
+	paths := []string{filepath.Join(dir, "m.zip"), filepath.Join(dir, "m.tar.gz"), filepath.Join(dir, "plain.txt"), filepath.Join(dir, "broken.zip")}
+	results, total, failed := countPaths(context.Background(), paths, columns, opts)
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1 for broken.zip", failed)
+	}
+
+	var got bytes.Buffer
+	writeText(&got, results, columns, textStyle{}, 1)
+	want := strings.NewReplacer("DIR", dir).Replace(`2 3 14 DIR/m.zip:a.txt
+1 1 5 DIR/m.zip:sub/b.txt
+2 3 14 DIR/m.tar.gz:a.txt
+1 1 5 DIR/m.tar.gz:sub/b.txt
+1 1 2 DIR/plain.txt
+7 9 40 total
+`)
+	if got.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got.String(), want)
+	}
+	if total.Lines != 7 {
+		t.Errorf("total lines = %d, want 7", total.Lines)
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		sample string
+		atEOF  bool
+		want   string
+	}{
+		{"empty", "", true, encodingASCII},
+		{"ascii", "plain text\n", true, encodingASCII},
+		{"utf-8", "café\n", true, encodingUTF8},
+		{"utf-8 with bom", "\xef\xbb\xbfabc", true, encodingUTF8BOM},
+		{"utf-16le", "\xff\xfea\x00", true, encodingUTF16LE},
+		{"utf-16be", "\xfe\xff\x00a", true, encodingUTF16BE},
+		{"utf-32le", "\xff\xfe\x00\x00a\x00\x00\x00", true, encodingUTF32LE},
+		{"binary", "ELF\x00\x01", true, encodingBinary},
+		{"latin-1", "caf\xe9\n", true, encodingUnknown},
+		{"character cut by the sample", "caf\xc3", false, encodingASCII},
+		{"truncated character at the end", "caf\xc3", true, encodingUnknown},
+	}
+
+	for _, test := range tests {
+		if got := detectEncoding([]byte(test.sample), test.atEOF); got != test.want {
+			t.Errorf("%s: detectEncoding(%q) = %s, want %s", test.name, test.sample, got, test.want)
+		}
+	}
+}
+
+// TestTranscodeUTF16 counts UTF-16 input as its text with
+// -detect-encoding=transcode, keeping the byte count of the input as it is.
+func TestTranscodeUTF16(t *testing.T) {
+
+	// "héllo wörld\nline two\n" in UTF-16BE with a byte order mark
+	text := "héllo wörld\nline two\n"
+	input := []byte{0xFE, 0xFF}
+	for _, r := range text {
+		input = append(input, byte(r>>8), byte(r))
+	}
+
+	columns := []column{colLines, colWords, colChars, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "runes"}
+
+	for _, mode := range []string{"report", "transcode"} {
+		opts.detectEncoding = mode
+		counts, err := countReader(context.Background(), bytes.NewReader(input), "in", columns, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts.Encoding != encodingUTF16BE || counts.Bytes != len(input) {
+			t.Errorf("%s: encoding %s, bytes %d; want %s, %d", mode, counts.Encoding, counts.Bytes, encodingUTF16BE, len(input))
+		}
+		if mode == "transcode" && (counts.Lines != 2 || counts.Words != 4 || counts.Chars != 21) {
+			t.Errorf("transcoded counts: lines %d, words %d, chars %d; want 2, 4, 21", counts.Lines, counts.Words, counts.Chars)
+		}
+	}
+}
+
+// TestBinaryPolicy counts, skips or fails on binary files among text
+// files, as -binary asks.
+func TestBinaryPolicy(t *testing.T) {
+
+	dir := t.TempDir()
+	text := filepath.Join(dir, "a.txt")
+	image := filepath.Join(dir, "b.png")
+	wide := filepath.Join(dir, "c.txt")
+	os.WriteFile(text, []byte("one two\n"), 0o644)
+	os.WriteFile(image, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), 0o644)
+
+	// UTF-16 text has NUL bytes too, but its byte order mark says it is text
+	os.WriteFile(wide, []byte("\xff\xfeh\x00i\x00\n\x00"), 0o644)
+
+	columns := []column{colLines, colWords, colBytes}
+	paths := []string{text, image, wide}
+
+	tests := []struct {
+		mode   string
+		files  []string
+		failed int
+	}{
+		{"count", []string{text, image, wide, "total"}, 0},
+		{"skip", []string{text, wide, "total"}, 0},
+		{"fail", []string{text, wide, "total"}, 1},
+	}
+
+	for _, test := range tests {
+		opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes", binary: test.mode}
+		results, _, failed := countPaths(context.Background(), paths, columns, opts)
+
+		var files []string
+		for _, counts := range results {
+			files = append(files, counts.File)
+		}
+		if strings.Join(files, " ") != strings.Join(test.files, " ") || failed != test.failed {
+			t.Errorf("-binary=%s: counted %v with %d failed, want %v with %d", test.mode, files, failed, test.files, test.failed)
+		}
+	}
+}
+
+func TestCountHistogram(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		input string
+		mode  string
+		want  map[string]int
+	}{
+		{"empty classes", "", "classes", map[string]int{}},
+		{"empty bytes", "", "bytes", map[string]int{}},
+		{
+			"classes",
+			"Ab 1,\t€e\u0301\x00\xff\u00a0",
+			"classes",
+			map[string]int{"letter": 3, "mark": 1, "digit": 1, "space": 3, "punct": 1, "symbol": 1, "control": 1, "invalid": 1},
+		},
+		{"other", "\u200b\u200b", "classes", map[string]int{"other": 2}},
+		{"bytes", "aab\n\xff", "bytes", map[string]int{"0x61": 2, "0x62": 1, "0x0A": 1, "0xFF": 1}},
+		{"multibyte bytes", "é", "bytes", map[string]int{"0xC3": 1, "0xA9": 1}},
+	}
+
+	for _, test := range tests {
+		got, err := countHistogram(strings.NewReader(test.input), test.mode)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !maps.Equal(got, test.want) {
+			t.Errorf("%s: countHistogram(%q) = %v, want %v", test.name, test.input, got, test.want)
+		}
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+
+	histogram := map[string]int{"0xFF": 1, "0x0A": 3, "0x61": 2}
+	if got := histogramBuckets(histogram, "bytes"); !slices.Equal(got, []string{"0x0A", "0x61", "0xFF"}) {
+		t.Errorf("byte buckets = %v, want them sorted by value", got)
+	}
+
+	// Every rune class is reported, even those that do not occur
+	if got := histogramBuckets(map[string]int{"letter": 1}, "classes"); !slices.Equal(got, runeClasses) {
+		t.Errorf("class buckets = %v, want %v", got, runeClasses)
+	}
+}
+
+func TestWordStats(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		input      string
+		longest    string
+		average    float64
+		vocabulary int
+	}{
+		{"empty", "", "", 0, 0},
+		{"only spaces", " \n\t ", "", 0, 0},
+		{"one word", "hello\n", "hello", 5, 1},
+		{"repeated words", "the cat saw the dog\n", "the", 3, 4},
+		{"first of the longest", "ab cd\nefg hij\n", "efg", 2.5, 4},
+		{"counted in runes", "ééééé abcdef\n", "abcdef", 5.5, 2},
+		{"case sensitive", "Go go GO\n", "Go", 2, 3},
+	}
+
+	for _, test := range tests {
+		stats := newWordStats()
+		if _, err := count.Words(strings.NewReader(test.input), count.ScanWords, stats.add); err != nil {
+			t.Fatal(err)
+		}
+
+		if stats.Longest != test.longest || stats.AverageLength() != test.average || stats.Vocabulary() != test.vocabulary {
+			t.Errorf("%s: longest %q, average %v, vocabulary %d; want %q, %v, %d", test.name,
+				stats.Longest, stats.AverageLength(), stats.Vocabulary(), test.longest, test.average, test.vocabulary)
+		}
+	}
+}
+
+func TestWordStatsMerge(t *testing.T) {
+
+	first, second := newWordStats(), newWordStats()
+	count.Words(strings.NewReader("one two three\n"), count.ScanWords, first.add)
+	count.Words(strings.NewReader("three fourteen\n"), count.ScanWords, second.add)
+
+	// An empty input changes nothing
+	first.merge(newWordStats())
+	first.merge(second)
+
+	if first.Longest != "fourteen" || first.AverageLength() != 24.0/5 || first.Vocabulary() != 4 {
+		t.Errorf("merged: longest %q, average %v, vocabulary %d; want %q, %v, %d",
+			first.Longest, first.AverageLength(), first.Vocabulary(), "fourteen", 24.0/5, 4)
+	}
+}
+
+func TestShortNumber(t *testing.T) {
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{-999, "-999"},
+		{1000, "1.0k"},
+		{1049, "1.0k"},
+		{1234, "1.2k"},
+		{999_949, "999.9k"},
+		{999_950, "1.0M"},
+		{999_999, "1.0M"},
+		{-999_999, "-1.0M"},
+		{1_000_000, "1.0M"},
+		{999_949_999, "999.9M"},
+		{999_950_000, "1.0G"},
+		{999_950_000_000, "1.0T"},
+		{999_950_000_000_000, "1.0P"},
+
+		// There is no unit past P to carry into
+		{999_999_000_000_000_000, "1000.0P"},
+	}
+
+	for _, test := range tests {
+		if got := shortNumber(test.n); got != test.want {
+			t.Errorf("shortNumber(%d) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
+
+func TestTextStyleCount(t *testing.T) {
+
+	tests := []struct {
+		style textStyle
+		n     int
+		width int
+		want  string
+	}{
+		{textStyle{}, 1234567, 1, "1234567"},
+		{textStyle{}, 42, 5, "   42"},
+		{textStyle{human: "grouped"}, 1234567, 1, "1_234_567"},
+		{textStyle{human: "grouped"}, -1234, 7, " -1_234"},
+		{textStyle{human: "short"}, 999_999, 6, "  1.0M"},
+		{textStyle{color: true}, 7, 3, ansiCount + "  7" + ansiReset},
+	}
+
+	for _, test := range tests {
+		if got := test.style.count(test.n, test.width); got != test.want {
+			t.Errorf("%+v: count(%d, %d) = %q, want %q", test.style, test.n, test.width, got, test.want)
+		}
+	}
+}
+
+func TestCountIndent(t *testing.T) {
+
+	type kinds struct{ tab, space, mixed, unindented int }
+
+	tests := []struct {
+		name   string
+		input  string
+		want   kinds
+		common int
+	}{
+		{"empty", "", kinds{}, 0},
+		{"tabs", "\ta\n\t\tb\n", kinds{tab: 2}, 0},
+		{"spaces", "  a\n    b\n    c\nd\n", kinds{space: 3, unindented: 1}, 4},
+		{"narrower wins a tie", "    a\n  b\n", kinds{space: 2}, 2},
+		{"mixed", "\t  a\n  \tb\n\tc\n", kinds{tab: 1, mixed: 2}, 0},
+		{"blank lines are not counted", "a\n\n   \n\t\n \t \r\nb", kinds{unindented: 2}, 0},
+		{"crlf", "  a\r\n\tb\r\n", kinds{tab: 1, space: 1}, 2},
+		{"only leading whitespace", "a\t b\n", kinds{unindented: 1}, 0},
+	}
+
+	for _, test := range tests {
+		stats, err := countIndent(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := kinds{stats.Tab, stats.Space, stats.Mixed, stats.Unindented}
+		if got != test.want || stats.CommonWidth() != test.common {
+			t.Errorf("%s: got %+v with common width %d, want %+v with %d", test.name, got, stats.CommonWidth(), test.want, test.common)
+		}
+	}
+}
+
+func TestLineLengthStats(t *testing.T) {
+
+	// lines returns an input with a line of each length
+	lines := func(lengths ...int) string {
+		var input strings.Builder
+		for _, length := range lengths {
+			input.WriteString(strings.Repeat("x", length) + "\n")
+		}
+		return input.String()
+	}
+
+	twenty := make([]int, 20)
+	for i := range twenty {
+		twenty[i] = 20 - i
+	}
+
+	tests := []struct {
+		name                   string
+		input                  string
+		min, median, p95, most int
+	}{
+		{"empty", "", 0, 0, 0, 0},
+		{"one line", lines(7), 7, 7, 7, 7},
+		{"one unterminated line", "abc", 3, 3, 3, 3},
+		{"odd count", lines(5, 1, 3), 1, 3, 5, 5},
+		{"even count takes the lower median", lines(4, 1, 3, 2), 1, 2, 4, 4},
+		{"blank lines have no length", lines(0, 0, 6), 0, 0, 6, 6},
+		{"p95 below the maximum", lines(twenty...), 1, 10, 19, 20},
+		{"crlf is not counted", "ab\r\nabcd\r\n", 2, 2, 4, 4},
+	}
+
+	for _, test := range tests {
+		stats, err := countLineLengths(strings.NewReader(test.input), "runes")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stats.Min() != test.min || stats.Median() != test.median || stats.P95() != test.p95 || stats.Max() != test.most {
+			t.Errorf("%s: min %d, median %d, p95 %d, max %d; want %d, %d, %d, %d", test.name,
+				stats.Min(), stats.Median(), stats.P95(), stats.Max(), test.min, test.median, test.p95, test.most)
+		}
+	}
+}
+
+func TestLineLengthColumns(t *testing.T) {
+
+	tests := []struct {
+		input string
+		runes int
+		width int
+	}{
+		{"a\tb", 3, 9},
+		{"日本", 2, 4},
+		{"e\u0301", 2, 1},
+	}
+
+	for _, test := range tests {
+		runes, _ := countLineLengths(strings.NewReader(test.input), "runes")
+		columns, _ := countLineLengths(strings.NewReader(test.input), "columns")
+		if runes.Max() != test.runes || columns.Max() != test.width {
+			t.Errorf("%q: %d runes, %d columns; want %d, %d", test.input, runes.Max(), columns.Max(), test.runes, test.width)
+		}
+	}
+}
+
+// TestJobsKeepInputOrder counts files of very different sizes four at a
+// time, so later files tend to finish first, and checks that the results
+// still come out in the order the files were given. Run it with -race.
+func TestJobsKeepInputOrder(t *testing.T) {
+
+	dir := t.TempDir()
+
+	var paths []string
+	for i := range 16 {
+		path := filepath.Join(dir, fmt.Sprintf("f%02d.txt", i))
+		lines := strings.Repeat("word\n", (16-i)*5000)
+		if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	columns := []column{colLines, colWords, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes", jobs: 4}
+
+	for range 5 {
+		results, total, failed := countPaths(context.Background(), paths, columns, opts)
+		if failed != 0 || len(results) != len(paths)+1 {
+			t.Fatalf("got %d results with %d failed, want %d with none failed", len(results), failed, len(paths)+1)
+		}
+
+		for i, path := range paths {
+			if results[i].File != path || results[i].Lines != (16-i)*5000 {
+				t.Fatalf("result %d is %s with %d lines, want %s with %d", i, results[i].File, results[i].Lines, path, (16-i)*5000)
+			}
+		}
+		if total.Lines != 16*17/2*5000 {
+			t.Errorf("total lines = %d, want %d", total.Lines, 16*17/2*5000)
+		}
+	}
+}
+
+func TestUniqueLinesExact(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		input     string
+		delimiter string
+		want      int
+	}{
+		{"empty", "", "\n", 0},
+		{"one line", "a\n", "\n", 1},
+		{"duplicates", "a\nb\na\nb\nc\n", "\n", 3},
+		{"blank lines count once", "\n\na\n\n", "\n", 2},
+		{"final line without a delimiter", "a\nb\na", "\n", 2},
+		{"final line differs from its terminated twin", "a\nb\nab", "\n", 3},
+		{"longer delimiter", "a\r\nb\r\na\nb\r\n", "\r\n", 3},
+		{"nul delimiter", "x\x00y\x00x\x00", "\x00", 2},
+	}
+
+	for _, test := range tests {
+		reader := bufio.NewReader(strings.NewReader(test.input))
+		unique, err := countUniqueLines(reader, []byte(test.delimiter), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := unique.Count(); got != test.want {
+			t.Errorf("%s: Count() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// TestUniqueLinesApprox checks the sketch against known cardinalities. Its
+// standard error is about 0.8%, so 4% is five standard errors, enough that
+// the random hash seed never makes it fail in practice.
+func TestUniqueLinesApprox(t *testing.T) {
+
+	for _, n := range []int{0, 10, 1000, 100_000} {
+		unique := newUniqueLines(true)
+		for i := range n {
+			line := []byte(strconv.Itoa(i))
+
+			// Repeats do not add to the count
+			unique.add(line)
+			unique.add(line)
+		}
+
+		got := unique.Count()
+		if bound := 0.04 * float64(n); math.Abs(float64(got-n)) > bound {
+			t.Errorf("%d distinct lines: estimated %d, want within %.0f", n, got, bound)
+		}
+	}
+
+	// Merged sketches estimate the union of their inputs
+	first, second := newUniqueLines(true), newUniqueLines(true)
+	for i := range 60_000 {
+		first.add([]byte(strconv.Itoa(i)))
+		second.add([]byte(strconv.Itoa(i + 40_000)))
+	}
+	first.merge(second)
+
+	if got := first.Count(); math.Abs(float64(got-100_000)) > 4000 {
+		t.Errorf("merged: estimated %d, want within 4000 of 100000", got)
+	}
+}
+
+func TestCountCode(t *testing.T) {
+
+	tests := []struct {
+		name                 string
+		file                 string
+		input                string
+		code, comment, blank int
+	}{
+		{"go", "a.go", "package a\n\n// A is a.\nfunc A() {} // trailing\n/*\n\n  block\n*/\n", 2, 4, 2},
+		{"go strings", "a.go", "s := \"// not a comment\"\nt := `/* nor this`\nu := '\"' /* but this\n*/\n", 3, 1, 0},
+		{"escaped quote", "a.c", "char *s = \"\\\" /* still a string\";\nint x;\n", 2, 0, 0},
+		{"comment between code", "a.js", "a(); /* note */ b();\n/* a */ /* b */\n", 1, 1, 0},
+		{"python", "a.py", "# comment\nx = '#not'\n\n  \ny = 1  # trailing\n", 2, 1, 2},
+		{"lua block wins over line", "a.lua", "--[[ block\nstill ]] x = 1\n-- line\n", 1, 2, 0},
+		{"html", "a.html", "<p>\n<!-- a\n-->\n</p>\n", 2, 2, 0},
+		{"vim quote comments", "a.vim", "\" comment\nset nu\n", 1, 1, 0},
+		{"empty", "a.go", "", 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		code, comment, blank, err := countCode(strings.NewReader(test.input), languageOf(test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code || comment != test.comment || blank != test.blank {
+			t.Errorf("%s: code %d, comment %d, blank %d; want %d, %d, %d", test.name, code, comment, blank, test.code, test.comment, test.blank)
+		}
+	}
+}
+
+// TestCountCodeLanguages builds a sample from the markers of each supported
+// language: line and block comments, markers inside strings, and blank
+// lines.
+func TestCountCodeLanguages(t *testing.T) {
+
+	for ext, lang := range languages {
+		var input strings.Builder
+		var code, comment, blank int
+
+		add := func(kind *int, lines ...string) {
+			for _, line := range lines {
+				input.WriteString(line + "\n")
+				*kind++
+			}
+		}
+
+		add(&blank, "", " \t")
+		for _, marker := range lang.line {
+			add(&comment, marker+" note", "  "+marker)
+			add(&code, "x = 1 "+marker+" note")
+		}
+		for _, markers := range lang.block {
+			add(&comment, markers[0]+" start", "  middle")
+			add(&blank, "")
+			add(&comment, markers[1], markers[0]+" one line "+markers[1])
+			add(&code, "x "+markers[0]+" inline "+markers[1]+" y")
+		}
+
+		// A comment marker in a string does not start a comment, so the
+		// line after one that would open a block comment is still code
+		if lang.quotes != "" {
+			quote := lang.quotes[:1]
+			for _, markers := range lang.block {
+				add(&code, "x = "+quote+markers[0]+quote, "y")
+			}
+			for _, marker := range lang.line {
+				add(&code, quote+marker+quote)
+			}
+		}
+
+		gotCode, gotComment, gotBlank, err := countCode(strings.NewReader(input.String()), lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotCode != code || gotComment != comment || gotBlank != blank {
+			t.Errorf("%s: code %d, comment %d, blank %d; want %d, %d, %d in:\n%s", ext, gotCode, gotComment, gotBlank, code, comment, blank, input.String())
+		}
+	}
+}
+
+// TestSummary writes the -summary of a run where one of three files cannot
+// be read, and decodes it as a consumer would.
+func TestSummary(t *testing.T) {
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "a.txt")
+	second := filepath.Join(dir, "b.txt")
+	os.WriteFile(first, []byte("one two\nthree\n"), 0o644)
+	os.WriteFile(second, []byte("four\n"), 0o644)
+	paths := []string{first, filepath.Join(dir, "missing.txt"), second}
+
+	columns := []column{colLines, colWords, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes"}
+	results, total, failed := countPaths(context.Background(), paths, columns, opts)
+
+	target := filepath.Join(dir, "summary.json")
+	summary := newRunSummary(results[:len(results)-1], failed, total, columns, 1500*time.Millisecond)
+	if err := writeSummary(target, summary); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Files          int            `json:"files"`
+		Failures       int            `json:"failures"`
+		ElapsedSeconds float64        `json:"elapsed_seconds"`
+		Totals         map[string]int `json:"totals"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&got); err != nil {
+		t.Fatalf("decoding %s: %v", data, err)
+	}
+
+	if got.Files != 2 || got.Failures != 1 || got.ElapsedSeconds != 1.5 {
+		t.Errorf("files %d, failures %d, elapsed %v; want 2, 1, 1.5", got.Files, got.Failures, got.ElapsedSeconds)
+	}
+	if want := map[string]int{"lines": 3, "words": 4, "bytes": 19}; !maps.Equal(got.Totals, want) {
+		t.Errorf("totals %v, want %v", got.Totals, want)
+	}
+
+	if err := writeSummary("fd:x", summary); err == nil {
+		t.Error("writeSummary accepted an invalid file descriptor")
+	}
+	if err := writeSummary(filepath.Join(dir, "no", "such", "dir.json"), summary); err == nil {
+		t.Error("writeSummary succeeded in a missing directory")
+	}
+}
+
+// TestEstimate samples a deterministic corpus just past the size -estimate
+// starts sampling at, and checks that the exact counts fall inside the
+// reported 95% margins. Smaller files are counted exactly.
+func TestEstimate(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("writes a 17 MiB corpus")
+	}
+
+	dir := t.TempDir()
+
+	// Lines of 0 to 15 words of 1 to 12 letters, some of them accented
+	random := rand.New(rand.NewPCG(1, 2))
+	var corpus bytes.Buffer
+	for corpus.Len() < minEstimateSize+minEstimateSize/16 {
+		for range random.IntN(16) {
+			word := strings.Repeat("ab", random.IntN(6)) + "c"
+			if random.IntN(4) == 0 {
+				word += "é"
+			}
+			corpus.WriteString(word + " ")
+		}
+		corpus.WriteByte('\n')
+	}
+
+	large := filepath.Join(dir, "large.txt")
+	small := filepath.Join(dir, "small.txt")
+	os.WriteFile(large, corpus.Bytes(), 0o644)
+	os.WriteFile(small, corpus.Bytes()[:minEstimateSize-1], 0o644)
+
+	columns := []column{colLines, colWords, colChars, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes"}
+
+	countWith := func(path string, estimate bool) Counts {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		opts := opts
+		opts.estimate = estimate
+		counts, err := countFile(context.Background(), file, path, columns, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counts
+	}
+
+	exact, estimated := countWith(large, false), countWith(large, true)
+	if estimated.Estimate == nil {
+		t.Fatal("large file was counted exactly")
+	}
+	if estimated.Bytes != exact.Bytes {
+		t.Errorf("bytes = %d, want the exact %d", estimated.Bytes, exact.Bytes)
+	}
+	for _, col := range []column{colLines, colWords, colChars} {
+		got, want, margin := col.value(estimated), col.value(exact), estimated.Estimate.Margin(col)
+		if margin <= 0 || got-margin > want || got+margin < want {
+			t.Errorf("%s: estimated %d ± %d, exact %d is outside", col.name(), got, margin, want)
+		}
+	}
+
+	exact, estimated = countWith(small, false), countWith(small, true)
+	if estimated.Estimate != nil {
+		t.Error("file smaller than the sampling size was estimated")
+	}
+	for _, col := range columns {
+		if col.value(estimated) != col.value(exact) {
+			t.Errorf("small file %s: %d, want the exact %d", col.name(), col.value(estimated), col.value(exact))
+		}
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{`\n`, "\n", false},
+		{`\r\n`, "\r\n", false},
+		{`\t`, "\t", false},
+		{`\0`, "\x00", false},
+		{`\\`, `\`, false},
+		{`a\\n`, `a\n`, false},
+		{`\x1e`, "\x1e", false},
+		{`\x1E\n`, "\x1e\n", false},
+		{`;`, ";", false},
+		{`"`, `"`, false},
+		{`'`, `'`, false},
+		{`--`, `--`, false},
+		{`\`, "", true},
+		{`a\`, "", true},
+		{`\q`, "", true},
+		{`\"`, "", true},
+		{`\x`, "", true},
+		{`\x4`, "", true},
+		{`\xzz`, "", true},
+		{`\x+1`, "", true},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := parseDelimiter(test.value)
+		if (err != nil) != test.wantErr || string(got) != test.want {
+			t.Errorf("parseDelimiter(%q) = %q, %v; want %q, error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestParseBufferSize(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"auto", 0, false},
+		{"4096", 4096, false},
+		{"64k", 64 << 10, false},
+		{"1M", 1 << 20, false},
+		{"64M", 64 << 20, false},
+		{"4095", 0, true},
+		{"65M", 0, true},
+		{"1g", 0, true},
+		{"100G", 0, true},
+		{"9223372036854775807K", 0, true},
+		{"99999999999999999999", 0, true},
+		{"-4K", 0, true},
+		{"big", 0, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseBufferSize(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseBufferSize(%q) = %d, %v; want %d, error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestFollowedFile(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.txt")
+
+	write := func(flags int, text string) {
+		t.Helper()
+		file, err := os.OpenFile(path, flags|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteString(text); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+	}
+
+	columns := []column{colLines, colWords, colBytes}
+	opts := options{wordSplit: count.ScanWords, delimiter: []byte{'\n'}, utf8Chars: true, invalidUTF8: "bytes", binary: "count"}
+	followed := &followedFile{path: path}
+
+	tests := []struct {
+		name    string
+		change  func()
+		changed bool
+		lines   int
+		words   int
+		bytes   int
+		counted int64
+	}{
+		{"partial line", func() { write(os.O_TRUNC, "one two\nthr") }, true, 1, 3, 11, 8},
+		{"appended", func() { write(os.O_APPEND, "ee\nfour\n") }, true, 3, 4, 19, 19},
+		{"unchanged", func() {}, false, 3, 4, 19, 19},
+		{"rotated away", func() { os.Rename(path, path+".1") }, false, 3, 4, 19, 19},
+		{"replaced", func() { write(os.O_TRUNC, "a\nb\n") }, true, 2, 2, 4, 4},
+		{"truncated", func() { write(os.O_TRUNC, "c") }, true, 0, 1, 1, 0},
+	}
+
+	for _, test := range tests {
+		test.change()
+
+		changed, err := followed.update(context.Background(), columns, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		got := followed.current
+		if changed != test.changed || got.Lines != test.lines || got.Words != test.words || got.Bytes != test.bytes {
+			t.Errorf("%s: changed %v with %d lines, %d words and %d bytes, want %v with %d, %d and %d",
+				test.name, changed, got.Lines, got.Words, got.Bytes, test.changed, test.lines, test.words, test.bytes)
+		}
+		if followed.counted != test.counted {
+			t.Errorf("%s: counted up to %d, want %d", test.name, followed.counted, test.counted)
+		}
+	}
+}
+
+func TestLastLineEnd(t *testing.T) {
+
+	long := strings.Repeat("x", 100*1024)
+
+	tests := []struct {
+		text string
+		from int64
+		want int64
+	}{
+		{"", 0, 0},
+		{"abc", 0, 0},
+		{"a\nb\nc", 0, 4},
+		{"a\nb\n", 0, 4},
+		{"a\nbc", 2, 2},
+		{"a\n" + long, 0, 2},
+		{"a\n" + long + "\n" + long, 0, int64(len(long)) + 3},
+	}
+
+	for _, test := range tests {
+		got, err := lastLineEnd(strings.NewReader(test.text), test.from, int64(len(test.text)))
+		if err != nil || got != test.want {
+			t.Errorf("lastLineEnd(%.10q, %d) = %d, %v, want %d", test.text, test.from, got, err, test.want)
+		}
+	}
+}
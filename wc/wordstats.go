@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"codechallenge/internal/cli"
+)
+
+// WordStats summarises the words of an input, with -word-stats.
+type WordStats struct {
+	// Longest is the first of the longest words, measured in runes
+	Longest string
+
+	words      int
+	runes      int
+	vocabulary map[string]struct{}
+}
+
+func newWordStats() *WordStats {
+	return &WordStats{vocabulary: make(map[string]struct{})}
+}
+
+func (stats *WordStats) add(word []byte) {
+
+	length := utf8.RuneCount(word)
+	if length > utf8.RuneCountInString(stats.Longest) {
+		stats.Longest = string(word)
+	}
+
+	stats.words++
+	stats.runes += length
+
+	if _, seen := stats.vocabulary[string(word)]; !seen {
+		stats.vocabulary[string(word)] = struct{}{}
+	}
+}
+
+func (stats *WordStats) merge(other *WordStats) {
+
+	if utf8.RuneCountInString(other.Longest) > utf8.RuneCountInString(stats.Longest) {
+		stats.Longest = other.Longest
+	}
+
+	stats.words += other.words
+	stats.runes += other.runes
+
+	for word := range other.vocabulary {
+		stats.vocabulary[word] = struct{}{}
+	}
+}
+
+// AverageLength is the mean word length in runes.
+func (stats *WordStats) AverageLength() float64 {
+	if stats.words == 0 {
+		return 0
+	}
+	return float64(stats.runes) / float64(stats.words)
+}
+
+// Vocabulary is the number of distinct words.
+func (stats *WordStats) Vocabulary() int {
+	return len(stats.vocabulary)
+}
+
+func writeWordStatsText(out io.Writer, results []Counts) {
+
+	fmt.Fprintln(out)
+
+	for _, counts := range results {
+		stats := counts.WordStats
+		fmt.Fprintf(out, "%s: longest %q (%d), average length %.2f, vocabulary %d\n",
+			cli.DisplayName(counts.File), stats.Longest, utf8.RuneCountInString(stats.Longest),
+			stats.AverageLength(), stats.Vocabulary())
+	}
+}
+
+func writeWordStatsDelimited(out io.Writer, results []Counts, comma rune) error {
+
+	writer := csv.NewWriter(out)
+	writer.Comma = comma
+
+	writer.Write([]string{"file", "longest_word", "longest_length", "average_length", "vocabulary"})
+
+	for _, counts := range results {
+		name := counts.File
+		if name == "" {
+			name = "-"
+		}
+
+		stats := counts.WordStats
+		writer.Write([]string{
+			name,
+			stats.Longest,
+			strconv.Itoa(utf8.RuneCountInString(stats.Longest)),
+			strconv.FormatFloat(stats.AverageLength(), 'f', 2, 64),
+			strconv.Itoa(stats.Vocabulary()),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
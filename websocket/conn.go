@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"codechallenge/internal/textutil"
+)
+
+// closeTimeout is how long to wait for the peer to close its end once the
+// server has sent a close frame.
+const closeTimeout = time.Second
+
+// conn is the server end of a WebSocket connection.
+type conn struct {
+	rw  net.Conn
+	buf *bufio.Reader
+
+	// maxMessage is the largest message accepted, after reassembly
+	maxMessage int64
+
+	// mu guards writes, which a shutdown may make while a handler is
+	// writing
+	mu     sync.Mutex
+	closed bool
+}
+
+// errClosed is returned by readMessage once the peer closed the connection
+// cleanly.
+var errClosed = errors.New("websocket closed")
+
+// readMessage returns the next data message, reassembled from its
+// fragments. It answers pings and close frames as it goes. On a protocol
+// error it closes the connection with the matching status and returns the
+// error; after a clean close it returns errClosed.
+func (c *conn) readMessage() (opcode byte, message []byte, err error) {
+
+	for {
+		f, err := readFrame(c.buf, c.maxMessage-int64(len(message)))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.write(opPong, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.fail(c.closeFrom(f.payload))
+		case opContinuation:
+			if opcode == 0 {
+				return 0, nil, c.fail(protocolError("continuation without a message to continue"))
+			}
+		default:
+			if opcode != 0 {
+				return 0, nil, c.fail(protocolError("new message before the last one finished"))
+			}
+			opcode = f.opcode
+		}
+
+		message = append(message, f.payload...)
+		if !f.fin {
+			continue
+		}
+		if opcode == opText {
+			if offset := textutil.FirstInvalid(message); offset >= 0 {
+				reason := fmt.Sprintf("text message is not valid UTF-8 at byte %d", offset)
+				return 0, nil, c.fail(&closeError{code: closeInvalidData, reason: reason})
+			}
+		}
+		return opcode, message, nil
+	}
+}
+
+// closeFrom checks the payload of a close frame from the peer, returning
+// errClosed for a well-formed one.
+func (c *conn) closeFrom(payload []byte) error {
+
+	switch {
+	case len(payload) == 0:
+		return errClosed
+	case len(payload) == 1:
+		return protocolError("close frame with a one-byte payload")
+	case !utf8.Valid(payload[2:]):
+		return &closeError{code: closeInvalidData, reason: "close reason is not valid UTF-8"}
+	}
+
+	code := int(binary.BigEndian.Uint16(payload))
+	if !validCloseCode(code) {
+		return protocolError("invalid close status %d", code)
+	}
+	return errClosed
+}
+
+// validCloseCode reports whether a peer may send code in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != closeNoStatus && code != 1006
+	}
+	return false
+}
+
+// fail closes the connection in answer to err: a clean close is echoed
+// with status 1000, a closeError sent with its own status, and anything
+// else, such as a dropped connection, ends it without a close frame. It
+// returns errClosed if the close was the server's own doing, and err
+// otherwise.
+func (c *conn) fail(err error) error {
+
+	var ce *closeError
+	switch {
+	case c.isClosed():
+		err = errClosed
+	case errors.Is(err, errClosed):
+		c.close(closeNormal, "")
+	case errors.As(err, &ce):
+		c.close(ce.code, ce.reason)
+	default:
+		c.rw.Close()
+		return err
+	}
+
+	// Read what the peer still sends until it closes its end too, so the
+	// close frame is not lost to a reset
+	io.Copy(io.Discard, c.buf)
+	c.rw.Close()
+	return err
+}
+
+func (c *conn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// write sends a single-frame message.
+func (c *conn) write(opcode byte, payload []byte) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errClosed
+	}
+	return writeFrame(c.rw, opcode, payload)
+}
+
+// close sends a close frame with code and reason, unless one was sent
+// already, and shuts the connection for writing. The reader then has
+// closeTimeout for the peer to finish.
+func (c *conn) close(code int, reason string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+
+	// Cut the reason at a character boundary to fit a control frame
+	reason = truncate(reason, maxControlPayload-2)
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	writeFrame(c.rw, opClose, append(payload, reason...))
+	if cw, ok := c.rw.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+	c.rw.SetReadDeadline(time.Now().Add(closeTimeout))
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Opcodes of RFC 6455. Those from 0x8 up are control frames.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close status codes of RFC 6455.
+const (
+	closeNormal        = 1000
+	closeGoingAway     = 1001
+	closeProtocolError = 1002
+	closeUnsupported   = 1003
+	closeNoStatus      = 1005 // never sent, stands for a close frame without a code
+	closeInvalidData   = 1007
+	closeTooBig        = 1009
+	closeInternalError = 1011
+)
+
+// maxControlPayload is the most a ping, pong or close frame may carry.
+const maxControlPayload = 125
+
+// closeError is a reason to close a connection, with the status code to
+// send the peer.
+type closeError struct {
+	code   int
+	reason string
+}
+
+func (e *closeError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d: %s", e.code, e.reason)
+}
+
+func protocolError(format string, args ...any) error {
+	return &closeError{code: closeProtocolError, reason: fmt.Sprintf(format, args...)}
+}
+
+// frame is a WebSocket frame, its payload unmasked.
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+func isControl(opcode byte) bool {
+	return opcode&0x8 != 0
+}
+
+// readFrame reads a frame sent by a client, which must be masked, with a
+// payload of at most maxPayload bytes.
+func readFrame(r *bufio.Reader, maxPayload int64) (frame, error) {
+
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{fin: header[0]&0x80 != 0, opcode: header[0] & 0x0F}
+	if header[0]&0x70 != 0 {
+		return frame{}, protocolError("reserved bits set without an extension")
+	}
+	switch f.opcode {
+	case opContinuation, opText, opBinary, opClose, opPing, opPong:
+	default:
+		return frame{}, protocolError("unknown opcode %#x", f.opcode)
+	}
+	if header[1]&0x80 == 0 {
+		return frame{}, protocolError("client frames must be masked")
+	}
+
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, unexpectedEOF(err)
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, unexpectedEOF(err)
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n>>63 != 0 {
+			return frame{}, protocolError("payload length has its top bit set")
+		}
+		length = int64(n)
+	}
+
+	if isControl(f.opcode) && (length > maxControlPayload || !f.fin) {
+		return frame{}, protocolError("control frames must be short and unfragmented")
+	}
+	if length > maxPayload {
+		return frame{}, &closeError{code: closeTooBig, reason: fmt.Sprintf("message larger than %d bytes", maxPayload)}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return frame{}, unexpectedEOF(err)
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return frame{}, unexpectedEOF(err)
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// writeFrame writes an unfragmented, unmasked frame, as servers send them.
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+
+	header := make([]byte, 2, 10+len(payload))
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// unexpectedEOF reports a frame cut short as such, rather than as the
+// clean end of the stream.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+module codechallenge/websocket
+
+go 1.23.2
+
+require codechallenge/internal v0.0.0
+
+require github.com/rivo/uniseg v0.4.7 // indirect
+
+replace codechallenge/internal => ../internal
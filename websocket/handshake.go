@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acceptGUID is the value RFC 6455 appends to a client's key to prove the
+// server understood the handshake.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// upgrade checks that r opens a WebSocket connection and, if so, takes
+// over the underlying connection and completes the handshake. If it
+// returns an error, it has already answered the request.
+func upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.Reader, error) {
+
+	fail := func(status int, message string) (net.Conn, *bufio.Reader, error) {
+		http.Error(w, message, status)
+		return nil, nil, &closeError{code: closeProtocolError, reason: message}
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		return fail(http.StatusMethodNotAllowed, "a WebSocket handshake must use GET")
+	}
+	if !headerHasToken(r.Header, "Upgrade", "websocket") || !headerHasToken(r.Header, "Connection", "upgrade") {
+		w.Header().Set("Upgrade", "websocket")
+		return fail(http.StatusUpgradeRequired, "this endpoint only speaks WebSocket")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusBadRequest, "unsupported WebSocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "connection cannot be upgraded")
+	}
+	rw, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := rw.Write([]byte(response)); err != nil {
+		rw.Close()
+		return nil, nil, err
+	}
+	return rw, buf.Reader, nil
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value answering key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHasToken reports whether the comma-separated header name lists
+// token, compared without regard to case.
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
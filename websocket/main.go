@@ -0,0 +1,79 @@
+// Command ccws is a WebSocket service that validates JSON. Each text
+// message a client sends is checked as a JSON document, and the answer is
+// a JSON message saying whether it is valid: the type of its value if so,
+// or the error and its line and column if not.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"codechallenge/internal/cli"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccws [flags]")
+	fmt.Fprintln(out, "Accept WebSocket connections on /ws and answer each text message with")
+	fmt.Fprintln(out, "{\"valid\": ...} describing it as JSON.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccws"
+
+	addr := flag.String("addr", ":8080", "listen on this `address`")
+	maxMessage := flag.Int64("max-message", 1<<20, "close connections sending messages larger than this many `bytes`")
+	verbose := flag.Bool("v", false, "log connections that end with an error")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		cli.Exit(cli.Usagef("unexpected argument %q", flag.Arg(0)))
+	}
+	if *maxMessage < 1 {
+		cli.Exit(cli.Usagef("-max-message must be positive"))
+	}
+
+	app := &server{maxMessage: *maxMessage}
+	if *verbose {
+		app.log = log.Default()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: *addr, Handler: app.routes()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+		app.closeAll()
+	}()
+
+	port := ""
+	if i := strings.LastIndex(*addr, ":"); i >= 0 {
+		port = (*addr)[i:]
+	}
+	log.Printf("ccws: serving ws://localhost%s/ws on %s", port, *addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		cli.Exit(err)
+	}
+	<-done
+}
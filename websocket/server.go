@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// server answers each text message on its WebSocket connections with the
+// result of validating it as JSON.
+type server struct {
+	maxMessage int64
+
+	// log, if set, receives a line for each connection that ends badly
+	log *log.Logger
+
+	mu       sync.Mutex
+	conns    map[*conn]bool
+	shutdown bool
+	wg       sync.WaitGroup
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.serve)
+	return mux
+}
+
+// serve upgrades a request to a WebSocket connection and validates the
+// messages that arrive on it until either side closes it.
+func (s *server) serve(w http.ResponseWriter, r *http.Request) {
+
+	rw, buf, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	c := &conn{rw: rw, buf: buf, maxMessage: s.maxMessage}
+	if !s.track(c) {
+		c.close(closeGoingAway, "server shutting down")
+		return
+	}
+	defer s.untrack(c)
+
+	for {
+		opcode, message, err := c.readMessage()
+		if err != nil {
+			s.logf(r, err)
+			return
+		}
+		if opcode == opBinary {
+			c.fail(&closeError{code: closeUnsupported, reason: "only text messages are accepted"})
+			return
+		}
+
+		reply, err := json.Marshal(validate(message))
+		if err != nil {
+			c.fail(&closeError{code: closeInternalError, reason: err.Error()})
+			return
+		}
+		if err := c.write(opText, reply); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
+
+// track adds c to the open connections, unless the server is shutting
+// down.
+func (s *server) track(c *conn) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[*conn]bool)
+	}
+	s.conns[c] = true
+	s.wg.Add(1)
+	return true
+}
+
+func (s *server) untrack(c *conn) {
+
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// closeAll closes every open connection with status 1001, going away, and
+// waits for their handlers to return. http.Server.Shutdown leaves upgraded
+// connections alone, so they need closing this way.
+func (s *server) closeAll() {
+
+	s.mu.Lock()
+	s.shutdown = true
+	for c := range s.conns {
+		c.close(closeGoingAway, "server shutting down")
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// logf logs why a connection ended, unless the client closed it cleanly or
+// simply went away.
+func (s *server) logf(r *http.Request, err error) {
+	if s.log == nil || errors.Is(err, errClosed) || errors.Is(err, io.EOF) {
+		return
+	}
+	s.log.Printf("%s: %v", r.RemoteAddr, err)
+}
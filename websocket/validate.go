@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"codechallenge/internal/textutil"
+)
+
+// result is the reply to a message: whether it holds one valid JSON value,
+// and either the value's type or where and why it is invalid.
+type result struct {
+	Valid bool   `json:"valid"`
+	Type  string `json:"type,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	// Line and Column are 1-based, with columns counting characters
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// validate checks that message is exactly one JSON value.
+func validate(message []byte) result {
+
+	var value json.RawMessage
+	err := json.Unmarshal(message, &value)
+	if err == nil {
+		return result{Valid: true, Type: typeOf(value)}
+	}
+
+	r := result{Error: err.Error()}
+	offset := len(message)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) && !strings.HasPrefix(syntaxErr.Error(), "unexpected end") {
+		// Offset counts the bytes read up to and including the bad one
+		offset = int(syntaxErr.Offset) - 1
+	}
+	r.Line, r.Column = textutil.NewLineIndex(message).Position(offset)
+	return r
+}
+
+// typeOf names the type of a valid JSON value by its first character.
+func typeOf(value []byte) string {
+
+	value = bytes.TrimLeft(value, " \t\r\n")
+	switch value[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient is a bare WebSocket client, so that tests can send frames a
+// well-behaved client would not.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	buf  *bufio.Reader
+}
+
+func dial(t *testing.T, s *server) *testClient {
+
+	t.Helper()
+	ts := httptest.NewServer(s.routes())
+	t.Cleanup(func() {
+		s.closeAll()
+		ts.Close()
+	})
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: "+key+"\r\n\r\n")
+
+	buf := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d, want 101", resp.StatusCode)
+	}
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Fatalf("Sec-WebSocket-Accept = %q, want %q", got, want)
+	}
+	return &testClient{t: t, conn: conn, buf: buf}
+}
+
+// send writes a frame, masked unless unmasked is set.
+func (c *testClient) send(fin bool, opcode byte, payload []byte, unmasked bool) {
+
+	c.t.Helper()
+	var first byte = opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = binary.BigEndian.AppendUint16(append(frame, maskBit|126), uint16(n))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, maskBit|127), uint64(n))
+	}
+	if unmasked {
+		frame = append(frame, payload...)
+	} else {
+		mask := []byte{0x12, 0x34, 0x56, 0x78}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// receive reads a frame from the server, which must be unmasked and final.
+func (c *testClient) receive() (byte, []byte) {
+
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.buf, header[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		c.t.Fatalf("frame header %x: want final and unmasked", header)
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.buf, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.buf, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.buf, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// receiveResult reads a validation result.
+func (c *testClient) receiveResult() result {
+
+	c.t.Helper()
+	opcode, payload := c.receive()
+	if opcode != opText {
+		c.t.Fatalf("opcode = %#x, want text", opcode)
+	}
+	var r result
+	if err := json.Unmarshal(payload, &r); err != nil {
+		c.t.Fatalf("%s: %v", payload, err)
+	}
+	return r
+}
+
+// expectClose reads a close frame with the given status, and then the end
+// of the connection.
+func (c *testClient) expectClose(code int) {
+
+	c.t.Helper()
+	opcode, payload := c.receive()
+	if opcode != opClose || len(payload) < 2 {
+		c.t.Fatalf("got opcode %#x with %q, want a close frame", opcode, payload)
+	}
+	if got := int(binary.BigEndian.Uint16(payload)); got != code {
+		c.t.Fatalf("close status = %d (%s), want %d", got, payload[2:], code)
+	}
+	if _, err := c.buf.ReadByte(); err != io.EOF {
+		c.t.Fatalf("after close: err = %v, want EOF", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		input string
+		want  result
+	}{
+		{`{"a": [1, 2.5, null]}`, result{Valid: true, Type: "object"}},
+		{` [] `, result{Valid: true, Type: "array"}},
+		{`"text"`, result{Valid: true, Type: "string"}},
+		{`-1e3`, result{Valid: true, Type: "number"}},
+		{`false`, result{Valid: true, Type: "boolean"}},
+		{`null`, result{Valid: true, Type: "null"}},
+		{``, result{Error: "unexpected end of JSON input", Line: 1, Column: 1}},
+		{`{"a": 1,}`, result{Error: "invalid character '}' looking for beginning of object key string", Line: 1, Column: 9}},
+		{"[1,\n  tru", result{Error: "unexpected end of JSON input", Line: 2, Column: 6}},
+		{"{\"é\":\n  x}", result{Error: "invalid character 'x' looking for beginning of value", Line: 2, Column: 3}},
+		{`1 2`, result{Error: "invalid character '2' after top-level value", Line: 1, Column: 3}},
+	}
+
+	for _, test := range tests {
+		if got := validate([]byte(test.input)); got != test.want {
+			t.Errorf("validate(%q) = %+v, want %+v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestEcho(t *testing.T) {
+
+	c := dial(t, &server{maxMessage: 1 << 20})
+
+	c.send(true, opText, []byte(`{"ok": true}`), false)
+	if r := c.receiveResult(); r != (result{Valid: true, Type: "object"}) {
+		t.Errorf("valid message: got %+v", r)
+	}
+
+	c.send(true, opText, []byte(`[1, }`), false)
+	if r := c.receiveResult(); r.Valid || r.Line != 1 || r.Column != 5 {
+		t.Errorf("invalid message: got %+v", r)
+	}
+
+	// A message larger than a 16-bit length, split over three frames with
+	// a ping in between
+	big := []byte(`["` + strings.Repeat("x", 70000) + `"]`)
+	c.send(false, opText, big[:10], false)
+	c.send(true, opPing, []byte("hi"), false)
+	if opcode, payload := c.receive(); opcode != opPong || string(payload) != "hi" {
+		t.Errorf("ping: got opcode %#x with %q, want pong with \"hi\"", opcode, payload)
+	}
+	c.send(false, opContinuation, big[10:40000], false)
+	c.send(true, opContinuation, big[40000:], false)
+	if r := c.receiveResult(); r != (result{Valid: true, Type: "array"}) {
+		t.Errorf("fragmented message: got %+v", r)
+	}
+
+	c.send(true, opClose, binary.BigEndian.AppendUint16(nil, closeNormal), false)
+	c.expectClose(closeNormal)
+}
+
+func TestProtocolErrors(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		frames func(c *testClient)
+		code   int
+	}{
+		{"unmasked", func(c *testClient) { c.send(true, opText, []byte("1"), true) }, closeProtocolError},
+		{"unknown opcode", func(c *testClient) { c.send(true, 0x3, nil, false) }, closeProtocolError},
+		{"lone continuation", func(c *testClient) { c.send(true, opContinuation, []byte("1"), false) }, closeProtocolError},
+		{"interleaved message", func(c *testClient) {
+			c.send(false, opText, []byte("["), false)
+			c.send(true, opText, []byte("1"), false)
+		}, closeProtocolError},
+		{"fragmented ping", func(c *testClient) { c.send(false, opPing, nil, false) }, closeProtocolError},
+		{"long ping", func(c *testClient) { c.send(true, opPing, make([]byte, 126), false) }, closeProtocolError},
+		{"invalid UTF-8", func(c *testClient) { c.send(true, opText, []byte("\"\xff\""), false) }, closeInvalidData},
+		{"UTF-8 split across frames", func(c *testClient) {
+			c.send(false, opText, []byte("\"\xc3"), false)
+			c.send(true, opContinuation, []byte("\""), false)
+		}, closeInvalidData},
+		{"binary", func(c *testClient) { c.send(true, opBinary, []byte{1}, false) }, closeUnsupported},
+		{"too big", func(c *testClient) { c.send(true, opText, make([]byte, 2000), false) }, closeTooBig},
+		{"too big in fragments", func(c *testClient) {
+			c.send(false, opText, bytes.Repeat([]byte(" "), 600), false)
+			c.send(true, opContinuation, bytes.Repeat([]byte(" "), 600), false)
+		}, closeTooBig},
+		{"one-byte close", func(c *testClient) { c.send(true, opClose, []byte{3}, false) }, closeProtocolError},
+		{"reserved close status", func(c *testClient) {
+			c.send(true, opClose, binary.BigEndian.AppendUint16(nil, closeNoStatus), false)
+		}, closeProtocolError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := dial(t, &server{maxMessage: 1000})
+			test.frames(c)
+			c.expectClose(test.code)
+		})
+	}
+}
+
+func TestInvalidUTF8Reason(t *testing.T) {
+
+	c := dial(t, &server{maxMessage: 1000})
+	c.send(false, opText, []byte("\"ok"), false)
+	c.send(true, opContinuation, []byte("\xff\""), false)
+
+	opcode, payload := c.receive()
+	want := "text message is not valid UTF-8 at byte 3"
+	if opcode != opClose || len(payload) < 2 || string(payload[2:]) != want {
+		t.Errorf("got opcode %#x with %q, want a close frame with reason %q", opcode, payload, want)
+	}
+}
+
+func TestHandshakeRejected(t *testing.T) {
+
+	ts := httptest.NewServer((&server{maxMessage: 1000}).routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+	}{
+		{"plain GET", "GET", nil, http.StatusUpgradeRequired},
+		{"POST", "POST", nil, http.StatusMethodNotAllowed},
+		{"old version", "GET", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusBadRequest},
+		{"short key", "GET", map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, ts.URL+"/ws", nil)
+		if test.name != "plain GET" {
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		}
+		for name, value := range test.header {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status = %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+	}
+}
+
+func TestCloseAll(t *testing.T) {
+
+	s := &server{maxMessage: 1000}
+	c := dial(t, s)
+
+	// Make sure the connection is being served before shutting down
+	c.send(true, opText, []byte("1"), false)
+	c.receiveResult()
+
+	done := make(chan struct{})
+	go func() {
+		s.closeAll()
+		close(done)
+	}()
+	c.expectClose(closeGoingAway)
+	c.send(true, opClose, binary.BigEndian.AppendUint16(nil, closeGoingAway), false)
+	c.conn.(*net.TCPConn).CloseWrite()
+	<-done
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// layout is how a dump is laid out.
+type layout struct {
+	// cols is the number of bytes per line
+	cols int
+
+	// group is the number of bytes run together between spaces, or 0
+	// for no spaces
+	group int
+
+	upper bool
+
+	// plain prints only the hex digits, with no offsets, spaces or text
+	plain bool
+}
+
+// dumper writes a hex dump of the bytes it is given, which may arrive in
+// chunks of any size, a line at a time.
+type dumper struct {
+	out *bufio.Writer
+	layout
+
+	// offset is the offset shown for the next line
+	offset int64
+
+	// pending holds the bytes of a line not yet complete
+	pending []byte
+
+	digits string
+}
+
+func newDumper(out io.Writer, l layout, offset int64) *dumper {
+
+	d := &dumper{out: bufio.NewWriter(out), layout: l, offset: offset, digits: "0123456789abcdef"}
+	if l.upper {
+		d.digits = "0123456789ABCDEF"
+	}
+	if d.group == 0 {
+		d.group = d.cols
+	}
+	return d
+}
+
+// write dumps the full lines data completes, holding back the rest.
+func (d *dumper) write(data []byte) error {
+
+	for len(data) > 0 {
+		n := min(len(data), d.cols-len(d.pending))
+		d.pending = append(d.pending, data[:n]...)
+		data = data[n:]
+
+		if len(d.pending) == d.cols {
+			d.line(d.pending)
+			d.pending = d.pending[:0]
+		}
+	}
+	return d.out.Flush()
+}
+
+// close dumps the last, partial line and flushes the output.
+func (d *dumper) close() error {
+	if len(d.pending) > 0 {
+		d.line(d.pending)
+	}
+	return d.out.Flush()
+}
+
+// line writes one line of the dump. Short lines are padded, so the text
+// column lines up with that of full lines.
+func (d *dumper) line(data []byte) {
+
+	if d.plain {
+		for _, b := range data {
+			d.hex(b)
+		}
+		d.out.WriteByte('\n')
+		return
+	}
+
+	fmt.Fprintf(d.out, "%08x: ", d.offset)
+	d.offset += int64(len(data))
+
+	width := 0
+	for i, b := range data {
+		d.hex(b)
+		width += 2
+		if (i+1)%d.group == 0 && i+1 < d.cols {
+			d.out.WriteByte(' ')
+			width++
+		}
+	}
+
+	// The hex column holds two digits a byte and a space between groups,
+	// and two spaces separate it from the text
+	full := 2*d.cols + (d.cols-1)/d.group
+	for range full - width + 2 {
+		d.out.WriteByte(' ')
+	}
+
+	for _, b := range data {
+		if b < ' ' || b > '~' {
+			b = '.'
+		}
+		d.out.WriteByte(b)
+	}
+	d.out.WriteByte('\n')
+}
+
+func (d *dumper) hex(b byte) {
+	d.out.WriteByte(d.digits[b>>4])
+	d.out.WriteByte(d.digits[b&0xf])
+}
@@ -0,0 +1,137 @@
+// Command ccxxd makes a hex dump of a file, or of standard input, like
+// xxd: offsets, bytes in hex and the same bytes as text. With -r it turns
+// a dump back into the bytes it shows.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"codechallenge/internal/cli"
+	"codechallenge/internal/streamio"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+
+	fmt.Fprintln(out, "Usage: ccxxd [flags] [infile [outfile]]")
+	fmt.Fprintln(out, "Print a hex dump of infile, or of standard input when it is missing or -, to")
+	fmt.Fprintln(out, "outfile or standard output, or with -r, turn a dump back into binary.")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	cli.Name = "ccxxd"
+
+	cols := flag.Int("c", 0, "print `N` bytes per line (default 16, or 30 with -p)")
+	group := flag.Int("g", 2, "group the hex digits of `N` bytes together, or not at all with 0")
+	length := flag.String("l", "", "stop after `N` bytes")
+	seek := flag.String("s", "", "start at `offset`, or with a - offset, that far from the end")
+	displayOffset := flag.Int64("o", 0, "add `N` to the offsets shown")
+	plain := flag.Bool("p", false, "print plain hex digits, with no offsets or text")
+	upper := flag.Bool("u", false, "print hex digits in upper case")
+	revert := flag.Bool("r", false, "turn a hex dump back into binary")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 2 {
+		cli.Exit(cli.Usagef("too many arguments"))
+	}
+	if *cols == 0 {
+		*cols = 16
+		if *plain {
+			*cols = 30
+		}
+	}
+	if *cols < 1 || *cols > 256 {
+		cli.Exit(cli.Usagef("invalid -c %d: must be from 1 to 256", *cols))
+	}
+	if *group < 0 {
+		cli.Exit(cli.Usagef("invalid -g %d: must not be negative", *group))
+	}
+
+	inPath := cli.Stdin
+	if flag.NArg() > 0 {
+		inPath = flag.Arg(0)
+	}
+	input, closeInput, err := cli.Open(inPath)
+	if err != nil {
+		cli.Exit(&cli.FileError{File: inPath, Err: err})
+	}
+	defer closeInput()
+
+	out := os.Stdout
+	if flag.NArg() > 1 && flag.Arg(1) != cli.Stdin {
+		if out, err = os.Create(flag.Arg(1)); err != nil {
+			cli.Exit(err)
+		}
+		defer out.Close()
+	}
+
+	if *revert {
+		if err := reverse(out, input, *plain); err != nil {
+			cli.Exit(&cli.FileError{File: inPath, Err: err})
+		}
+		return
+	}
+
+	start, err := seekInput(input, *seek)
+	if err != nil {
+		cli.Exit(&cli.FileError{File: inPath, Err: err})
+	}
+
+	var reader io.Reader = input
+	if *length != "" {
+		n, err := strconv.ParseInt(*length, 0, 64)
+		if err != nil || n < 0 {
+			cli.Exit(cli.Usagef("invalid -l %q", *length))
+		}
+		reader = io.LimitReader(input, n)
+	}
+
+	d := newDumper(out, layout{cols: *cols, group: *group, upper: *upper, plain: *plain}, start+*displayOffset)
+	err = streamio.ForEachChunk(reader, streamio.BufferSizeFor(input), d.write)
+	if closeErr := d.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cli.Exit(err)
+	}
+}
+
+// seekInput moves to the offset -s asks for, and returns it. Regular files
+// are seeked; other input, which can only skip forward, is read past.
+func seekInput(input *os.File, value string) (int64, error) {
+
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		return 0, cli.Usagef("invalid -s %q", value)
+	}
+
+	info, err := input.Stat()
+	if err == nil && info.Mode().IsRegular() {
+		whence := io.SeekStart
+		if offset < 0 {
+			whence = io.SeekEnd
+		}
+		return input.Seek(offset, whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("cannot seek from the end of a stream")
+	}
+	n, err := io.CopyN(io.Discard, input, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
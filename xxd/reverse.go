@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+
+	"codechallenge/internal/streamio"
+	"codechallenge/internal/textutil"
+)
+
+// reverser turns a hex dump back into the bytes it shows.
+type reverser struct {
+	out *bufio.Writer
+
+	// plain reads the dump as bare hex digits, with no offsets or text
+	plain bool
+
+	// written is the offset the next byte is written at
+	written int64
+}
+
+// reverse reads a dump from input and writes its bytes to out. Each line
+// of a dump with offsets is placed at its offset, with zeros filling any
+// gap before it, so a dump with lines left out still comes back with
+// everything in its place.
+func reverse(out io.Writer, input io.Reader, plain bool) error {
+
+	r := &reverser{out: bufio.NewWriter(out), plain: plain}
+	reader := textutil.NewLineReader(input, streamio.StreamBufferSize)
+
+	// A digit left over from the end of a plain line pairs with the first
+	// of the next, as bare hex may wrap anywhere
+	half := -1
+
+	for {
+		line, _, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if plain {
+			if half, err = r.plainLine(line, half); err != nil {
+				return fmt.Errorf("line %d: %v", reader.Number(), err)
+			}
+			continue
+		}
+		if err := r.dumpLine(line); err != nil {
+			return fmt.Errorf("line %d: %v", reader.Number(), err)
+		}
+	}
+
+	if half >= 0 {
+		return fmt.Errorf("odd number of hex digits")
+	}
+	return r.out.Flush()
+}
+
+// plainLine writes the bytes of a line of bare hex digits, which may be
+// separated by whitespace.
+func (r *reverser) plainLine(line []byte, half int) (int, error) {
+
+	for _, c := range line {
+		if c == ' ' || c == '\t' || c == '\r' {
+			continue
+		}
+		v := hexValue(c)
+		if v < 0 {
+			return half, fmt.Errorf("invalid hex digit %q", c)
+		}
+		if half < 0 {
+			half = v
+			continue
+		}
+		r.out.WriteByte(byte(half<<4 | v))
+		half = -1
+	}
+	return half, nil
+}
+
+// dumpLine writes the bytes of a line of a dump with offsets: the offset
+// and a colon, then pairs of hex digits, optionally grouped, up to the two
+// spaces before the text column.
+func (r *reverser) dumpLine(line []byte) error {
+
+	colon := -1
+	for i, c := range line {
+		if c == ':' {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return fmt.Errorf("missing offset")
+	}
+	offset, err := strconv.ParseInt(string(line[:colon]), 16, 64)
+	if err != nil || offset < 0 {
+		return fmt.Errorf("invalid offset %q", line[:colon])
+	}
+
+	if offset < r.written {
+		return fmt.Errorf("offset %x goes back before %x", offset, r.written)
+	}
+	for ; r.written < offset; r.written++ {
+		r.out.WriteByte(0)
+	}
+
+	rest := line[colon+1:]
+	i := 0
+	if i < len(rest) && rest[i] == ' ' {
+		i++
+	}
+	for i+1 < len(rest) {
+		if rest[i] == ' ' {
+			// Two spaces start the text column
+			if rest[i+1] == ' ' {
+				break
+			}
+			i++
+			continue
+		}
+
+		high, low := hexValue(rest[i]), hexValue(rest[i+1])
+		if high < 0 || low < 0 {
+			break
+		}
+		r.out.WriteByte(byte(high<<4 | low))
+		r.written++
+		i += 2
+	}
+	return nil
+}
+
+func hexValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+// sample is dumped by the tests; the expected dumps are what xxd prints.
+var sample = []byte("Hello world\n\x00\x01\xff")
+
+func dump(t *testing.T, data []byte, l layout, offset int64, chunk int) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	d := newDumper(&out, l, offset)
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		if err := d.write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := d.close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestDump(t *testing.T) {
+
+	tests := []struct {
+		layout layout
+		offset int64
+		want   string
+	}{
+		{layout{cols: 16, group: 2}, 0, "00000000: 4865 6c6c 6f20 776f 726c 640a 0001 ff    Hello world....\n"},
+		{layout{cols: 8, group: 1}, 0, "00000000: 48 65 6c 6c 6f 20 77 6f  Hello wo\n00000008: 72 6c 64 0a 00 01 ff     rld....\n"},
+		{layout{cols: 16, group: 3}, 0, "00000000: 48656c 6c6f20 776f72 6c640a 0001ff     Hello world....\n"},
+		{layout{cols: 16, group: 0}, 0, "00000000: 48656c6c6f20776f726c640a0001ff    Hello world....\n"},
+		{layout{cols: 16, group: 2}, 256, "00000100: 4865 6c6c 6f20 776f 726c 640a 0001 ff    Hello world....\n"},
+		{layout{cols: 16, group: 2, upper: true}, 0, "00000000: 4865 6C6C 6F20 776F 726C 640A 0001 FF    Hello world....\n"},
+		{layout{cols: 30, plain: true}, 0, "48656c6c6f20776f726c640a0001ff\n"},
+		{layout{cols: 4, plain: true}, 0, "48656c6c\n6f20776f\n726c640a\n0001ff\n"},
+	}
+
+	for _, tt := range tests {
+		// However the input arrives, the dump is the same
+		for _, chunk := range []int{1, 3, 1024} {
+			if got := dump(t, sample, tt.layout, tt.offset, chunk); got != tt.want {
+				t.Errorf("%+v in chunks of %d:\ngot  %q\nwant %q", tt.layout, chunk, got, tt.want)
+			}
+		}
+	}
+
+	if got := dump(t, nil, layout{cols: 16, group: 2}, 0, 1); got != "" {
+		t.Errorf("dump of nothing = %q", got)
+	}
+}
+
+func TestReverse(t *testing.T) {
+
+	random := rand.New(rand.NewPCG(3, 4))
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(random.IntN(256))
+	}
+
+	layouts := []layout{
+		{cols: 16, group: 2},
+		{cols: 7, group: 3},
+		{cols: 16, group: 0},
+		{cols: 1, group: 1},
+		{cols: 30, plain: true},
+		{cols: 13, plain: true},
+	}
+	for _, l := range layouts {
+		var out bytes.Buffer
+		if err := reverse(&out, strings.NewReader(dump(t, data, l, 0, 100)), l.plain); err != nil {
+			t.Fatalf("%+v: %v", l, err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("%+v: reversed dump differs from the input", l)
+		}
+	}
+}
+
+func TestReverseOffsets(t *testing.T) {
+
+	tests := []struct {
+		dump  string
+		plain bool
+		want  string
+		err   string
+	}{
+		{dump: "00000004: 4142  AB\n", want: "\x00\x00\x00\x00AB"},
+		{dump: "00000000: 41\n00000003: 42\n", want: "A\x00\x00B"},
+		{dump: "00000000: 4142 4344  ABCD\n", want: "ABCD"},
+		{dump: "00000002: 41\n00000000: 42\n", err: "line 2: offset 0 goes back before 3"},
+		{dump: "4142\n", err: "line 1: missing offset"},
+		{dump: "zz: 41\n", err: "line 1: invalid offset \"zz\""},
+		{dump: "41 4\n2 43\n", plain: true, want: "ABC"},
+		{dump: "414\n", plain: true, err: "odd number of hex digits"},
+		{dump: "41xx\n", plain: true, err: "line 1: invalid hex digit 'x'"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := reverse(&out, strings.NewReader(tt.dump), tt.plain)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("reverse(%q) = %v, want %q", tt.dump, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || out.String() != tt.want {
+			t.Errorf("reverse(%q) = %q, %v, want %q", tt.dump, out.String(), err, tt.want)
+		}
+	}
+}